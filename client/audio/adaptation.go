@@ -0,0 +1,347 @@
+package audio
+
+import (
+	"io"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v3"
+)
+
+const (
+	// 自适应调整参数
+	adaptInterval    = 2 * time.Second  // 调整周期
+	feedbackTimeout  = 10 * time.Second // 超过该时间没有反馈的对端不参与计算
+	lossSmoothing    = 0.3              // 丢包率指数平滑系数
+	highLossFraction = 0.10             // 超过该丢包率时降低码率
+	lowLossFraction  = 0.02             // 低于该丢包率时尝试提高码率
+	frameUpLoss      = 0.15             // 超过该丢包率时增大帧长
+	frameDownLoss    = 0.05             // 低于该丢包率时恢复帧长
+	lowBandwidthBps  = 24000            // 低于该带宽时增大帧长以降低包头开销
+	bitrateStepKbps  = 4                // 每次提高码率的步长
+	bandwidthUsage   = 0.9              // 最多使用估计带宽的比例
+
+	rtpClockRate = 48000 // Opus的RTP时钟频率固定为48kHz
+)
+
+// peerFeedback 单个对端的RTCP反馈状态
+type peerFeedback struct {
+	lossFraction float64 // 平滑后的丢包率(0-1)
+	jitterMs     float64 // 到达抖动(毫秒)
+	rembBps      float64 // REMB报告的最大可用码率
+	twccBps      float64 // 根据TWCC估计的接收速率
+	lastUpdate   time.Time
+}
+
+// estimatedBps 返回对端的可用带宽估计，0表示未知
+func (f *peerFeedback) estimatedBps() float64 {
+	switch {
+	case f.rembBps > 0 && f.twccBps > 0:
+		if f.rembBps < f.twccBps {
+			return f.rembBps
+		}
+		return f.twccBps
+	case f.rembBps > 0:
+		return f.rembBps
+	default:
+		return f.twccBps
+	}
+}
+
+// readRTCP 读取对端发回的RTCP包，直到发送器关闭
+func (m *Manager) readRTCP(peerID string, sender *webrtc.RTPSender, track *webrtc.TrackLocalStaticSample) {
+	defer func() {
+		m.feedbackMu.Lock()
+		delete(m.feedback, peerID)
+		m.feedbackMu.Unlock()
+
+		// 只移除自己添加的轨道，对端重连后可能已替换为新轨道
+		m.mu.Lock()
+		if m.tracks[peerID] == track {
+			delete(m.tracks, peerID)
+		}
+		m.mu.Unlock()
+	}()
+
+	for {
+		packets, _, err := sender.ReadRTCP()
+		if err != nil {
+			// 发送器关闭时返回EOF或ErrClosedPipe，其他错误同样停止读取，避免持续失败时反复记录日志
+			if err != io.EOF && err != io.ErrClosedPipe {
+				log.Error("读取RTCP包失败", "error", err)
+			}
+			return
+		}
+		m.handleRTCP(peerID, packets)
+	}
+}
+
+// handleRTCP 根据接收报告、REMB和TWCC更新对端的网络状况估计
+func (m *Manager) handleRTCP(peerID string, packets []rtcp.Packet) {
+	m.feedbackMu.Lock()
+	defer m.feedbackMu.Unlock()
+
+	fb, ok := m.feedback[peerID]
+	if !ok {
+		fb = &peerFeedback{}
+		m.feedback[peerID] = fb
+	}
+
+	for _, packet := range packets {
+		switch p := packet.(type) {
+		case *rtcp.ReceiverReport:
+			fb.updateFromReports(p.Reports)
+		case *rtcp.SenderReport:
+			fb.updateFromReports(p.Reports)
+		case *rtcp.ReceiverEstimatedMaximumBitrate:
+			fb.rembBps = float64(p.Bitrate)
+			fb.lastUpdate = time.Now()
+		case *rtcp.TransportLayerCC:
+			m.updateFromTWCC(fb, p)
+		}
+	}
+}
+
+// updateFromReports 从接收报告中提取丢包率和抖动
+func (f *peerFeedback) updateFromReports(reports []rtcp.ReceptionReport) {
+	for _, report := range reports {
+		loss := float64(report.FractionLost) / 256
+		f.lossFraction = f.lossFraction*(1-lossSmoothing) + loss*lossSmoothing
+		f.jitterMs = float64(report.Jitter) * 1000 / rtpClockRate
+		f.lastUpdate = time.Now()
+	}
+}
+
+// updateFromTWCC 根据TWCC反馈估计丢包率和接收速率
+//
+// TWCC只携带到达时间，没有发送时间无法做延迟梯度估计，
+// 这里用收到的包数乘以平均包大小除以到达时间跨度近似接收速率。
+func (m *Manager) updateFromTWCC(f *peerFeedback, p *rtcp.TransportLayerCC) {
+	if p.PacketStatusCount == 0 {
+		return
+	}
+
+	received := 0
+	for _, chunk := range p.PacketChunks {
+		switch c := chunk.(type) {
+		case *rtcp.RunLengthChunk:
+			if c.PacketStatusSymbol != rtcp.TypeTCCPacketNotReceived {
+				received += int(c.RunLength)
+			}
+		case *rtcp.StatusVectorChunk:
+			for _, symbol := range c.SymbolList {
+				if symbol != rtcp.TypeTCCPacketNotReceived {
+					received++
+				}
+			}
+		}
+	}
+	// 最后一个块可能包含填充的符号
+	if received > int(p.PacketStatusCount) {
+		received = int(p.PacketStatusCount)
+	}
+
+	loss := 1 - float64(received)/float64(p.PacketStatusCount)
+	f.lossFraction = f.lossFraction*(1-lossSmoothing) + loss*lossSmoothing
+	f.lastUpdate = time.Now()
+
+	// 第一个增量相对于参考时间，不计入跨度
+	var spanUs int64
+	for i, delta := range p.RecvDeltas {
+		if i > 0 && delta != nil {
+			spanUs += delta.Delta
+		}
+	}
+	packets := m.sentPackets.Load()
+	if spanUs <= 0 || received < 2 || packets == 0 {
+		return
+	}
+	avgPacketBits := float64(m.sentBytes.Load()) * 8 / float64(packets)
+	f.twccBps = float64(received-1) * avgPacketBits * 1e6 / float64(spanUs)
+}
+
+// adaptationLoop 周期性根据反馈调整编码器参数
+func (m *Manager) adaptationLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(adaptInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			m.adaptEncoder()
+		}
+	}
+}
+
+// networkEstimate 汇总所有活跃对端的网络状况，编码器为所有对端共享，因此按最差的对端计算
+func (m *Manager) networkEstimate() (loss float64, bandwidthBps float64, ok bool) {
+	m.feedbackMu.Lock()
+	defer m.feedbackMu.Unlock()
+
+	now := time.Now()
+	for _, fb := range m.feedback {
+		if now.Sub(fb.lastUpdate) > feedbackTimeout {
+			continue
+		}
+		ok = true
+		if fb.lossFraction > loss {
+			loss = fb.lossFraction
+		}
+		if bps := fb.estimatedBps(); bps > 0 && (bandwidthBps == 0 || bps < bandwidthBps) {
+			bandwidthBps = bps
+		}
+	}
+	return loss, bandwidthBps, ok
+}
+
+// adaptEncoder 根据网络状况计算新的编码参数并应用
+func (m *Manager) adaptEncoder() {
+	loss, bandwidthBps, ok := m.networkEstimate()
+	if !ok {
+		return
+	}
+
+	m.encMu.Lock()
+	current := m.encSettings
+	m.encMu.Unlock()
+
+	next := current
+
+	// 码率：丢包严重时乘性降低，网络良好时加性提高，并受估计带宽限制
+	switch {
+	case loss > highLossFraction:
+		next.BitrateKbps = current.BitrateKbps * 85 / 100
+	case loss < lowLossFraction:
+		next.BitrateKbps = current.BitrateKbps + bitrateStepKbps
+	}
+	if bandwidthBps > 0 {
+		if limit := int(bandwidthBps * bandwidthUsage / 1000); next.BitrateKbps > limit {
+			next.BitrateKbps = limit
+		}
+	}
+	if next.BitrateKbps < m.config.MinBitrateKbps {
+		next.BitrateKbps = m.config.MinBitrateKbps
+	}
+	if next.BitrateKbps > m.config.MaxBitrateKbps {
+		next.BitrateKbps = m.config.MaxBitrateKbps
+	}
+
+	// 丢包率和带内FEC，不低于配置的预期丢包率
+	next.PacketLossPerc = int(loss*100 + 0.5)
+	if next.PacketLossPerc > 100 {
+		next.PacketLossPerc = 100
+	}
+	if next.PacketLossPerc < m.config.OpusPacketLossPerc {
+		next.PacketLossPerc = m.config.OpusPacketLossPerc
+	}
+	next.FEC = m.config.OpusFEC || next.PacketLossPerc >= m.config.FECLossThreshold
+
+	// 帧长：网络较差时使用更长的帧以减少包数和包头开销
+	constrained := loss > frameUpLoss || (bandwidthBps > 0 && bandwidthBps < lowBandwidthBps)
+	recovered := loss < frameDownLoss && (bandwidthBps == 0 || bandwidthBps >= 2*lowBandwidthBps)
+	switch {
+	case constrained:
+		next.FrameSize = m.nextFrameSize(current.FrameSize, true)
+	case recovered:
+		next.FrameSize = m.nextFrameSize(current.FrameSize, false)
+	}
+
+	if next == current {
+		return
+	}
+	m.applyEncoderSettings(next)
+	log.Info("调整音频编码参数",
+		"bitrate_kbps", next.BitrateKbps,
+		"fec", next.FEC,
+		"packet_loss_perc", next.PacketLossPerc,
+		"frame_size", next.FrameSize,
+		"estimated_kbps", int(bandwidthBps/1000))
+}
+
+// nextFrameSize 在配置的帧大小和最大帧大小之间按Opus合法帧长上调或下调一级
+func (m *Manager) nextFrameSize(current int, up bool) int {
+	ladder := []int{m.config.FrameSize}
+	for _, ms := range []int{10, 20, 40, 60} {
+		size := m.config.SampleRate * ms / 1000
+		if size > m.config.FrameSize && size <= m.config.MaxFrameSize {
+			ladder = append(ladder, size)
+		}
+	}
+
+	for i, size := range ladder {
+		if size != current {
+			continue
+		}
+		if up && i+1 < len(ladder) {
+			return ladder[i+1]
+		}
+		if !up && i > 0 {
+			return ladder[i-1]
+		}
+		return current
+	}
+	return m.config.FrameSize
+}
+
+// applyEncoderSettings 将参数应用到编码器
+func (m *Manager) applyEncoderSettings(settings EncoderSettings) {
+	m.encMu.Lock()
+	defer m.encMu.Unlock()
+
+	if err := m.encoder.SetBitrate(1000 * settings.BitrateKbps); err != nil {
+		log.Error("设置Opus码率失败", "error", err)
+	}
+	if err := m.encoder.SetInBandFEC(settings.FEC); err != nil {
+		log.Error("设置Opus带内FEC失败", "error", err)
+	}
+	if err := m.encoder.SetPacketLossPerc(settings.PacketLossPerc); err != nil {
+		log.Error("设置Opus丢包率失败", "error", err)
+	}
+	m.encSettings = settings
+}
+
+// currentFrameSize 返回当前使用的帧大小
+func (m *Manager) currentFrameSize() int {
+	m.encMu.Lock()
+	defer m.encMu.Unlock()
+	return m.encSettings.FrameSize
+}
+
+// GetStats 返回当前的编码参数和各对端的网络状况
+func (m *Manager) GetStats() Stats {
+	m.encMu.Lock()
+	stats := Stats{
+		Encoder:  m.encSettings,
+		Pipeline: m.encStats,
+		Peers:    make(map[string]PeerNetworkStats),
+	}
+	m.encMu.Unlock()
+	stats.Pipeline.SentPackets = m.sentPackets.Load()
+	stats.Pipeline.SentBytes = m.sentBytes.Load()
+
+	m.ioMu.RLock()
+	if b, ok := m.audioSource.(bufferStatser); ok {
+		input := b.bufferStats()
+		stats.InputBuffer = &input
+	}
+	if b, ok := m.audioSink.(bufferStatser); ok {
+		output := b.bufferStats()
+		stats.OutputBuffer = &output
+	}
+	m.ioMu.RUnlock()
+
+	m.feedbackMu.Lock()
+	defer m.feedbackMu.Unlock()
+	for peerID, fb := range m.feedback {
+		stats.Peers[peerID] = PeerNetworkStats{
+			LossPercent:   fb.lossFraction * 100,
+			JitterMs:      fb.jitterMs,
+			EstimatedKbps: int(fb.estimatedBps() / 1000),
+			LastFeedback:  fb.lastUpdate,
+		}
+	}
+	return stats
+}
@@ -0,0 +1,263 @@
+package audio
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"gopkg.in/hraban/opus.v2"
+)
+
+// newAdaptiveManager 创建只包含编码器和反馈状态的音频管理器
+//
+// 帧长在960(20ms)、1920(40ms)和2880(60ms)之间调整，码率在16-64kbps之间调整，丢包率达到5%时启用FEC。
+func newAdaptiveManager(t *testing.T, settings EncoderSettings) *Manager {
+	t.Helper()
+
+	encoder, err := newOpusEncoder(48000, 1, opus.AppVoIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Manager{
+		config: AudioConfig{
+			SampleRate:       48000,
+			Channels:         1,
+			FrameSize:        960,
+			MaxFrameSize:     2880,
+			MinBitrateKbps:   16,
+			MaxBitrateKbps:   64,
+			FECLossThreshold: 5,
+		},
+		encoder:     encoder,
+		encSettings: settings,
+		feedback:    make(map[string]*peerFeedback),
+	}
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestHandleReceiverReport(t *testing.T) {
+	m := newAdaptiveManager(t, EncoderSettings{})
+
+	// FractionLost以1/256为单位，Jitter以RTP时钟为单位
+	report := &rtcp.ReceiverReport{Reports: []rtcp.ReceptionReport{{FractionLost: 64, Jitter: 480}}}
+	m.handleRTCP("peer", []rtcp.Packet{report})
+	fb := m.feedback["peer"]
+	if !almostEqual(fb.lossFraction, 0.25*lossSmoothing) || !almostEqual(fb.jitterMs, 10) {
+		t.Fatalf("loss = %v, jitter = %v", fb.lossFraction, fb.jitterMs)
+	}
+
+	// 丢包率指数平滑
+	m.handleRTCP("peer", []rtcp.Packet{report})
+	want := 0.25*lossSmoothing*(1-lossSmoothing) + 0.25*lossSmoothing
+	if !almostEqual(fb.lossFraction, want) {
+		t.Errorf("平滑后loss = %v, want %v", fb.lossFraction, want)
+	}
+
+	// 发送报告中的接收报告同样计入
+	m.handleRTCP("other", []rtcp.Packet{&rtcp.SenderReport{Reports: []rtcp.ReceptionReport{{FractionLost: 128}}}})
+	if got := m.feedback["other"].lossFraction; !almostEqual(got, 0.5*lossSmoothing) {
+		t.Errorf("SenderReport loss = %v", got)
+	}
+}
+
+func TestHandleREMBAndTWCC(t *testing.T) {
+	m := newAdaptiveManager(t, EncoderSettings{})
+	// 平均每包100字节
+	m.sentPackets.Store(100)
+	m.sentBytes.Store(10000)
+
+	// 10个包中8个到达，到达间隔20ms
+	deltas := make([]*rtcp.RecvDelta, 8)
+	for i := range deltas {
+		deltas[i] = &rtcp.RecvDelta{Type: rtcp.TypeTCCPacketReceivedSmallDelta, Delta: 20000}
+	}
+	twcc := &rtcp.TransportLayerCC{
+		PacketStatusCount: 10,
+		PacketChunks: []rtcp.PacketStatusChunk{
+			&rtcp.RunLengthChunk{PacketStatusSymbol: rtcp.TypeTCCPacketReceivedSmallDelta, RunLength: 8},
+			&rtcp.RunLengthChunk{PacketStatusSymbol: rtcp.TypeTCCPacketNotReceived, RunLength: 2},
+		},
+		RecvDeltas: deltas,
+	}
+	m.handleRTCP("peer", []rtcp.Packet{twcc})
+	fb := m.feedback["peer"]
+	// 7个间隔共140ms内收到7个包，每包800比特
+	if !almostEqual(fb.lossFraction, 0.2*lossSmoothing) || !almostEqual(fb.twccBps, 40000) {
+		t.Fatalf("TWCC loss = %v, bps = %v", fb.lossFraction, fb.twccBps)
+	}
+
+	// REMB和TWCC同时存在时取较小值
+	m.handleRTCP("peer", []rtcp.Packet{&rtcp.ReceiverEstimatedMaximumBitrate{Bitrate: 30000}})
+	if got := fb.estimatedBps(); got != 30000 {
+		t.Errorf("estimatedBps = %v, want 30000", got)
+	}
+	m.handleRTCP("peer", []rtcp.Packet{&rtcp.ReceiverEstimatedMaximumBitrate{Bitrate: 50000}})
+	if got := fb.estimatedBps(); got != 40000 {
+		t.Errorf("estimatedBps = %v, want 40000", got)
+	}
+	if got := m.GetStats().Peers["peer"].EstimatedKbps; got != 40 {
+		t.Errorf("EstimatedKbps = %d, want 40", got)
+	}
+}
+
+func TestHandleTWCCPadding(t *testing.T) {
+	m := newAdaptiveManager(t, EncoderSettings{})
+
+	// 状态向量块的14个符号中只有前5个有效
+	symbols := make([]uint16, 14)
+	for i := range symbols {
+		symbols[i] = rtcp.TypeTCCPacketReceivedSmallDelta
+	}
+	twcc := &rtcp.TransportLayerCC{
+		PacketStatusCount: 5,
+		PacketChunks:      []rtcp.PacketStatusChunk{&rtcp.StatusVectorChunk{SymbolSize: rtcp.TypeTCCSymbolSizeOneBit, SymbolList: symbols}},
+	}
+	m.handleRTCP("peer", []rtcp.Packet{twcc})
+	fb := m.feedback["peer"]
+	if fb.lossFraction != 0 {
+		t.Errorf("loss = %v, want 0", fb.lossFraction)
+	}
+	// 没有到达时间时不估计速率
+	if fb.twccBps != 0 {
+		t.Errorf("twccBps = %v, want 0", fb.twccBps)
+	}
+}
+
+func TestAdaptEncoder(t *testing.T) {
+	base := EncoderSettings{BitrateKbps: 32, FrameSize: 960}
+	tests := []struct {
+		name     string
+		current  EncoderSettings
+		feedback []peerFeedback // lastUpdate为零值时视为刚收到
+		fec      bool           // 配置为始终启用FEC
+		want     EncoderSettings
+	}{
+		{
+			name:    "没有反馈",
+			current: base,
+			want:    base,
+		},
+		{
+			name:     "反馈超时",
+			current:  base,
+			feedback: []peerFeedback{{lossFraction: 0.3, lastUpdate: time.Now().Add(-feedbackTimeout - time.Second)}},
+			want:     base,
+		},
+		{
+			name:     "网络良好时提高码率",
+			current:  base,
+			feedback: []peerFeedback{{}},
+			want:     EncoderSettings{BitrateKbps: 36, FrameSize: 960},
+		},
+		{
+			name:     "不超过码率上限",
+			current:  EncoderSettings{BitrateKbps: 62, FrameSize: 960},
+			feedback: []peerFeedback{{}},
+			want:     EncoderSettings{BitrateKbps: 64, FrameSize: 960},
+		},
+		{
+			name:     "丢包中等时保持码率并启用FEC",
+			current:  base,
+			feedback: []peerFeedback{{lossFraction: 0.06}},
+			want:     EncoderSettings{BitrateKbps: 32, FEC: true, PacketLossPerc: 6, FrameSize: 960},
+		},
+		{
+			name:     "丢包低于FEC阈值",
+			current:  base,
+			feedback: []peerFeedback{{lossFraction: 0.03}},
+			want:     EncoderSettings{BitrateKbps: 32, PacketLossPerc: 3, FrameSize: 960},
+		},
+		{
+			name:     "丢包严重时降低码率并增大帧长",
+			current:  base,
+			feedback: []peerFeedback{{lossFraction: 0.2}},
+			want:     EncoderSettings{BitrateKbps: 27, FEC: true, PacketLossPerc: 20, FrameSize: 1920},
+		},
+		{
+			name:     "帧长不超过上限",
+			current:  EncoderSettings{BitrateKbps: 16, FEC: true, PacketLossPerc: 20, FrameSize: 2880},
+			feedback: []peerFeedback{{lossFraction: 0.2}},
+			want:     EncoderSettings{BitrateKbps: 16, FEC: true, PacketLossPerc: 20, FrameSize: 2880},
+		},
+		{
+			name:     "网络恢复后逐级减小帧长",
+			current:  EncoderSettings{BitrateKbps: 32, FEC: true, PacketLossPerc: 20, FrameSize: 2880},
+			feedback: []peerFeedback{{lossFraction: 0.01, rembBps: 100000}},
+			want:     EncoderSettings{BitrateKbps: 36, PacketLossPerc: 1, FrameSize: 1920},
+		},
+		{
+			name:     "带宽不足时不减小帧长",
+			current:  EncoderSettings{BitrateKbps: 32, FrameSize: 1920},
+			feedback: []peerFeedback{{rembBps: 40000}},
+			want:     EncoderSettings{BitrateKbps: 36, FrameSize: 1920},
+		},
+		{
+			name:     "码率受REMB限制",
+			current:  base,
+			feedback: []peerFeedback{{rembBps: 30000}},
+			want:     EncoderSettings{BitrateKbps: 27, FrameSize: 960},
+		},
+		{
+			name:     "低带宽时增大帧长且码率不低于下限",
+			current:  base,
+			feedback: []peerFeedback{{twccBps: 10000}},
+			want:     EncoderSettings{BitrateKbps: 16, FrameSize: 1920},
+		},
+		{
+			name:     "按最差的对端计算",
+			current:  base,
+			feedback: []peerFeedback{{rembBps: 100000}, {lossFraction: 0.12}, {rembBps: 30000}},
+			want:     EncoderSettings{BitrateKbps: 27, FEC: true, PacketLossPerc: 12, FrameSize: 960},
+		},
+		{
+			name:     "配置为始终启用FEC",
+			current:  base,
+			feedback: []peerFeedback{{}},
+			fec:      true,
+			want:     EncoderSettings{BitrateKbps: 36, FEC: true, FrameSize: 960},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newAdaptiveManager(t, tt.current)
+			m.config.OpusFEC = tt.fec
+			for i := range tt.feedback {
+				fb := tt.feedback[i]
+				if fb.lastUpdate.IsZero() {
+					fb.lastUpdate = time.Now()
+				}
+				m.feedback[string(rune('a'+i))] = &fb
+			}
+
+			m.adaptEncoder()
+			if got := m.GetStats().Encoder; got != tt.want {
+				t.Errorf("adaptEncoder() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextFrameSize(t *testing.T) {
+	m := newAdaptiveManager(t, EncoderSettings{})
+	tests := []struct {
+		current int
+		up      bool
+		want    int
+	}{
+		{960, true, 1920},
+		{1920, true, 2880},
+		{2880, true, 2880},
+		{2880, false, 1920},
+		{960, false, 960},
+		{1234, true, 960}, // 不在可选帧长中时恢复配置的帧长
+	}
+	for _, tt := range tests {
+		if got := m.nextFrameSize(tt.current, tt.up); got != tt.want {
+			t.Errorf("nextFrameSize(%d, %v) = %d, want %d", tt.current, tt.up, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package audio
+
+import (
+	"client/config"
+	"fmt"
+	"strings"
+
+	"github.com/pion/webrtc/v3"
+	"gopkg.in/hraban/opus.v2"
+)
+
+// Opus编码器选项的取值
+var (
+	opusApplications = map[string]opus.Application{
+		"voip":     opus.AppVoIP,
+		"audio":    opus.AppAudio,
+		"lowdelay": opus.AppRestrictedLowdelay,
+	}
+	opusSignals = map[string]int{
+		"auto":  opusSignalAuto,
+		"voice": opusSignalVoice,
+		"music": opusSignalMusic,
+	}
+	opusBandwidths = map[string]opus.Bandwidth{
+		"narrowband":    opus.Narrowband,
+		"mediumband":    opus.Mediumband,
+		"wideband":      opus.Wideband,
+		"superwideband": opus.SuperWideband,
+		"fullband":      opus.Fullband,
+	}
+	opusBitrateModes = []string{"vbr", "cvbr", "cbr"}
+)
+
+// NewAudioConfig 从配置文件生成音频配置，填充默认值并校验Opus参数
+func NewAudioConfig(cfg *config.Config) (AudioConfig, error) {
+	audioConfig := AudioConfig{
+		Enabled:        cfg.Audio.Enabled,
+		InputDevice:    cfg.Audio.InputDevice,
+		OutputDevice:   cfg.Audio.OutputDevice,
+		CaptureSystem:  cfg.Audio.CaptureSystem,
+		MixWithMic:     cfg.Audio.MixWithMic,
+		LoopbackDevice: cfg.Audio.LoopbackDevice,
+		FileLoop:       cfg.Audio.FileLoop,
+		SampleRate:     cfg.Audio.SampleRate,
+		Channels:       cfg.Audio.Channels,
+		FrameSize:      cfg.Audio.FrameSize,
+		BitrateKbps:    cfg.Audio.BitrateKbps,
+		OpusComplexity: cfg.Audio.OpusComplexity,
+
+		ResampleQuality: strings.ToLower(cfg.Audio.ResampleQuality),
+		BufferMs:        cfg.Audio.BufferMs,
+
+		MicGainDB:          cfg.Audio.MicGainDB,
+		SystemGainDB:       cfg.Audio.SystemGainDB,
+		MicMute:            cfg.Audio.MicMute,
+		SystemMute:         cfg.Audio.SystemMute,
+		Ducking:            cfg.Audio.Ducking,
+		DuckingDB:          cfg.Audio.DuckingDB,
+		DuckingThresholdDB: cfg.Audio.DuckingThresholdDB,
+
+		OpusApplication:    strings.ToLower(cfg.Audio.OpusApplication),
+		OpusSignal:         strings.ToLower(cfg.Audio.OpusSignal),
+		OpusDTX:            cfg.Audio.OpusDTX,
+		OpusFEC:            cfg.Audio.OpusFEC,
+		OpusPacketLossPerc: cfg.Audio.OpusPacketLossPerc,
+		OpusBitrateMode:    strings.ToLower(cfg.Audio.OpusBitrateMode),
+		OpusMaxBandwidth:   strings.ToLower(cfg.Audio.OpusMaxBandwidth),
+
+		AdaptiveBitrate:  cfg.Audio.AdaptiveBitrate,
+		MinBitrateKbps:   cfg.Audio.MinBitrateKbps,
+		MaxBitrateKbps:   cfg.Audio.MaxBitrateKbps,
+		FECLossThreshold: cfg.Audio.FECLossThreshold,
+		MaxFrameSize:     cfg.Audio.MaxFrameSize,
+	}
+
+	// 设置默认值
+	if audioConfig.SampleRate == 0 {
+		audioConfig.SampleRate = defaultSampleRate
+	}
+	if audioConfig.Channels == 0 {
+		audioConfig.Channels = defaultChannels
+	}
+	if audioConfig.FrameSize == 0 {
+		audioConfig.FrameSize = defaultFrameSize
+	}
+	if audioConfig.BitrateKbps == 0 {
+		audioConfig.BitrateKbps = defaultBitrateKbps
+	}
+	if audioConfig.OpusComplexity == 0 {
+		audioConfig.OpusComplexity = defaultOpusComplexity
+	}
+	if audioConfig.ResampleQuality == "" {
+		audioConfig.ResampleQuality = ResampleQualityMedium
+	}
+	if audioConfig.DuckingDB == 0 {
+		audioConfig.DuckingDB = defaultDuckingDB
+	}
+	if audioConfig.DuckingThresholdDB == 0 {
+		audioConfig.DuckingThresholdDB = defaultDuckingThresholdDB
+	}
+	if audioConfig.OpusApplication == "" {
+		audioConfig.OpusApplication = "voip"
+	}
+	if audioConfig.OpusSignal == "" {
+		audioConfig.OpusSignal = "auto"
+	}
+	if audioConfig.OpusBitrateMode == "" {
+		audioConfig.OpusBitrateMode = "vbr"
+	}
+	if audioConfig.OpusMaxBandwidth == "" {
+		audioConfig.OpusMaxBandwidth = "fullband"
+	}
+	if audioConfig.MinBitrateKbps == 0 {
+		audioConfig.MinBitrateKbps = defaultMinBitrateKbps
+	}
+	if audioConfig.MaxBitrateKbps == 0 {
+		audioConfig.MaxBitrateKbps = defaultMaxBitrateKbps
+	}
+	if audioConfig.FECLossThreshold == 0 {
+		audioConfig.FECLossThreshold = defaultFECLossThreshold
+	}
+	// 未配置最大帧大小时不调整帧长
+	if audioConfig.MaxFrameSize == 0 {
+		audioConfig.MaxFrameSize = audioConfig.FrameSize
+	}
+	// 默认缓冲区至少容纳两个最大帧
+	if audioConfig.BufferMs == 0 && audioConfig.SampleRate > 0 {
+		audioConfig.BufferMs = max(defaultBufferMs, 2*audioConfig.MaxFrameSize*1000/audioConfig.SampleRate)
+	}
+
+	if err := validateAudioConfig(audioConfig); err != nil {
+		return AudioConfig{}, err
+	}
+	return audioConfig, nil
+}
+
+// validateAudioConfig 校验Opus编码参数
+func validateAudioConfig(c AudioConfig) error {
+	switch c.SampleRate {
+	case 8000, 12000, 16000, 24000, 48000:
+	default:
+		return fmt.Errorf("无效的sample_rate %d: Opus仅支持8000/12000/16000/24000/48000", c.SampleRate)
+	}
+	if c.Channels != 1 && c.Channels != 2 {
+		return fmt.Errorf("无效的channels %d: Opus仅支持1或2个通道", c.Channels)
+	}
+	if !isLegalFrameSize(c.SampleRate, c.FrameSize) {
+		return fmt.Errorf("无效的frame_size %d: 在%dHz下必须对应2.5/5/10/20/40/60ms帧长(%s)",
+			c.FrameSize, c.SampleRate, legalFrameSizes(c.SampleRate))
+	}
+	if c.MaxFrameSize < c.FrameSize || !isLegalFrameSize(c.SampleRate, c.MaxFrameSize) {
+		return fmt.Errorf("无效的max_frame_size %d: 必须不小于frame_size且为合法帧长(%s)",
+			c.MaxFrameSize, legalFrameSizes(c.SampleRate))
+	}
+	if c.BitrateKbps < 6 || c.BitrateKbps > 510 {
+		return fmt.Errorf("无效的bitrate_kbps %d: 范围为6-510", c.BitrateKbps)
+	}
+	if c.OpusComplexity < 0 || c.OpusComplexity > 10 {
+		return fmt.Errorf("无效的opus_complexity %d: 范围为0-10", c.OpusComplexity)
+	}
+	if _, ok := resampleHalfTaps[c.ResampleQuality]; !ok {
+		return fmt.Errorf("无效的resample_quality %q: 可选值为low、medium、high", c.ResampleQuality)
+	}
+	// 缓冲区至少能容纳两帧，避免回调和读取之间来回欠载
+	if minMs := 2 * c.MaxFrameSize * 1000 / c.SampleRate; c.BufferMs < minMs || c.BufferMs > maxBufferMs {
+		return fmt.Errorf("无效的buffer_ms %d: 范围为%d-%d(至少两个max_frame_size帧长)", c.BufferMs, minMs, maxBufferMs)
+	}
+	if c.MicGainDB < minMixerGainDB || c.MicGainDB > maxMixerGainDB {
+		return fmt.Errorf("无效的mic_gain_db %g: 范围为%g到%g", c.MicGainDB, minMixerGainDB, maxMixerGainDB)
+	}
+	if c.SystemGainDB < minMixerGainDB || c.SystemGainDB > maxMixerGainDB {
+		return fmt.Errorf("无效的system_gain_db %g: 范围为%g到%g", c.SystemGainDB, minMixerGainDB, maxMixerGainDB)
+	}
+	if c.DuckingDB < 0 || c.DuckingDB > -minMixerGainDB {
+		return fmt.Errorf("无效的ducking_db %g: 范围为0到%g", c.DuckingDB, -minMixerGainDB)
+	}
+	if c.DuckingThresholdDB < -96 || c.DuckingThresholdDB > 0 {
+		return fmt.Errorf("无效的ducking_threshold_db %g: 范围为-96到0", c.DuckingThresholdDB)
+	}
+	if _, ok := opusApplications[c.OpusApplication]; !ok {
+		return fmt.Errorf("无效的opus_application %q: 可选值为voip、audio、lowdelay", c.OpusApplication)
+	}
+	if _, ok := opusSignals[c.OpusSignal]; !ok {
+		return fmt.Errorf("无效的opus_signal %q: 可选值为auto、voice、music", c.OpusSignal)
+	}
+	if _, ok := opusBandwidths[c.OpusMaxBandwidth]; !ok {
+		return fmt.Errorf("无效的opus_max_bandwidth %q: 可选值为narrowband、mediumband、wideband、superwideband、fullband", c.OpusMaxBandwidth)
+	}
+	validMode := false
+	for _, mode := range opusBitrateModes {
+		if c.OpusBitrateMode == mode {
+			validMode = true
+		}
+	}
+	if !validMode {
+		return fmt.Errorf("无效的opus_bitrate_mode %q: 可选值为vbr、cvbr、cbr", c.OpusBitrateMode)
+	}
+	if c.OpusPacketLossPerc < 0 || c.OpusPacketLossPerc > 100 {
+		return fmt.Errorf("无效的opus_packet_loss_perc %d: 范围为0-100", c.OpusPacketLossPerc)
+	}
+	if c.MinBitrateKbps > c.MaxBitrateKbps {
+		return fmt.Errorf("min_bitrate_kbps(%d)不能大于max_bitrate_kbps(%d)", c.MinBitrateKbps, c.MaxBitrateKbps)
+	}
+	if c.MinBitrateKbps < 6 || c.MaxBitrateKbps > 510 {
+		return fmt.Errorf("自适应码率范围%d-%dkbps超出Opus支持的6-510kbps", c.MinBitrateKbps, c.MaxBitrateKbps)
+	}
+	return nil
+}
+
+// isLegalFrameSize 判断帧大小是否为Opus支持的2.5/5/10/20/40/60ms
+func isLegalFrameSize(sampleRate, frameSize int) bool {
+	// 2.5ms对应sampleRate/400个样本，合法帧长为其1/2/4/8/16/24倍
+	unit := sampleRate / 400
+	if unit == 0 || frameSize <= 0 || frameSize%unit != 0 {
+		return false
+	}
+	switch frameSize / unit {
+	case 1, 2, 4, 8, 16, 24:
+		return true
+	}
+	return false
+}
+
+// legalFrameSizes 返回指定采样率下所有合法帧大小的说明
+func legalFrameSizes(sampleRate int) string {
+	unit := sampleRate / 400
+	sizes := make([]string, 0, 6)
+	for _, n := range []int{1, 2, 4, 8, 16, 24} {
+		sizes = append(sizes, fmt.Sprintf("%d", unit*n))
+	}
+	return strings.Join(sizes, "/")
+}
+
+// configureEncoder 按配置设置编码器参数
+func configureEncoder(enc *opusEncoder, c AudioConfig) error {
+	if err := enc.SetBitrate(1000 * c.BitrateKbps); err != nil {
+		return fmt.Errorf("设置比特率失败: %w", err)
+	}
+	if err := enc.SetComplexity(c.OpusComplexity); err != nil {
+		return fmt.Errorf("设置编码复杂度失败: %w", err)
+	}
+	if err := enc.SetSignal(opusSignals[c.OpusSignal]); err != nil {
+		return fmt.Errorf("设置信号类型失败: %w", err)
+	}
+	if err := enc.SetMaxBandwidth(opusBandwidths[c.OpusMaxBandwidth]); err != nil {
+		return fmt.Errorf("设置最大带宽失败: %w", err)
+	}
+	if err := enc.SetVBR(c.OpusBitrateMode != "cbr"); err != nil {
+		return fmt.Errorf("设置VBR失败: %w", err)
+	}
+	if err := enc.SetVBRConstraint(c.OpusBitrateMode == "cvbr"); err != nil {
+		return fmt.Errorf("设置受限VBR失败: %w", err)
+	}
+	if err := enc.SetDTX(c.OpusDTX); err != nil {
+		return fmt.Errorf("设置DTX失败: %w", err)
+	}
+	if err := enc.SetInBandFEC(c.OpusFEC); err != nil {
+		return fmt.Errorf("设置带内FEC失败: %w", err)
+	}
+	if err := enc.SetPacketLossPerc(c.OpusPacketLossPerc); err != nil {
+		return fmt.Errorf("设置预期丢包率失败: %w", err)
+	}
+	return nil
+}
+
+// sdpFmtpLine 根据编码配置生成Opus的SDP fmtp参数(RFC 7587)
+func sdpFmtpLine(c AudioConfig) string {
+	params := []string{fmt.Sprintf("minptime=%d", minPtimeMs(c.FrameSize, c.SampleRate))}
+
+	// 自适应编码可能随时开启FEC
+	if c.OpusFEC || c.AdaptiveBitrate {
+		params = append(params, "useinbandfec=1")
+	}
+	if c.OpusDTX {
+		params = append(params, "usedtx=1")
+	}
+	if c.OpusBitrateMode == "cbr" {
+		params = append(params, "cbr=1")
+	}
+	if c.Channels == 2 {
+		params = append(params, "stereo=1", "sprop-stereo=1")
+	}
+
+	maxBitrateKbps := c.BitrateKbps
+	if c.AdaptiveBitrate && c.MaxBitrateKbps > maxBitrateKbps {
+		maxBitrateKbps = c.MaxBitrateKbps
+	}
+	params = append(params, fmt.Sprintf("maxaveragebitrate=%d", maxBitrateKbps*1000))
+
+	if c.SampleRate < 48000 {
+		params = append(params, fmt.Sprintf("sprop-maxcapturerate=%d", c.SampleRate))
+	}
+	return strings.Join(params, ";")
+}
+
+// minPtimeMs 返回帧长对应的毫秒数，minptime只接受整数，2.5ms按3ms处理
+func minPtimeMs(frameSize, sampleRate int) int {
+	return (frameSize*1000 + sampleRate - 1) / sampleRate
+}
+
+// codecCapability 返回与编码配置一致的Opus编解码能力
+//
+// 按RFC 7587，Opus在SDP中的时钟频率固定为48000且通道数固定为2，
+// 实际的立体声与采样率通过fmtp参数描述。
+func codecCapability(c AudioConfig) webrtc.RTPCodecCapability {
+	return webrtc.RTPCodecCapability{
+		MimeType:    webrtc.MimeTypeOpus,
+		ClockRate:   rtpClockRate,
+		Channels:    2,
+		SDPFmtpLine: sdpFmtpLine(c),
+	}
+}
@@ -0,0 +1,123 @@
+package audio
+
+import (
+	"strings"
+	"testing"
+
+	"client/config"
+)
+
+// defaultAudioConfig 返回填充了默认值的音频配置：48kHz立体声、20ms帧、64kbps VBR
+func defaultAudioConfig(t *testing.T) AudioConfig {
+	t.Helper()
+	c, err := NewAudioConfig(&config.Config{})
+	if err != nil {
+		t.Fatalf("默认配置无效: %v", err)
+	}
+	return c
+}
+
+func TestValidateAudioConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *AudioConfig)
+		want   string // 错误说明包含的内容，为空表示通过
+	}{
+		{"默认配置", func(c *AudioConfig) {}, ""},
+		{"16kHz单声道", func(c *AudioConfig) { c.SampleRate, c.Channels, c.FrameSize, c.MaxFrameSize = 16000, 1, 320, 320 }, ""},
+		{"2.5ms帧", func(c *AudioConfig) { c.FrameSize = 120 }, ""},
+		{"60ms最大帧", func(c *AudioConfig) { c.MaxFrameSize, c.BufferMs = 2880, 120 }, ""},
+		{"cbr", func(c *AudioConfig) { c.OpusBitrateMode = "cbr" }, ""},
+
+		{"不支持的采样率", func(c *AudioConfig) { c.SampleRate = 44100 }, "sample_rate"},
+		{"通道数", func(c *AudioConfig) { c.Channels = 3 }, "channels"},
+		{"帧长不是2.5ms的整数倍", func(c *AudioConfig) { c.FrameSize = 1000 }, "frame_size"},
+		{"30ms帧", func(c *AudioConfig) { c.FrameSize, c.MaxFrameSize = 1440, 1440 }, "frame_size"},
+		{"120ms帧", func(c *AudioConfig) { c.FrameSize, c.MaxFrameSize = 5760, 5760 }, "frame_size"},
+		{"按其他采样率计算的帧长", func(c *AudioConfig) { c.FrameSize, c.MaxFrameSize = 320, 320 }, "frame_size"},
+		{"最大帧小于帧长", func(c *AudioConfig) { c.MaxFrameSize = 480 }, "max_frame_size"},
+		{"非法的最大帧", func(c *AudioConfig) { c.MaxFrameSize = 1440 }, "max_frame_size"},
+		{"码率过低", func(c *AudioConfig) { c.BitrateKbps = 5 }, "bitrate_kbps"},
+		{"码率过高", func(c *AudioConfig) { c.BitrateKbps = 511 }, "bitrate_kbps"},
+		{"编码复杂度", func(c *AudioConfig) { c.OpusComplexity = 11 }, "opus_complexity"},
+		{"重采样质量", func(c *AudioConfig) { c.ResampleQuality = "best" }, "resample_quality"},
+		{"缓冲区小于两个最大帧", func(c *AudioConfig) { c.MaxFrameSize, c.BufferMs = 2880, 100 }, "buffer_ms"},
+		{"麦克风增益", func(c *AudioConfig) { c.MicGainDB = 30 }, "mic_gain_db"},
+		{"应用模式", func(c *AudioConfig) { c.OpusApplication = "game" }, "opus_application"},
+		{"信号类型", func(c *AudioConfig) { c.OpusSignal = "speech" }, "opus_signal"},
+		{"最大带宽", func(c *AudioConfig) { c.OpusMaxBandwidth = "ultraband" }, "opus_max_bandwidth"},
+		{"码率模式", func(c *AudioConfig) { c.OpusBitrateMode = "abr" }, "opus_bitrate_mode"},
+		{"预期丢包率", func(c *AudioConfig) { c.OpusPacketLossPerc = 101 }, "opus_packet_loss_perc"},
+		{"自适应码率下限大于上限", func(c *AudioConfig) { c.MinBitrateKbps, c.MaxBitrateKbps = 64, 32 }, "min_bitrate_kbps"},
+		{"自适应码率超出范围", func(c *AudioConfig) { c.MaxBitrateKbps = 600 }, "6-510kbps"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := defaultAudioConfig(t)
+			tt.modify(&c)
+			err := validateAudioConfig(c)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("validateAudioConfig() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("validateAudioConfig() = %v, want ...%s...", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSDPFmtpLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *AudioConfig)
+		want   string
+	}{
+		{"默认立体声", func(c *AudioConfig) {}, "minptime=20;stereo=1;sprop-stereo=1;maxaveragebitrate=64000"},
+		{"单声道", func(c *AudioConfig) { c.Channels = 1 }, "minptime=20;maxaveragebitrate=64000"},
+		{
+			"cbr、DTX和FEC",
+			func(c *AudioConfig) { c.Channels, c.OpusBitrateMode, c.OpusDTX, c.OpusFEC = 1, "cbr", true, true },
+			"minptime=20;useinbandfec=1;usedtx=1;cbr=1;maxaveragebitrate=64000",
+		},
+		{"cvbr不声明cbr", func(c *AudioConfig) { c.Channels, c.OpusBitrateMode = 1, "cvbr" }, "minptime=20;maxaveragebitrate=64000"},
+		{
+			"自适应码率按上限声明并可能开启FEC",
+			func(c *AudioConfig) { c.AdaptiveBitrate = true },
+			"minptime=20;useinbandfec=1;stereo=1;sprop-stereo=1;maxaveragebitrate=128000",
+		},
+		{
+			"自适应码率上限低于初始码率",
+			func(c *AudioConfig) { c.Channels, c.AdaptiveBitrate, c.MaxBitrateKbps = 1, true, 32 },
+			"minptime=20;useinbandfec=1;maxaveragebitrate=64000",
+		},
+		{
+			"低采样率",
+			func(c *AudioConfig) { c.SampleRate, c.Channels, c.FrameSize, c.BitrateKbps = 16000, 1, 320, 24 },
+			"minptime=20;maxaveragebitrate=24000;sprop-maxcapturerate=16000",
+		},
+		{"2.5ms帧向上取整", func(c *AudioConfig) { c.Channels, c.FrameSize = 1, 120 }, "minptime=3;maxaveragebitrate=64000"},
+		{"60ms帧", func(c *AudioConfig) { c.Channels, c.FrameSize = 1, 2880 }, "minptime=60;maxaveragebitrate=64000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := defaultAudioConfig(t)
+			tt.modify(&c)
+			if got := sdpFmtpLine(c); got != tt.want {
+				t.Errorf("sdpFmtpLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCodecCapability SDP中Opus的时钟频率和通道数固定，单声道也声明为2通道
+func TestCodecCapability(t *testing.T) {
+	c := defaultAudioConfig(t)
+	c.Channels, c.SampleRate, c.FrameSize = 1, 16000, 320
+	capability := codecCapability(c)
+	if capability.ClockRate != 48000 || capability.Channels != 2 || capability.SDPFmtpLine != sdpFmtpLine(c) {
+		t.Errorf("codecCapability() = %+v", capability)
+	}
+}
@@ -0,0 +1,439 @@
+package audio
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gordonklaus/portaudio"
+)
+
+const (
+	// 设备监控参数
+	devicePollInterval   = 2 * time.Second  // 设备枚举和健康检查周期
+	deviceStallTimeout   = 3 * time.Second  // 音频流超过该时长没有回调视为设备失效
+	deviceRescanInterval = 30 * time.Second // 回退到默认设备后重新扫描首选设备的周期
+	maxInputErrors       = 50               // 连续读取失败超过该次数视为设备失效
+)
+
+// 设备事件类型
+const (
+	DeviceEventAdded    = "added"    // 发现新设备
+	DeviceEventRemoved  = "removed"  // 设备被移除
+	DeviceEventSwitched = "switched" // 切换到指定设备
+	DeviceEventFallback = "fallback" // 首选设备不可用，回退到默认设备
+	DeviceEventFailed   = "failed"   // 设备失效或无法打开
+)
+
+// DeviceEvent 音频设备变化事件
+type DeviceEvent struct {
+	Type   string    `json:"type"`            // 事件类型
+	Kind   string    `json:"kind,omitempty"`  // input或output
+	Device string    `json:"device"`          // 设备名称，空字符串表示默认设备
+	Error  string    `json:"error,omitempty"` // 失败原因
+	Time   time.Time `json:"time"`
+}
+
+// DeviceStatus 当前使用的音频设备
+type DeviceStatus struct {
+	Input           string `json:"input"`            // 实际使用的输入设备，空字符串表示默认设备
+	Output          string `json:"output"`           // 实际使用的输出设备，空字符串表示默认设备
+	PreferredInput  string `json:"preferred_input"`  // 配置的输入设备
+	PreferredOutput string `json:"preferred_output"` // 配置的输出设备
+}
+
+// streamChecker 可以报告底层音频流是否仍在工作的音频源或接收器
+type streamChecker interface {
+	// stalled 音频流在timeout内没有任何回调时返回true
+	stalled(timeout time.Duration) bool
+}
+
+// newCaptureSource 根据配置创建音频源：麦克风、系统音频或两者混合
+func newCaptureSource(config AudioConfig) (AudioSource, error) {
+	if !config.CaptureSystem {
+		source, err := NewAudioSource(config)
+		if err != nil {
+			return nil, fmt.Errorf("创建音频源失败: %w", err)
+		}
+		return source, nil
+	}
+
+	log.Info("配置为捕获系统音频")
+	if !config.MixWithMic {
+		// 只捕获系统音频
+		source, err := NewLoopbackSource(config)
+		if err != nil {
+			return nil, fmt.Errorf("创建系统音频源失败: %w", err)
+		}
+		return source, nil
+	}
+
+	log.Info("将系统音频与麦克风混合")
+
+	// 创建麦克风音频源
+	micSource, err := NewAudioSource(config)
+	if err != nil {
+		return nil, fmt.Errorf("创建麦克风音频源失败: %w", err)
+	}
+
+	// 创建系统音频源
+	sysSource, err := NewLoopbackSource(config)
+	if err != nil {
+		log.Warn("创建系统音频源失败，只使用麦克风", "error", err)
+		return micSource, nil
+	}
+
+	// 创建混合音频源
+	// 麦克风作为时钟基准，有语音时压低系统音频
+	source, err := NewMixerSource(config, []MixerInput{
+		{Source: micSource, GainDB: config.MicGainDB, Muted: config.MicMute, Voice: true},
+		{Source: sysSource, GainDB: config.SystemGainDB, Muted: config.SystemMute, Ducked: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建混合音频源失败: %w", err)
+	}
+	return source, nil
+}
+
+// OnDeviceEvent 注册设备事件回调
+//
+// 回调在释放设备锁之后执行，可以调用DeviceStatus等方法，但不应阻塞。
+func (m *Manager) OnDeviceEvent(handler func(DeviceEvent)) {
+	m.eventMu.Lock()
+	defer m.eventMu.Unlock()
+	m.eventHandlers = append(m.eventHandlers, handler)
+}
+
+// emitDeviceEvent 记录日志并将事件加入待通知队列
+//
+// 调用方可能持有ioMu，回调由flushDeviceEvents在释放锁之后执行。
+func (m *Manager) emitDeviceEvent(eventType, kind, device string, err error) {
+	event := DeviceEvent{
+		Type:   eventType,
+		Kind:   kind,
+		Device: device,
+		Time:   time.Now(),
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	switch eventType {
+	case DeviceEventFailed:
+		log.Error("音频设备失效", "kind", kind, "device", device, "error", err)
+	case DeviceEventFallback:
+		log.Warn("音频设备不可用，回退到默认设备", "kind", kind, "device", device)
+	default:
+		log.Info("音频设备变化", "event", eventType, "kind", kind, "device", device)
+	}
+
+	m.eventMu.Lock()
+	m.pendingEvents = append(m.pendingEvents, event)
+	m.eventMu.Unlock()
+}
+
+// flushDeviceEvents 将待通知的事件交给所有回调，调用方不能持有ioMu
+func (m *Manager) flushDeviceEvents() {
+	m.eventMu.Lock()
+	events := m.pendingEvents
+	m.pendingEvents = nil
+	handlers := append([]func(DeviceEvent){}, m.eventHandlers...)
+	m.eventMu.Unlock()
+
+	for _, event := range events {
+		for _, handler := range handlers {
+			handler(event)
+		}
+	}
+}
+
+// DeviceStatus 返回当前使用的音频设备
+func (m *Manager) DeviceStatus() DeviceStatus {
+	m.ioMu.RLock()
+	defer m.ioMu.RUnlock()
+	return DeviceStatus{
+		Input:           m.activeInput,
+		Output:          m.activeOutput,
+		PreferredInput:  m.preferredInput,
+		PreferredOutput: m.preferredOutput,
+	}
+}
+
+// SetInputDevice 在运行时切换输入设备，不影响已建立的WebRTC连接
+func (m *Manager) SetInputDevice(device string) error {
+	// 先注册的defer后执行，事件在释放ioMu之后通知
+	defer m.flushDeviceEvents()
+	m.ioMu.Lock()
+	defer m.ioMu.Unlock()
+
+	if err := m.switchInput(device); err != nil {
+		m.emitDeviceEvent(DeviceEventFailed, "input", device, err)
+		return err
+	}
+	m.preferredInput = device
+	m.emitDeviceEvent(DeviceEventSwitched, "input", device, nil)
+	return nil
+}
+
+// SetOutputDevice 在运行时切换输出设备，不影响已建立的WebRTC连接
+func (m *Manager) SetOutputDevice(device string) error {
+	// 先注册的defer后执行，事件在释放ioMu之后通知
+	defer m.flushDeviceEvents()
+	m.ioMu.Lock()
+	defer m.ioMu.Unlock()
+
+	if err := m.switchOutput(device); err != nil {
+		m.emitDeviceEvent(DeviceEventFailed, "output", device, err)
+		return err
+	}
+	m.preferredOutput = device
+	m.emitDeviceEvent(DeviceEventSwitched, "output", device, nil)
+	return nil
+}
+
+// switchInput 用新设备替换当前音频源，调用方需持有ioMu写锁
+func (m *Manager) switchInput(device string) error {
+	config := m.config
+	config.InputDevice = device
+	source, err := newCaptureSource(config)
+	if err != nil {
+		return err
+	}
+
+	if m.isRunning() {
+		// 部分设备不能同时被打开两次，先停止旧设备
+		m.audioSource.Stop()
+		if err := source.Start(); err != nil {
+			if restartErr := m.audioSource.Start(); restartErr != nil {
+				log.Error("恢复原输入设备失败", "error", restartErr)
+			}
+			return fmt.Errorf("启动输入设备失败: %w", err)
+		}
+	}
+
+	m.audioSource = source
+	m.activeInput = device
+	m.inputErrors.Store(0)
+	return nil
+}
+
+// switchOutput 用新设备替换当前音频接收器，调用方需持有ioMu写锁
+func (m *Manager) switchOutput(device string) error {
+	config := m.config
+	config.OutputDevice = device
+	sink, err := NewAudioSink(config)
+	if err != nil {
+		return fmt.Errorf("创建音频接收器失败: %w", err)
+	}
+
+	if m.isRunning() {
+		m.audioSink.Stop()
+		if err := sink.Start(); err != nil {
+			if restartErr := m.audioSink.Start(); restartErr != nil {
+				log.Error("恢复原输出设备失败", "error", restartErr)
+			}
+			return fmt.Errorf("启动输出设备失败: %w", err)
+		}
+	}
+
+	m.audioSink = sink
+	m.activeOutput = device
+	return nil
+}
+
+// isRunning 返回音频系统是否在运行
+func (m *Manager) isRunning() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.running
+}
+
+// deviceMonitorLoop 周期性枚举设备并检查当前设备是否仍在工作
+func (m *Manager) deviceMonitorLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(devicePollInterval)
+	defer ticker.Stop()
+
+	m.knownDevices = enumerateDevices()
+	lastRescan := time.Now()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		m.diffDevices(enumerateDevices())
+
+		m.ioMu.RLock()
+		inputFailed := m.inputErrors.Load() > maxInputErrors || isStalled(m.audioSource)
+		outputFailed := isStalled(m.audioSink)
+		fallback := m.activeInput != m.preferredInput || m.activeOutput != m.preferredOutput
+		m.ioMu.RUnlock()
+
+		switch {
+		case inputFailed || outputFailed:
+			if inputFailed {
+				m.emitDeviceEvent(DeviceEventFailed, "input", m.DeviceStatus().Input, fmt.Errorf("音频输入流已停止"))
+			}
+			if outputFailed {
+				m.emitDeviceEvent(DeviceEventFailed, "output", m.DeviceStatus().Output, fmt.Errorf("音频输出流已停止"))
+			}
+			m.recoverDevices()
+			lastRescan = time.Now()
+		case fallback && time.Since(lastRescan) >= deviceRescanInterval:
+			// PortAudio只在重新初始化后才能发现新插入的设备
+			m.recoverDevices()
+			lastRescan = time.Now()
+		}
+		m.flushDeviceEvents()
+	}
+}
+
+// recoverDevices 重新初始化PortAudio，打开首选设备，不可用时回退到默认设备
+func (m *Manager) recoverDevices() {
+	defer m.flushDeviceEvents()
+	m.ioMu.Lock()
+	defer m.ioMu.Unlock()
+
+	if !m.isRunning() {
+		return
+	}
+
+	// 重新初始化前必须关闭所有PortAudio流
+	m.audioSource.Stop()
+	m.audioSink.Stop()
+	refreshPortAudio()
+	m.diffDevices(enumerateDevices())
+
+	m.reopen("input", m.preferredInput, m.activeInput, m.switchInputStopped)
+	m.reopen("output", m.preferredOutput, m.activeOutput, m.switchOutputStopped)
+}
+
+// reopen 打开首选设备，失败时回退到默认设备
+//
+// 都失败时保持停止状态，下一个监控周期会再次尝试。
+func (m *Manager) reopen(kind, preferred, active string, open func(device string) error) {
+	err := open(preferred)
+	if err == nil {
+		if preferred != active {
+			m.emitDeviceEvent(DeviceEventSwitched, kind, preferred, nil)
+		}
+		return
+	}
+	if preferred != "" {
+		if active != "" {
+			// 首次回退时才发出事件，避免重新扫描时重复通知
+			m.emitDeviceEvent(DeviceEventFallback, kind, preferred, err)
+		}
+		if err = open(""); err == nil {
+			return
+		}
+	}
+	m.emitDeviceEvent(DeviceEventFailed, kind, "", err)
+}
+
+// switchInputStopped 在原音频源已停止的情况下打开新的输入设备
+func (m *Manager) switchInputStopped(device string) error {
+	config := m.config
+	config.InputDevice = device
+	source, err := newCaptureSource(config)
+	if err != nil {
+		return err
+	}
+	if err := source.Start(); err != nil {
+		return err
+	}
+	m.audioSource = source
+	m.activeInput = device
+	m.inputErrors.Store(0)
+	return nil
+}
+
+// switchOutputStopped 在原音频接收器已停止的情况下打开新的输出设备
+func (m *Manager) switchOutputStopped(device string) error {
+	config := m.config
+	config.OutputDevice = device
+	sink, err := NewAudioSink(config)
+	if err != nil {
+		return err
+	}
+	if err := sink.Start(); err != nil {
+		return err
+	}
+	m.audioSink = sink
+	m.activeOutput = device
+	return nil
+}
+
+// diffDevices 比较设备列表并发出新增和移除事件
+func (m *Manager) diffDevices(current map[string]string) {
+	previous := m.knownDevices
+	m.knownDevices = current
+	if previous == nil {
+		return
+	}
+
+	var added, removed []string
+	for name := range current {
+		if _, ok := previous[name]; !ok {
+			added = append(added, name)
+		}
+	}
+	for name := range previous {
+		if _, ok := current[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+
+	for _, name := range added {
+		m.emitDeviceEvent(DeviceEventAdded, current[name], name, nil)
+	}
+	for _, name := range removed {
+		m.emitDeviceEvent(DeviceEventRemoved, previous[name], name, nil)
+	}
+}
+
+// enumerateDevices 返回设备名称到类型(input/output/duplex)的映射
+func enumerateDevices() map[string]string {
+	devices, err := portaudio.Devices()
+	if err != nil {
+		log.Error("获取音频设备失败", "error", err)
+		return nil
+	}
+
+	result := make(map[string]string, len(devices))
+	for _, device := range devices {
+		switch {
+		case device.MaxInputChannels > 0 && device.MaxOutputChannels > 0:
+			result[device.Name] = "duplex"
+		case device.MaxInputChannels > 0:
+			result[device.Name] = "input"
+		case device.MaxOutputChannels > 0:
+			result[device.Name] = "output"
+		}
+	}
+	return result
+}
+
+// refreshPortAudio 重新初始化PortAudio以刷新设备列表
+func refreshPortAudio() {
+	streamMu.Lock()
+	defer streamMu.Unlock()
+
+	if err := portaudio.Terminate(); err != nil {
+		log.Error("终止PortAudio失败", "error", err)
+	}
+	if err := portaudio.Initialize(); err != nil {
+		log.Error("初始化PortAudio失败", "error", err)
+	}
+}
+
+// isStalled 判断音频源或接收器的底层流是否已停止
+func isStalled(device interface{}) bool {
+	checker, ok := device.(streamChecker)
+	return ok && checker.stalled(deviceStallTimeout)
+}
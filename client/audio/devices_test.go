@@ -0,0 +1,48 @@
+package audio
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestDeviceEventHandlerCanQueryStatus 回调中调用DeviceStatus不能死锁
+func TestDeviceEventHandlerCanQueryStatus(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.wav")
+	second := filepath.Join(dir, "second.wav")
+	writeToneWAV(t, first, defaultSampleRate, defaultChannels, 100*time.Millisecond)
+	writeToneWAV(t, second, defaultSampleRate, defaultChannels, 100*time.Millisecond)
+
+	m := newFileManager(t, "local", first, filepath.Join(dir, "out.wav"))
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+
+	statuses := make(chan DeviceStatus, 1)
+	m.OnDeviceEvent(func(event DeviceEvent) {
+		if event.Type == DeviceEventSwitched {
+			statuses <- m.DeviceStatus()
+		}
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.SetInputDevice(fileDevicePrefix + second)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SetInputDevice在回调中死锁")
+	}
+
+	status := <-statuses
+	if status.Input != fileDevicePrefix+second || status.PreferredInput != fileDevicePrefix+second {
+		t.Errorf("切换后的设备状态不正确: %+v", status)
+	}
+}
@@ -0,0 +1,632 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3/pkg/media/oggreader"
+	"github.com/pion/webrtc/v3/pkg/media/oggwriter"
+	"gopkg.in/hraban/opus.v2"
+)
+
+const (
+	// fileDevicePrefix 设备名以该前缀开头时使用文件代替声卡
+	fileDevicePrefix = "file:"
+	// maxFileLag 文件源落后实时进度超过该时长时丢弃积压，避免突发大量数据
+	maxFileLag = time.Second
+	// opusGranuleRate Ogg/Opus的granule position和RTP时间戳固定以48kHz计数
+	opusGranuleRate = 48000
+)
+
+// IsFileDevice 判断设备名是否指向文件
+func IsFileDevice(device string) bool {
+	return strings.HasPrefix(device, fileDevicePrefix)
+}
+
+// filePath 返回文件设备名中的文件路径
+func filePath(device string) string {
+	return strings.TrimPrefix(device, fileDevicePrefix)
+}
+
+// isOggFile 根据扩展名判断是否为Ogg/Opus文件，其余按WAV处理
+func isOggFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ogg", ".opus", ".oga":
+		return true
+	}
+	return false
+}
+
+// pcmFileReader 按配置的采样率和通道数读取文件中的PCM数据
+type pcmFileReader interface {
+	// read 读取交错的PCM样本，返回采样帧数，文件结束时返回io.EOF
+	read(buffer []int16) (int, error)
+	// rewind 回到文件开头
+	rewind() error
+	Close() error
+}
+
+// pcmFileWriter 将PCM数据写入文件
+type pcmFileWriter interface {
+	// write 写入交错的PCM样本
+	write(samples []int16) error
+	Close() error
+}
+
+// FileSource 从WAV或Ogg/Opus文件读取音频，按实时速度输出
+type FileSource struct {
+	config    AudioConfig
+	path      string
+	reader    pcmFileReader
+	startTime time.Time
+	delivered int64 // 已输出的采样帧数
+	finished  bool  // 不循环时文件已读完
+	mutex     sync.Mutex
+	running   bool
+}
+
+// NewFileSource 创建文件音频源
+func NewFileSource(config AudioConfig) (AudioSource, error) {
+	path := filePath(config.InputDevice)
+	if path == "" {
+		return nil, errors.New("未指定输入文件")
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("无法访问输入文件: %w", err)
+	}
+	return &FileSource{
+		config: config,
+		path:   path,
+	}, nil
+}
+
+// Start 打开文件并开始计时
+func (s *FileSource) Start() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.running {
+		return nil
+	}
+
+	var reader pcmFileReader
+	var err error
+	if isOggFile(s.path) {
+		reader, err = newOggFileReader(s.path, s.config)
+	} else {
+		reader, err = newWAVFileReader(s.path, s.config)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.reader = reader
+	s.startTime = time.Now()
+	s.delivered = 0
+	s.finished = false
+	s.running = true
+
+	log.Info("文件音频输入已启动", "file", s.path, "loop", s.config.FileLoop)
+	return nil
+}
+
+// Stop 关闭文件
+func (s *FileSource) Stop() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.running {
+		return nil
+	}
+
+	if err := s.reader.Close(); err != nil {
+		log.Error("关闭输入文件失败", "error", err)
+	}
+	s.reader = nil
+	s.running = false
+	log.Info("文件音频输入已停止", "file", s.path)
+	return nil
+}
+
+// Read 读取自开始以来按实时进度应输出的音频，文件结束后循环或输出静音
+func (s *FileSource) Read(buffer []int16) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.running {
+		return 0, errors.New("文件音频输入未启动")
+	}
+
+	channels := s.config.Channels
+	due := int64(time.Since(s.startTime)*time.Duration(s.config.SampleRate)/time.Second) - s.delivered
+	if due > int64(s.config.SampleRate)*int64(maxFileLag)/int64(time.Second) {
+		// 读取方长时间未读取，跳过积压部分
+		s.startTime = time.Now()
+		s.delivered = 0
+		due = 0
+	}
+	frames := len(buffer) / channels
+	if int64(frames) > due {
+		frames = int(due)
+	}
+	if frames <= 0 {
+		return 0, nil
+	}
+
+	filled := 0
+	rewound := false
+	for filled < frames {
+		if s.finished {
+			// 文件已结束，剩余部分填充静音
+			clear(buffer[filled*channels : frames*channels])
+			break
+		}
+
+		n, err := s.reader.read(buffer[filled*channels : frames*channels])
+		filled += n
+		if n > 0 {
+			rewound = false
+		}
+		if err == io.EOF {
+			// 不循环，或回到开头后仍读不到数据(文件中没有音频)
+			if !s.config.FileLoop || rewound {
+				s.finished = true
+				log.Info("输入文件已播放完毕", "file", s.path)
+				continue
+			}
+			if err := s.reader.rewind(); err != nil {
+				return 0, fmt.Errorf("重新读取输入文件失败: %w", err)
+			}
+			rewound = true
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("读取输入文件失败: %w", err)
+		}
+	}
+
+	s.delivered += int64(frames)
+	return frames, nil
+}
+
+// GetDeviceList 返回当前使用的文件
+func (s *FileSource) GetDeviceList() ([]string, error) {
+	return []string{fileDevicePrefix + s.path}, nil
+}
+
+// FileSink 将接收到的音频写入WAV或Ogg/Opus文件
+type FileSink struct {
+	config  AudioConfig
+	path    string
+	writer  pcmFileWriter
+	mutex   sync.Mutex
+	running bool
+}
+
+// NewFileSink 创建文件音频接收器
+func NewFileSink(config AudioConfig) (AudioSink, error) {
+	path := filePath(config.OutputDevice)
+	if path == "" {
+		return nil, errors.New("未指定输出文件")
+	}
+	return &FileSink{
+		config: config,
+		path:   path,
+	}, nil
+}
+
+// Start 创建输出文件
+func (s *FileSink) Start() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.running {
+		return nil
+	}
+
+	var writer pcmFileWriter
+	var err error
+	if isOggFile(s.path) {
+		writer, err = newOggFileWriter(s.path, s.config)
+	} else {
+		writer, err = newWAVFileWriter(s.path, s.config)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.writer = writer
+	s.running = true
+	log.Info("文件音频输出已启动", "file", s.path)
+	return nil
+}
+
+// Stop 完成文件写入
+func (s *FileSink) Stop() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.running {
+		return nil
+	}
+
+	err := s.writer.Close()
+	s.writer = nil
+	s.running = false
+	if err != nil {
+		return fmt.Errorf("关闭输出文件失败: %w", err)
+	}
+	log.Info("文件音频输出已停止", "file", s.path)
+	return nil
+}
+
+// Write 写入音频帧
+func (s *FileSink) Write(buffer []int16) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.running {
+		return errors.New("文件音频输出未启动")
+	}
+	return s.writer.write(buffer)
+}
+
+// GetDeviceList 返回当前使用的文件
+func (s *FileSink) GetDeviceList() ([]string, error) {
+	return []string{fileDevicePrefix + s.path}, nil
+}
+
+// wavFileReader 读取16位PCM WAV文件，并转换为配置的采样率和通道数
+type wavFileReader struct {
+	file      *os.File
+	format    wavFormat
+	channels  int // 输出通道数
+	converter *formatConverter
+	raw       []byte  // 文件中读出的原始字节
+	samples   []int16 // 文件格式的样本
+	pending   []int16 // 已转换但未读取的样本
+	position  int64   // 已读取的data字节数
+}
+
+func newWAVFileReader(path string, config AudioConfig) (*wavFileReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开WAV文件失败: %w", err)
+	}
+
+	format, err := readWAVHeader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if format.SampleRate <= 0 || format.Channels <= 0 {
+		file.Close()
+		return nil, fmt.Errorf("无效的WAV格式: %dHz %d通道", format.SampleRate, format.Channels)
+	}
+
+	return &wavFileReader{
+		file:      file,
+		format:    format,
+		channels:  config.Channels,
+		converter: newFormatConverter(format.SampleRate, format.Channels, config.SampleRate, config.Channels, config.ResampleQuality),
+	}, nil
+}
+
+func (r *wavFileReader) read(buffer []int16) (int, error) {
+	// 缓冲区放不下一个采样帧时无法读取，不能当作文件结束
+	if len(buffer) < r.channels {
+		return 0, io.ErrShortBuffer
+	}
+	for len(r.pending) == 0 {
+		samples, err := r.readChunk(len(buffer) / r.channels)
+		if err != nil {
+			return 0, err
+		}
+		r.pending = r.converter.process(samples)
+	}
+
+	count := copy(buffer[:len(buffer)-len(buffer)%r.channels], r.pending)
+	r.pending = r.pending[count:]
+	return count / r.channels, nil
+}
+
+// readChunk 从文件读取最多frames个采样帧
+func (r *wavFileReader) readChunk(frames int) ([]int16, error) {
+	frameBytes := int64(r.format.Channels * wavBytesPerSample)
+	if remaining := (r.format.dataSize - r.position) / frameBytes; int64(frames) > remaining {
+		frames = int(remaining)
+	}
+	if frames == 0 {
+		return nil, io.EOF
+	}
+
+	size := frames * int(frameBytes)
+	if cap(r.raw) < size {
+		r.raw = make([]byte, size)
+	}
+	n, err := io.ReadFull(r.file, r.raw[:size])
+	n -= n % int(frameBytes)
+	r.position += int64(n)
+	if n == 0 {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return nil, err
+	}
+
+	count := n / wavBytesPerSample
+	if cap(r.samples) < count {
+		r.samples = make([]int16, count)
+	}
+	samples := r.samples[:count]
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(r.raw[i*2:]))
+	}
+	return samples, nil
+}
+
+func (r *wavFileReader) rewind() error {
+	r.position = 0
+	r.pending = nil
+	_, err := r.file.Seek(r.format.dataOffset, io.SeekStart)
+	return err
+}
+
+func (r *wavFileReader) Close() error {
+	return r.file.Close()
+}
+
+// wavFileWriter 写入16位PCM WAV文件，关闭时回填文件头中的长度
+type wavFileWriter struct {
+	file       *os.File
+	sampleRate int
+	channels   int
+	dataSize   int64
+	raw        []byte
+}
+
+func newWAVFileWriter(path string, config AudioConfig) (*wavFileWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("创建WAV文件失败: %w", err)
+	}
+	if err := writeWAVHeader(file, config.SampleRate, config.Channels, 0); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("写入WAV头失败: %w", err)
+	}
+	return &wavFileWriter{
+		file:       file,
+		sampleRate: config.SampleRate,
+		channels:   config.Channels,
+	}, nil
+}
+
+func (w *wavFileWriter) write(samples []int16) error {
+	size := len(samples) * wavBytesPerSample
+	if cap(w.raw) < size {
+		w.raw = make([]byte, size)
+	}
+	raw := w.raw[:size]
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(raw[i*2:], uint16(sample))
+	}
+	n, err := w.file.Write(raw)
+	w.dataSize += int64(n)
+	return err
+}
+
+func (w *wavFileWriter) Close() error {
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		w.file.Close()
+		return err
+	}
+	if err := writeWAVHeader(w.file, w.sampleRate, w.channels, w.dataSize); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// oggFileReader 读取并解码Ogg/Opus文件
+//
+// oggreader按页返回数据，要求每页只包含一个Opus包，与oggwriter和通话录音写出的文件一致。
+type oggFileReader struct {
+	path    string
+	file    *os.File
+	ogg     *oggreader.OggReader
+	decoder *opus.Decoder
+	config  AudioConfig
+	pcm     []int16 // 解码缓冲区
+	pending []int16 // 已解码但未读取的样本
+	skip    int     // 开头需要丢弃的采样帧数
+}
+
+func newOggFileReader(path string, config AudioConfig) (*oggFileReader, error) {
+	decoder, err := opus.NewDecoder(config.SampleRate, config.Channels)
+	if err != nil {
+		return nil, fmt.Errorf("创建Opus解码器失败: %w", err)
+	}
+
+	r := &oggFileReader{
+		path:    path,
+		decoder: decoder,
+		config:  config,
+		// 单个Opus包最长120ms
+		pcm: make([]int16, config.SampleRate*120/1000*config.Channels),
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open 打开文件并解析Ogg/Opus头
+func (r *oggFileReader) open() error {
+	file, err := os.Open(r.path)
+	if err != nil {
+		return fmt.Errorf("打开Ogg文件失败: %w", err)
+	}
+	ogg, header, err := oggreader.NewWith(file)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("不是Ogg/Opus文件: %w", err)
+	}
+	if header.ChannelMap != 0 {
+		file.Close()
+		return fmt.Errorf("不支持的Opus通道映射族: %d", header.ChannelMap)
+	}
+
+	r.file = file
+	r.ogg = ogg
+	r.pending = nil
+	r.skip = int(header.PreSkip) * r.config.SampleRate / opusGranuleRate
+	return r.decoder.Init(r.config.SampleRate, r.config.Channels)
+}
+
+func (r *oggFileReader) read(buffer []int16) (int, error) {
+	channels := r.config.Channels
+	for len(r.pending) == 0 {
+		packet, _, err := r.ogg.ParseNextPage()
+		if err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return 0, err
+		}
+		// 跳过OpusTags头和空页
+		if len(packet) == 0 || strings.HasPrefix(string(packet), "OpusTags") {
+			continue
+		}
+
+		n, err := r.decoder.Decode(packet, r.pcm)
+		if err != nil {
+			log.Error("解码Ogg/Opus包失败", "file", r.path, "error", err)
+			continue
+		}
+		r.pending = r.pcm[:n*channels]
+
+		// 丢弃编码器的预热样本
+		if r.skip > 0 {
+			drop := r.skip
+			if drop > n {
+				drop = n
+			}
+			r.pending = r.pending[drop*channels:]
+			r.skip -= drop
+		}
+	}
+
+	count := copy(buffer[:len(buffer)-len(buffer)%channels], r.pending)
+	r.pending = r.pending[count:]
+	return count / channels, nil
+}
+
+func (r *oggFileReader) rewind() error {
+	r.file.Close()
+	return r.open()
+}
+
+func (r *oggFileReader) Close() error {
+	return r.file.Close()
+}
+
+// oggFileWriter 将PCM编码为Opus并写入Ogg文件，每个包单独成页
+type oggFileWriter struct {
+	ogg       *oggwriter.OggWriter
+	encoder   *opusEncoder
+	frameSize int
+	channels  int
+	frame     []int16 // 未凑满一帧的样本
+	encoded   []byte
+	rateScale int    // 配置采样率到48kHz的倍数
+	timestamp uint32 // 下一帧的48kHz时间戳，oggwriter据此计算granule position
+}
+
+func newOggFileWriter(path string, config AudioConfig) (*oggFileWriter, error) {
+	encoder, err := newOpusEncoder(config.SampleRate, config.Channels, opusApplications[config.OpusApplication])
+	if err != nil {
+		return nil, fmt.Errorf("创建Opus编码器失败: %w", err)
+	}
+	if err := configureEncoder(encoder, config); err != nil {
+		return nil, fmt.Errorf("配置Opus编码器失败: %w", err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("创建Ogg文件失败: %w", err)
+	}
+	// 以流方式写入：按文件打开时Close会回写最后一页，超过255字节的包会写坏该页
+	ogg, err := oggwriter.NewWith(file, uint32(config.SampleRate), uint16(config.Channels))
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("写入Ogg头失败: %w", err)
+	}
+
+	return &oggFileWriter{
+		ogg:       ogg,
+		encoder:   encoder,
+		frameSize: config.FrameSize,
+		channels:  config.Channels,
+		frame:     make([]int16, 0, config.FrameSize*config.Channels),
+		encoded:   make([]byte, maxFrameSize*2),
+		rateScale: opusGranuleRate / config.SampleRate,
+	}, nil
+}
+
+func (w *oggFileWriter) write(samples []int16) error {
+	frameLen := w.frameSize * w.channels
+	for len(samples) > 0 {
+		n := frameLen - len(w.frame)
+		if n > len(samples) {
+			n = len(samples)
+		}
+		w.frame = append(w.frame, samples[:n]...)
+		samples = samples[n:]
+
+		if len(w.frame) == frameLen {
+			if err := w.encodeFrame(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// encodeFrame 编码一帧并写入Ogg
+func (w *oggFileWriter) encodeFrame() error {
+	n, err := w.encoder.Encode(w.frame, w.encoded)
+	w.frame = w.frame[:0]
+	if err != nil {
+		return fmt.Errorf("编码音频数据失败: %w", err)
+	}
+	packet := &rtp.Packet{
+		Header:  rtp.Header{Timestamp: w.timestamp},
+		Payload: w.encoded[:n],
+	}
+	w.timestamp += uint32(w.frameSize * w.rateScale)
+	return w.ogg.WriteRTP(packet)
+}
+
+func (w *oggFileWriter) Close() error {
+	// 用静音补齐最后一帧
+	if len(w.frame) > 0 {
+		frameLen := w.frameSize * w.channels
+		for len(w.frame) < frameLen {
+			w.frame = append(w.frame, 0)
+		}
+		if err := w.encodeFrame(); err != nil {
+			w.ogg.Close()
+			return err
+		}
+	}
+	return w.ogg.Close()
+}
@@ -144,11 +144,4 @@ func newMacOSLoopback(config AudioConfig) (loopbackImpl, error) {
 	return nil, errors.New("macOS回环捕获未实现")
 }
 
-// LinuxLoopback Linux PulseAudio/JACK回环实现
-type LinuxLoopback struct {
-	// Linux特定实现
-}
-
-func newLinuxLoopback(config AudioConfig) (loopbackImpl, error) {
-	return nil, errors.New("Linux回环捕获未实现")
-} 
\ No newline at end of file
+// Linux PulseAudio/PipeWire回环实现见loopback_linux.go 
\ No newline at end of file
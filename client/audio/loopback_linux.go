@@ -0,0 +1,222 @@
+//go:build linux
+
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gordonklaus/portaudio"
+)
+
+const (
+	// defaultMonitorSource PulseAudio/PipeWire中表示默认输出设备监视源的特殊名称
+	defaultMonitorSource = "@DEFAULT_MONITOR@"
+	// monitorSuffix 监视源名称的后缀
+	monitorSuffix = ".monitor"
+)
+
+// pulseDeviceNames 通过ALSA访问PulseAudio的PortAudio设备名称，按优先级排列
+var pulseDeviceNames = []string{"pulse", "default"}
+
+// LinuxLoopback Linux PulseAudio/PipeWire回环实现
+//
+// 通过ALSA的pulse插件打开录音流，并使用PULSE_SOURCE环境变量
+// 将其指向输出设备的.monitor源，从而捕获系统正在播放的声音。
+// PipeWire通过pipewire-pulse提供相同的接口。
+type LinuxLoopback struct {
+	config      AudioConfig
+	source      string // 监视源名称
+	deviceInfo  *portaudio.DeviceInfo
+	stream      *portaudio.Stream
+	ring        *ringBuffer
+	readTimeout time.Duration
+}
+
+func newLinuxLoopback(config AudioConfig) (loopbackImpl, error) {
+	return &LinuxLoopback{
+		config:      config,
+		ring:        newRingBuffer(config.BufferMs, config.SampleRate, config.Channels),
+		readTimeout: 2 * frameDuration(config.FrameSize, config.SampleRate),
+	}, nil
+}
+
+// initialize 确定要捕获的监视源和PortAudio设备
+func (l *LinuxLoopback) initialize(config AudioConfig) error {
+	l.config = config
+
+	source, err := resolveMonitorSource(config.LoopbackDevice)
+	if err != nil {
+		return err
+	}
+	l.source = source
+
+	deviceInfo, err := findPulseDevice()
+	if err != nil {
+		return err
+	}
+	l.deviceInfo = deviceInfo
+	return nil
+}
+
+// start 打开指向监视源的录音流
+func (l *LinuxLoopback) start() error {
+	inputParams := portaudio.StreamParameters{
+		Input: portaudio.StreamDeviceParameters{
+			Device:   l.deviceInfo,
+			Channels: l.config.Channels,
+			Latency:  l.deviceInfo.DefaultLowInputLatency,
+		},
+		SampleRate:      float64(l.config.SampleRate),
+		FramesPerBuffer: l.config.FrameSize,
+	}
+
+	l.ring.Reset()
+	callback := func(in []int16, _ []int16) {
+		l.ring.Write(in)
+	}
+
+	// pulse插件在打开流时读取PULSE_SOURCE，打开后恢复原值，避免影响麦克风等其他流
+	stream, err := withPulseSource(l.source, inputParams, callback)
+	if err != nil {
+		return fmt.Errorf("打开监视源录音流失败: %w", err)
+	}
+
+	if err := stream.Start(); err != nil {
+		stream.Close()
+		return fmt.Errorf("启动监视源录音流失败: %w", err)
+	}
+
+	l.stream = stream
+
+	log.Info("系统音频捕获已启动", "source", l.source, "device", l.deviceInfo.Name)
+	return nil
+}
+
+// stop 停止并关闭录音流
+func (l *LinuxLoopback) stop() error {
+	if l.stream == nil {
+		return nil
+	}
+
+	if err := l.stream.Stop(); err != nil {
+		log.Error("停止监视源录音流失败", "error", err)
+	}
+	if err := l.stream.Close(); err != nil {
+		log.Error("关闭监视源录音流失败", "error", err)
+	}
+	l.stream = nil
+
+	log.Info("系统音频捕获已停止")
+	return nil
+}
+
+// read 从缓冲区读取捕获的音频，缓冲区为空时最多等待两个帧长
+func (l *LinuxLoopback) read(buffer []int16) (int, error) {
+	n := l.ring.ReadTimeout(buffer, l.readTimeout)
+	return n / l.config.Channels, nil
+}
+
+// getDeviceList 列出所有监视源
+func (l *LinuxLoopback) getDeviceList() ([]string, error) {
+	return listMonitorSources()
+}
+
+// listMonitorSources 通过pactl列出PulseAudio/PipeWire中的所有.monitor源
+func listMonitorSources() ([]string, error) {
+	out, err := exec.Command("pactl", "list", "short", "sources").Output()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, errors.New("未找到pactl，请安装PulseAudio或pipewire-pulse工具")
+		}
+		return nil, fmt.Errorf("获取PulseAudio音频源失败: %w", err)
+	}
+
+	// 每行格式: 索引 名称 驱动 采样格式 状态
+	var sources []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		if strings.HasSuffix(fields[1], monitorSuffix) {
+			sources = append(sources, fields[1])
+		}
+	}
+	return sources, scanner.Err()
+}
+
+// resolveMonitorSource 将配置的设备名解析为监视源名称
+//
+// 未配置时使用默认输出设备的监视源；配置为输出设备名时自动补全.monitor后缀。
+func resolveMonitorSource(device string) (string, error) {
+	if device == "" {
+		return defaultMonitorSource, nil
+	}
+
+	sources, err := listMonitorSources()
+	if err != nil {
+		// 无法列出时按原样使用，由PulseAudio在打开流时报告错误
+		log.Warn("无法校验监视源名称", "source", device, "error", err)
+		return device, nil
+	}
+
+	for _, source := range sources {
+		if source == device {
+			return device, nil
+		}
+	}
+	for _, source := range sources {
+		if source == device+monitorSuffix {
+			return source, nil
+		}
+	}
+	return "", fmt.Errorf("未找到监视源: %s，可用的监视源: %s", device, strings.Join(sources, ", "))
+}
+
+// findPulseDevice 查找通过ALSA访问PulseAudio的PortAudio输入设备
+func findPulseDevice() (*portaudio.DeviceInfo, error) {
+	devices, err := portaudio.Devices()
+	if err != nil {
+		return nil, fmt.Errorf("获取音频设备失败: %w", err)
+	}
+
+	for _, name := range pulseDeviceNames {
+		for _, device := range devices {
+			if device.Name == name && device.MaxInputChannels > 0 {
+				return device, nil
+			}
+		}
+	}
+	return nil, errors.New("未找到ALSA pulse设备，请安装alsa-plugins-pulseaudio或pipewire-alsa")
+}
+
+// withPulseSource 在设置PULSE_SOURCE的情况下打开流，完成后恢复环境变量
+//
+// 环境变量是进程全局的，持有streamMu期间其他流不会打开，不会误用监视源。
+func withPulseSource(source string, params portaudio.StreamParameters, callback interface{}) (*portaudio.Stream, error) {
+	streamMu.Lock()
+	defer streamMu.Unlock()
+
+	old, hadOld := os.LookupEnv("PULSE_SOURCE")
+	if err := os.Setenv("PULSE_SOURCE", source); err != nil {
+		return nil, fmt.Errorf("设置PULSE_SOURCE失败: %w", err)
+	}
+	defer func() {
+		if hadOld {
+			os.Setenv("PULSE_SOURCE", old)
+		} else {
+			os.Unsetenv("PULSE_SOURCE")
+		}
+	}()
+
+	return portaudio.OpenStream(params, callback)
+}
@@ -0,0 +1,127 @@
+//go:build linux
+
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nullSinkName 测试时创建的PulseAudio空输出设备名称
+const nullSinkName = "p2p_test_null_sink"
+
+// loadNullSink 创建一个空输出设备，测试结束后卸载，没有pactl或音频服务时跳过测试
+func loadNullSink(t *testing.T) {
+	t.Helper()
+
+	if _, err := exec.LookPath("pactl"); err != nil {
+		t.Skip("未找到pactl，跳过PulseAudio测试")
+	}
+	if err := exec.Command("pactl", "info").Run(); err != nil {
+		t.Skip("PulseAudio/PipeWire未运行，跳过测试")
+	}
+
+	out, err := exec.Command("pactl", "load-module", "module-null-sink", "sink_name="+nullSinkName).Output()
+	if err != nil {
+		t.Fatalf("创建空输出设备失败: %v", err)
+	}
+	module := strings.TrimSpace(string(out))
+	t.Cleanup(func() {
+		exec.Command("pactl", "unload-module", module).Run()
+	})
+}
+
+func TestResolveMonitorSourceNullSink(t *testing.T) {
+	loadNullSink(t)
+
+	sources, err := listMonitorSources()
+	if err != nil {
+		t.Fatalf("列出监视源失败: %v", err)
+	}
+	monitor := nullSinkName + monitorSuffix
+	found := false
+	for _, source := range sources {
+		if source == monitor {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("监视源列表中没有%s: %v", monitor, sources)
+	}
+
+	// 输出设备名自动补全.monitor后缀
+	for _, device := range []string{nullSinkName, monitor} {
+		source, err := resolveMonitorSource(device)
+		if err != nil {
+			t.Fatalf("解析%s失败: %v", device, err)
+		}
+		if source != monitor {
+			t.Errorf("resolveMonitorSource(%q) = %q, 期望 %q", device, source, monitor)
+		}
+	}
+
+	if _, err := resolveMonitorSource("p2p_test_missing_sink"); err == nil {
+		t.Error("不存在的设备应返回错误")
+	}
+}
+
+func TestLinuxLoopbackCaptureNullSink(t *testing.T) {
+	loadNullSink(t)
+	if _, err := exec.LookPath("pacat"); err != nil {
+		t.Skip("未找到pacat，跳过捕获测试")
+	}
+
+	config := AudioConfig{
+		LoopbackDevice: nullSinkName,
+		SampleRate:     48000,
+		Channels:       1,
+		FrameSize:      960,
+		BufferMs:       200,
+	}
+	impl, err := newLinuxLoopback(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loopback := impl.(*LinuxLoopback)
+	if err := loopback.initialize(config); err != nil {
+		t.Skipf("无法通过ALSA访问PulseAudio: %v", err)
+	}
+	if err := loopback.start(); err != nil {
+		t.Fatalf("启动捕获失败: %v", err)
+	}
+	defer loopback.stop()
+
+	// 向空输出设备播放一秒440Hz正弦波
+	tone := make([]byte, 2*config.SampleRate)
+	for i := 0; i < config.SampleRate; i++ {
+		v := int16(8000 * math.Sin(2*math.Pi*440*float64(i)/float64(config.SampleRate)))
+		binary.LittleEndian.PutUint16(tone[2*i:], uint16(v))
+	}
+	play := exec.Command("pacat", "--playback", "--raw", "--device="+nullSinkName,
+		"--format=s16le", "--rate=48000", "--channels=1")
+	play.Stdin = bytes.NewReader(tone)
+	if err := play.Start(); err != nil {
+		t.Fatalf("播放测试音频失败: %v", err)
+	}
+	defer play.Wait()
+
+	buffer := make([]int16, config.FrameSize*config.Channels)
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		n, err := loopback.read(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, sample := range buffer[:n*config.Channels] {
+			if sample != 0 {
+				return
+			}
+		}
+	}
+	t.Fatal("未从监视源捕获到声音")
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package audio
+
+import "errors"
+
+func newLinuxLoopback(config AudioConfig) (loopbackImpl, error) {
+	return nil, errors.New("Linux回环捕获仅在Linux上可用")
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/log"
@@ -22,55 +23,57 @@ const (
 	defaultBitrateKbps   = 64  // 64 kbps
 	defaultOpusComplexity = 10
 
+	// 自适应编码默认值
+	defaultMinBitrateKbps   = 16
+	defaultMaxBitrateKbps   = 128
+	defaultFECLossThreshold = 2 // 2%
+
 	// OPUS相关常量
 	maxFrameSize = 48000 * 60 / 1000 // 60ms at 48kHz
-	
-	// Opus应用类型
-	opusAppVoip = 2048 // OPUS_APPLICATION_VOIP
 )
 
 // Manager 音频管理器
 type Manager struct {
 	config      AudioConfig
-	encoder     *opus.Encoder
-	decoder     *opus.Decoder
-	audioSource AudioSource
-	audioSink   AudioSink
+	encoder     *opusEncoder
+	recorder    *Recorder // 未启用录音时为nil
 	tracks      map[string]*webrtc.TrackLocalStaticSample
 	stopChan    chan struct{}
 	mu          sync.RWMutex
 	running     bool
+
+	// 音频源和接收器可在运行时切换，需要单独加锁；与mu同时持有时先锁ioMu
+	ioMu            sync.RWMutex
+	audioSource     AudioSource
+	audioSink       AudioSink
+	preferredInput  string // 配置的输入设备
+	preferredOutput string // 配置的输出设备
+	activeInput     string // 实际使用的输入设备
+	activeOutput    string // 实际使用的输出设备
+	inputErrors     atomic.Int32
+	knownDevices    map[string]string // 设备监控协程维护的设备列表
+	eventHandlers   []func(DeviceEvent)
+	pendingEvents   []DeviceEvent // 等待释放ioMu后通知的事件
+	eventMu         sync.Mutex
+
+	// 编码器会被编码循环和自适应调整同时访问，需要单独加锁
+	encMu       sync.Mutex
+	encSettings EncoderSettings
+	encStats    PipelineStats // 编码耗时统计，由encMu保护
+
+	// 每个对端的RTCP反馈
+	feedback    map[string]*peerFeedback
+	feedbackMu  sync.Mutex
+	sentBytes   atomic.Uint64
+	sentPackets atomic.Uint64
 }
 
 // NewManager 创建新的音频管理器
 func NewManager(cfg *config.Config) (*Manager, error) {
 	// 从配置中加载音频配置
-	audioConfig := AudioConfig{
-		Enabled:       cfg.Audio.Enabled,
-		InputDevice:   cfg.Audio.InputDevice,
-		OutputDevice:  cfg.Audio.OutputDevice,
-		SampleRate:    cfg.Audio.SampleRate,
-		Channels:      cfg.Audio.Channels,
-		FrameSize:     cfg.Audio.FrameSize,
-		BitrateKbps:   cfg.Audio.BitrateKbps,
-		OpusComplexity: cfg.Audio.OpusComplexity,
-	}
-
-	// 设置默认值
-	if audioConfig.SampleRate == 0 {
-		audioConfig.SampleRate = defaultSampleRate
-	}
-	if audioConfig.Channels == 0 {
-		audioConfig.Channels = defaultChannels
-	}
-	if audioConfig.FrameSize == 0 {
-		audioConfig.FrameSize = defaultFrameSize
-	}
-	if audioConfig.BitrateKbps == 0 {
-		audioConfig.BitrateKbps = defaultBitrateKbps
-	}
-	if audioConfig.OpusComplexity == 0 {
-		audioConfig.OpusComplexity = defaultOpusComplexity
+	audioConfig, err := NewAudioConfig(cfg)
+	if err != nil {
+		return nil, err
 	}
 
 	// 创建音频接收器
@@ -79,78 +82,66 @@ func NewManager(cfg *config.Config) (*Manager, error) {
 		return nil, fmt.Errorf("创建音频接收器失败: %w", err)
 	}
 
-	// 创建适当的音频源
-	var audioSource AudioSource
-	
-	// 判断是否需要捕获系统音频
-	if cfg.Audio.CaptureSystem {
-		log.Info("配置为捕获系统音频")
-		
-		if cfg.Audio.MixWithMic {
-			log.Info("将系统音频与麦克风混合")
-			
-			// 创建麦克风音频源
-			micSource, err := NewAudioSource(audioConfig)
-			if err != nil {
-				return nil, fmt.Errorf("创建麦克风音频源失败: %w", err)
-			}
-			
-			// 创建系统音频源
-			sysSource, err := NewLoopbackSource(audioConfig)
-			if err != nil {
-				log.Warn("创建系统音频源失败，只使用麦克风", "error", err)
-				audioSource = micSource
-			} else {
-				// 创建混合音频源
-				audioSource, err = NewMixerSource(audioConfig, []AudioSource{micSource, sysSource})
-				if err != nil {
-					return nil, fmt.Errorf("创建混合音频源失败: %w", err)
-				}
-			}
-		} else {
-			// 只捕获系统音频
-			audioSource, err = NewLoopbackSource(audioConfig)
-			if err != nil {
-				return nil, fmt.Errorf("创建系统音频源失败: %w", err)
-			}
-		}
-	} else {
-		// 创建标准麦克风音频源
-		audioSource, err = NewAudioSource(audioConfig)
-		if err != nil {
-			return nil, fmt.Errorf("创建音频源失败: %w", err)
-		}
+	// 创建音频源
+	audioSource, err := newCaptureSource(audioConfig)
+	if err != nil {
+		return nil, err
 	}
 
 	// 创建Opus编码器
-	encoder, err := opus.NewEncoder(audioConfig.SampleRate, audioConfig.Channels, opusAppVoip)
+	encoder, err := newOpusEncoder(audioConfig.SampleRate, audioConfig.Channels, opusApplications[audioConfig.OpusApplication])
 	if err != nil {
 		return nil, fmt.Errorf("创建Opus编码器失败: %w", err)
 	}
 
 	// 设置编码器参数
-	encoder.SetBitrate(1000 * audioConfig.BitrateKbps)
-	encoder.SetComplexity(audioConfig.OpusComplexity)
+	if err := configureEncoder(encoder, audioConfig); err != nil {
+		return nil, fmt.Errorf("配置Opus编码器失败: %w", err)
+	}
 
-	// 创建Opus解码器
-	decoder, err := opus.NewDecoder(audioConfig.SampleRate, audioConfig.Channels)
-	if err != nil {
-		return nil, fmt.Errorf("创建Opus解码器失败: %w", err)
+	// 创建录音器
+	var recorder *Recorder
+	if cfg.Audio.RecordDir != "" {
+		recorder, err = NewRecorder(RecorderConfig{
+			Dir:        cfg.Audio.RecordDir,
+			LocalID:    cfg.Client.ID,
+			Mix:        cfg.Audio.RecordMix,
+			Rotate:     time.Duration(cfg.Audio.RecordRotateMinutes) * time.Minute,
+			SampleRate: audioConfig.SampleRate,
+			Channels:   audioConfig.Channels,
+		})
+		if err != nil {
+			return nil, err
+		}
+		log.Info("通话录音已启用", "dir", cfg.Audio.RecordDir, "mix", cfg.Audio.RecordMix)
 	}
 
 	return &Manager{
 		config:      audioConfig,
 		encoder:     encoder,
-		decoder:     decoder,
-		audioSource: audioSource,
-		audioSink:   audioSink,
+		recorder:    recorder,
+		audioSource:     audioSource,
+		audioSink:       audioSink,
+		preferredInput:  audioConfig.InputDevice,
+		preferredOutput: audioConfig.OutputDevice,
+		activeInput:     audioConfig.InputDevice,
+		activeOutput:    audioConfig.OutputDevice,
 		tracks:      make(map[string]*webrtc.TrackLocalStaticSample),
 		stopChan:    make(chan struct{}),
+		encSettings: EncoderSettings{
+			BitrateKbps:    audioConfig.BitrateKbps,
+			FEC:            audioConfig.OpusFEC,
+			PacketLossPerc: audioConfig.OpusPacketLossPerc,
+			FrameSize:      audioConfig.FrameSize,
+		},
+		feedback: make(map[string]*peerFeedback),
 	}, nil
 }
 
 // Start 开始音频处理
 func (m *Manager) Start() error {
+	m.ioMu.Lock()
+	defer m.ioMu.Unlock()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -174,7 +165,13 @@ func (m *Manager) Start() error {
 	}
 
 	m.running = true
-	go m.captureAndEncodeLoop()
+	go m.captureAndEncodeLoop(m.stopChan)
+	if m.config.AdaptiveBitrate {
+		go m.adaptationLoop(m.stopChan)
+	}
+	if !IsFileDevice(m.config.InputDevice) || !IsFileDevice(m.config.OutputDevice) {
+		go m.deviceMonitorLoop(m.stopChan)
+	}
 
 	log.Info("音频系统已启动", "sampleRate", m.config.SampleRate, "channels", m.config.Channels)
 	return nil
@@ -182,6 +179,8 @@ func (m *Manager) Start() error {
 
 // Stop 停止音频处理
 func (m *Manager) Stop() error {
+	m.ioMu.Lock()
+	defer m.ioMu.Unlock()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -194,6 +193,9 @@ func (m *Manager) Stop() error {
 	// 停止音频源和接收器
 	m.audioSource.Stop()
 	m.audioSink.Stop()
+	if m.recorder != nil {
+		m.recorder.Close()
+	}
 
 	// 重置状态
 	m.running = false
@@ -203,8 +205,8 @@ func (m *Manager) Stop() error {
 	return nil
 }
 
-// AddTrack 添加音频轨道到WebRTC PeerConnection
-func (m *Manager) AddTrack(pc *webrtc.PeerConnection) (*webrtc.TrackLocalStaticSample, error) {
+// AddTrack 添加音频轨道到与peerID对应的WebRTC PeerConnection
+func (m *Manager) AddTrack(peerID string, pc *webrtc.PeerConnection) (*webrtc.TrackLocalStaticSample, error) {
 	if !m.config.Enabled {
 		return nil, errors.New("音频功能未启用")
 	}
@@ -214,12 +216,7 @@ func (m *Manager) AddTrack(pc *webrtc.PeerConnection) (*webrtc.TrackLocalStaticS
 
 	// 创建音频轨道
 	audioTrack, err := webrtc.NewTrackLocalStaticSample(
-		webrtc.RTPCodecCapability{
-			MimeType:    webrtc.MimeTypeOpus,
-			ClockRate:   uint32(m.config.SampleRate),
-			Channels:    uint16(m.config.Channels),
-			SDPFmtpLine: "minptime=10;useinbandfec=1",
-		},
+		m.CodecCapability(),
 		"audio",
 		"mediadevices",
 	)
@@ -233,48 +230,60 @@ func (m *Manager) AddTrack(pc *webrtc.PeerConnection) (*webrtc.TrackLocalStaticS
 		return nil, fmt.Errorf("添加音频轨道失败: %w", err)
 	}
 
-	// 处理RTCP包
-	go func() {
-		rtcpBuf := make([]byte, 1500)
-		for {
-			if _, _, rtcpErr := rtpSender.Read(rtcpBuf); rtcpErr != nil {
-				if rtcpErr == io.EOF {
-					return
-				}
-				log.Error("读取RTCP包失败", "error", rtcpErr)
-			}
-		}
-	}()
+	// 处理RTCP反馈
+	go m.readRTCP(peerID, rtpSender, audioTrack)
 
 	// 保存轨道
-	peerID := pc.ConnectionState().String() // 使用连接状态字符串作为ID
 	m.tracks[peerID] = audioTrack
 
 	log.Info("添加音频轨道", "peerID", peerID)
 	return audioTrack, nil
 }
 
-// OnTrack 处理接收到的音频轨道
-func (m *Manager) OnTrack(track *webrtc.TrackRemote) {
+// CodecCapability 返回与当前编码配置一致的Opus编解码能力，用于注册到MediaEngine
+func (m *Manager) CodecCapability() webrtc.RTPCodecCapability {
+	return codecCapability(m.config)
+}
+
+// SetPeerSpace 记录对端所在的空间，用于录音元数据
+func (m *Manager) SetPeerSpace(peerID, spaceID string) {
+	if m.recorder != nil {
+		m.recorder.SetPeerSpace(peerID, spaceID)
+	}
+}
+
+// OnTrack 处理从peerID收到的音频轨道
+func (m *Manager) OnTrack(peerID string, track *webrtc.TrackRemote) {
 	if !m.config.Enabled {
 		return
 	}
 
 	// 仅处理Opus音频
-	if track.Codec().MimeType != webrtc.MimeTypeOpus {
+	codec := track.Codec()
+	if codec.MimeType != webrtc.MimeTypeOpus {
 		return
 	}
 
-	log.Info("收到音频轨道", "trackID", track.ID(), "mimetype", track.Codec().MimeType)
+	log.Info("收到音频轨道", "peerID", peerID, "trackID", track.ID(), "mimetype", codec.MimeType)
+
+	// 每个轨道使用独立的解码器，解码状态不能在多个流之间共享
+	decoder, err := opus.NewDecoder(m.config.SampleRate, m.config.Channels)
+	if err != nil {
+		log.Error("创建Opus解码器失败", "error", err)
+		return
+	}
 
-	// 创建缓冲区接收RTP包
-	buf := make([]byte, maxFrameSize*2) // 足够大的缓冲区
-	pcmBuf := make([]int16, m.config.FrameSize*m.config.Channels)
+	// Opus包最长120ms
+	pcmBuf := make([]int16, m.config.SampleRate*120/1000*m.config.Channels)
 
 	go func() {
+		if m.recorder != nil {
+			defer m.recorder.ClosePeer(peerID)
+		}
+
 		for {
 			// 读取RTP包
-			n, _, readErr := track.Read(buf)
+			packet, _, readErr := track.ReadRTP()
 			if readErr != nil {
 				if readErr == io.EOF {
 					return
@@ -283,12 +292,17 @@ func (m *Manager) OnTrack(track *webrtc.TrackRemote) {
 				continue
 			}
 
-			if n == 0 {
+			if len(packet.Payload) == 0 {
 				continue
 			}
 
+			// 录制原始Opus包
+			if m.recorder != nil {
+				m.recorder.WriteRTP(peerID, packet, codec.ClockRate, codec.Channels)
+			}
+
 			// 解码Opus数据
-			samplesDecoded, decodeErr := m.decoder.Decode(buf[:n], pcmBuf)
+			samplesDecoded, decodeErr := decoder.Decode(packet.Payload, pcmBuf)
 			if decodeErr != nil {
 				log.Error("解码音频失败", "error", decodeErr)
 				continue
@@ -298,38 +312,69 @@ func (m *Manager) OnTrack(track *webrtc.TrackRemote) {
 				continue
 			}
 
-			// 播放解码后的PCM数据
-			m.audioSink.Write(pcmBuf[:samplesDecoded*m.config.Channels])
+			pcm := pcmBuf[:samplesDecoded*m.config.Channels]
+			if m.recorder != nil {
+				m.recorder.WritePCM(peerID, pcm)
+			}
+
+			// 播放解码后的PCM数据，切换设备时等待切换完成
+			m.ioMu.RLock()
+			m.audioSink.Write(pcm)
+			m.ioMu.RUnlock()
 		}
 	}()
 }
 
 // captureAndEncodeLoop 捕获和编码音频循环
-func (m *Manager) captureAndEncodeLoop() {
-	// 创建PCM和编码后的缓冲区
-	pcmBuf := make([]int16, m.config.FrameSize*m.config.Channels)
+func (m *Manager) captureAndEncodeLoop(stop <-chan struct{}) {
+	// 创建PCM和编码后的缓冲区，按最大帧大小分配以便自适应调整帧长
+	pcmBuf := make([]int16, m.config.MaxFrameSize*m.config.Channels)
 	encodedBuf := make([]byte, maxFrameSize*2)
+	filled := 0
 
 	for {
 		select {
-		case <-m.stopChan:
+		case <-stop:
 			return
 		default:
+			// Opus只能编码完整的帧，先凑齐当前帧大小的样本
+			frameSize := m.currentFrameSize()
+			frameLen := frameSize * m.config.Channels
+			if filled > frameLen {
+				filled = 0
+			}
+
 			// 读取音频数据
-			samplesRead, err := m.audioSource.Read(pcmBuf)
+			m.ioMu.RLock()
+			samplesRead, err := m.audioSource.Read(pcmBuf[filled:frameLen])
+			m.ioMu.RUnlock()
 			if err != nil {
-				log.Error("读取音频数据失败", "error", err)
+				// 设备失效时由设备监控协程重新打开，只记录第一次错误
+				if m.inputErrors.Add(1) == 1 {
+					log.Error("读取音频数据失败", "error", err)
+				}
 				time.Sleep(10 * time.Millisecond)
 				continue
 			}
+			m.inputErrors.Store(0)
 
-			if samplesRead == 0 {
-				time.Sleep(10 * time.Millisecond)
+			filled += samplesRead * m.config.Channels
+			if filled < frameLen {
+				// PortAudio设备的Read会阻塞等待数据，其他音频源没有数据时稍后重试
+				if samplesRead == 0 {
+					time.Sleep(5 * time.Millisecond)
+				}
 				continue
 			}
+			filled = 0
+			samplesRead = frameSize
 
 			// 编码音频数据
-			n, err := m.encoder.Encode(pcmBuf[:samplesRead*m.config.Channels], encodedBuf)
+			m.encMu.Lock()
+			encodeStart := time.Now()
+			n, err := m.encoder.Encode(pcmBuf[:frameLen], encodedBuf)
+			m.recordEncode(time.Since(encodeStart), err)
+			m.encMu.Unlock()
 			if err != nil {
 				log.Error("编码音频数据失败", "error", err)
 				continue
@@ -350,6 +395,14 @@ func (m *Manager) captureAndEncodeLoop() {
 			// 计算样本持续时间
 			sampleDuration := time.Duration(samplesRead) * time.Second / time.Duration(m.config.SampleRate)
 
+			m.sentBytes.Add(uint64(n))
+			m.sentPackets.Add(1)
+
+			// 录制本地编码的Opus包
+			if m.recorder != nil {
+				m.recorder.WriteLocal(packet.Data, pcmBuf[:frameLen])
+			}
+
 			// 将编码后的数据发送到所有轨道
 			m.mu.RLock()
 			for _, track := range m.tracks {
@@ -363,8 +416,27 @@ func (m *Manager) captureAndEncodeLoop() {
 			m.mu.RUnlock()
 
 			// 帧间延迟，避免CPU占用过高
-			frameDuration := time.Duration(1000*m.config.FrameSize/m.config.SampleRate) * time.Millisecond
+			frameDuration := time.Duration(1000*frameSize/m.config.SampleRate) * time.Millisecond
 			time.Sleep(frameDuration / 2) // 减少一半等待时间，确保不会跳帧
 		}
 	}
-} 
\ No newline at end of file
+} 
+
+// recordEncode 记录一次编码的耗时，调用方需持有encMu
+func (m *Manager) recordEncode(elapsed time.Duration, err error) {
+	if err != nil {
+		m.encStats.EncodeErrors++
+		return
+	}
+
+	us := elapsed.Microseconds()
+	if m.encStats.EncodedFrames == 0 {
+		m.encStats.EncodeTimeUs = float64(us)
+	} else {
+		m.encStats.EncodeTimeUs += (float64(us) - m.encStats.EncodeTimeUs) * 0.05
+	}
+	if us > m.encStats.MaxEncodeUs {
+		m.encStats.MaxEncodeUs = us
+	}
+	m.encStats.EncodedFrames++
+}
@@ -0,0 +1,244 @@
+package audio
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+
+	"client/config"
+)
+
+// writeToneWAV 生成一个440Hz正弦波WAV文件
+func writeToneWAV(t *testing.T, path string, sampleRate, channels int, duration time.Duration) {
+	t.Helper()
+
+	frames := int(int64(sampleRate) * int64(duration) / int64(time.Second))
+	data := make([]byte, frames*channels*wavBytesPerSample)
+	for i := 0; i < frames; i++ {
+		v := int16(8000 * math.Sin(2*math.Pi*440*float64(i)/float64(sampleRate)))
+		for c := 0; c < channels; c++ {
+			binary.LittleEndian.PutUint16(data[(i*channels+c)*wavBytesPerSample:], uint16(v))
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := writeWAVHeader(file, sampleRate, channels, int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readWAVSamples 读取WAV文件中的全部样本
+func readWAVSamples(t *testing.T, path string) []int16 {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	format, err := readWAVHeader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := make([]byte, format.dataSize)
+	if _, err := io.ReadFull(file, raw); err != nil {
+		t.Fatal(err)
+	}
+	samples := make([]int16, len(raw)/wavBytesPerSample)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(raw[i*wavBytesPerSample:]))
+	}
+	return samples
+}
+
+// newFileManager 创建使用文件作为输入输出的音频管理器
+func newFileManager(t *testing.T, id, input, output string) *Manager {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.Client.ID = id
+	cfg.Audio.Enabled = true
+	cfg.Audio.InputDevice = fileDevicePrefix + input
+	cfg.Audio.OutputDevice = fileDevicePrefix + output
+	cfg.Audio.FileLoop = true
+	m, err := NewManager(cfg)
+	if err != nil {
+		t.Fatalf("创建音频管理器失败: %v", err)
+	}
+	return m
+}
+
+// newPeerConnection 创建只注册了m的Opus编码的PeerConnection
+func newPeerConnection(t *testing.T, m *Manager) *webrtc.PeerConnection {
+	t.Helper()
+
+	mediaEngine := &webrtc.MediaEngine{}
+	if err := mediaEngine.RegisterCodec(webrtc.RTPCodecParameters{
+		RTPCodecCapability: m.CodecCapability(),
+		PayloadType:        111,
+	}, webrtc.RTPCodecTypeAudio); err != nil {
+		t.Fatal(err)
+	}
+	api := webrtc.NewAPI(webrtc.WithMediaEngine(mediaEngine))
+	pc, err := api.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	return pc
+}
+
+// connect 在两个本地PeerConnection之间完成offer/answer交换
+func connect(t *testing.T, offerer, answerer *webrtc.PeerConnection) {
+	t.Helper()
+
+	offer, err := offerer.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gathered := webrtc.GatheringCompletePromise(offerer)
+	if err := offerer.SetLocalDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	<-gathered
+	if err := answerer.SetRemoteDescription(*offerer.LocalDescription()); err != nil {
+		t.Fatal(err)
+	}
+
+	answer, err := answerer.CreateAnswer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gathered = webrtc.GatheringCompletePromise(answerer)
+	if err := answerer.SetLocalDescription(answer); err != nil {
+		t.Fatal(err)
+	}
+	<-gathered
+	if err := offerer.SetRemoteDescription(*answerer.LocalDescription()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestManagerFileLoopback 将WAV文件经过两个音频管理器和本地PeerConnection传输，检查接收端输出了声音
+func TestManagerFileLoopback(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.wav")
+	writeToneWAV(t, input, defaultSampleRate, defaultChannels, time.Second)
+
+	sender := newFileManager(t, "sender", input, filepath.Join(dir, "sender_out.wav"))
+	receiverOut := filepath.Join(dir, "receiver_out.wav")
+	receiver := newFileManager(t, "receiver", input, receiverOut)
+
+	senderPC := newPeerConnection(t, sender)
+	receiverPC := newPeerConnection(t, receiver)
+	receiverPC.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		receiver.OnTrack("sender", track)
+	})
+
+	if _, err := sender.AddTrack("receiver", senderPC); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := receiverPC.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio,
+		webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionRecvonly}); err != nil {
+		t.Fatal(err)
+	}
+	connect(t, senderPC, receiverPC)
+
+	if err := receiver.Start(); err != nil {
+		t.Fatalf("启动接收端失败: %v", err)
+	}
+	defer receiver.Stop()
+	if err := sender.Start(); err != nil {
+		t.Fatalf("启动发送端失败: %v", err)
+	}
+	defer sender.Stop()
+
+	// 等待接收端写出至少半秒音频
+	want := int64(wavHeaderSize + defaultSampleRate/2*defaultChannels*wavBytesPerSample)
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if info, err := os.Stat(receiverOut); err == nil && info.Size() >= want {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	sender.Stop()
+	receiver.Stop()
+
+	for _, sample := range readWAVSamples(t, receiverOut) {
+		if sample != 0 {
+			return
+		}
+	}
+	t.Fatal("接收端没有输出声音")
+}
+
+// TestOggFileRoundTrip 写入Ogg/Opus文件后重新读取
+func TestOggFileRoundTrip(t *testing.T) {
+	config := AudioConfig{
+		SampleRate: defaultSampleRate,
+		Channels:   defaultChannels,
+		FrameSize:  defaultFrameSize,
+	}
+	path := filepath.Join(t.TempDir(), "tone.ogg")
+
+	writer, err := newOggFileWriter(path, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := config.SampleRate / 2
+	samples := make([]int16, frames*config.Channels)
+	for i := 0; i < frames; i++ {
+		v := int16(8000 * math.Sin(2*math.Pi*440*float64(i)/float64(config.SampleRate)))
+		for c := 0; c < config.Channels; c++ {
+			samples[i*config.Channels+c] = v
+		}
+	}
+	if err := writer.write(samples); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := newOggFileReader(path, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	buffer := make([]int16, config.FrameSize*config.Channels)
+	total, nonZero := 0, false
+	for {
+		n, err := reader.read(buffer)
+		for _, sample := range buffer[:n*config.Channels] {
+			if sample != 0 {
+				nonZero = true
+			}
+		}
+		total += n
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if total == 0 || !nonZero {
+		t.Fatalf("读取到%d帧，非零样本: %v", total, nonZero)
+	}
+}
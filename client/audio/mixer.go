@@ -2,58 +2,135 @@ package audio
 
 import (
 	"errors"
+	"math"
 	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	// 混音增益范围(dB)
+	minMixerGainDB = -60.0
+	maxMixerGainDB = 20.0
+
+	// 闪避默认值
+	defaultDuckingDB          = 12  // 压低12dB
+	defaultDuckingThresholdDB = -45 // 麦克风RMS电平超过-45dBFS视为有语音
+
+	duckingAttack  = 10 * time.Millisecond  // 开始压低的时间常数
+	duckingRelease = 400 * time.Millisecond // 恢复音量的时间常数
+	duckingHold    = 300 * time.Millisecond // 语音结束后保持压低的时长
+
+	mixerMaxLatency = 200 * time.Millisecond // 每个输入最多缓存的音频时长
+	mixerMaxLag     = 60 * time.Millisecond  // 每个输入超出本次输出的数据最多保留的时长
+	softClipKnee    = 0.8                    // 软削波开始压缩的电平(相对满幅)
 )
 
+// MixerInput 混音器的一路输入
+type MixerInput struct {
+	Source AudioSource
+	GainDB float64 // 增益(dB)
+	Muted  bool    // 是否静音
+	Voice  bool    // 是否用于语音检测(通常为麦克风)
+	Ducked bool    // 检测到语音时是否压低(通常为系统音频)
+}
+
+// mixerInput 带独立环形缓冲区的混音输入
+//
+// 每个输入由单独的协程读取并写入缓冲区，Read是唯一的读取者，
+// 慢速或阻塞的音频源不会拖慢其他输入。
+type mixerInput struct {
+	MixerInput
+	gain float32 // 线性增益，由MixerSource.mutex保护
+
+	ring     *ringBuffer
+	scratch  []int16 // 本次混音取出的样本
+	lastWarn time.Time
+}
+
 // MixerSource 混合多个音频源的音频源
+//
+// 没有固定的基准输入：任一输入缓存满一帧时即输出，所有输入都不足一帧时按墙上时钟
+// 在帧截止时间输出一帧。数据不足的输入补静音，积压过多时丢弃最旧的数据，
+// 因此停顿或阻塞的输入不会让其他输入也无声，各输入保持时间对齐。
+// 混合结果经过软削波，避免多路叠加时产生硬削波失真。
 type MixerSource struct {
-	config      AudioConfig
-	sources     []AudioSource
-	buffer      []int16
-	mixBuffer   []int32 // 使用32位整数避免混合时溢出
-	bufferSize  int
-	mutex       sync.Mutex
-	running     bool
+	config    AudioConfig
+	inputs    []*mixerInput
+	mixBuffer []float32
+	mutex     sync.Mutex
+	running   bool
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+
+	notify chan struct{} // 任一输入写入数据后通知Read，容量为1
+	next   time.Time     // 已输出的音频按墙上时钟播完的时间，由mutex保护
+	now    func() time.Time
+
+	// 闪避状态
+	duckGain      float32 // 当前施加到Ducked输入的增益
+	duckFloor     float32 // 完全压低时的增益
+	voiceLevel    float32 // 判定为语音的RMS电平(满幅为1)
+	lastVoiceTime time.Time
 }
 
 // NewMixerSource 创建新的混合音频源
-func NewMixerSource(config AudioConfig, sources []AudioSource) (AudioSource, error) {
-	if len(sources) == 0 {
+func NewMixerSource(config AudioConfig, inputs []MixerInput) (*MixerSource, error) {
+	if len(inputs) == 0 {
 		return nil, errors.New("至少需要一个音频源")
 	}
-	
-	// 创建缓冲区
-	bufferSize := config.FrameSize * config.Channels
-	
-	return &MixerSource{
+
+	m := &MixerSource{
 		config:     config,
-		sources:    sources,
-		buffer:     make([]int16, bufferSize),
-		mixBuffer:  make([]int32, bufferSize),
-		bufferSize: bufferSize,
-	}, nil
+		duckGain:   1,
+		duckFloor:  float32(dbToGain(-config.DuckingDB)),
+		voiceLevel: float32(dbToGain(config.DuckingThresholdDB)),
+		notify:     make(chan struct{}, 1),
+		now:        time.Now,
+	}
+	if !config.Ducking {
+		m.duckFloor = 1
+	}
+	for _, input := range inputs {
+		m.inputs = append(m.inputs, &mixerInput{
+			MixerInput: input,
+			gain:       float32(dbToGain(input.GainDB)),
+			ring:       newRingBuffer(int(mixerMaxLatency/time.Millisecond), config.SampleRate, config.Channels),
+		})
+	}
+	return m, nil
 }
 
 // Start 启动所有音频源
 func (m *MixerSource) Start() error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
-	
+
 	if m.running {
 		return nil
 	}
-	
+
 	// 启动所有音频源
-	for _, source := range m.sources {
-		if err := source.Start(); err != nil {
+	for i, input := range m.inputs {
+		if err := input.Source.Start(); err != nil {
 			// 停止已启动的源
-			for _, s := range m.sources {
-				s.Stop()
+			for _, started := range m.inputs[:i] {
+				started.Source.Stop()
 			}
 			return err
 		}
+		input.ring.Reset()
 	}
-	
+
+	m.stopChan = make(chan struct{})
+	for _, input := range m.inputs {
+		m.wg.Add(1)
+		go m.pullLoop(input, m.stopChan)
+	}
+
+	m.duckGain = 1
+	m.next = time.Time{}
 	m.running = true
 	return nil
 }
@@ -61,83 +138,284 @@ func (m *MixerSource) Start() error {
 // Stop 停止所有音频源
 func (m *MixerSource) Stop() error {
 	m.mutex.Lock()
-	defer m.mutex.Unlock()
-	
 	if !m.running {
+		m.mutex.Unlock()
 		return nil
 	}
-	
-	// 停止所有音频源
-	for _, source := range m.sources {
-		source.Stop()
-	}
-	
 	m.running = false
+	close(m.stopChan)
+	m.mutex.Unlock()
+
+	// 等待读取协程退出后再停止音频源
+	m.wg.Wait()
+	for _, input := range m.inputs {
+		input.Source.Stop()
+	}
 	return nil
 }
 
-// Read 从所有音频源读取并混合
-func (m *MixerSource) Read(buffer []int16) (int, error) {
+// SetGain 设置第index路输入的增益(dB)
+func (m *MixerSource) SetGain(index int, gainDB float64) error {
+	if index < 0 || index >= len(m.inputs) {
+		return errors.New("无效的混音输入")
+	}
+	if gainDB < minMixerGainDB || gainDB > maxMixerGainDB {
+		return errors.New("增益超出范围")
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.inputs[index].GainDB = gainDB
+	m.inputs[index].gain = float32(dbToGain(gainDB))
+	return nil
+}
+
+// SetMuted 设置第index路输入是否静音
+func (m *MixerSource) SetMuted(index int, muted bool) error {
+	if index < 0 || index >= len(m.inputs) {
+		return errors.New("无效的混音输入")
+	}
+
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
-	
+	m.inputs[index].Muted = muted
+	return nil
+}
+
+// pullLoop 持续从音频源读取数据写入该输入的环形缓冲区
+func (m *MixerSource) pullLoop(input *mixerInput, stop <-chan struct{}) {
+	defer m.wg.Done()
+
+	buf := make([]int16, m.config.FrameSize*m.config.Channels)
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		n, err := input.Source.Read(buf)
+		if err != nil {
+			// 单个输入失败时其他输入继续工作，避免刷屏只定期记录
+			if time.Since(input.lastWarn) > 10*time.Second {
+				log.Warn("读取混音输入失败", "error", err)
+				input.lastWarn = time.Now()
+			}
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		if n == 0 {
+			time.Sleep(2 * time.Millisecond)
+			continue
+		}
+		input.ring.Write(buf[:n*m.config.Channels])
+		select {
+		case m.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Read 从所有输入的缓冲区取出对齐的数据并混合
+//
+// 任一输入缓存满一帧时立即返回；否则最多等到帧截止时间，按时钟输出一帧，
+// 没有数据的输入补静音。截止时间比上次输出的音频播完晚一帧，容忍输入的抖动。等待期间不持有锁。
+func (m *MixerSource) Read(buffer []int16) (int, error) {
+	channels := m.config.Channels
+	frames := len(buffer) / channels
+	frameSize := m.config.FrameSize
+	if frameSize > frames {
+		frameSize = frames
+	}
+
+	m.mutex.Lock()
 	if !m.running {
+		m.mutex.Unlock()
 		return 0, errors.New("混合器未启动")
 	}
-	
-	// 清空混合缓冲区
-	for i := range m.mixBuffer {
-		m.mixBuffer[i] = 0
-	}
-	
-	// 从所有源读取并混合
-	maxSamples := 0
-	for _, source := range m.sources {
-		// 读取到临时缓冲区
-		samples, err := source.Read(m.buffer)
-		if err != nil {
-			return 0, err
+	stop := m.stopChan
+	now := m.now()
+	// 第一次读取或长时间没有读取时重新计时
+	if m.next.IsZero() || now.Sub(m.next) > mixerMaxLatency {
+		m.next = now
+	}
+	deadline := m.next.Add(frameDuration(m.config.FrameSize, m.config.SampleRate))
+	m.mutex.Unlock()
+
+	if frames == 0 {
+		return 0, nil
+	}
+
+	timer := time.NewTimer(deadline.Sub(now))
+	defer timer.Stop()
+	for waiting := true; waiting; {
+		if available := m.maxAvailable() / channels; available >= frameSize {
+			if available < frames {
+				frames = available
+			}
+			break
 		}
-		
-		// 更新最大样本数
-		if samples > maxSamples {
-			maxSamples = samples
+		select {
+		case <-m.notify:
+		case <-timer.C:
+			// 所有输入都不足一帧，按时钟输出一帧
+			frames = frameSize
+			waiting = false
+		case <-stop:
+			return 0, nil
 		}
-		
-		// 混合音频（简单相加，可能需要音量调整）
-		totalSamples := samples * m.config.Channels
-		for i := 0; i < totalSamples; i++ {
-			m.mixBuffer[i] += int32(m.buffer[i])
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !m.running {
+		return 0, errors.New("混合器未启动")
+	}
+	m.next = m.next.Add(time.Duration(frames) * time.Second / time.Duration(m.config.SampleRate))
+	if limit := m.now().Add(mixerMaxLatency); m.next.After(limit) {
+		m.next = limit
+	}
+	samples := frames * channels
+
+	// 取出各输入的数据，限制积压以保持对齐，数据不足的部分补静音
+	maxLag := int(int64(m.config.SampleRate)*int64(mixerMaxLag)/int64(time.Second)) * channels
+	voiceActive := false
+	for _, input := range m.inputs {
+		input.ring.Discard(samples + maxLag)
+		input.pop(samples)
+		if input.Voice && !input.Muted && rmsLevel(input.scratch)*input.gain >= m.voiceLevel {
+			voiceActive = true
 		}
 	}
-	
-	// 不要超出缓冲区大小
-	if maxSamples * m.config.Channels > len(buffer) {
-		maxSamples = len(buffer) / m.config.Channels
-	}
-	
-	// 将32位混合结果限制在16位范围内并复制到输出缓冲区
-	totalSamples := maxSamples * m.config.Channels
-	for i := 0; i < totalSamples; i++ {
-		// 限制在int16范围内 (-32768 到 32767)
-		if m.mixBuffer[i] > 32767 {
-			buffer[i] = 32767
-		} else if m.mixBuffer[i] < -32768 {
-			buffer[i] = -32768
-		} else {
-			buffer[i] = int16(m.mixBuffer[i])
+
+	startGain, endGain := m.updateDucking(voiceActive, frames)
+
+	if cap(m.mixBuffer) < samples {
+		m.mixBuffer = make([]float32, samples)
+	}
+	mix := m.mixBuffer[:samples]
+	for i := range mix {
+		mix[i] = 0
+	}
+
+	for _, input := range m.inputs {
+		if input.Muted {
+			continue
+		}
+		if !input.Ducked || startGain == endGain {
+			gain := input.gain
+			if input.Ducked {
+				gain *= endGain
+			}
+			for i, v := range input.scratch {
+				mix[i] += float32(v) * gain
+			}
+			continue
 		}
+		// 闪避增益在本次数据内线性过渡，避免音量突变产生杂音
+		step := (endGain - startGain) / float32(frames)
+		for i, v := range input.scratch {
+			gain := input.gain * (startGain + step*float32(i/channels))
+			mix[i] += float32(v) * gain
+		}
+	}
+
+	for i, v := range mix {
+		buffer[i] = softClip(v)
 	}
-	
-	return maxSamples, nil
+	return frames, nil
+}
+
+// updateDucking 根据语音活动更新闪避增益，返回本次数据开始和结束时的增益
+func (m *MixerSource) updateDucking(voiceActive bool, frames int) (float32, float32) {
+	start := m.duckGain
+	if m.duckFloor >= 1 {
+		return start, start
+	}
+
+	now := m.now()
+	if voiceActive {
+		m.lastVoiceTime = now
+	}
+
+	target, tau := float32(1), duckingRelease
+	if now.Sub(m.lastVoiceTime) < duckingHold {
+		target, tau = m.duckFloor, duckingAttack
+	}
+
+	duration := time.Duration(frames) * time.Second / time.Duration(m.config.SampleRate)
+	coef := float32(math.Exp(-float64(duration) / float64(tau)))
+	m.duckGain = target + (m.duckGain-target)*coef
+	return start, m.duckGain
 }
 
 // GetDeviceList 返回所有源的设备列表
 func (m *MixerSource) GetDeviceList() ([]string, error) {
-	if len(m.sources) == 0 {
-		return []string{}, nil
-	}
-	
 	// 返回第一个源的设备列表
-	return m.sources[0].GetDeviceList()
-} 
\ No newline at end of file
+	return m.inputs[0].Source.GetDeviceList()
+}
+
+// stalled 任一子音频源失效时返回true
+func (m *MixerSource) stalled(timeout time.Duration) bool {
+	for _, input := range m.inputs {
+		if checker, ok := input.Source.(streamChecker); ok && checker.stalled(timeout) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxAvailable 返回各输入中缓存最多的样本数
+func (m *MixerSource) maxAvailable() int {
+	available := 0
+	for _, input := range m.inputs {
+		if n := input.ring.Available(); n > available {
+			available = n
+		}
+	}
+	return available
+}
+
+// pop 取出n个样本到scratch，数据不足的部分补静音
+func (in *mixerInput) pop(n int) {
+	if cap(in.scratch) < n {
+		in.scratch = make([]int16, n)
+	}
+	in.scratch = in.scratch[:n]
+	in.ring.ReadFull(in.scratch)
+}
+
+// dbToGain 将分贝转换为线性增益
+func dbToGain(db float64) float64 {
+	return math.Pow(10, db/20)
+}
+
+// rmsLevel 计算样本的RMS电平，满幅为1
+func rmsLevel(samples []int16) float32 {
+	if len(samples) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range samples {
+		f := float64(v) / 32768
+		sum += f * f
+	}
+	return float32(math.Sqrt(sum / float64(len(samples))))
+}
+
+// softClip 超过拐点的电平用tanh平滑压缩到满幅以内
+func softClip(v float32) int16 {
+	const limit = 32767
+	const knee = limit * softClipKnee
+
+	a := float64(v)
+	sign := 1.0
+	if a < 0 {
+		a, sign = -a, -1
+	}
+	if a > knee {
+		a = knee + (limit-knee)*math.Tanh((a-knee)/(limit-knee))
+	}
+	return clampFloatSample(float32(sign * a))
+}
@@ -0,0 +1,250 @@
+package audio
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// toneSource 按实时速度产生固定电平样本的测试音频源
+type toneSource struct {
+	value     int16
+	frameTime time.Duration
+}
+
+func (s *toneSource) Start() error                     { return nil }
+func (s *toneSource) Stop() error                      { return nil }
+func (s *toneSource) GetDeviceList() ([]string, error) { return nil, nil }
+
+func (s *toneSource) Read(buffer []int16) (int, error) {
+	time.Sleep(s.frameTime)
+	for i := range buffer {
+		buffer[i] = s.value
+	}
+	return len(buffer), nil
+}
+
+// blockedSource Read一直阻塞到release关闭的测试音频源，模拟卡住的设备
+type blockedSource struct {
+	release chan struct{}
+}
+
+func (s *blockedSource) Start() error                     { return nil }
+func (s *blockedSource) Stop() error                      { return nil }
+func (s *blockedSource) GetDeviceList() ([]string, error) { return nil, nil }
+
+func (s *blockedSource) Read(buffer []int16) (int, error) {
+	<-s.release
+	return 0, nil
+}
+
+// mixerTestConfig 48kHz单声道、10ms帧长的混音配置
+func mixerTestConfig() AudioConfig {
+	return AudioConfig{SampleRate: 48000, Channels: 1, FrameSize: 480}
+}
+
+// startMixer 创建并启动混音器，测试结束时先释放阻塞的音频源再停止
+func startMixer(t *testing.T, config AudioConfig, inputs []MixerInput) *MixerSource {
+	t.Helper()
+	mixer, err := NewMixerSource(config, inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mixer.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		for _, input := range inputs {
+			if blocked, ok := input.Source.(*blockedSource); ok {
+				close(blocked.release)
+			}
+		}
+		mixer.Stop()
+	})
+	return mixer
+}
+
+// TestMixerStalledInput 麦克风卡住时其他输入照常输出
+func TestMixerStalledInput(t *testing.T) {
+	config := mixerTestConfig()
+	frameTime := frameDuration(config.FrameSize, config.SampleRate)
+	mixer := startMixer(t, config, []MixerInput{
+		{Source: &blockedSource{release: make(chan struct{})}, Voice: true},
+		{Source: &toneSource{value: 1000, frameTime: frameTime}},
+	})
+
+	buffer := make([]int16, config.FrameSize)
+	const reads = 20
+	audible := 0
+	start := time.Now()
+	for i := 0; i < reads; i++ {
+		n, err := mixer.Read(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			t.Fatal("有输入时混音器没有输出")
+		}
+		if buffer[n-1] == 1000 {
+			audible++
+		}
+	}
+	if audible < reads/2 {
+		t.Errorf("%d次读取中只有%d次包含系统音频", reads, audible)
+	}
+	if elapsed := time.Since(start); elapsed > 2*reads*frameTime {
+		t.Errorf("%d次读取用时%v，混音被卡住的输入拖慢", reads, elapsed)
+	}
+}
+
+// TestMixerAllInputsStalled 所有输入都卡住时按时钟输出静音
+func TestMixerAllInputsStalled(t *testing.T) {
+	config := mixerTestConfig()
+	frameTime := frameDuration(config.FrameSize, config.SampleRate)
+	mixer := startMixer(t, config, []MixerInput{
+		{Source: &blockedSource{release: make(chan struct{})}},
+		{Source: &blockedSource{release: make(chan struct{})}},
+	})
+
+	buffer := make([]int16, 2*config.FrameSize)
+	for i := range buffer {
+		buffer[i] = 1
+	}
+	start := time.Now()
+	n, err := mixer.Read(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*frameTime {
+		t.Errorf("读取用时%v，期望在帧截止时间返回", elapsed)
+	}
+	if n != config.FrameSize {
+		t.Fatalf("输出%d个采样帧，期望一帧%d", n, config.FrameSize)
+	}
+	for i, v := range buffer[:n] {
+		if v != 0 {
+			t.Fatalf("第%d个样本为%d，期望静音", i, v)
+		}
+	}
+}
+
+// TestMixerReadDoesNotHoldLock 等待数据期间可以调整增益和静音
+func TestMixerReadDoesNotHoldLock(t *testing.T) {
+	config := mixerTestConfig()
+	config.FrameSize = 4800 // 100ms，读取会等待到帧截止时间
+	mixer := startMixer(t, config, []MixerInput{
+		{Source: &blockedSource{release: make(chan struct{})}},
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mixer.Read(make([]int16, config.FrameSize))
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	if err := mixer.SetGain(0, -6); err != nil {
+		t.Fatal(err)
+	}
+	if err := mixer.SetMuted(0, true); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+		t.Error("读取在帧截止时间之前返回")
+	default:
+	}
+	<-done
+}
+
+// TestSoftClip 拐点以下不变，拐点以上平滑压缩到满幅以内
+func TestSoftClip(t *testing.T) {
+	knee := float32(32767 * softClipKnee)
+	tests := []struct {
+		in   float32
+		want int16
+	}{
+		{0, 0},
+		{1000, 1000},
+		{-1000, -1000},
+		{26000, 26000},
+		{-26000, -26000},
+	}
+	for _, tt := range tests {
+		if got := softClip(tt.in); got != tt.want {
+			t.Errorf("softClip(%v) = %d，期望%d", tt.in, got, tt.want)
+		}
+	}
+
+	// 超过拐点后单调递增但增幅变小，不超过满幅，正负对称
+	prev := softClip(knee)
+	for _, v := range []float32{knee + 1000, 32767, 50000, 100000, 1e6} {
+		got := softClip(v)
+		if got <= prev && got != 32767 {
+			t.Errorf("softClip(%v) = %d，没有单调递增", v, got)
+		}
+		if float32(got) >= v {
+			t.Errorf("softClip(%v) = %d，没有压缩", v, got)
+		}
+		if neg := softClip(-v); neg != -got {
+			t.Errorf("softClip(%v) = %d，与softClip(%v) = %d不对称", -v, neg, v, got)
+		}
+		prev = got
+	}
+	if got := softClip(1e6); got < 32700 {
+		t.Errorf("softClip(1e6) = %d，期望接近满幅", got)
+	}
+}
+
+// TestMixerDucking 有语音时按attack压低，语音结束保持一段时间后按release恢复
+func TestMixerDucking(t *testing.T) {
+	config := mixerTestConfig()
+	config.Ducking = true
+	config.DuckingDB = 12
+	mixer, err := NewMixerSource(config, []MixerInput{{Source: &toneSource{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mixer.now = func() time.Time { return now }
+	framesOf := func(d time.Duration) int { return int(int64(config.SampleRate) * int64(d) / int64(time.Second)) }
+	floor := float32(dbToGain(-12))
+	near := func(got, want float32) bool { return math.Abs(float64(got-want)) < 1e-3 }
+
+	// 经过一个attack时间常数，增益向压低值靠近1-1/e
+	start, end := mixer.updateDucking(true, framesOf(duckingAttack))
+	want := floor + (1-floor)*float32(math.Exp(-1))
+	if start != 1 || !near(end, want) {
+		t.Errorf("attack后增益为%v -> %v，期望1 -> %v", start, end, want)
+	}
+	for i := 0; i < 10; i++ {
+		_, end = mixer.updateDucking(true, framesOf(duckingAttack))
+	}
+	if !near(end, floor) {
+		t.Errorf("持续有语音时增益为%v，期望%v", end, floor)
+	}
+
+	// 保持时间内不恢复
+	now = now.Add(duckingHold / 2)
+	if _, end = mixer.updateDucking(false, framesOf(duckingRelease)); !near(end, floor) {
+		t.Errorf("保持时间内增益为%v，期望%v", end, floor)
+	}
+
+	// 保持时间过后经过一个release时间常数，恢复1-1/e
+	now = now.Add(duckingHold)
+	_, end = mixer.updateDucking(false, framesOf(duckingRelease))
+	want = 1 + (floor-1)*float32(math.Exp(-1))
+	if !near(end, want) {
+		t.Errorf("release后增益为%v，期望%v", end, want)
+	}
+
+	// 未开启闪避时增益保持不变
+	config.Ducking = false
+	plain, err := NewMixerSource(config, []MixerInput{{Source: &toneSource{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start, end := plain.updateDucking(true, framesOf(time.Second)); start != 1 || end != 1 {
+		t.Errorf("未开启闪避时增益为%v -> %v", start, end)
+	}
+}
@@ -0,0 +1,174 @@
+package audio
+
+/*
+#cgo pkg-config: opus
+#include <opus.h>
+
+static int bridge_encoder_set_bitrate(OpusEncoder *st, opus_int32 bitrate)
+{
+	return opus_encoder_ctl(st, OPUS_SET_BITRATE(bitrate));
+}
+
+static int bridge_encoder_set_complexity(OpusEncoder *st, opus_int32 complexity)
+{
+	return opus_encoder_ctl(st, OPUS_SET_COMPLEXITY(complexity));
+}
+
+static int bridge_encoder_set_inband_fec(OpusEncoder *st, opus_int32 fec)
+{
+	return opus_encoder_ctl(st, OPUS_SET_INBAND_FEC(fec));
+}
+
+static int bridge_encoder_set_packet_loss_perc(OpusEncoder *st, opus_int32 loss_perc)
+{
+	return opus_encoder_ctl(st, OPUS_SET_PACKET_LOSS_PERC(loss_perc));
+}
+
+static int bridge_encoder_set_dtx(OpusEncoder *st, opus_int32 dtx)
+{
+	return opus_encoder_ctl(st, OPUS_SET_DTX(dtx));
+}
+
+static int bridge_encoder_set_vbr(OpusEncoder *st, opus_int32 vbr)
+{
+	return opus_encoder_ctl(st, OPUS_SET_VBR(vbr));
+}
+
+static int bridge_encoder_set_vbr_constraint(OpusEncoder *st, opus_int32 constraint)
+{
+	return opus_encoder_ctl(st, OPUS_SET_VBR_CONSTRAINT(constraint));
+}
+
+static int bridge_encoder_set_signal(OpusEncoder *st, opus_int32 signal)
+{
+	return opus_encoder_ctl(st, OPUS_SET_SIGNAL(signal));
+}
+
+static int bridge_encoder_set_max_bandwidth(OpusEncoder *st, opus_int32 max_bw)
+{
+	return opus_encoder_ctl(st, OPUS_SET_MAX_BANDWIDTH(max_bw));
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"gopkg.in/hraban/opus.v2"
+)
+
+// Opus信号类型
+const (
+	opusSignalAuto  = int(C.OPUS_AUTO)
+	opusSignalVoice = int(C.OPUS_SIGNAL_VOICE)
+	opusSignalMusic = int(C.OPUS_SIGNAL_MUSIC)
+)
+
+// opusEncoder Opus编码器
+//
+// gopkg.in/hraban/opus.v2 没有提供信号类型和VBR相关的设置，
+// 因此编码器直接基于libopus实现，解码仍使用该库。
+type opusEncoder struct {
+	p        *C.OpusEncoder
+	channels int
+	// 编码器状态分配在Go堆上，由GC管理
+	mem []byte
+}
+
+// newOpusEncoder 创建新的Opus编码器
+func newOpusEncoder(sampleRate int, channels int, application opus.Application) (*opusEncoder, error) {
+	if channels != 1 && channels != 2 {
+		return nil, fmt.Errorf("Opus仅支持1或2个通道: %d", channels)
+	}
+
+	enc := &opusEncoder{channels: channels}
+	enc.mem = make([]byte, C.opus_encoder_get_size(C.int(channels)))
+	enc.p = (*C.OpusEncoder)(unsafe.Pointer(&enc.mem[0]))
+	errno := C.opus_encoder_init(enc.p, C.opus_int32(sampleRate), C.int(channels), C.int(application))
+	if errno != C.OPUS_OK {
+		return nil, opus.Error(errno)
+	}
+	return enc, nil
+}
+
+// Encode 编码一帧PCM数据，返回写入data的字节数
+func (enc *opusEncoder) Encode(pcm []int16, data []byte) (int, error) {
+	if len(pcm) == 0 || len(data) == 0 {
+		return 0, errors.New("PCM或输出缓冲区为空")
+	}
+	if len(pcm)%enc.channels != 0 {
+		return 0, errors.New("PCM样本数不是通道数的整数倍")
+	}
+
+	n := C.opus_encode(
+		enc.p,
+		(*C.opus_int16)(unsafe.Pointer(&pcm[0])),
+		C.int(len(pcm)/enc.channels),
+		(*C.uchar)(unsafe.Pointer(&data[0])),
+		C.opus_int32(cap(data)))
+	if n < 0 {
+		return 0, opus.Error(n)
+	}
+	return int(n), nil
+}
+
+// SetBitrate 设置比特率(bps)
+func (enc *opusEncoder) SetBitrate(bitrate int) error {
+	return opusCtlError(C.bridge_encoder_set_bitrate(enc.p, C.opus_int32(bitrate)))
+}
+
+// SetComplexity 设置编码复杂度(0-10)
+func (enc *opusEncoder) SetComplexity(complexity int) error {
+	return opusCtlError(C.bridge_encoder_set_complexity(enc.p, C.opus_int32(complexity)))
+}
+
+// SetInBandFEC 设置是否启用带内FEC
+func (enc *opusEncoder) SetInBandFEC(fec bool) error {
+	return opusCtlError(C.bridge_encoder_set_inband_fec(enc.p, cBool(fec)))
+}
+
+// SetPacketLossPerc 设置预期丢包率(0-100)
+func (enc *opusEncoder) SetPacketLossPerc(lossPerc int) error {
+	return opusCtlError(C.bridge_encoder_set_packet_loss_perc(enc.p, C.opus_int32(lossPerc)))
+}
+
+// SetDTX 设置是否启用不连续传输
+func (enc *opusEncoder) SetDTX(dtx bool) error {
+	return opusCtlError(C.bridge_encoder_set_dtx(enc.p, cBool(dtx)))
+}
+
+// SetVBR 设置是否使用可变比特率
+func (enc *opusEncoder) SetVBR(vbr bool) error {
+	return opusCtlError(C.bridge_encoder_set_vbr(enc.p, cBool(vbr)))
+}
+
+// SetVBRConstraint 设置是否使用受限可变比特率
+func (enc *opusEncoder) SetVBRConstraint(constrained bool) error {
+	return opusCtlError(C.bridge_encoder_set_vbr_constraint(enc.p, cBool(constrained)))
+}
+
+// SetSignal 设置信号类型提示
+func (enc *opusEncoder) SetSignal(signal int) error {
+	return opusCtlError(C.bridge_encoder_set_signal(enc.p, C.opus_int32(signal)))
+}
+
+// SetMaxBandwidth 设置最大音频带宽
+func (enc *opusEncoder) SetMaxBandwidth(maxBw opus.Bandwidth) error {
+	return opusCtlError(C.bridge_encoder_set_max_bandwidth(enc.p, C.opus_int32(maxBw)))
+}
+
+func cBool(b bool) C.opus_int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func opusCtlError(res C.int) error {
+	if res != C.OPUS_OK {
+		return opus.Error(res)
+	}
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/gordonklaus/portaudio"
@@ -16,36 +18,55 @@ func init() {
 	}
 }
 
+// streamMu 串行化PortAudio流的打开和重新初始化
+//
+// Linux回环捕获在打开流期间临时设置PULSE_SOURCE，其他流不能在此期间打开，
+// 否则会错误地连接到监视源。
+var streamMu sync.Mutex
+
+// openStream 打开PortAudio流，与回环捕获和设备刷新互斥
+func openStream(params portaudio.StreamParameters, callback interface{}) (*portaudio.Stream, error) {
+	streamMu.Lock()
+	defer streamMu.Unlock()
+	return portaudio.OpenStream(params, callback)
+}
+
 // PortAudioSource 基于PortAudio的音频源实现
+//
+// 设备以其原生格式打开，捕获的数据转换为配置的采样率和通道数后写入无锁环形缓冲区，
+// PortAudio回调中不加锁。
 type PortAudioSource struct {
-	config      AudioConfig
-	stream      *portaudio.Stream
-	deviceInfo  *portaudio.DeviceInfo
-	buffer      []int16
-	bufferSize  int
-	readPos     int
-	writePos    int
-	bufferCount int
-	mutex       sync.Mutex
-	running     bool
+	config       AudioConfig
+	stream       *portaudio.Stream
+	deviceInfo   *portaudio.DeviceInfo
+	format       deviceFormat
+	converter    *formatConverter // 只在回调中使用
+	ring         *ringBuffer
+	readTimeout  time.Duration
+	mutex        sync.Mutex // 保护Start/Stop
+	running      atomic.Bool
+	lastCallback atomic.Int64 // 最后一次回调的时间(UnixNano)，用于检测设备失效
 }
 
 // NewAudioSource 创建新的音频源
 func NewAudioSource(config AudioConfig) (AudioSource, error) {
+	// 以file:开头的设备名从文件读取
+	if IsFileDevice(config.InputDevice) {
+		return NewFileSource(config)
+	}
+
 	// 获取音频设备
 	deviceInfo, err := getAudioDevice(config.InputDevice, true)
 	if err != nil {
 		return nil, err
 	}
 
-	// 缓冲区大小设置为5帧
-	bufferSize := config.FrameSize * config.Channels * 5
-
 	return &PortAudioSource{
-		config:     config,
-		deviceInfo: deviceInfo,
-		buffer:     make([]int16, bufferSize),
-		bufferSize: bufferSize,
+		config:      config,
+		deviceInfo:  deviceInfo,
+		format:      nativeFormat(deviceInfo, deviceInfo.MaxInputChannels, config),
+		ring:        newRingBuffer(config.BufferMs, config.SampleRate, config.Channels),
+		readTimeout: 2 * frameDuration(config.FrameSize, config.SampleRate),
 	}, nil
 }
 
@@ -54,58 +75,46 @@ func (s *PortAudioSource) Start() error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	if s.running {
+	if s.running.Load() {
 		return nil
 	}
 
-	// 创建输入流参数
+	// 创建输入流参数，使用设备的原生格式
 	inputParams := portaudio.StreamParameters{
 		Input: portaudio.StreamDeviceParameters{
 			Device:   s.deviceInfo,
-			Channels: s.config.Channels,
+			Channels: s.format.Channels,
 			Latency:  s.deviceInfo.DefaultLowInputLatency,
 		},
-		SampleRate:      float64(s.config.SampleRate),
-		FramesPerBuffer: s.config.FrameSize,
+		SampleRate:      float64(s.format.SampleRate),
+		FramesPerBuffer: s.format.FrameSize,
 	}
+	s.converter = newFormatConverter(s.format.SampleRate, s.format.Channels, s.config.SampleRate, s.config.Channels, s.config.ResampleQuality)
+	s.ring.Reset()
 
-	// 创建回调函数
+	// 创建回调函数，转换为配置的格式后写入缓冲区
 	callback := func(in []int16, _ []int16) {
-		s.mutex.Lock()
-		defer s.mutex.Unlock()
-
-		// 写入缓冲区
-		for i := 0; i < len(in); i++ {
-			s.buffer[s.writePos] = in[i]
-			s.writePos = (s.writePos + 1) % s.bufferSize
-			if s.writePos == s.readPos {
-				// 缓冲区已满，移动读指针
-				s.readPos = (s.readPos + 1) % s.bufferSize
-			} else {
-				s.bufferCount++
-			}
-		}
+		s.lastCallback.Store(time.Now().UnixNano())
+		s.ring.Write(s.converter.process(in))
 	}
 
 	// 创建音频流
-	stream, err := portaudio.OpenStream(inputParams, callback)
+	stream, err := openStream(inputParams, callback)
 	if err != nil {
 		return fmt.Errorf("打开音频输入流失败: %w", err)
 	}
 
 	// 启动流
+	s.lastCallback.Store(time.Now().UnixNano())
 	if err := stream.Start(); err != nil {
 		stream.Close()
 		return fmt.Errorf("启动音频输入流失败: %w", err)
 	}
 
 	s.stream = stream
-	s.running = true
-	s.readPos = 0
-	s.writePos = 0
-	s.bufferCount = 0
+	s.running.Store(true)
 
-	log.Info("音频输入已启动", "device", s.deviceInfo.Name)
+	log.Info("音频输入已启动", "device", s.deviceInfo.Name, "sampleRate", s.format.SampleRate, "channels", s.format.Channels)
 	return nil
 }
 
@@ -114,9 +123,10 @@ func (s *PortAudioSource) Stop() error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	if !s.running {
+	if !s.running.Load() {
 		return nil
 	}
+	s.running.Store(false)
 
 	// 停止并关闭流
 	if s.stream != nil {
@@ -129,40 +139,18 @@ func (s *PortAudioSource) Stop() error {
 		s.stream = nil
 	}
 
-	s.running = false
 	log.Info("音频输入已停止")
 	return nil
 }
 
-// Read 读取音频帧
+// Read 读取音频帧，缓冲区为空时最多等待两个帧长
 func (s *PortAudioSource) Read(buffer []int16) (int, error) {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
-	if !s.running {
+	if !s.running.Load() {
 		return 0, errors.New("音频输入未启动")
 	}
 
-	// 计算可读取的样本数
-	available := s.bufferCount
-	if available == 0 {
-		return 0, nil
-	}
-
-	// 确保不超过缓冲区大小
-	toRead := len(buffer)
-	if toRead > available {
-		toRead = available
-	}
-
-	// 读取数据
-	for i := 0; i < toRead; i++ {
-		buffer[i] = s.buffer[s.readPos]
-		s.readPos = (s.readPos + 1) % s.bufferSize
-		s.bufferCount--
-	}
-
-	return toRead / s.config.Channels, nil
+	n := s.ring.ReadTimeout(buffer, s.readTimeout)
+	return n / s.config.Channels, nil
 }
 
 // GetDeviceList 获取可用输入设备列表
@@ -170,36 +158,54 @@ func (s *PortAudioSource) GetDeviceList() ([]string, error) {
 	return getAudioDeviceList(true)
 }
 
+// stalled 输入流未运行或超过timeout没有回调时返回true
+func (s *PortAudioSource) stalled(timeout time.Duration) bool {
+	return !s.running.Load() || time.Since(time.Unix(0, s.lastCallback.Load())) > timeout
+}
+
+// bufferStats 返回输入缓冲区统计
+func (s *PortAudioSource) bufferStats() BufferStats {
+	return s.ring.Stats()
+}
+
 // PortAudioSink 基于PortAudio的音频接收器实现
+//
+// 写入的数据从配置的格式转换为设备的原生格式后写入无锁环形缓冲区，
+// PortAudio回调中不加锁。
 type PortAudioSink struct {
-	config     AudioConfig
-	stream     *portaudio.Stream
-	deviceInfo *portaudio.DeviceInfo
-	buffer     []int16
-	bufferSize int
-	readPos    int
-	writePos   int
-	bufferCount int
-	mutex      sync.Mutex
-	running    bool
+	config       AudioConfig
+	stream       *portaudio.Stream
+	deviceInfo   *portaudio.DeviceInfo
+	format       deviceFormat
+	converter    *formatConverter
+	ring         *ringBuffer
+	mutex        sync.Mutex // 保护Start/Stop
+	writeMu      sync.Mutex // 多个对端的解码协程同时写入，保证缓冲区只有一个生产者
+	running      atomic.Bool
+	lastCallback atomic.Int64 // 最后一次回调的时间(UnixNano)，用于检测设备失效
 }
 
 // NewAudioSink 创建新的音频接收器
 func NewAudioSink(config AudioConfig) (AudioSink, error) {
+	// 以file:开头的设备名写入文件
+	if IsFileDevice(config.OutputDevice) {
+		return NewFileSink(config)
+	}
+
 	// 获取音频设备
 	deviceInfo, err := getAudioDevice(config.OutputDevice, false)
 	if err != nil {
 		return nil, err
 	}
 
-	// 缓冲区大小设置为5帧
-	bufferSize := config.FrameSize * config.Channels * 5
+	// 缓冲区按设备格式存储
+	format := nativeFormat(deviceInfo, deviceInfo.MaxOutputChannels, config)
 
 	return &PortAudioSink{
 		config:     config,
 		deviceInfo: deviceInfo,
-		buffer:     make([]int16, bufferSize),
-		bufferSize: bufferSize,
+		format:     format,
+		ring:       newRingBuffer(config.BufferMs, format.SampleRate, format.Channels),
 	}, nil
 }
 
@@ -208,63 +214,48 @@ func (s *PortAudioSink) Start() error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	if s.running {
+	if s.running.Load() {
 		return nil
 	}
 
-	// 创建输出流参数
+	// 创建输出流参数，使用设备的原生格式
 	outputParams := portaudio.StreamParameters{
 		Output: portaudio.StreamDeviceParameters{
 			Device:   s.deviceInfo,
-			Channels: s.config.Channels,
+			Channels: s.format.Channels,
 			Latency:  s.deviceInfo.DefaultLowOutputLatency,
 		},
-		SampleRate:      float64(s.config.SampleRate),
-		FramesPerBuffer: s.config.FrameSize,
+		SampleRate:      float64(s.format.SampleRate),
+		FramesPerBuffer: s.format.FrameSize,
 	}
+	s.writeMu.Lock()
+	s.converter = newFormatConverter(s.config.SampleRate, s.config.Channels, s.format.SampleRate, s.format.Channels, s.config.ResampleQuality)
+	s.ring.Reset()
+	s.writeMu.Unlock()
 
-	// 创建回调函数
+	// 创建回调函数，数据不足时用静音填充
 	callback := func(_ []int16, out []int16) {
-		s.mutex.Lock()
-		defer s.mutex.Unlock()
-
-		// 从缓冲区读取数据
-		samplesToWrite := len(out)
-		if s.bufferCount < samplesToWrite {
-			// 缓冲区数据不足，用静音填充
-			for i := 0; i < samplesToWrite; i++ {
-				out[i] = 0
-			}
-			return
-		}
-
-		// 写出数据
-		for i := 0; i < samplesToWrite; i++ {
-			out[i] = s.buffer[s.readPos]
-			s.readPos = (s.readPos + 1) % s.bufferSize
-			s.bufferCount--
-		}
+		s.lastCallback.Store(time.Now().UnixNano())
+		s.ring.ReadFull(out)
 	}
 
 	// 创建音频流
-	stream, err := portaudio.OpenStream(outputParams, callback)
+	stream, err := openStream(outputParams, callback)
 	if err != nil {
 		return fmt.Errorf("打开音频输出流失败: %w", err)
 	}
 
 	// 启动流
+	s.lastCallback.Store(time.Now().UnixNano())
 	if err := stream.Start(); err != nil {
 		stream.Close()
 		return fmt.Errorf("启动音频输出流失败: %w", err)
 	}
 
 	s.stream = stream
-	s.running = true
-	s.readPos = 0
-	s.writePos = 0
-	s.bufferCount = 0
+	s.running.Store(true)
 
-	log.Info("音频输出已启动", "device", s.deviceInfo.Name)
+	log.Info("音频输出已启动", "device", s.deviceInfo.Name, "sampleRate", s.format.SampleRate, "channels", s.format.Channels)
 	return nil
 }
 
@@ -273,9 +264,10 @@ func (s *PortAudioSink) Stop() error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	if !s.running {
+	if !s.running.Load() {
 		return nil
 	}
+	s.running.Store(false)
 
 	// 停止并关闭流
 	if s.stream != nil {
@@ -288,35 +280,21 @@ func (s *PortAudioSink) Stop() error {
 		s.stream = nil
 	}
 
-	s.running = false
 	log.Info("音频输出已停止")
 	return nil
 }
 
-// Write 写入音频帧
+// Write 写入音频帧，缓存超过设定时长时由播放回调丢弃最旧的数据
 func (s *PortAudioSink) Write(buffer []int16) error {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
-	if !s.running {
+	if !s.running.Load() {
 		return errors.New("音频输出未启动")
 	}
 
-	// 检查缓冲区是否有足够空间
-	if len(buffer) > s.bufferSize-s.bufferCount {
-		// 缓冲区已满，丢弃旧数据
-		needed := len(buffer) - (s.bufferSize - s.bufferCount)
-		s.readPos = (s.readPos + needed) % s.bufferSize
-		s.bufferCount -= needed
-	}
-
-	// 写入数据
-	for i := 0; i < len(buffer); i++ {
-		s.buffer[s.writePos] = buffer[i]
-		s.writePos = (s.writePos + 1) % s.bufferSize
-		s.bufferCount++
-	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
 
+	// 转换为设备格式
+	s.ring.Write(s.converter.process(buffer))
 	return nil
 }
 
@@ -325,6 +303,46 @@ func (s *PortAudioSink) GetDeviceList() ([]string, error) {
 	return getAudioDeviceList(false)
 }
 
+// stalled 输出流未运行或超过timeout没有回调时返回true
+func (s *PortAudioSink) stalled(timeout time.Duration) bool {
+	return !s.running.Load() || time.Since(time.Unix(0, s.lastCallback.Load())) > timeout
+}
+
+// bufferStats 返回输出缓冲区统计
+func (s *PortAudioSink) bufferStats() BufferStats {
+	return s.ring.Stats()
+}
+
+// deviceFormat 设备打开时使用的格式
+type deviceFormat struct {
+	SampleRate int
+	Channels   int
+	FrameSize  int // 与配置帧长相同时长的设备采样帧数
+}
+
+// frameDuration 返回frameSize个采样帧的时长
+func frameDuration(frameSize, sampleRate int) time.Duration {
+	return time.Duration(frameSize) * time.Second / time.Duration(sampleRate)
+}
+
+// nativeFormat 根据设备信息确定原生格式
+//
+// 采样率使用设备的默认采样率，通道数不超过设备支持的最大通道数。
+func nativeFormat(device *portaudio.DeviceInfo, maxChannels int, config AudioConfig) deviceFormat {
+	format := deviceFormat{
+		SampleRate: int(device.DefaultSampleRate),
+		Channels:   config.Channels,
+	}
+	if format.SampleRate <= 0 {
+		format.SampleRate = config.SampleRate
+	}
+	if maxChannels > 0 && format.Channels > maxChannels {
+		format.Channels = maxChannels
+	}
+	format.FrameSize = (config.FrameSize*format.SampleRate + config.SampleRate - 1) / config.SampleRate
+	return format
+}
+
 // 获取可用音频设备列表
 func getAudioDeviceList(isInput bool) ([]string, error) {
 	devices, err := portaudio.Devices()
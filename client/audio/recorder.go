@@ -0,0 +1,413 @@
+package audio
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3/pkg/media/oggwriter"
+)
+
+const (
+	// recordTimeFormat 录音文件名中的时间格式
+	recordTimeFormat = "20060102-150405"
+	// mixDelay 混音时等待迟到数据的时长，超过该时长的样本写入文件
+	mixDelay = 500 * time.Millisecond
+)
+
+// RecorderConfig 录音配置
+type RecorderConfig struct {
+	Dir        string        // 录音目录
+	LocalID    string        // 本地客户端ID
+	Mix        bool          // 是否额外录制所有参与者混音后的WAV
+	Rotate     time.Duration // 按时长切分文件，0表示不切分
+	SampleRate int           // 本地采样率
+	Channels   int           // 本地通道数
+}
+
+// recordingMetadata 录音文件旁的JSON元数据
+type recordingMetadata struct {
+	PeerID       string     `json:"peer_id,omitempty"`
+	Participants []string   `json:"participants,omitempty"`
+	Local        bool       `json:"local,omitempty"`
+	Space        string     `json:"space,omitempty"`
+	File         string     `json:"file"`
+	Format       string     `json:"format"`
+	SampleRate   int        `json:"sample_rate"`
+	Channels     int        `json:"channels"`
+	StartTime    time.Time  `json:"start_time"`
+	EndTime      *time.Time `json:"end_time,omitempty"`
+	Packets      int        `json:"packets,omitempty"`
+}
+
+// trackRecording 单个参与者当前的录音文件
+type trackRecording struct {
+	writer    *oggwriter.OggWriter
+	meta      recordingMetadata
+	timestamp uint32 // 本地包的RTP时间戳
+}
+
+// Recorder 将通话中每个参与者的Opus包直接写入Ogg文件，不重新编码
+type Recorder struct {
+	config RecorderConfig
+	spaces map[string]string // 对端ID到空间ID
+	tracks map[string]*trackRecording
+	mix    *mixdownRecording
+	mu     sync.Mutex
+
+	now func() time.Time // 当前时间，测试时替换
+}
+
+// NewRecorder 创建录音器
+func NewRecorder(config RecorderConfig) (*Recorder, error) {
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建录音目录失败: %w", err)
+	}
+	return &Recorder{
+		config: config,
+		spaces: make(map[string]string),
+		tracks: make(map[string]*trackRecording),
+		now:    time.Now,
+	}, nil
+}
+
+// SetPeerSpace 记录对端所在的空间，写入之后创建的录音元数据
+func (r *Recorder) SetPeerSpace(peerID, spaceID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spaces[peerID] = spaceID
+}
+
+// WriteRTP 记录从对端收到的RTP包
+func (r *Recorder) WriteRTP(peerID string, packet *rtp.Packet, sampleRate uint32, channels uint16) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rec, err := r.track(peerID, false, int(sampleRate), int(channels))
+	if err != nil {
+		log.Error("创建录音文件失败", "peer_id", peerID, "error", err)
+		return
+	}
+	if err := rec.writer.WriteRTP(packet); err != nil {
+		log.Error("写入录音失败", "peer_id", peerID, "error", err)
+		return
+	}
+	rec.meta.Packets++
+}
+
+// WriteLocal 记录本地编码的Opus包，pcm为编码前的数据，用于混音
+func (r *Recorder) WriteLocal(payload []byte, pcm []int16) {
+	r.WritePCM(r.config.LocalID, pcm)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rec, err := r.track(r.config.LocalID, true, r.config.SampleRate, r.config.Channels)
+	if err != nil {
+		log.Error("创建录音文件失败", "peer_id", r.config.LocalID, "error", err)
+		return
+	}
+
+	// 本地包没有RTP头，按48kHz时钟构造时间戳
+	packet := &rtp.Packet{
+		Header:  rtp.Header{Timestamp: rec.timestamp},
+		Payload: payload,
+	}
+	samples := len(pcm) / r.config.Channels
+	rec.timestamp += uint32(samples * rtpClockRate / r.config.SampleRate)
+	if err := rec.writer.WriteRTP(packet); err != nil {
+		log.Error("写入录音失败", "peer_id", r.config.LocalID, "error", err)
+		return
+	}
+	rec.meta.Packets++
+}
+
+// WritePCM 将参与者解码后的PCM加入混音，未启用混音时忽略
+func (r *Recorder) WritePCM(peerID string, pcm []int16) {
+	if !r.config.Mix {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	if r.mix != nil && r.config.Rotate > 0 && now.Sub(r.mix.meta.StartTime) >= r.config.Rotate {
+		r.closeMix()
+	}
+	if r.mix == nil {
+		mix, err := r.newMixdown(now)
+		if err != nil {
+			log.Error("创建混音录音文件失败", "error", err)
+			return
+		}
+		r.mix = mix
+	}
+	r.mix.add(peerID, pcm, now)
+}
+
+// ClosePeer 结束对端的录音
+func (r *Recorder) ClosePeer(peerID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if rec, ok := r.tracks[peerID]; ok {
+		r.closeTrack(rec)
+		delete(r.tracks, peerID)
+	}
+}
+
+// Close 结束所有录音，之后写入的数据会创建新文件
+func (r *Recorder) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for peerID, rec := range r.tracks {
+		r.closeTrack(rec)
+		delete(r.tracks, peerID)
+	}
+	r.closeMix()
+}
+
+// track 返回参与者当前的录音文件，需要时创建或按时长切分
+func (r *Recorder) track(peerID string, local bool, sampleRate, channels int) (*trackRecording, error) {
+	now := r.now()
+	rec, ok := r.tracks[peerID]
+	if ok && r.config.Rotate > 0 && now.Sub(rec.meta.StartTime) >= r.config.Rotate {
+		r.closeTrack(rec)
+		ok = false
+	}
+	if ok {
+		return rec, nil
+	}
+
+	path := r.filePath(now, peerID, ".ogg")
+	file, err := os.Create(path)
+	if err != nil {
+		delete(r.tracks, peerID)
+		return nil, err
+	}
+	// 以流方式写入，Close时关闭文件：按文件打开时Close会回写最后一页，超过255字节的包会写坏该页
+	writer, err := oggwriter.NewWith(file, uint32(sampleRate), uint16(channels))
+	if err != nil {
+		file.Close()
+		delete(r.tracks, peerID)
+		return nil, err
+	}
+
+	rec = &trackRecording{
+		writer: writer,
+		meta: recordingMetadata{
+			PeerID:     peerID,
+			Local:      local,
+			Space:      r.spaceOf(peerID, local),
+			File:       filepath.Base(path),
+			Format:     "ogg/opus",
+			SampleRate: sampleRate,
+			Channels:   channels,
+			StartTime:  now,
+		},
+	}
+	r.tracks[peerID] = rec
+	writeRecordingMetadata(r.config.Dir, rec.meta)
+	log.Info("开始录音", "peer_id", peerID, "file", path)
+	return rec, nil
+}
+
+// closeTrack 关闭录音文件并补全元数据
+func (r *Recorder) closeTrack(rec *trackRecording) {
+	if err := rec.writer.Close(); err != nil {
+		log.Error("关闭录音文件失败", "file", rec.meta.File, "error", err)
+	}
+	end := r.now()
+	rec.meta.EndTime = &end
+	writeRecordingMetadata(r.config.Dir, rec.meta)
+	log.Info("录音结束", "peer_id", rec.meta.PeerID, "file", rec.meta.File, "packets", rec.meta.Packets)
+}
+
+// spaceOf 返回参与者所在的空间，本地参与者可能同时在多个空间中
+func (r *Recorder) spaceOf(peerID string, local bool) string {
+	if !local {
+		return r.spaces[peerID]
+	}
+	spaces := make([]string, 0, len(r.spaces))
+	seen := make(map[string]bool)
+	for _, space := range r.spaces {
+		if !seen[space] {
+			seen[space] = true
+			spaces = append(spaces, space)
+		}
+	}
+	sort.Strings(spaces)
+	return strings.Join(spaces, ",")
+}
+
+// filePath 生成录音文件路径
+func (r *Recorder) filePath(start time.Time, name, ext string) string {
+	// 避免ID中的路径分隔符
+	name = strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(name)
+	return filepath.Join(r.config.Dir, start.Format(recordTimeFormat)+"_"+name+ext)
+}
+
+// writeRecordingMetadata 写入录音文件旁的JSON元数据
+func writeRecordingMetadata(dir string, meta recordingMetadata) {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		log.Error("序列化录音元数据失败", "error", err)
+		return
+	}
+	path := filepath.Join(dir, strings.TrimSuffix(meta.File, filepath.Ext(meta.File))+".json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		log.Error("写入录音元数据失败", "file", path, "error", err)
+	}
+}
+
+// mixdownRecording 将所有参与者的PCM按到达时间对齐后混合写入WAV
+type mixdownRecording struct {
+	writer     *wavFileWriter
+	meta       recordingMetadata
+	sampleRate int
+	channels   int
+	flushed    int64            // 已写入文件的采样帧数
+	acc        []int32          // 从flushed开始尚未写入的混音累加值
+	cursors    map[string]int64 // 每个参与者下一个样本的位置
+	out        []int16
+}
+
+// newMixdown 创建混音录音
+func (r *Recorder) newMixdown(now time.Time) (*mixdownRecording, error) {
+	path := r.filePath(now, "mix", ".wav")
+	writer, err := newWAVFileWriter(path, AudioConfig{
+		SampleRate: r.config.SampleRate,
+		Channels:   r.config.Channels,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mix := &mixdownRecording{
+		writer: writer,
+		meta: recordingMetadata{
+			Space:      r.spaceOf(r.config.LocalID, true),
+			File:       filepath.Base(path),
+			Format:     "wav",
+			SampleRate: r.config.SampleRate,
+			Channels:   r.config.Channels,
+			StartTime:  now,
+		},
+		sampleRate: r.config.SampleRate,
+		channels:   r.config.Channels,
+		cursors:    make(map[string]int64),
+	}
+	writeRecordingMetadata(r.config.Dir, mix.meta)
+	log.Info("开始混音录音", "file", path)
+	return mix, nil
+}
+
+// closeMix 写入剩余的混音数据并关闭文件
+func (r *Recorder) closeMix() {
+	if r.mix == nil {
+		return
+	}
+	mix := r.mix
+	r.mix = nil
+
+	mix.flush(mix.flushed + int64(len(mix.acc)/mix.channels))
+	if err := mix.writer.Close(); err != nil {
+		log.Error("关闭混音录音文件失败", "file", mix.meta.File, "error", err)
+	}
+	end := r.now()
+	mix.meta.EndTime = &end
+	writeRecordingMetadata(r.config.Dir, mix.meta)
+	log.Info("混音录音结束", "file", mix.meta.File)
+}
+
+// add 将参与者的PCM叠加到对应时间位置
+func (m *mixdownRecording) add(peerID string, pcm []int16, now time.Time) {
+	if _, ok := m.cursors[peerID]; !ok {
+		m.meta.Participants = append(m.meta.Participants, peerID)
+	}
+
+	// 参与者的数据与到达时间偏差过大时(丢包或暂停)重新对齐
+	position := int64(now.Sub(m.meta.StartTime) * time.Duration(m.sampleRate) / time.Second)
+	frames := int64(len(pcm) / m.channels)
+	cursor, ok := m.cursors[peerID]
+	if !ok || cursor+frames < position-int64(m.sampleRate)/5 || cursor > position+int64(m.sampleRate) {
+		// 数据在到达时刻结束；混音开始时到达的第一包从0开始，不能整包落在开始之前
+		cursor = position - frames
+		if cursor < 0 {
+			cursor = 0
+		}
+	}
+	m.cursors[peerID] = cursor + frames
+
+	// 已写入文件的部分无法再叠加
+	if cursor < m.flushed {
+		skip := m.flushed - cursor
+		if skip >= frames {
+			return
+		}
+		pcm = pcm[skip*int64(m.channels):]
+		cursor = m.flushed
+	}
+
+	// 先写出等待时间之前的数据，避免累加缓冲区无限增长
+	m.flush(position - int64(mixDelay)*int64(m.sampleRate)/int64(time.Second))
+
+	offset := int(cursor-m.flushed) * m.channels
+	if need := offset + len(pcm); need > len(m.acc) {
+		m.acc = append(m.acc, make([]int32, need-len(m.acc))...)
+	}
+	for i, sample := range pcm {
+		m.acc[offset+i] += int32(sample)
+	}
+}
+
+// flush 将upTo之前的混音数据写入文件，没有数据的部分写入静音
+func (m *mixdownRecording) flush(upTo int64) {
+	for m.flushed < upTo {
+		frames := upTo - m.flushed
+		// 每次最多写入1秒，长时间静音分多次写入
+		if frames > int64(m.sampleRate) {
+			frames = int64(m.sampleRate)
+		}
+		n := int(frames) * m.channels
+		if cap(m.out) < n {
+			m.out = make([]int16, n)
+		}
+		out := m.out[:n]
+
+		available := len(m.acc)
+		if available > n {
+			available = n
+		}
+		for i := 0; i < available; i++ {
+			out[i] = clampSample(m.acc[i])
+		}
+		clear(out[available:])
+		m.acc = m.acc[available:]
+
+		if err := m.writer.write(out); err != nil {
+			log.Error("写入混音录音失败", "error", err)
+		}
+		m.flushed += frames
+	}
+}
+
+// clampSample 将累加值限制在int16范围内
+func clampSample(v int32) int16 {
+	if v > 32767 {
+		return 32767
+	}
+	if v < -32768 {
+		return -32768
+	}
+	return int16(v)
+}
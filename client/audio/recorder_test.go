@@ -0,0 +1,210 @@
+package audio
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3/pkg/media/oggreader"
+)
+
+// newTestRecorder 创建使用手动时钟的录音器，返回的函数用于推进时钟
+func newTestRecorder(t *testing.T, config RecorderConfig) (*Recorder, func(time.Duration)) {
+	t.Helper()
+	config.Dir = t.TempDir()
+	recorder, err := NewRecorder(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	recorder.now = func() time.Time { return now }
+	return recorder, func(d time.Duration) { now = now.Add(d) }
+}
+
+// recordingFiles 返回录音目录中扩展名为ext的文件，按文件名排序
+func recordingFiles(t *testing.T, dir, ext string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*"+ext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// readRecordingMetadata 读取录音文件旁的JSON元数据
+func readRecordingMetadata(t *testing.T, path string) recordingMetadata {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var meta recordingMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		t.Fatal(err)
+	}
+	return meta
+}
+
+// readOggPackets 读取Ogg文件的所有页并校验，返回每页的负载长度
+func readOggPackets(t *testing.T, path string) []int {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	ogg, _, err := oggreader.NewWith(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sizes []int
+	for {
+		payload, _, err := ogg.ParseNextPage()
+		if errors.Is(err, io.EOF) {
+			return sizes
+		}
+		if err != nil {
+			t.Fatalf("%s 第%d页读取失败: %v", filepath.Base(path), len(sizes)+1, err)
+		}
+		// 跳过OpusTags页
+		if len(payload) >= 8 && string(payload[:8]) == "OpusTags" {
+			continue
+		}
+		sizes = append(sizes, len(payload))
+	}
+}
+
+func opusPacket(timestamp uint32, size int) *rtp.Packet {
+	return &rtp.Packet{
+		Header:  rtp.Header{Timestamp: timestamp},
+		Payload: make([]byte, size),
+	}
+}
+
+// TestRecorderRotate 超过切分时长后写入新文件，最后一个超过255字节的包不会写坏文件
+func TestRecorderRotate(t *testing.T) {
+	recorder, advance := newTestRecorder(t, RecorderConfig{Rotate: time.Minute, SampleRate: 48000, Channels: 2})
+
+	recorder.WriteRTP("peer", opusPacket(0, 100), 48000, 2)
+	advance(30 * time.Second)
+	recorder.WriteRTP("peer", opusPacket(960, 400), 48000, 2)
+	advance(30 * time.Second)
+	recorder.WriteRTP("peer", opusPacket(1920, 100), 48000, 2)
+	recorder.WriteRTP("peer", opusPacket(2880, 300), 48000, 2)
+	recorder.Close()
+
+	files := recordingFiles(t, recorder.config.Dir, ".ogg")
+	if len(files) != 2 {
+		t.Fatalf("录音文件为%v，期望按时长切分为2个", files)
+	}
+	want := [][]int{{100, 400}, {100, 300}}
+	for i, file := range files {
+		sizes := readOggPackets(t, file)
+		if len(sizes) != len(want[i]) || sizes[0] != want[i][0] || sizes[1] != want[i][1] {
+			t.Errorf("%s 的包长度为%v，期望%v", filepath.Base(file), sizes, want[i])
+		}
+	}
+}
+
+// TestRecorderMetadata 元数据记录参与者、空间、格式、起止时间和包数
+func TestRecorderMetadata(t *testing.T) {
+	recorder, advance := newTestRecorder(t, RecorderConfig{SampleRate: 48000, Channels: 1})
+	start := recorder.now()
+	recorder.SetPeerSpace("peer", "space-1")
+
+	recorder.WriteRTP("peer", opusPacket(0, 80), 48000, 2)
+
+	// 录音进行中的元数据没有结束时间
+	files := recordingFiles(t, recorder.config.Dir, ".json")
+	if len(files) != 1 {
+		t.Fatalf("元数据文件为%v", files)
+	}
+	if meta := readRecordingMetadata(t, files[0]); meta.EndTime != nil {
+		t.Errorf("录音进行中的结束时间为%v", meta.EndTime)
+	}
+
+	recorder.WriteRTP("peer", opusPacket(960, 80), 48000, 2)
+	advance(10 * time.Second)
+	recorder.ClosePeer("peer")
+
+	meta := readRecordingMetadata(t, files[0])
+	if meta.PeerID != "peer" || meta.Space != "space-1" || meta.Local {
+		t.Errorf("参与者信息不正确: %+v", meta)
+	}
+	if meta.Format != "ogg/opus" || meta.SampleRate != 48000 || meta.Channels != 2 || meta.Packets != 2 {
+		t.Errorf("格式信息不正确: %+v", meta)
+	}
+	if filepath.Join(recorder.config.Dir, meta.File) != recordingFiles(t, recorder.config.Dir, ".ogg")[0] {
+		t.Errorf("元数据中的文件为%s", meta.File)
+	}
+	if !meta.StartTime.Equal(start) || meta.EndTime == nil || !meta.EndTime.Equal(start.Add(10*time.Second)) {
+		t.Errorf("起止时间为%v - %v", meta.StartTime, meta.EndTime)
+	}
+}
+
+// TestRecorderMixdownAlignment 混音按到达时间对齐，同时到达的数据叠加，间隔处写入静音
+func TestRecorderMixdownAlignment(t *testing.T) {
+	const frames = 480 // 48kHz下10ms
+	recorder, advance := newTestRecorder(t, RecorderConfig{Mix: true, SampleRate: 48000, Channels: 1})
+	frame := func(v int16) []int16 {
+		pcm := make([]int16, frames)
+		for i := range pcm {
+			pcm[i] = v
+		}
+		return pcm
+	}
+
+	// 第一包从混音开始处写入
+	recorder.WritePCM("a", frame(1000))
+	recorder.WritePCM("b", frame(2000))
+	advance(10 * time.Millisecond)
+	recorder.WritePCM("a", frame(1000))
+	// b迟到40ms，在抖动范围内，紧接上一包
+	advance(40 * time.Millisecond)
+	recorder.WritePCM("b", frame(500))
+	// 中断300ms后按到达时刻重新对齐，中间写入静音
+	advance(300 * time.Millisecond)
+	recorder.WritePCM("a", frame(30000))
+	recorder.WritePCM("b", frame(30000))
+	recorder.Close()
+
+	files := recordingFiles(t, recorder.config.Dir, ".wav")
+	if len(files) != 1 {
+		t.Fatalf("混音文件为%v", files)
+	}
+	samples := readWAVSamples(t, files[0])
+	total := 48000 * 350 / 1000
+	if len(samples) != total {
+		t.Fatalf("混音长度为%d，期望%d", len(samples), total)
+	}
+
+	want := []struct {
+		from, to int
+		value    int16
+	}{
+		{0, frames, 3000},               // a和b同时到达
+		{frames, 2 * frames, 1500},      // a的第2包和迟到的b
+		{2 * frames, total - frames, 0}, // 中断期间为静音
+		{total - frames, total, 32767},  // 重新对齐后叠加并限幅
+	}
+	for _, w := range want {
+		for i := w.from; i < w.to; i++ {
+			if samples[i] != w.value {
+				t.Fatalf("第%d个样本为%d，期望%d", i, samples[i], w.value)
+			}
+		}
+	}
+
+	meta := readRecordingMetadata(t, recordingFiles(t, recorder.config.Dir, ".json")[0])
+	if len(meta.Participants) != 2 || meta.Participants[0] != "a" || meta.Participants[1] != "b" {
+		t.Errorf("混音参与者为%v", meta.Participants)
+	}
+}
@@ -0,0 +1,265 @@
+package audio
+
+import (
+	"math"
+)
+
+// 重采样质量，质量越高使用的滤波器越长，CPU占用越高
+const (
+	ResampleQualityLow    = "low"    // 线性插值
+	ResampleQualityMedium = "medium" // 16抽头加窗sinc
+	ResampleQualityHigh   = "high"   // 64抽头加窗sinc
+)
+
+// resampleHalfTaps 各质量对应的sinc滤波器单侧抽头数
+var resampleHalfTaps = map[string]int{
+	ResampleQualityLow:    1,
+	ResampleQualityMedium: 8,
+	ResampleQualityHigh:   32,
+}
+
+// sincPhases 滤波器表中每个采样间隔的相位数，相位之间线性插值
+const sincPhases = 256
+
+// Resampler 流式采样率转换器，处理交错的int16样本
+//
+// 低质量使用线性插值，其余使用Blackman窗sinc插值。
+// 降采样时降低截止频率以避免混叠。
+type Resampler struct {
+	inRate   int
+	outRate  int
+	channels int
+	halfTaps int
+	step     float64   // 每个输出样本对应的输入样本数
+	kernel   []float32 // 半个sinc滤波器，按sincPhases过采样
+	scale    float32   // 降采样时的增益补偿
+
+	history []float32 // 尚未完全使用的输入样本(交错)
+	pos     float64   // 下一个输出样本在history中的位置(采样帧)
+	out     []int16
+}
+
+// NewResampler 创建采样率转换器
+func NewResampler(inRate, outRate, channels int, quality string) *Resampler {
+	halfTaps, ok := resampleHalfTaps[quality]
+	if !ok {
+		halfTaps = resampleHalfTaps[ResampleQualityMedium]
+	}
+
+	r := &Resampler{
+		inRate:   inRate,
+		outRate:  outRate,
+		channels: channels,
+		halfTaps: halfTaps,
+		step:     float64(inRate) / float64(outRate),
+		scale:    1,
+	}
+
+	if halfTaps > 1 {
+		// 降采样时截止频率为输出的奈奎斯特频率
+		cutoff := 1.0
+		if outRate < inRate {
+			cutoff = float64(outRate) / float64(inRate)
+		}
+		r.scale = float32(cutoff)
+
+		r.kernel = make([]float32, halfTaps*sincPhases+1)
+		for i := range r.kernel {
+			x := float64(i) / sincPhases
+			r.kernel[i] = float32(sinc(x*cutoff) * blackman(x/float64(halfTaps)))
+		}
+	}
+
+	// 开头补零，使第一个输出样本有完整的左侧上下文
+	r.history = make([]float32, (halfTaps-1)*channels)
+	r.pos = float64(halfTaps - 1)
+	return r
+}
+
+// Process 转换一段输入样本，返回的切片在下次调用前有效
+func (r *Resampler) Process(in []int16) []int16 {
+	if r.inRate == r.outRate {
+		return in
+	}
+
+	for _, sample := range in {
+		r.history = append(r.history, float32(sample))
+	}
+
+	channels := r.channels
+	frames := len(r.history) / channels
+	r.out = r.out[:0]
+
+	// 输出样本需要其右侧halfTaps个输入样本
+	for {
+		base := int(r.pos)
+		if base+r.halfTaps >= frames {
+			break
+		}
+		frac := r.pos - float64(base)
+		for ch := 0; ch < channels; ch++ {
+			var v float32
+			if r.kernel == nil {
+				a := r.history[base*channels+ch]
+				b := r.history[(base+1)*channels+ch]
+				v = a + (b-a)*float32(frac)
+			} else {
+				v = r.interpolate(base, frac, ch)
+			}
+			r.out = append(r.out, clampFloatSample(v))
+		}
+		r.pos += r.step
+	}
+
+	// 丢弃不再需要的输入样本
+	if drop := int(r.pos) - r.halfTaps + 1; drop > 0 {
+		if drop > frames {
+			drop = frames
+		}
+		r.history = append(r.history[:0], r.history[drop*channels:]...)
+		r.pos -= float64(drop)
+	}
+	return r.out
+}
+
+// interpolate 计算位置base+frac处单个通道的sinc插值
+func (r *Resampler) interpolate(base int, frac float64, ch int) float32 {
+	channels := r.channels
+	var sum float32
+	// 左侧样本: 距离为frac, frac+1, ...
+	for i := 0; i < r.halfTaps; i++ {
+		sum += r.history[(base-i)*channels+ch] * r.kernelAt(frac+float64(i))
+	}
+	// 右侧样本: 距离为1-frac, 2-frac, ...
+	for i := 1; i <= r.halfTaps; i++ {
+		sum += r.history[(base+i)*channels+ch] * r.kernelAt(float64(i)-frac)
+	}
+	return sum * r.scale
+}
+
+// kernelAt 查表获取距离为x处的滤波器系数
+func (r *Resampler) kernelAt(x float64) float32 {
+	p := x * sincPhases
+	i := int(p)
+	if i >= len(r.kernel)-1 {
+		return 0
+	}
+	f := float32(p - float64(i))
+	return r.kernel[i] + (r.kernel[i+1]-r.kernel[i])*f
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// blackman 区间[0,1]上的半个Blackman窗，0为中心
+func blackman(x float64) float64 {
+	if x >= 1 {
+		return 0
+	}
+	return 0.42 + 0.5*math.Cos(math.Pi*x) + 0.08*math.Cos(2*math.Pi*x)
+}
+
+func clampFloatSample(v float32) int16 {
+	if v >= 32767 {
+		return 32767
+	}
+	if v <= -32768 {
+		return -32768
+	}
+	return int16(math.Round(float64(v)))
+}
+
+// remixChannels 转换通道数，返回写入out的采样帧数
+//
+// 单声道扩展为多通道时复制到每个通道；多通道缩减为单声道时取平均；
+// 其余情况按通道对应复制，多出的输出通道填充静音。
+func remixChannels(in []int16, inChannels int, out []int16, outChannels int) int {
+	frames := len(in) / inChannels
+	if n := len(out) / outChannels; frames > n {
+		frames = n
+	}
+
+	switch {
+	case inChannels == outChannels:
+		copy(out, in[:frames*inChannels])
+	case inChannels == 1:
+		for i := 0; i < frames; i++ {
+			for ch := 0; ch < outChannels; ch++ {
+				out[i*outChannels+ch] = in[i]
+			}
+		}
+	case outChannels == 1:
+		for i := 0; i < frames; i++ {
+			var sum int32
+			for ch := 0; ch < inChannels; ch++ {
+				sum += int32(in[i*inChannels+ch])
+			}
+			out[i] = int16(sum / int32(inChannels))
+		}
+	default:
+		for i := 0; i < frames; i++ {
+			for ch := 0; ch < outChannels; ch++ {
+				if ch < inChannels {
+					out[i*outChannels+ch] = in[i*inChannels+ch]
+				} else {
+					out[i*outChannels+ch] = 0
+				}
+			}
+		}
+	}
+	return frames
+}
+
+// formatConverter 在两种采样率和通道数之间转换
+type formatConverter struct {
+	inChannels  int
+	outChannels int
+	resampler   *Resampler
+	remixed     []int16
+}
+
+// newFormatConverter 创建格式转换器，格式相同时返回nil
+func newFormatConverter(inRate, inChannels, outRate, outChannels int, quality string) *formatConverter {
+	if inRate == outRate && inChannels == outChannels {
+		return nil
+	}
+
+	// 通道数较少的一侧做重采样以减少计算量
+	resampleChannels := outChannels
+	if inChannels < outChannels {
+		resampleChannels = inChannels
+	}
+	return &formatConverter{
+		inChannels:  inChannels,
+		outChannels: outChannels,
+		resampler:   NewResampler(inRate, outRate, resampleChannels, quality),
+	}
+}
+
+// process 转换交错样本，返回的切片在下次调用前有效
+func (c *formatConverter) process(in []int16) []int16 {
+	if c == nil {
+		return in
+	}
+
+	if c.inChannels < c.outChannels {
+		return c.remix(c.resampler.Process(in), c.inChannels, c.outChannels)
+	}
+	return c.resampler.Process(c.remix(in, c.inChannels, c.outChannels))
+}
+
+func (c *formatConverter) remix(in []int16, inChannels, outChannels int) []int16 {
+	if inChannels == outChannels {
+		return in
+	}
+	n := len(in) / inChannels * outChannels
+	if cap(c.remixed) < n {
+		c.remixed = make([]int16, n)
+	}
+	frames := remixChannels(in, inChannels, c.remixed[:n], outChannels)
+	return c.remixed[:frames*outChannels]
+}
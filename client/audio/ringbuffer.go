@@ -0,0 +1,201 @@
+package audio
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultBufferMs = 100  // 默认缓冲时长(毫秒)
+	maxBufferMs     = 2000 // 最大缓冲时长(毫秒)
+)
+
+// BufferStats 环形缓冲区统计
+type BufferStats struct {
+	CapacityMs int    `json:"capacity_ms"` // 容量(毫秒)
+	FillMs     int    `json:"fill_ms"`     // 当前缓存的音频时长(毫秒)
+	Overruns   uint64 `json:"overruns"`    // 缓存超过容量导致丢弃数据的次数
+	Underruns  uint64 `json:"underruns"`   // 数据不足导致补静音的次数
+}
+
+// bufferStatser 使用环形缓冲区的音频源或接收器
+type bufferStatser interface {
+	bufferStats() BufferStats
+}
+
+// ringBuffer 单生产者/单消费者的无锁环形缓冲区
+//
+// 写入和读取各自只由一个协程(或PortAudio回调线程)调用，双方通过原子的读写位置同步，
+// 实时回调中不需要加锁。读写都以采样帧为单位，不会拆开一个帧的各个通道。
+//
+// 生产者不能移动消费者的读位置，因此实际容量是设定时长的两倍，由消费者在每次读取前
+// 丢弃超过设定时长的最旧数据，保持延迟有界。只有消费者长时间停止读取导致缓冲区写满时，
+// 才会丢弃新写入的数据。
+type ringBuffer struct {
+	buf        []int16
+	mask       uint64
+	limit      int // 消费者保留的最大样本数
+	channels   int
+	sampleRate int
+
+	writePos atomic.Uint64 // 只由生产者修改
+	readPos  atomic.Uint64 // 只由消费者修改
+
+	overruns  atomic.Uint64
+	underruns atomic.Uint64
+
+	// 写入后通知阻塞中的读取者，容量为1，发送不阻塞
+	notify chan struct{}
+}
+
+// newRingBuffer 创建保留capacityMs毫秒音频的环形缓冲区
+//
+// 底层容量为两倍时长并向上取整到2的幂。
+func newRingBuffer(capacityMs, sampleRate, channels int) *ringBuffer {
+	samples := sampleRate * capacityMs / 1000 * channels
+	samples -= samples % channels
+	size := 1
+	for size < 2*samples {
+		size <<= 1
+	}
+	return &ringBuffer{
+		buf:        make([]int16, size),
+		mask:       uint64(size - 1),
+		limit:      samples,
+		channels:   channels,
+		sampleRate: sampleRate,
+		notify:     make(chan struct{}, 1),
+	}
+}
+
+// Write 写入样本，返回写入的样本数；空间不足时丢弃放不下的部分并记录一次溢出
+//
+// 只能由生产者调用。
+func (r *ringBuffer) Write(samples []int16) int {
+	write := r.writePos.Load()
+	free := len(r.buf) - int(write-r.readPos.Load())
+	free -= free % r.channels
+
+	n := len(samples)
+	if n > free {
+		n = free
+		r.overruns.Add(1)
+	}
+	if n == 0 {
+		return 0
+	}
+
+	start := int(write & r.mask)
+	copied := copy(r.buf[start:], samples[:n])
+	copy(r.buf, samples[copied:n])
+	r.writePos.Store(write + uint64(n))
+
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+	return n
+}
+
+// Read 读取最多len(out)个样本，不阻塞，返回读取的样本数
+//
+// 缓存超过设定时长时先丢弃最旧的数据并记录一次溢出。只能由消费者调用。
+func (r *ringBuffer) Read(out []int16) int {
+	if r.Discard(r.limit) > 0 {
+		r.overruns.Add(1)
+	}
+
+	read := r.readPos.Load()
+	n := int(r.writePos.Load() - read)
+	if n > len(out) {
+		n = len(out)
+	}
+	n -= n % r.channels
+	if n == 0 {
+		return 0
+	}
+
+	start := int(read & r.mask)
+	copied := copy(out[:n], r.buf[start:])
+	copy(out[copied:n], r.buf)
+	r.readPos.Store(read + uint64(n))
+	return n
+}
+
+// ReadTimeout 读取最多len(out)个样本，缓冲区为空时最多等待timeout
+func (r *ringBuffer) ReadTimeout(out []int16, timeout time.Duration) int {
+	r.Wait(timeout)
+	return r.Read(out)
+}
+
+// Wait 等待缓冲区中有数据，超时返回false，只能由消费者调用
+func (r *ringBuffer) Wait(timeout time.Duration) bool {
+	if r.Available() > 0 {
+		return true
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-r.notify:
+			if r.Available() > 0 {
+				return true
+			}
+		case <-timer.C:
+			return r.Available() > 0
+		}
+	}
+}
+
+// ReadFull 填满out，数据不足时补静音并记录一次欠载
+//
+// 用于播放回调，返回实际读取的样本数。
+func (r *ringBuffer) ReadFull(out []int16) int {
+	n := r.Read(out)
+	if n < len(out) {
+		clear(out[n:])
+		r.underruns.Add(1)
+	}
+	return n
+}
+
+// Discard 丢弃最旧的样本直到只剩max个，返回丢弃的样本数，只能由消费者调用
+func (r *ringBuffer) Discard(max int) int {
+	read := r.readPos.Load()
+	drop := int(r.writePos.Load()-read) - max
+	drop -= drop % r.channels
+	if drop <= 0 {
+		return 0
+	}
+	r.readPos.Store(read + uint64(drop))
+	return drop
+}
+
+// Available 返回可读取的样本数
+func (r *ringBuffer) Available() int {
+	return int(r.writePos.Load() - r.readPos.Load())
+}
+
+// Reset 清空缓冲区，只能在生产者和消费者都停止时调用
+func (r *ringBuffer) Reset() {
+	r.readPos.Store(r.writePos.Load())
+	select {
+	case <-r.notify:
+	default:
+	}
+}
+
+// Stats 返回缓冲区统计
+func (r *ringBuffer) Stats() BufferStats {
+	perMs := r.sampleRate * r.channels / 1000
+	if perMs == 0 {
+		perMs = 1
+	}
+	return BufferStats{
+		CapacityMs: r.limit / perMs,
+		FillMs:     r.Available() / perMs,
+		Overruns:   r.overruns.Load(),
+		Underruns:  r.underruns.Load(),
+	}
+}
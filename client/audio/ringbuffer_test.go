@@ -0,0 +1,216 @@
+package audio
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRingBufferSPSC 一个生产者和一个消费者并发读写，检查样本顺序和环绕
+func TestRingBufferSPSC(t *testing.T) {
+	const (
+		channels = 2
+		total    = 200000 * channels
+	)
+	r := newRingBuffer(10, 48000, channels)
+
+	go func() {
+		chunk := make([]int16, 0, 333*channels)
+		next := 0
+		for next < total {
+			// 每次写入不同长度，使读写位置在缓冲区内各处环绕
+			frames := 1 + next/channels%333
+			chunk = chunk[:0]
+			for i := 0; i < frames*channels && next < total; i++ {
+				chunk = append(chunk, int16(next))
+				next++
+			}
+			// 不超过保留时长，保证不丢数据
+			for r.Available()+len(chunk) > r.limit {
+				runtime.Gosched()
+			}
+			if n := r.Write(chunk); n != len(chunk) {
+				t.Errorf("写入%d个样本，只写入了%d个", len(chunk), n)
+				return
+			}
+		}
+	}()
+
+	out := make([]int16, 257*channels)
+	received := 0
+	for received < total {
+		if !r.Wait(5 * time.Second) {
+			t.Fatalf("等待数据超时，已收到%d个样本", received)
+		}
+		n := r.Read(out)
+		if n%channels != 0 {
+			t.Fatalf("读取了%d个样本，拆开了采样帧", n)
+		}
+		for _, sample := range out[:n] {
+			if sample != int16(received) {
+				t.Fatalf("第%d个样本为%d，顺序错误", received, sample)
+			}
+			received++
+		}
+	}
+
+	if r.writePos.Load() <= uint64(len(r.buf)) {
+		t.Fatalf("写入%d个样本，没有发生环绕", r.writePos.Load())
+	}
+	if stats := r.Stats(); stats.Overruns != 0 {
+		t.Errorf("没有超过容量却记录了%d次溢出", stats.Overruns)
+	}
+}
+
+// TestRingBufferDropsOldest 缓存超过设定时长时读取前丢弃最旧的数据
+func TestRingBufferDropsOldest(t *testing.T) {
+	r := newRingBuffer(10, 48000, 1) // 保留480个样本
+	samples := make([]int16, r.limit+100)
+	for i := range samples {
+		samples[i] = int16(i)
+	}
+	if n := r.Write(samples); n != len(samples) {
+		t.Fatalf("写入%d个样本，只写入了%d个", len(samples), n)
+	}
+
+	out := make([]int16, 10)
+	if n := r.Read(out); n != len(out) {
+		t.Fatalf("读取了%d个样本", n)
+	}
+	if out[0] != 100 {
+		t.Errorf("第一个样本为%d，应丢弃最旧的100个样本", out[0])
+	}
+	if stats := r.Stats(); stats.Overruns != 1 {
+		t.Errorf("溢出次数为%d，期望1", stats.Overruns)
+	}
+}
+
+// TestRingBufferWriteFull 消费者停止时写满缓冲区只丢弃放不下的新数据
+func TestRingBufferWriteFull(t *testing.T) {
+	r := newRingBuffer(10, 48000, 2)
+	samples := make([]int16, len(r.buf)+6)
+	if n := r.Write(samples); n != len(r.buf) {
+		t.Fatalf("写入了%d个样本，期望%d", n, len(r.buf))
+	}
+	if n := r.Write(samples[:2]); n != 0 {
+		t.Fatalf("缓冲区已满仍写入了%d个样本", n)
+	}
+	if stats := r.Stats(); stats.Overruns != 2 {
+		t.Errorf("溢出次数为%d，期望2", stats.Overruns)
+	}
+}
+
+// TestRingBufferDiscard Discard按采样帧丢弃最旧的数据
+func TestRingBufferDiscard(t *testing.T) {
+	r := newRingBuffer(10, 48000, 2)
+	r.Write([]int16{1, 2, 3, 4, 5, 6, 7, 8})
+
+	if n := r.Discard(8); n != 0 {
+		t.Fatalf("数据未超过上限却丢弃了%d个样本", n)
+	}
+	// 丢弃量向下取整到整帧
+	if n := r.Discard(3); n != 4 {
+		t.Fatalf("丢弃了%d个样本，期望4", n)
+	}
+	out := make([]int16, 8)
+	if n := r.Read(out); n != 4 || out[0] != 5 {
+		t.Fatalf("读取到%v", out[:n])
+	}
+	// Discard由调用方决定是否计为溢出
+	if stats := r.Stats(); stats.Overruns != 0 {
+		t.Errorf("溢出次数为%d，期望0", stats.Overruns)
+	}
+}
+
+// TestRingBufferReadFull 数据不足时补静音并记录欠载
+func TestRingBufferReadFull(t *testing.T) {
+	r := newRingBuffer(10, 48000, 1)
+	r.Write([]int16{1, 2})
+
+	out := []int16{9, 9, 9, 9}
+	if n := r.ReadFull(out); n != 2 {
+		t.Fatalf("读取了%d个样本，期望2", n)
+	}
+	if out[2] != 0 || out[3] != 0 {
+		t.Errorf("不足部分没有补静音: %v", out)
+	}
+	if stats := r.Stats(); stats.Underruns != 1 {
+		t.Errorf("欠载次数为%d，期望1", stats.Underruns)
+	}
+}
+
+// mutexBuffer 改用环形缓冲区之前的加锁实现，用于性能对比
+type mutexBuffer struct {
+	mutex       sync.Mutex
+	buffer      []int16
+	bufferSize  int
+	readPos     int
+	writePos    int
+	bufferCount int
+}
+
+func newMutexBuffer(size int) *mutexBuffer {
+	return &mutexBuffer{buffer: make([]int16, size), bufferSize: size}
+}
+
+func (s *mutexBuffer) Write(buffer []int16) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(buffer) > s.bufferSize {
+		buffer = buffer[len(buffer)-s.bufferSize:]
+	}
+	if len(buffer) > s.bufferSize-s.bufferCount {
+		needed := len(buffer) - (s.bufferSize - s.bufferCount)
+		s.readPos = (s.readPos + needed) % s.bufferSize
+		s.bufferCount -= needed
+	}
+	for i := 0; i < len(buffer); i++ {
+		s.buffer[s.writePos] = buffer[i]
+		s.writePos = (s.writePos + 1) % s.bufferSize
+		s.bufferCount++
+	}
+}
+
+func (s *mutexBuffer) Read(buffer []int16) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	toRead := len(buffer)
+	if toRead > s.bufferCount {
+		toRead = s.bufferCount
+	}
+	for i := 0; i < toRead; i++ {
+		buffer[i] = s.buffer[s.readPos]
+		s.readPos = (s.readPos + 1) % s.bufferSize
+		s.bufferCount--
+	}
+	return toRead
+}
+
+// benchmarkBuffer 每次操作写入并读出一帧，比较两种实现搬运一帧数据的开销
+func benchmarkBuffer(b *testing.B, write func([]int16), read func([]int16) int) {
+	frame := make([]int16, defaultFrameSize*defaultChannels)
+	out := make([]int16, defaultFrameSize*defaultChannels)
+
+	b.SetBytes(int64(len(frame) * 2))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		write(frame)
+		if read(out) != len(out) {
+			b.Fatal("读出的样本数不足一帧")
+		}
+	}
+}
+
+func BenchmarkRingBuffer(b *testing.B) {
+	b.Run("ring", func(b *testing.B) {
+		r := newRingBuffer(defaultBufferMs, defaultSampleRate, defaultChannels)
+		benchmarkBuffer(b, func(s []int16) { r.Write(s) }, r.Read)
+	})
+	b.Run("mutex", func(b *testing.B) {
+		m := newMutexBuffer(defaultFrameSize * defaultChannels * 5)
+		benchmarkBuffer(b, m.Write, m.Read)
+	})
+}
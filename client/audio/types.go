@@ -1,6 +1,8 @@
 package audio
 
 import (
+	"time"
+
 	"github.com/pion/webrtc/v3"
 )
 
@@ -17,17 +19,87 @@ type AudioConfig struct {
 	Enabled       bool   // 是否启用音频
 	InputDevice   string // 输入设备
 	OutputDevice  string // 输出设备
+	CaptureSystem bool   // 是否捕获系统音频
+	MixWithMic    bool   // 系统音频是否与麦克风混合
+	LoopbackDevice string // 系统音频捕获设备
+	FileLoop      bool   // 文件输入是否循环
 	SampleRate    int    // 采样率
 	Channels      int    // 通道数
 	FrameSize     int    // 帧大小
 	BitrateKbps   int    // 比特率(kbps)
 	OpusComplexity int    // Opus编码复杂度
+	ResampleQuality string // 重采样质量(low/medium/high)
+	BufferMs        int    // 设备输入/输出缓冲区时长(毫秒)
+
+	MicGainDB          float64 // 麦克风增益(dB)
+	SystemGainDB       float64 // 系统音频增益(dB)
+	MicMute            bool    // 是否静音麦克风
+	SystemMute         bool    // 是否静音系统音频
+	Ducking            bool    // 麦克风有语音时是否压低系统音频
+	DuckingDB          float64 // 压低的幅度(dB)
+	DuckingThresholdDB float64 // 判定为语音的麦克风电平(dBFS)
+
+	OpusApplication    string // 应用模式(voip/audio/lowdelay)
+	OpusSignal         string // 信号类型(auto/voice/music)
+	OpusDTX            bool   // 是否启用不连续传输
+	OpusFEC            bool   // 是否始终启用带内FEC
+	OpusPacketLossPerc int    // 预期丢包率(%)
+	OpusBitrateMode    string // 码率模式(vbr/cvbr/cbr)
+	OpusMaxBandwidth   string // 最大带宽
+
+	AdaptiveBitrate  bool // 是否根据RTCP反馈自适应调整编码参数
+	MinBitrateKbps   int  // 自适应码率下限(kbps)
+	MaxBitrateKbps   int  // 自适应码率上限(kbps)
+	FECLossThreshold int  // 启用带内FEC的丢包率阈值(%)
+	MaxFrameSize     int  // 自适应时允许的最大帧大小
+}
+
+// EncoderSettings 当前生效的Opus编码器参数
+type EncoderSettings struct {
+	BitrateKbps    int  `json:"bitrate_kbps"`     // 比特率(kbps)
+	FEC            bool `json:"fec"`              // 是否启用带内FEC
+	PacketLossPerc int  `json:"packet_loss_perc"` // 预期丢包率(%)
+	FrameSize      int  `json:"frame_size"`       // 帧大小
+}
+
+// PeerNetworkStats 根据RTCP反馈估计的对端网络状况
+type PeerNetworkStats struct {
+	LossPercent   float64   `json:"loss_percent"`   // 平滑后的丢包率(%)
+	JitterMs      float64   `json:"jitter_ms"`      // 到达抖动(毫秒)
+	EstimatedKbps int       `json:"estimated_kbps"` // 估计的可用带宽(kbps)，0表示未知
+	LastFeedback  time.Time `json:"last_feedback"`  // 最后一次收到反馈的时间
+}
+
+// PipelineStats 音频采集编码流水线计数
+type PipelineStats struct {
+	EncodedFrames uint64  `json:"encoded_frames"` // 已编码的帧数
+	EncodeErrors  uint64  `json:"encode_errors"`  // 编码失败的次数
+	EncodeTimeUs  float64 `json:"encode_time_us"` // 平滑后的单帧编码耗时(微秒)
+	MaxEncodeUs   int64   `json:"max_encode_us"`  // 单帧编码耗时的最大值(微秒)
+	SentPackets   uint64  `json:"sent_packets"`   // 已发送的音频包数
+	SentBytes     uint64  `json:"sent_bytes"`     // 已发送的编码数据字节数
+}
+
+// Stats 音频管理器统计信息
+type Stats struct {
+	Encoder      EncoderSettings             `json:"encoder"`
+	Pipeline     PipelineStats               `json:"pipeline"`
+	Peers        map[string]PeerNetworkStats `json:"peers"`
+	InputBuffer  *BufferStats                `json:"input_buffer,omitempty"`  // 输入设备缓冲区，非PortAudio设备时为空
+	OutputBuffer *BufferStats                `json:"output_buffer,omitempty"` // 输出设备缓冲区，非PortAudio设备时为空
 }
 
 // AudioManager 音频管理接口
 type AudioManager interface {
 	Start() error
 	Stop() error
-	AddTrack(pc *webrtc.PeerConnection) (*webrtc.TrackLocalStaticSample, error)
-	OnTrack(track *webrtc.TrackRemote)
+	AddTrack(peerID string, pc *webrtc.PeerConnection) (*webrtc.TrackLocalStaticSample, error)
+	OnTrack(peerID string, track *webrtc.TrackRemote)
+	SetPeerSpace(peerID, spaceID string)
+	GetStats() Stats
+	CodecCapability() webrtc.RTPCodecCapability
+	SetInputDevice(device string) error
+	SetOutputDevice(device string) error
+	OnDeviceEvent(handler func(DeviceEvent))
+	DeviceStatus() DeviceStatus
 } 
\ No newline at end of file
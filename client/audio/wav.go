@@ -0,0 +1,120 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// WAV格式常量
+const (
+	wavHeaderSize        = 44
+	wavFormatPCM         = 1
+	wavFormatExtensible  = 0xFFFE
+	wavBitsPerSample     = 16
+	wavBytesPerSample    = wavBitsPerSample / 8
+	wavMaxChunkSizeBytes = 0xFFFFFFFF
+)
+
+// wavFormat WAV文件的音频格式
+type wavFormat struct {
+	SampleRate int
+	Channels   int
+	dataOffset int64 // data块在文件中的偏移
+	dataSize   int64 // data块的字节数
+}
+
+// readWAVHeader 解析RIFF/WAVE头，返回音频格式并将r定位到data块开头
+//
+// 仅支持16位PCM。
+func readWAVHeader(r io.ReadSeeker) (wavFormat, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return wavFormat{}, fmt.Errorf("读取WAV头失败: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return wavFormat{}, errors.New("不是WAV文件")
+	}
+
+	var format wavFormat
+	haveFmt := false
+	offset := int64(12)
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return wavFormat{}, errors.New("WAV文件缺少data块")
+		}
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		offset += 8
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return wavFormat{}, errors.New("无效的WAV fmt块")
+			}
+			body := make([]byte, size)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return wavFormat{}, fmt.Errorf("读取WAV fmt块失败: %w", err)
+			}
+			audioFormat := binary.LittleEndian.Uint16(body[0:2])
+			if audioFormat == wavFormatExtensible && size >= 26 {
+				// WAVE_FORMAT_EXTENSIBLE的子格式GUID前两个字节为实际格式
+				audioFormat = binary.LittleEndian.Uint16(body[24:26])
+			}
+			bits := binary.LittleEndian.Uint16(body[14:16])
+			if audioFormat != wavFormatPCM || bits != wavBitsPerSample {
+				return wavFormat{}, fmt.Errorf("不支持的WAV格式: format=%d bits=%d，仅支持16位PCM", audioFormat, bits)
+			}
+			format.Channels = int(binary.LittleEndian.Uint16(body[2:4]))
+			format.SampleRate = int(binary.LittleEndian.Uint32(body[4:8]))
+			haveFmt = true
+		case "data":
+			if !haveFmt {
+				return wavFormat{}, errors.New("WAV文件的data块位于fmt块之前")
+			}
+			format.dataOffset = offset
+			format.dataSize = size
+			return format, nil
+		default:
+			if _, err := r.Seek(size, io.SeekCurrent); err != nil {
+				return wavFormat{}, fmt.Errorf("跳过WAV块失败: %w", err)
+			}
+		}
+
+		// 块按偶数字节对齐
+		if size%2 == 1 {
+			if _, err := r.Seek(1, io.SeekCurrent); err != nil {
+				return wavFormat{}, fmt.Errorf("跳过WAV填充字节失败: %w", err)
+			}
+			size++
+		}
+		offset += size
+	}
+}
+
+// writeWAVHeader 写入16位PCM的WAV头，dataSize为data块的字节数
+func writeWAVHeader(w io.Writer, sampleRate, channels int, dataSize int64) error {
+	if dataSize > wavMaxChunkSizeBytes-(wavHeaderSize-8) {
+		dataSize = wavMaxChunkSizeBytes - (wavHeaderSize - 8)
+	}
+
+	header := make([]byte, wavHeaderSize)
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(dataSize+wavHeaderSize-8))
+	copy(header[8:12], "WAVE")
+	copy(header[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:20], 16)
+	binary.LittleEndian.PutUint16(header[20:22], wavFormatPCM)
+	binary.LittleEndian.PutUint16(header[22:24], uint16(channels))
+	binary.LittleEndian.PutUint32(header[24:28], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(header[28:32], uint32(sampleRate*channels*wavBytesPerSample))
+	binary.LittleEndian.PutUint16(header[32:34], uint16(channels*wavBytesPerSample))
+	binary.LittleEndian.PutUint16(header[34:36], wavBitsPerSample)
+	copy(header[36:40], "data")
+	binary.LittleEndian.PutUint32(header[40:44], uint32(dataSize))
+
+	_, err := w.Write(header)
+	return err
+}
@@ -473,6 +473,7 @@ func testSystemCapture() {
 		Enabled:       true,
 		InputDevice:   cfg.Audio.InputDevice,
 		OutputDevice:  cfg.Audio.OutputDevice,
+		LoopbackDevice: cfg.Audio.LoopbackDevice,
 		SampleRate:    cfg.Audio.SampleRate,
 		Channels:      cfg.Audio.Channels,
 		FrameSize:     cfg.Audio.FrameSize,
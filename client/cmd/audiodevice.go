@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"client/audio"
+	"client/config"
+	"client/control"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// 运行时切换音频设备参数
+	switchInput  string
+	switchOutput string
+	watchDevices bool
+)
+
+// audioDeviceCmd 查看或切换运行中客户端的音频设备
+var audioDeviceCmd = &cobra.Command{
+	Use:   "audio-device",
+	Short: "查看或切换运行中客户端的音频设备",
+	Long: `通过运行中的client run进程查看当前使用的音频设备，或在不断开连接的情况下切换输入输出设备。
+--input ""或--output ""表示切换到系统默认设备，--watch持续输出设备变化事件。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			fmt.Printf("配置文件加载失败：%v\n", err)
+			os.Exit(1)
+		}
+
+		var status audio.DeviceStatus
+		req := audioDeviceRequest{}
+		if cmd.Flags().Changed("input") {
+			req.Input = &switchInput
+		}
+		if cmd.Flags().Changed("output") {
+			req.Output = &switchOutput
+		}
+		if req.Input != nil || req.Output != nil {
+			err = control.Call(cfg.Control.Socket, "POST", "/audio/devices", req, &status)
+		} else {
+			err = control.Call(cfg.Control.Socket, "GET", "/audio/devices", nil, &status)
+		}
+		if err != nil {
+			fmt.Printf("操作失败：%v\n", err)
+			os.Exit(1)
+		}
+		printDeviceStatus(status)
+
+		if !watchDevices {
+			return
+		}
+		err = control.Stream(cfg.Control.Socket, "GET", "/audio/events", nil, func(line []byte) error {
+			var event audio.DeviceEvent
+			if err := json.Unmarshal(line, &event); err != nil {
+				return fmt.Errorf("解析设备事件失败: %w", err)
+			}
+			printDeviceEvent(event)
+			return nil
+		})
+		if err != nil {
+			fmt.Printf("接收设备事件失败：%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(audioDeviceCmd)
+	audioDeviceCmd.Flags().StringVar(&switchInput, "input", "", "切换到的输入设备，空字符串表示默认设备")
+	audioDeviceCmd.Flags().StringVar(&switchOutput, "output", "", "切换到的输出设备，空字符串表示默认设备")
+	audioDeviceCmd.Flags().BoolVarP(&watchDevices, "watch", "w", false, "持续输出设备变化事件")
+	audioDeviceCmd.Flags().StringVarP(&configPath, "config", "c", "config.toml", "配置文件路径")
+}
+
+// deviceName 显示用的设备名称
+func deviceName(device string) string {
+	if device == "" {
+		return "(默认设备)"
+	}
+	return device
+}
+
+// printDeviceStatus 打印当前使用的音频设备
+func printDeviceStatus(status audio.DeviceStatus) {
+	fmt.Printf("输入设备: %s", deviceName(status.Input))
+	if status.Input != status.PreferredInput {
+		fmt.Printf(" (首选 %s 不可用)", deviceName(status.PreferredInput))
+	}
+	fmt.Println()
+	fmt.Printf("输出设备: %s", deviceName(status.Output))
+	if status.Output != status.PreferredOutput {
+		fmt.Printf(" (首选 %s 不可用)", deviceName(status.PreferredOutput))
+	}
+	fmt.Println()
+}
+
+// printDeviceEvent 打印一条设备事件
+func printDeviceEvent(event audio.DeviceEvent) {
+	fmt.Printf("%s %s %s %s", event.Time.Format("15:04:05"), event.Type, event.Kind, deviceName(event.Device))
+	if event.Error != "" {
+		fmt.Printf(": %s", event.Error)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,284 @@
+package cmd
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"client/audio"
+	"client/config"
+	"client/control"
+	"client/webrtc"
+
+	"github.com/charmbracelet/log"
+)
+
+// sendRequest 发送消息请求
+type sendRequest struct {
+	PeerID string `json:"peer_id"`
+	Text   string `json:"text"`
+}
+
+// sendFileRequest 发送文件请求
+type sendFileRequest struct {
+	PeerID string `json:"peer_id"`
+	Path   string `json:"path"` // 绝对路径，由运行中的客户端读取
+}
+
+// sendFileEvent 发送文件过程中逐行返回的进度和结果
+type sendFileEvent struct {
+	Sent  int64  `json:"sent,omitempty"`
+	Total int64  `json:"total,omitempty"`
+	Done  bool   `json:"done,omitempty"`
+	Path  string `json:"path,omitempty"` // 接收方保存的文件路径
+	Error string `json:"error,omitempty"`
+}
+
+// forwardRequest 端口转发请求
+type forwardRequest struct {
+	PeerID  string `json:"peer_id"`
+	Network string `json:"network"` // tcp或udp
+	Local   string `json:"local"`   // 本地监听地址
+	Remote  string `json:"remote"`  // 由对端连接的目标地址
+}
+
+// forwardEvent 端口转发开始后返回的监听地址
+type forwardEvent struct {
+	Listening string `json:"listening"`
+}
+
+// audioDeviceRequest 切换音频设备请求，字段为nil时不切换，空字符串表示默认设备
+type audioDeviceRequest struct {
+	Input  *string `json:"input,omitempty"`
+	Output *string `json:"output,omitempty"`
+}
+
+// fileAnswerRequest 答复文件传输请求
+type fileAnswerRequest struct {
+	TransferID string `json:"transfer_id"`
+	Accept     bool   `json:"accept"`
+}
+
+// eventBufferSize 每个订阅者缓存的事件数，写满后丢弃新事件
+const eventBufferSize = 16
+
+// eventHub 将客户端事件分发给订阅事件流的控制接口请求
+type eventHub struct {
+	name        string // 事件名称，用于日志
+	mu          sync.Mutex
+	subscribers map[chan interface{}]struct{}
+}
+
+func newEventHub(name string) *eventHub {
+	return &eventHub{name: name, subscribers: make(map[chan interface{}]struct{})}
+}
+
+// subscribe 订阅事件，使用完后需调用unsubscribe
+func (h *eventHub) subscribe() chan interface{} {
+	ch := make(chan interface{}, eventBufferSize)
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan interface{}) {
+	h.mu.Lock()
+	delete(h.subscribers, ch)
+	h.mu.Unlock()
+}
+
+// count 返回当前订阅者数量
+func (h *eventHub) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers)
+}
+
+// publish 分发事件，在事件来源的回调中调用，不能阻塞
+func (h *eventHub) publish(event interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+			log.Warn("事件订阅者处理过慢，丢弃事件", "event", h.name)
+		}
+	}
+}
+
+// serve 将订阅到的事件逐行写出，直到调用方断开连接
+func (h *eventHub) serve(w http.ResponseWriter, r *http.Request) {
+	events := h.subscribe()
+	defer h.unsubscribe(events)
+
+	stream := control.NewStreamWriter(w)
+	// 立即发送响应头，调用方不必等到第一个事件才确认订阅成功
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	for {
+		select {
+		case event := <-events:
+			stream.Write(event)
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// newControlServer 创建本地控制接口，供send等命令操作运行中的客户端
+func newControlServer(cfg *config.Config, webrtcClient *webrtc.Client) *control.Server {
+	server := control.NewServer(cfg.Control.Socket)
+
+	// 向对端发送文本消息
+	server.Handle("POST /send", func(w http.ResponseWriter, r *http.Request) {
+		var req sendRequest
+		if !control.DecodeJSON(w, r, &req) {
+			return
+		}
+		if req.PeerID == "" || req.Text == "" {
+			http.Error(w, "peer_id和text不能为空", http.StatusBadRequest)
+			return
+		}
+		if err := webrtcClient.SendChat(req.PeerID, req.Text); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// 向对端发送文件，逐行返回进度，最后一行为结果
+	server.Handle("POST /send-file", func(w http.ResponseWriter, r *http.Request) {
+		var req sendFileRequest
+		if !control.DecodeJSON(w, r, &req) {
+			return
+		}
+		if req.PeerID == "" || req.Path == "" {
+			http.Error(w, "peer_id和path不能为空", http.StatusBadRequest)
+			return
+		}
+
+		stream := control.NewStreamWriter(w)
+		var lastReport time.Time
+		remotePath, err := webrtcClient.SendFile(r.Context(), req.PeerID, req.Path, func(p webrtc.FileProgress) {
+			// 限制进度输出频率
+			if time.Since(lastReport) < 200*time.Millisecond && p.Sent < p.Total {
+				return
+			}
+			lastReport = time.Now()
+			stream.Write(sendFileEvent{Sent: p.Sent, Total: p.Total})
+		})
+		if err != nil {
+			stream.Write(sendFileEvent{Error: err.Error()})
+			return
+		}
+		stream.Write(sendFileEvent{Done: true, Path: remotePath})
+	})
+
+	// 端口转发，调用方断开连接前一直保持
+	server.Handle("POST /forward", func(w http.ResponseWriter, r *http.Request) {
+		var req forwardRequest
+		if !control.DecodeJSON(w, r, &req) {
+			return
+		}
+		if req.PeerID == "" || req.Local == "" || req.Remote == "" {
+			http.Error(w, "peer_id、local和remote不能为空", http.StatusBadRequest)
+			return
+		}
+
+		addr, err := webrtcClient.Forward(r.Context(), req.PeerID, req.Network, req.Local, req.Remote)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Info("端口转发已启动", "peer_id", req.PeerID, "network", req.Network, "local", addr.String(), "remote", req.Remote)
+
+		control.NewStreamWriter(w).Write(forwardEvent{Listening: addr.String()})
+		<-r.Context().Done()
+		log.Info("端口转发已停止", "peer_id", req.PeerID, "local", addr.String())
+	})
+
+	// 音频设备状态和切换
+	deviceEvents := newEventHub("audio_device")
+	webrtcClient.OnAudioDeviceEvent(func(event audio.DeviceEvent) {
+		deviceEvents.publish(event)
+	})
+
+	server.Handle("GET /audio/devices", func(w http.ResponseWriter, r *http.Request) {
+		status, ok := webrtcClient.GetAudioDeviceStatus()
+		if !ok {
+			http.Error(w, "音频功能未启用", http.StatusConflict)
+			return
+		}
+		control.WriteJSON(w, status)
+	})
+
+	server.Handle("POST /audio/devices", func(w http.ResponseWriter, r *http.Request) {
+		var req audioDeviceRequest
+		if !control.DecodeJSON(w, r, &req) {
+			return
+		}
+		if req.Input == nil && req.Output == nil {
+			http.Error(w, "input和output不能同时为空", http.StatusBadRequest)
+			return
+		}
+		if req.Input != nil {
+			if err := webrtcClient.SetAudioInputDevice(*req.Input); err != nil {
+				http.Error(w, "切换输入设备失败: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Output != nil {
+			if err := webrtcClient.SetAudioOutputDevice(*req.Output); err != nil {
+				http.Error(w, "切换输出设备失败: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		status, _ := webrtcClient.GetAudioDeviceStatus()
+		control.WriteJSON(w, status)
+	})
+
+	// 设备事件流，调用方断开连接前一直保持
+	server.Handle("GET /audio/events", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := webrtcClient.GetAudioDeviceStatus(); !ok {
+			http.Error(w, "音频功能未启用", http.StatusConflict)
+			return
+		}
+		deviceEvents.serve(w, r)
+	})
+
+	// 未开启自动接收时，由订阅了请求流的命令确认对端发送的文件
+	fileOffers := newEventHub("file_offer")
+	webrtcClient.OnFileOffer(func(event webrtc.FileOfferEvent) {
+		if fileOffers.count() == 0 {
+			// 没有人在确认，立即拒绝而不是让发送方等到超时
+			if err := webrtcClient.AnswerFileOffer(event.TransferID, false); err != nil {
+				log.Error("拒绝文件传输失败", "error", err)
+			}
+			return
+		}
+		fileOffers.publish(event)
+	})
+
+	server.Handle("GET /file-offers", fileOffers.serve)
+
+	server.Handle("POST /file-offers/answer", func(w http.ResponseWriter, r *http.Request) {
+		var req fileAnswerRequest
+		if !control.DecodeJSON(w, r, &req) {
+			return
+		}
+		if req.TransferID == "" {
+			http.Error(w, "transfer_id不能为空", http.StatusBadRequest)
+			return
+		}
+		if err := webrtcClient.AnswerFileOffer(req.TransferID, req.Accept); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return server
+}
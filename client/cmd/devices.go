@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"client/audio"
 	"fmt"
 	"time"
 
@@ -93,6 +94,18 @@ func listAudioDevices() {
 		}
 	}
 
+	// 打印系统音频捕获设备
+	fmt.Println("=== 系统音频捕获设备 ===")
+	fmt.Println()
+	loopbackDevices, err := listLoopbackDevices()
+	if err != nil {
+		fmt.Printf("无法获取系统音频捕获设备: %v\n", err)
+	}
+	for i, device := range loopbackDevices {
+		fmt.Printf("[%d] %s\n", i, device)
+	}
+	fmt.Println()
+
 	// 使用提示
 	fmt.Println("=== 配置音频设备 ===")
 	fmt.Println("在配置文件中设置input_device和output_device参数:")
@@ -100,4 +113,18 @@ func listAudioDevices() {
 	fmt.Println("[Audio]")
 	fmt.Println("input_device = \"设备名称\"  # 留空使用系统默认设备")
 	fmt.Println("output_device = \"设备名称\" # 留空使用系统默认设备")
+	fmt.Println("loopback_device = \"设备名称\" # 系统音频捕获设备，留空使用默认输出设备")
+}
+
+// listLoopbackDevices 列出可用于系统音频捕获的设备
+func listLoopbackDevices() ([]string, error) {
+	source, err := audio.NewLoopbackSource(audio.AudioConfig{
+		SampleRate: 48000,
+		Channels:   2,
+		FrameSize:  960,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return source.GetDeviceList()
 } 
\ No newline at end of file
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"client/config"
+	"client/control"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	forwardPeer   string
+	forwardLocal  string
+	forwardRemote string
+	forwardUDP    bool
+)
+
+// forwardCmd 经由对端转发本地端口
+var forwardCmd = &cobra.Command{
+	Use:   "forward",
+	Short: "经由对端转发本地TCP/UDP端口",
+	Long: `通过运行中的client run进程，在本地监听--local地址，并将每个连接经WebRTC数据通道
+转发给对端，由对端连接--remote地址。对端只允许转发到其配置中[Forward]的allow列表里的目标。
+按Ctrl+C停止转发。`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			fmt.Printf("配置文件加载失败：%v\n", err)
+			os.Exit(1)
+		}
+
+		req := forwardRequest{
+			PeerID:  forwardPeer,
+			Network: "tcp",
+			Local:   forwardLocal,
+			Remote:  forwardRemote,
+		}
+		if forwardUDP {
+			req.Network = "udp"
+		}
+
+		err = control.Stream(cfg.Control.Socket, "POST", "/forward", req, func(line []byte) error {
+			var event forwardEvent
+			if err := json.Unmarshal(line, &event); err != nil {
+				return fmt.Errorf("解析响应失败: %w", err)
+			}
+			fmt.Printf("正在转发 %s/%s -> %s 上的 %s，按Ctrl+C停止\n", req.Network, event.Listening, req.PeerID, req.Remote)
+			return nil
+		})
+		if err != nil {
+			fmt.Printf("转发失败：%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("运行中的客户端已停止，转发结束")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(forwardCmd)
+	forwardCmd.Flags().StringVarP(&configPath, "config", "c", "config.toml", "配置文件路径")
+	forwardCmd.Flags().StringVar(&forwardPeer, "peer", "", "对端客户端ID")
+	forwardCmd.Flags().StringVar(&forwardLocal, "local", "", "本地监听地址，如127.0.0.1:5432")
+	forwardCmd.Flags().StringVar(&forwardRemote, "remote", "", "由对端连接的目标地址，如127.0.0.1:5432")
+	forwardCmd.Flags().BoolVar(&forwardUDP, "udp", false, "转发UDP而不是TCP")
+	forwardCmd.MarkFlagRequired("peer")
+	forwardCmd.MarkFlagRequired("local")
+	forwardCmd.MarkFlagRequired("remote")
+}
@@ -56,15 +56,41 @@ func printAudioHelp() {
 
 [Audio]
 enabled = true                # 是否启用音频
-input_device = ""             # 输入设备名称（空值表示使用默认设备）
-output_device = ""            # 输出设备名称（空值表示使用默认设备）
+input_device = ""             # 输入设备名称（空值表示使用默认设备，"file:a.wav"表示从文件读取）
+output_device = ""            # 输出设备名称（空值表示使用默认设备，"file:b.ogg"表示写入文件）
+file_loop = false             # 文件输入是否循环播放
 capture_system = false        # 是否捕获系统音频输出
 mix_with_mic = false          # 是否将系统音频与麦克风混合
+mic_gain_db = 0.0             # 混合时麦克风增益(dB)
+system_gain_db = 0.0          # 混合时系统音频增益(dB)
+mic_mute = false              # 混合时静音麦克风
+system_mute = false           # 混合时静音系统音频
+ducking = false               # 说话时自动压低系统音频
+ducking_db = 12.0             # 压低的幅度(dB)
+ducking_threshold_db = -45.0  # 判定为说话的麦克风电平(dBFS)
+loopback_device = ""          # 系统音频捕获设备（空值表示默认输出设备的监视源）
 sample_rate = 48000           # 采样率(Hz)
 channels = 2                  # 通道数，1=单声道，2=立体声
-frame_size = 960              # 帧大小，20ms@48kHz=960
+frame_size = 960              # 帧大小，必须为2.5/5/10/20/40/60ms，20ms@48kHz=960
 bitrate_kbps = 64             # 比特率(kbps)
 opus_complexity = 10          # Opus编码复杂度(0-10)
+resample_quality = "medium"   # 设备格式与编码格式不同时的重采样质量: low/medium/high
+buffer_ms = 100               # 设备输入/输出缓冲区时长(毫秒)
+opus_application = "voip"     # 应用模式: voip/audio/lowdelay
+opus_signal = "auto"          # 信号类型: auto/voice/music
+opus_dtx = false              # 静音时启用不连续传输
+opus_fec = false              # 始终启用带内FEC
+opus_packet_loss_perc = 0     # 预期丢包率(%)
+opus_bitrate_mode = "vbr"     # 码率模式: vbr/cvbr/cbr
+opus_max_bandwidth = "fullband" # 最大带宽: narrowband/mediumband/wideband/superwideband/fullband
+adaptive_bitrate = false      # 根据网络反馈自适应调整码率、FEC和帧长
+min_bitrate_kbps = 16         # 自适应码率下限(kbps)
+max_bitrate_kbps = 128        # 自适应码率上限(kbps)
+fec_loss_threshold = 2        # 丢包率(%)达到该值时启用带内FEC
+max_frame_size = 960          # 网络较差时允许的最大帧大小(最长60ms)
+record_dir = ""               # 通话录音目录（也可使用 client run --record-dir）
+record_mix = false            # 额外录制混音后的WAV
+record_rotate_minutes = 0     # 按时长切分录音文件(分钟)
 
 4. 常见问题
 -----------
@@ -72,17 +98,29 @@ opus_complexity = 10          # Opus编码复杂度(0-10)
 Q: 无法找到音频设备
 A: 运行 "client devices" 确认系统能识别您的设备，尝试重新插拔设备
 
+Q: 通话中如何更换耳机或麦克风
+A: 修改配置文件中的input_device/output_device后向客户端发送SIGHUP
+   (kill -HUP <pid>)，无需重新连接。设备被拔出时自动回退到默认设备，
+   重新插入后自动切换回来
+
 Q: 音质不佳
 A: 尝试增加比特率(bitrate_kbps)或改用更好的麦克风
 
 Q: 音频有延迟
-A: 可以尝试减小帧大小(frame_size)，但会增加CPU使用率
+A: 可以尝试减小帧大小(frame_size)和缓冲区(buffer_ms)，但会增加CPU使用率和断续的可能
+
+Q: 网络不稳定时声音断续
+A: 启用adaptive_bitrate，客户端会根据对端的丢包和带宽反馈降低码率并开启FEC
 
 Q: 系统音频捕获不工作
-A: 系统音频捕获需要特定平台支持，目前需要实现
-   Windows: 需要WASAPI Loopback支持
-   macOS: 需要Audio Unit或虚拟音频设备
-   Linux: 需要PulseAudio或JACK支持
+A: 系统音频捕获需要特定平台支持
+   Windows: 需要WASAPI Loopback支持，目前未实现
+   macOS: 需要Audio Unit或虚拟音频设备，目前未实现
+   Linux: 通过PulseAudio/PipeWire的.monitor源捕获，需要pactl和ALSA pulse插件
+          运行 "client devices" 查看可用的监视源并设置loopback_device
+          无声卡环境可创建虚拟输出设备进行测试:
+            pactl load-module module-null-sink sink_name=p2p_test
+            loopback_device = "p2p_test.monitor"
 `
 	fmt.Println(help)
 } 
\ No newline at end of file
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"client/config"
+	"client/control"
+	"client/webrtc"
+
+	"github.com/spf13/cobra"
+)
+
+// acceptAll 不询问，接受所有文件传输请求
+var acceptAll bool
+
+// receiveCmd 逐个确认对端发送的文件
+var receiveCmd = &cobra.Command{
+	Use:   "receive",
+	Short: "确认对端发送的文件",
+	Long: `未开启[Transfer]中的auto_accept时，通过运行中的client run进程逐个确认对端发送的文件。
+命令运行期间收到的请求会询问是否接收，接受的文件保存到receive_dir；没有运行该命令时请求直接被拒绝。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			fmt.Printf("配置文件加载失败：%v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("等待对端发送文件，按Ctrl+C退出")
+		input := bufio.NewReader(os.Stdin)
+		err = control.Stream(cfg.Control.Socket, "GET", "/file-offers", nil, func(line []byte) error {
+			var offer webrtc.FileOfferEvent
+			if err := json.Unmarshal(line, &offer); err != nil {
+				return fmt.Errorf("解析文件传输请求失败: %w", err)
+			}
+
+			accept := acceptAll
+			if acceptAll {
+				fmt.Printf("接收 %s 发送的 %s (%d 字节)\n", offer.PeerID, offer.Name, offer.Size)
+			} else {
+				fmt.Printf("%s 发送 %s (%d 字节)，是否接收？[y/N] ", offer.PeerID, offer.Name, offer.Size)
+				answer, _ := input.ReadString('\n')
+				answer = strings.ToLower(strings.TrimSpace(answer))
+				accept = answer == "y" || answer == "yes"
+			}
+
+			req := fileAnswerRequest{TransferID: offer.TransferID, Accept: accept}
+			if err := control.Call(cfg.Control.Socket, "POST", "/file-offers/answer", req, nil); err != nil {
+				fmt.Printf("答复失败：%v\n", err)
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Printf("接收文件传输请求失败：%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(receiveCmd)
+	receiveCmd.Flags().BoolVarP(&acceptAll, "yes", "y", false, "不询问，接受所有文件")
+	receiveCmd.Flags().StringVarP(&configPath, "config", "c", "config.toml", "配置文件路径")
+}
@@ -7,6 +7,7 @@ import (
 	"syscall"
 	"time"
 
+	"client/audio"
 	"client/config"
 	"client/logger"
 	"client/webrtc"
@@ -16,7 +17,14 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var configPath string
+var (
+	configPath string
+
+	// 录音参数，设置后覆盖配置文件
+	recordDir           string
+	recordMix           bool
+	recordRotateMinutes int
+)
 
 // runCmd 表示run命令
 var runCmd = &cobra.Command{
@@ -24,17 +32,43 @@ var runCmd = &cobra.Command{
 	Short: "运行WebSocket客户端",
 	Long:  `启动WebSocket客户端并连接到服务器。`,
 	Run: func(cmd *cobra.Command, args []string) {
-		// 初始化日志记录器
-		if err := logger.InitLogger("logs"); err != nil {
-			log.Fatal("初始化日志记录器失败", "error", err)
-		}
-
 		// 加载配置文件
 		cfg, err := config.LoadConfig(configPath)
 		if err != nil {
 			log.Fatal("配置文件加载失败", "error", err)
 		}
 
+		// 按配置初始化日志记录器
+		if err := logger.InitLogger(logger.Options{
+			Dir:        cfg.Log.Dir,
+			Name:       "client",
+			Level:      cfg.Log.Level,
+			Format:     cfg.Log.Format,
+			MaxSizeMB:  cfg.Log.MaxSizeMB,
+			MaxAgeDays: cfg.Log.MaxAgeDays,
+			MaxBackups: cfg.Log.MaxBackups,
+		}); err != nil {
+			log.Fatal("初始化日志记录器失败", "error", err)
+		}
+
+		// 命令行参数覆盖录音配置
+		if cmd.Flags().Changed("record-dir") {
+			cfg.Audio.RecordDir = recordDir
+		}
+		if cmd.Flags().Changed("record-mix") {
+			cfg.Audio.RecordMix = recordMix
+		}
+		if cmd.Flags().Changed("record-rotate") {
+			cfg.Audio.RecordRotateMinutes = recordRotateMinutes
+		}
+
+		// 启动前校验音频编码参数
+		if cfg.Audio.Enabled {
+			if _, err := audio.NewAudioConfig(cfg); err != nil {
+				log.Fatal("音频配置无效", "error", err)
+			}
+		}
+
 		// 创建WebSocket客户端
 		wsClient := websocket.NewClient(cfg)
 
@@ -44,6 +78,13 @@ var runCmd = &cobra.Command{
 		// 设置WebRTC客户端到WebSocket客户端
 		wsClient.SetWebRTCClient(webrtcClient)
 
+		// 启动本地控制接口
+		controlServer := newControlServer(cfg, webrtcClient)
+		if err := controlServer.Start(); err != nil {
+			log.Error("启动控制接口失败", "error", err)
+		}
+		defer controlServer.Close()
+
 		// 设置中断信号处理
 		interrupt := make(chan os.Signal, 1)
 		signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
@@ -79,6 +120,27 @@ var runCmd = &cobra.Command{
 			}
 		}()
 
+		// 定期采集连接和音频统计
+		go webrtcClient.RunStats(ctx)
+
+		// SIGHUP重新加载配置并切换音频设备
+		reload := make(chan os.Signal, 1)
+		signal.Notify(reload, syscall.SIGHUP)
+		go func() {
+			for range reload {
+				newCfg, err := config.LoadConfig(configPath)
+				if err != nil {
+					log.Error("重新加载配置失败", "error", err)
+					continue
+				}
+				if err := webrtcClient.SetAudioDevices(newCfg.Audio.InputDevice, newCfg.Audio.OutputDevice); err != nil {
+					log.Error("切换音频设备失败", "error", err)
+					continue
+				}
+				log.Info("已重新加载音频设备配置", "input", newCfg.Audio.InputDevice, "output", newCfg.Audio.OutputDevice)
+			}
+		}()
+
 		// 等待中断信号
 		sig := <-interrupt
 		log.Info("收到中断信号，正在关闭连接...", "signal", sig)
@@ -106,5 +168,8 @@ var runCmd = &cobra.Command{
 
 func init() {
 	runCmd.Flags().StringVarP(&configPath, "config", "c", "config.toml", "配置文件路径")
+	runCmd.Flags().StringVar(&recordDir, "record-dir", "", "通话录音目录，每个参与者保存为单独的Ogg/Opus文件")
+	runCmd.Flags().BoolVar(&recordMix, "record-mix", false, "额外录制所有参与者混音后的WAV文件")
+	runCmd.Flags().IntVar(&recordRotateMinutes, "record-rotate", 0, "按时长切分录音文件(分钟)，0表示不切分")
 	rootCmd.AddCommand(runCmd)
 }
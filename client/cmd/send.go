@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"client/config"
+	"client/control"
+
+	"github.com/spf13/cobra"
+)
+
+// sendCmd 通过运行中的客户端向对端发送文本消息
+var sendCmd = &cobra.Command{
+	Use:   "send <peer-id> <text>",
+	Short: "向对端发送文本消息",
+	Long:  `通过运行中的client run进程，经由WebRTC数据通道向已连接的对端发送文本消息。`,
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			fmt.Printf("配置文件加载失败：%v\n", err)
+			os.Exit(1)
+		}
+
+		req := sendRequest{
+			PeerID: args[0],
+			Text:   strings.Join(args[1:], " "),
+		}
+		if err := control.Call(cfg.Control.Socket, "POST", "/send", req, nil); err != nil {
+			fmt.Printf("发送失败：%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("已发送")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sendCmd)
+	sendCmd.Flags().StringVarP(&configPath, "config", "c", "config.toml", "配置文件路径")
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"client/config"
+	"client/control"
+
+	"github.com/spf13/cobra"
+)
+
+// sendFileCmd 通过运行中的客户端向对端发送文件
+var sendFileCmd = &cobra.Command{
+	Use:   "send-file <peer-id> <path>",
+	Short: "向对端发送文件",
+	Long: `通过运行中的client run进程，经由专用的WebRTC数据通道向已连接的对端发送文件。
+接收方校验SHA-256后保存文件；连接中断时会等待重新连接并从已接收的位置继续发送。`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			fmt.Printf("配置文件加载失败：%v\n", err)
+			os.Exit(1)
+		}
+
+		// 文件由运行中的客户端读取，其工作目录可能不同
+		path, err := filepath.Abs(args[1])
+		if err != nil {
+			fmt.Printf("无效的文件路径：%v\n", err)
+			os.Exit(1)
+		}
+
+		req := sendFileRequest{PeerID: args[0], Path: path}
+		err = control.Stream(cfg.Control.Socket, "POST", "/send-file", req, func(line []byte) error {
+			var event sendFileEvent
+			if err := json.Unmarshal(line, &event); err != nil {
+				return fmt.Errorf("解析响应失败: %w", err)
+			}
+			switch {
+			case event.Error != "":
+				return errors.New(event.Error)
+			case event.Done:
+				fmt.Printf("\n已发送，对端保存为：%s\n", event.Path)
+			case event.Total > 0:
+				fmt.Printf("\r已发送 %d/%d 字节 (%.1f%%)", event.Sent, event.Total, float64(event.Sent)*100/float64(event.Total))
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Printf("\n发送失败：%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sendFileCmd)
+	sendFileCmd.Flags().StringVarP(&configPath, "config", "c", "config.toml", "配置文件路径")
+}
@@ -26,11 +26,71 @@ type Config struct {
 		OutputDevice   string `toml:"output_device"`
 		CaptureSystem  bool   `toml:"capture_system"`  // 是否捕获系统音频
 		MixWithMic     bool   `toml:"mix_with_mic"`    // 是否将系统音频与麦克风混合
+		LoopbackDevice string `toml:"loopback_device"` // 系统音频捕获设备(Linux下为.monitor源)
+		FileLoop       bool   `toml:"file_loop"`       // 输入设备为文件时是否循环播放
 		SampleRate     int    `toml:"sample_rate"`
 		Channels       int    `toml:"channels"`
 		FrameSize      int    `toml:"frame_size"`
 		BitrateKbps    int    `toml:"bitrate_kbps"`
 		OpusComplexity int    `toml:"opus_complexity"`
+
+		// 设备以原生格式打开，与编码器格式不同时进行重采样
+		ResampleQuality string `toml:"resample_quality"` // 重采样质量: low、medium、high
+		BufferMs        int    `toml:"buffer_ms"`        // 设备输入/输出缓冲区时长(毫秒)
+
+		// 系统音频与麦克风混合(capture_system和mix_with_mic同时启用时生效)
+		MicGainDB          float64 `toml:"mic_gain_db"`          // 麦克风增益(dB)
+		SystemGainDB       float64 `toml:"system_gain_db"`       // 系统音频增益(dB)
+		MicMute            bool    `toml:"mic_mute"`             // 是否静音麦克风
+		SystemMute         bool    `toml:"system_mute"`          // 是否静音系统音频
+		Ducking            bool    `toml:"ducking"`              // 麦克风有语音时是否压低系统音频
+		DuckingDB          float64 `toml:"ducking_db"`           // 压低的幅度(dB)
+		DuckingThresholdDB float64 `toml:"ducking_threshold_db"` // 判定为语音的麦克风电平(dBFS)
+
+		// Opus编码器选项
+		OpusApplication    string `toml:"opus_application"`      // 应用模式: voip、audio、lowdelay
+		OpusSignal         string `toml:"opus_signal"`           // 信号类型: auto、voice、music
+		OpusDTX            bool   `toml:"opus_dtx"`              // 是否启用不连续传输(静音时降低码率)
+		OpusFEC            bool   `toml:"opus_fec"`              // 是否始终启用带内FEC
+		OpusPacketLossPerc int    `toml:"opus_packet_loss_perc"` // 预期丢包率(%)，影响FEC冗余量
+		OpusBitrateMode    string `toml:"opus_bitrate_mode"`     // 码率模式: vbr、cvbr、cbr
+		OpusMaxBandwidth   string `toml:"opus_max_bandwidth"`    // 最大带宽: narrowband、mediumband、wideband、superwideband、fullband
+
+		// 根据RTCP反馈自适应调整编码参数
+		AdaptiveBitrate  bool `toml:"adaptive_bitrate"`   // 是否启用自适应编码
+		MinBitrateKbps   int  `toml:"min_bitrate_kbps"`   // 自适应码率下限(kbps)
+		MaxBitrateKbps   int  `toml:"max_bitrate_kbps"`   // 自适应码率上限(kbps)
+		FECLossThreshold int  `toml:"fec_loss_threshold"` // 丢包率(%)达到该值时启用带内FEC
+		MaxFrameSize     int  `toml:"max_frame_size"`     // 网络较差时允许使用的最大帧大小
+
+		// 通话录音
+		RecordDir           string `toml:"record_dir"`            // 录音目录，为空时不录音
+		RecordMix           bool   `toml:"record_mix"`            // 是否额外录制所有参与者混音后的WAV
+		RecordRotateMinutes int    `toml:"record_rotate_minutes"` // 按时长切分录音文件(分钟)，0表示不切分
+	}
+	Transfer struct {
+		AutoAccept bool   `toml:"auto_accept"` // 是否自动接收对端发送的文件，关闭时由client receive确认
+		ReceiveDir string `toml:"receive_dir"` // 接收文件的保存目录
+		MaxSizeMB  int    `toml:"max_size_mb"` // 单个文件的大小上限(MB)，0表示1024
+	}
+	Forward struct {
+		Allow  []string `toml:"allow"`  // 允许对端转发到的目标，格式为tcp/主机:端口或udp/主机:端口，为空时不允许转发
+		Spaces []string `toml:"spaces"` // 只接受这些空间内对端的转发请求，为空时不限制
+	}
+	Stats struct {
+		Interval int    `toml:"interval"` // 统计采集间隔(秒)，0表示不采集
+		File     string `toml:"file"`     // 统计写入的本地JSON Lines文件，为空时只上报服务器
+	}
+	Log struct {
+		Dir        string `toml:"dir"`          // 日志目录，为空时使用logs
+		Level      string `toml:"level"`        // 日志级别: debug、info、warn、error，为空时为debug
+		Format     string `toml:"format"`       // 日志格式: text、json
+		MaxSizeMB  int    `toml:"max_size_mb"`  // 单个日志文件的大小上限(MB)，超过后轮转，0表示不按大小轮转
+		MaxAgeDays int    `toml:"max_age_days"` // 轮转后的日志保留天数，0表示不按时间清理
+		MaxBackups int    `toml:"max_backups"`  // 轮转后的日志最多保留的个数，0表示不按个数清理
+	}
+	Control struct {
+		Socket string `toml:"socket"` // 本地控制套接字路径，为空时使用$XDG_RUNTIME_DIR/go-p2p/client.sock
 	}
 }
 
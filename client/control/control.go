@@ -0,0 +1,243 @@
+// Package control 提供运行中的客户端与命令行之间的本地控制接口
+//
+// client run 在Unix域套接字上提供HTTP接口，send等命令通过该套接字
+// 操作已经建立的连接。套接字权限为0600，只有同一用户可以访问。
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	// DefaultSocket 未配置时使用的控制套接字文件名
+	DefaultSocket = "client.sock"
+	// socketDir 默认控制套接字所在的子目录
+	socketDir = "go-p2p"
+)
+
+// SocketPath 返回控制套接字路径
+//
+// 未配置时放在XDG_RUNTIME_DIR下，没有时依次使用用户配置目录和临时目录，
+// 使run和send等命令无论在哪个工作目录执行都使用同一个套接字。
+func SocketPath(path string) string {
+	if path != "" {
+		return path
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, socketDir, DefaultSocket)
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, socketDir, DefaultSocket)
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d", socketDir, os.Getuid()), DefaultSocket)
+}
+
+// Server 本地控制接口服务器
+type Server struct {
+	path     string
+	mux      *http.ServeMux
+	server   *http.Server
+	listener net.Listener
+}
+
+// NewServer 创建在path上监听的控制接口服务器
+func NewServer(path string) *Server {
+	mux := http.NewServeMux()
+	return &Server{
+		path:   SocketPath(path),
+		mux:    mux,
+		server: &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second},
+	}
+}
+
+// Handle 注册处理函数，pattern格式同http.ServeMux，如"POST /send"
+func (s *Server) Handle(pattern string, handler http.HandlerFunc) {
+	s.mux.HandleFunc(pattern, handler)
+}
+
+// Start 开始监听控制套接字
+func (s *Server) Start() error {
+	// 上次异常退出可能留下套接字文件，确认没有其他客户端在使用后删除
+	if _, err := os.Stat(s.path); err == nil {
+		if conn, err := net.Dial("unix", s.path); err == nil {
+			conn.Close()
+			return fmt.Errorf("控制套接字%s正在被其他客户端使用", s.path)
+		}
+		if err := os.Remove(s.path); err != nil {
+			return fmt.Errorf("删除旧的控制套接字失败: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("创建控制套接字目录失败: %w", err)
+	}
+	listener, err := net.Listen("unix", s.path)
+	if err != nil {
+		return fmt.Errorf("监听控制套接字失败: %w", err)
+	}
+	if err := os.Chmod(s.path, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("设置控制套接字权限失败: %w", err)
+	}
+	s.listener = listener
+
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("控制接口服务失败", "error", err)
+		}
+	}()
+
+	log.Info("控制接口已启动", "socket", s.path)
+	return nil
+}
+
+// Close 关闭控制接口并删除套接字文件
+func (s *Server) Close() {
+	if s.listener == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		log.Error("关闭控制接口失败", "error", err)
+	}
+	os.Remove(s.path)
+}
+
+// WriteJSON 以JSON格式写出响应
+func WriteJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error("写入控制接口响应失败", "error", err)
+	}
+}
+
+// DecodeJSON 解析JSON请求体，失败时写出400响应并返回false
+func DecodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, "无效的请求: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// Call 通过控制套接字调用运行中的客户端
+//
+// req不为nil时作为JSON请求体发送，resp不为nil时解析JSON响应。
+func Call(path, method, route string, req, resp interface{}) error {
+	httpResp, err := do(path, method, route, req)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	if resp != nil {
+		if err := json.NewDecoder(httpResp.Body).Decode(resp); err != nil {
+			return fmt.Errorf("解析响应失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// Stream 调用返回逐行JSON(NDJSON)的接口，每收到一行调用一次fn
+//
+// fn返回错误时停止读取并返回该错误。
+func Stream(path, method, route string, req interface{}, fn func(line []byte) error) error {
+	httpResp, err := do(path, method, route, req)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	scanner := bufio.NewScanner(httpResp.Body)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+	return nil
+}
+
+// StreamWriter 逐行写出JSON，每行写出后立即发送给调用方
+type StreamWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// NewStreamWriter 创建逐行JSON响应的写入器
+func NewStreamWriter(w http.ResponseWriter) *StreamWriter {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	return &StreamWriter{w: w, flusher: flusher}
+}
+
+// Write 写出一行JSON
+func (s *StreamWriter) Write(v interface{}) {
+	if err := json.NewEncoder(s.w).Encode(v); err != nil {
+		log.Error("写入控制接口响应失败", "error", err)
+		return
+	}
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
+
+// do 发送请求，响应状态码表示失败时以响应内容作为错误返回
+func do(path, method, route string, req interface{}) (*http.Response, error) {
+	path = SocketPath(path)
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+
+	var body io.Reader
+	if req != nil {
+		data, err := json.Marshal(req)
+		if err != nil {
+			return nil, fmt.Errorf("序列化请求失败: %w", err)
+		}
+		body = strings.NewReader(string(data))
+	}
+
+	httpReq, err := http.NewRequest(method, "http://client"+route, body)
+	if err != nil {
+		return nil, err
+	}
+	if req != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("连接运行中的客户端失败(请确认client run已启动): %w", err)
+	}
+	if httpResp.StatusCode >= 300 {
+		defer httpResp.Body.Close()
+		msg, _ := io.ReadAll(httpResp.Body)
+		return nil, errors.New(strings.TrimSpace(string(msg)))
+	}
+	return httpResp, nil
+}
@@ -0,0 +1,42 @@
+package control
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestSocketPath(t *testing.T) {
+	if got := SocketPath("/tmp/custom.sock"); got != "/tmp/custom.sock" {
+		t.Errorf("配置的路径被改写为%s", got)
+	}
+
+	dir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	want := filepath.Join(dir, socketDir, DefaultSocket)
+	if got := SocketPath(""); got != want {
+		t.Errorf("SocketPath(\"\") = %s, 期望 %s", got, want)
+	}
+}
+
+// TestDefaultSocket 默认路径的目录不存在时自动创建，并能通过该路径调用
+func TestDefaultSocket(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	server := NewServer("")
+	server.Handle("GET /ping", func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, map[string]string{"reply": "pong"})
+	})
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	var resp map[string]string
+	if err := Call("", "GET", "/ping", nil, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp["reply"] != "pong" {
+		t.Errorf("响应为%v", resp)
+	}
+}
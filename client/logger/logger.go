@@ -4,33 +4,69 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
-	"time"
+	"strings"
+
+	"logfile"
 
 	"github.com/charmbracelet/log"
 )
 
-// InitLogger 初始化日志记录器
-func InitLogger(logsDir string) error {
-	// 创建logs文件夹
-	if err := os.MkdirAll(logsDir, 0755); err != nil {
-		return fmt.Errorf("创建日志目录失败: %w", err)
+// Options 日志配置
+type Options struct {
+	Dir        string // 日志目录
+	Name       string // 日志文件名(不含扩展名)
+	Level      string // 日志级别: debug、info、warn、error
+	Format     string // 日志格式: text、json
+	MaxSizeMB  int    // 单个日志文件的大小上限(MB)，超过后轮转，0表示不按大小轮转
+	MaxAgeDays int    // 轮转后的日志保留天数，0表示不按时间清理
+	MaxBackups int    // 轮转后的日志最多保留的个数，0表示不按个数清理
+}
+
+// 未配置时的默认值
+const (
+	defaultDir    = "logs"
+	defaultLevel  = "debug"
+	defaultFormat = "text"
+)
+
+// InitLogger 初始化日志记录器，同时输出到轮转的日志文件和控制台，输出前隐去敏感字段
+func InitLogger(opts Options) error {
+	if opts.Dir == "" {
+		opts.Dir = defaultDir
+	}
+	if opts.Level == "" {
+		opts.Level = defaultLevel
+	}
+	level, err := log.ParseLevel(opts.Level)
+	if err != nil {
+		return fmt.Errorf("无效的日志级别: %s", opts.Level)
+	}
+
+	var formatter log.Formatter
+	switch strings.ToLower(opts.Format) {
+	case "", defaultFormat:
+		formatter = log.TextFormatter
+	case "json":
+		formatter = log.JSONFormatter
+	default:
+		return fmt.Errorf("无效的日志格式: %s", opts.Format)
 	}
 
-	// 创建日志文件
-	timestamp := time.Now().Format("2006-01-02-15-04-05")
-	logFile := filepath.Join(logsDir, fmt.Sprintf("%s.log", timestamp))
-	file, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	file, err := logfile.Open(logfile.Options{
+		Dir:        opts.Dir,
+		Name:       opts.Name,
+		MaxSizeMB:  opts.MaxSizeMB,
+		MaxAgeDays: opts.MaxAgeDays,
+		MaxBackups: opts.MaxBackups,
+	})
 	if err != nil {
-		return fmt.Errorf("创建日志文件失败: %w", err)
+		return err
 	}
 
-	// 配置日志记录器，同时输出到文件和控制台
-	log.SetOutput(io.MultiWriter(
-		file,
-		os.Stdout,
-	))
-	log.SetLevel(log.DebugLevel)
+	log.SetOutput(logfile.NewRedactWriter(io.MultiWriter(file, os.Stdout)))
+	log.SetFormatter(formatter)
+	log.SetLevel(level)
+	log.SetReportTimestamp(true)
 
 	return nil
-}
\ No newline at end of file
+}
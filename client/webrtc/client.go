@@ -1,22 +1,38 @@
 package webrtc
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"sync"
 
 	"client/audio"
 	"client/config"
 
 	"github.com/charmbracelet/log"
+	"github.com/pion/interceptor"
 	"github.com/pion/webrtc/v3"
 )
 
+// opusPayloadType Opus的RTP负载类型，与pion默认注册的一致
+const opusPayloadType = 111
+
 // Client WebRTC客户端结构
 type Client struct {
 	config          *config.Config
 	websocketClient interface{} // 使用interface{}避免循环导入
 	peerConnections map[string]*webrtc.PeerConnection
 	audioManager    audio.AudioManager
+	messenger       *messenger
+	api             *webrtc.API
+	peerSpaces      map[string]string // 对端所在的空间
 	mu              sync.RWMutex
+
+	// 对端创建的数据通道按标签前缀分发，标签格式为<前缀>:<ID>
+	channelHandlers map[string]func(peerID string, dc *webrtc.DataChannel)
+	channelMu       sync.Mutex
+
+	files *fileReceiver // 接收对端发送的文件
 }
 
 // NewClient 创建新的WebRTC客户端
@@ -37,12 +53,53 @@ func NewClient(cfg *config.Config, wsClient interface{}) *Client {
 		}
 	}
 
-	return &Client{
+	c := &Client{
 		config:          cfg,
 		websocketClient: wsClient,
 		peerConnections: make(map[string]*webrtc.PeerConnection),
 		audioManager:    audioManager,
+		messenger:       newMessenger(),
+		api:             newAPI(audioManager),
+		peerSpaces:      make(map[string]string),
+		channelHandlers: make(map[string]func(string, *webrtc.DataChannel)),
 	}
+	c.registerFileTransfer()
+	c.registerForwarding()
+	return c
+}
+
+// newAPI 创建WebRTC API，使SDP中的Opus参数与本地编码配置一致
+func newAPI(audioManager audio.AudioManager) *webrtc.API {
+	mediaEngine := &webrtc.MediaEngine{}
+	registered := false
+	if audioManager != nil {
+		err := mediaEngine.RegisterCodec(webrtc.RTPCodecParameters{
+			RTPCodecCapability: audioManager.CodecCapability(),
+			PayloadType:        opusPayloadType,
+		}, webrtc.RTPCodecTypeAudio)
+		if err != nil {
+			log.Error("注册Opus编解码器失败", "error", err)
+		} else {
+			registered = true
+		}
+	}
+	// 音频不可用或注册失败时使用默认编解码器
+	if !registered {
+		if err := mediaEngine.RegisterDefaultCodecs(); err != nil {
+			log.Error("注册默认编解码器失败", "error", err)
+		}
+	}
+
+	registry := &interceptor.Registry{}
+	if err := webrtc.RegisterDefaultInterceptors(mediaEngine, registry); err != nil {
+		log.Error("注册默认拦截器失败", "error", err)
+	}
+	// 默认拦截器只为收到的包生成TWCC反馈，发送的包还需要带上传输序号，对端才能回复TWCC
+	if err := webrtc.ConfigureTWCCHeaderExtensionSender(mediaEngine, registry); err != nil {
+		log.Error("注册TWCC头扩展拦截器失败", "error", err)
+	}
+
+	return webrtc.NewAPI(webrtc.WithMediaEngine(mediaEngine), webrtc.WithInterceptorRegistry(registry))
 }
 
 // GetPeerConnection 获取或创建与指定客户端的PeerConnection
@@ -66,7 +123,7 @@ func (c *Client) GetPeerConnection(targetID string) (*webrtc.PeerConnection, err
 
 	// 创建新的PeerConnection
 	var err error
-	pc, err = webrtc.NewPeerConnection(config)
+	pc, err = c.api.NewPeerConnection(config)
 	if err != nil {
 		return nil, err
 	}
@@ -75,12 +132,20 @@ func (c *Client) GetPeerConnection(targetID string) (*webrtc.PeerConnection, err
 	_, err = pc.CreateDataChannel("data", nil)
 	if err != nil {
 		log.Error("创建数据通道失败", "error", err)
+		c.discardPeerConnection(targetID, pc)
+		return nil, err
+	}
+
+	// 创建消息通道
+	if err := c.messenger.attach(targetID, pc); err != nil {
+		log.Error("创建消息通道失败", "error", err)
+		c.discardPeerConnection(targetID, pc)
 		return nil, err
 	}
 
 	// 如果音频管理器可用，添加音频轨道
 	if c.audioManager != nil {
-		_, err = c.audioManager.AddTrack(pc)
+		_, err = c.audioManager.AddTrack(targetID, pc)
 		if err != nil {
 			log.Error("添加音频轨道失败", "error", err)
 		}
@@ -92,10 +157,15 @@ func (c *Client) GetPeerConnection(targetID string) (*webrtc.PeerConnection, err
 
 		// 如果是音频轨道并且音频管理器可用
 		if track.Kind() == webrtc.RTPCodecTypeAudio && c.audioManager != nil {
-			c.audioManager.OnTrack(track)
+			c.audioManager.OnTrack(targetID, track)
 		}
 	})
 
+	// 分发对端创建的数据通道
+	pc.OnDataChannel(func(dc *webrtc.DataChannel) {
+		c.dispatchDataChannel(targetID, dc)
+	})
+
 	// 设置ICE候选收集处理
 	pc.OnICECandidate(func(candidate *webrtc.ICECandidate) {
 		if candidate == nil {
@@ -113,6 +183,8 @@ func (c *Client) GetPeerConnection(targetID string) (*webrtc.PeerConnection, err
 			c.mu.Lock()
 			delete(c.peerConnections, targetID)
 			c.mu.Unlock()
+			c.messenger.detach(targetID)
+			c.files.dropPeer(targetID)
 		}
 	})
 
@@ -124,6 +196,43 @@ func (c *Client) GetPeerConnection(targetID string) (*webrtc.PeerConnection, err
 	return pc, nil
 }
 
+// discardPeerConnection 关闭创建失败的PeerConnection，并在它已被保存时从列表中移除
+func (c *Client) discardPeerConnection(targetID string, pc *webrtc.PeerConnection) {
+	if err := pc.Close(); err != nil {
+		log.Error("关闭PeerConnection失败", "error", err)
+	}
+
+	c.mu.Lock()
+	if c.peerConnections[targetID] == pc {
+		delete(c.peerConnections, targetID)
+	}
+	c.mu.Unlock()
+}
+
+// handleChannel 注册对端创建的、标签以prefix开头的数据通道的处理函数
+func (c *Client) handleChannel(prefix string, handler func(peerID string, dc *webrtc.DataChannel)) {
+	c.channelMu.Lock()
+	defer c.channelMu.Unlock()
+	c.channelHandlers[prefix] = handler
+}
+
+// dispatchDataChannel 按标签前缀将对端创建的数据通道交给对应的处理函数
+func (c *Client) dispatchDataChannel(peerID string, dc *webrtc.DataChannel) {
+	prefix, _, _ := strings.Cut(dc.Label(), ":")
+
+	c.channelMu.Lock()
+	handler, ok := c.channelHandlers[prefix]
+	c.channelMu.Unlock()
+	if !ok {
+		// 对端为了建立连接创建的data通道不需要处理
+		if dc.Label() != "data" {
+			log.Warn("收到未知的数据通道", "peer_id", peerID, "label", dc.Label())
+		}
+		return
+	}
+	handler(peerID, dc)
+}
+
 // handleICECandidate 处理ICE候选并发送到信令服务器
 func (c *Client) handleICECandidate(targetID string, candidate *webrtc.ICECandidate) {
 	if candidate == nil {
@@ -196,6 +305,87 @@ func (c *Client) GetPeerConnections() map[string]*webrtc.PeerConnection {
 	return pcs
 }
 
+// setPeerSpace 记录对端所在的空间
+func (c *Client) setPeerSpace(peerID, spaceID string) {
+	c.mu.Lock()
+	c.peerSpaces[peerID] = spaceID
+	c.mu.Unlock()
+
+	if c.audioManager != nil {
+		c.audioManager.SetPeerSpace(peerID, spaceID)
+	}
+}
+
+// peerSpace 返回对端所在的空间，未知时返回空字符串
+func (c *Client) peerSpace(peerID string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.peerSpaces[peerID]
+}
+
+// GetAudioStats 获取音频编码参数和各对端的网络状况，音频未启用时返回false
+func (c *Client) GetAudioStats() (audio.Stats, bool) {
+	if c.audioManager == nil {
+		return audio.Stats{}, false
+	}
+	return c.audioManager.GetStats(), true
+}
+
+// SetAudioDevices 在不重新协商连接的情况下切换音频输入和输出设备
+//
+// 设备名与当前使用的相同时不做切换。
+func (c *Client) SetAudioDevices(input, output string) error {
+	if c.audioManager == nil {
+		return errors.New("音频功能未启用")
+	}
+
+	status := c.audioManager.DeviceStatus()
+	if input != status.PreferredInput {
+		if err := c.audioManager.SetInputDevice(input); err != nil {
+			return fmt.Errorf("切换输入设备失败: %w", err)
+		}
+	}
+	if output != status.PreferredOutput {
+		if err := c.audioManager.SetOutputDevice(output); err != nil {
+			return fmt.Errorf("切换输出设备失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetAudioDeviceStatus 返回当前使用的音频设备，音频未启用时返回false
+func (c *Client) GetAudioDeviceStatus() (audio.DeviceStatus, bool) {
+	if c.audioManager == nil {
+		return audio.DeviceStatus{}, false
+	}
+	return c.audioManager.DeviceStatus(), true
+}
+
+// SetAudioInputDevice 切换音频输入设备，空字符串表示默认设备
+func (c *Client) SetAudioInputDevice(device string) error {
+	if c.audioManager == nil {
+		return errors.New("音频功能未启用")
+	}
+	return c.audioManager.SetInputDevice(device)
+}
+
+// SetAudioOutputDevice 切换音频输出设备，空字符串表示默认设备
+func (c *Client) SetAudioOutputDevice(device string) error {
+	if c.audioManager == nil {
+		return errors.New("音频功能未启用")
+	}
+	return c.audioManager.SetOutputDevice(device)
+}
+
+// OnAudioDeviceEvent 注册音频设备事件回调，音频未启用时返回false
+func (c *Client) OnAudioDeviceEvent(handler func(audio.DeviceEvent)) bool {
+	if c.audioManager == nil {
+		return false
+	}
+	c.audioManager.OnDeviceEvent(handler)
+	return true
+}
+
 // Close 关闭所有PeerConnection
 func (c *Client) Close() {
 	c.mu.Lock()
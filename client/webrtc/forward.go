@@ -0,0 +1,428 @@
+package webrtc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v3"
+)
+
+const (
+	// MessageTypeForwardOpen 请求对端连接转发目标
+	MessageTypeForwardOpen = "forward_open"
+
+	forwardChannelPrefix = "forward" // 转发数据通道标签前缀，完整标签为forward:<转发ID>
+
+	forwardChunkSize         = 16 * 1024   // TCP每次读取的最大字节数
+	forwardMaxDatagram       = 64 * 1024   // UDP数据报的最大字节数
+	forwardMaxBufferedAmount = 1024 * 1024 // 发送缓冲超过该值时暂停读取
+	forwardLowBufferedAmount = 256 * 1024  // 发送缓冲低于该值时继续读取
+	forwardDialTimeout       = 10 * time.Second
+	forwardChannelTimeout    = 30 * time.Second // 对端连接目标后等待数据通道的时长
+	forwardUDPIdleTimeout    = 2 * time.Minute  // UDP会话无数据多久后关闭
+)
+
+// ForwardOpen 转发请求，对端按允许列表检查后连接目标
+type ForwardOpen struct {
+	ForwardID string `json:"forward_id"`
+	Network   string `json:"network"` // tcp或udp
+	Target    string `json:"target"`  // 对端要连接的地址，格式为主机:端口
+}
+
+// pendingForward 已连接目标、等待数据通道的转发
+type pendingForward struct {
+	peerID string // 发起转发的对端，只接受该对端的数据通道
+	conn   net.Conn
+}
+
+// forwarder 按配置的允许列表处理对端的转发请求
+type forwarder struct {
+	client  *Client
+	allow   map[string]bool            // 允许的目标，键为<网络>/<主机:端口>
+	spaces  map[string]bool            // 允许的空间，为空时不限制
+	pending map[string]*pendingForward // 键为转发ID
+	mu      sync.Mutex
+}
+
+// registerForwarding 注册端口转发相关的消息和数据通道处理器
+func (c *Client) registerForwarding() {
+	f := &forwarder{
+		client:  c,
+		allow:   make(map[string]bool),
+		spaces:  make(map[string]bool),
+		pending: make(map[string]*pendingForward),
+	}
+	for _, target := range c.config.Forward.Allow {
+		f.allow[strings.ToLower(strings.TrimSpace(target))] = true
+	}
+	for _, space := range c.config.Forward.Spaces {
+		f.spaces[space] = true
+	}
+
+	c.HandleMessage(MessageTypeForwardOpen, f.handleOpen)
+	c.handleChannel(forwardChannelPrefix, f.handleChannel)
+}
+
+// handleOpen 检查转发请求并连接目标
+func (f *forwarder) handleOpen(peerID string, msg *Message) (interface{}, error) {
+	var req ForwardOpen
+	if err := msg.Decode(&req); err != nil {
+		return nil, err
+	}
+	if req.Network != "tcp" && req.Network != "udp" {
+		return nil, fmt.Errorf("不支持的网络类型: %s", req.Network)
+	}
+
+	// 只有同一空间内的对端才能建立连接，可以进一步限制允许转发的空间
+	if len(f.spaces) > 0 && !f.spaces[f.client.peerSpace(peerID)] {
+		log.Warn("拒绝端口转发：对端所在空间不允许转发", "peer_id", peerID, "target", req.Target)
+		return nil, errors.New("对端所在空间不允许端口转发")
+	}
+	if !f.allow[strings.ToLower(req.Network+"/"+req.Target)] {
+		log.Warn("拒绝端口转发：目标不在允许列表中", "peer_id", peerID, "network", req.Network, "target", req.Target)
+		return nil, fmt.Errorf("目标%s/%s不在对端允许转发的列表中", req.Network, req.Target)
+	}
+
+	conn, err := net.DialTimeout(req.Network, req.Target, forwardDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("连接转发目标失败: %w", err)
+	}
+
+	pending := &pendingForward{peerID: peerID, conn: conn}
+	f.mu.Lock()
+	if _, ok := f.pending[req.ForwardID]; ok {
+		f.mu.Unlock()
+		conn.Close()
+		return nil, fmt.Errorf("重复的转发ID: %s", req.ForwardID)
+	}
+	f.pending[req.ForwardID] = pending
+	f.mu.Unlock()
+
+	// 发送方没有创建数据通道时关闭连接
+	time.AfterFunc(forwardChannelTimeout, func() {
+		f.mu.Lock()
+		ok := f.pending[req.ForwardID] == pending
+		if ok {
+			delete(f.pending, req.ForwardID)
+		}
+		f.mu.Unlock()
+		if ok {
+			conn.Close()
+		}
+	})
+
+	log.Info("开始端口转发", "peer_id", peerID, "network", req.Network, "target", req.Target)
+	return nil, nil
+}
+
+// handleChannel 将转发数据通道与已连接的目标对接
+func (f *forwarder) handleChannel(peerID string, dc *webrtc.DataChannel) {
+	forwardID := strings.TrimPrefix(dc.Label(), forwardChannelPrefix+":")
+
+	f.mu.Lock()
+	pending, ok := f.pending[forwardID]
+	if ok && pending.peerID == peerID {
+		delete(f.pending, forwardID)
+	}
+	f.mu.Unlock()
+	if !ok {
+		log.Warn("收到未知的转发通道", "peer_id", peerID, "label", dc.Label())
+		dc.Close()
+		return
+	}
+	// 其他对端猜到转发ID也不能接管已连接的目标
+	if pending.peerID != peerID {
+		log.Warn("拒绝其他对端的转发通道", "peer_id", peerID, "forward_peer_id", pending.peerID, "label", dc.Label())
+		dc.Close()
+		return
+	}
+	bridgeChannel(dc, pending.conn)
+}
+
+// Forward 在本地监听localAddr，将每个连接经对端转发到remoteAddr
+//
+// network为tcp时每个连接使用一个可靠有序的数据通道；为udp时每个来源地址使用一个
+// 不重传、不保证顺序的数据通道。返回实际监听的地址，ctx结束时停止监听并关闭所有转发。
+func (c *Client) Forward(ctx context.Context, peerID, network, localAddr, remoteAddr string) (net.Addr, error) {
+	if _, _, err := net.SplitHostPort(remoteAddr); err != nil {
+		return nil, fmt.Errorf("无效的远端地址: %w", err)
+	}
+
+	switch network {
+	case "tcp":
+		listener, err := net.Listen("tcp", localAddr)
+		if err != nil {
+			return nil, fmt.Errorf("监听本地地址失败: %w", err)
+		}
+		go func() {
+			<-ctx.Done()
+			listener.Close()
+		}()
+		go c.acceptForward(ctx, peerID, remoteAddr, listener)
+		return listener.Addr(), nil
+	case "udp":
+		conn, err := net.ListenPacket("udp", localAddr)
+		if err != nil {
+			return nil, fmt.Errorf("监听本地地址失败: %w", err)
+		}
+		go func() {
+			<-ctx.Done()
+			conn.Close()
+		}()
+		go c.forwardUDP(ctx, peerID, remoteAddr, conn)
+		return conn.LocalAddr(), nil
+	default:
+		return nil, fmt.Errorf("不支持的网络类型: %s", network)
+	}
+}
+
+// acceptForward 接受本地TCP连接并逐个转发
+func (c *Client) acceptForward(ctx context.Context, peerID, remoteAddr string, listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Error("接受转发连接失败", "error", err)
+			}
+			return
+		}
+		go c.forwardConn(ctx, peerID, remoteAddr, conn)
+	}
+}
+
+// forwardConn 请求对端连接目标后，在新的数据通道上转发TCP连接
+func (c *Client) forwardConn(ctx context.Context, peerID, remoteAddr string, conn net.Conn) {
+	dc, err := c.openForward(ctx, peerID, "tcp", remoteAddr, nil)
+	if err != nil {
+		log.Error("打开端口转发失败", "peer_id", peerID, "target", remoteAddr, "error", err)
+		conn.Close()
+		return
+	}
+
+	closed := bridgeChannel(dc, conn)
+	select {
+	case <-ctx.Done():
+		conn.Close()
+		dc.Close()
+	case <-closed:
+	}
+}
+
+// openForward 请求对端连接目标，并创建对应的数据通道
+func (c *Client) openForward(ctx context.Context, peerID, network, target string, init *webrtc.DataChannelInit) (*webrtc.DataChannel, error) {
+	req := ForwardOpen{
+		ForwardID: uuid.NewString(),
+		Network:   network,
+		Target:    target,
+	}
+	if _, err := c.Request(ctx, peerID, MessageTypeForwardOpen, req); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	pc, ok := c.peerConnections[peerID]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("未连接到对端: %s", peerID)
+	}
+	dc, err := pc.CreateDataChannel(forwardChannelPrefix+":"+req.ForwardID, init)
+	if err != nil {
+		return nil, fmt.Errorf("创建转发数据通道失败: %w", err)
+	}
+	return dc, nil
+}
+
+// bridgeChannel 在数据通道和网络连接之间双向转发，任一端关闭时关闭另一端
+//
+// 返回的通道在转发结束后关闭。
+func bridgeChannel(dc *webrtc.DataChannel, conn net.Conn) <-chan struct{} {
+	closed := make(chan struct{})
+	var closeOnce sync.Once
+	shutdown := func() {
+		closeOnce.Do(func() {
+			close(closed)
+			conn.Close()
+			dc.Close()
+		})
+	}
+
+	lowBuffer := make(chan struct{}, 1)
+	dc.SetBufferedAmountLowThreshold(forwardLowBufferedAmount)
+	dc.OnBufferedAmountLow(func() {
+		select {
+		case lowBuffer <- struct{}{}:
+		default:
+		}
+	})
+
+	// 在回调中直接写入连接：目标处理不过来时暂停接收，由对端的发送缓冲限速
+	dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+		if _, err := conn.Write(msg.Data); err != nil {
+			shutdown()
+		}
+	})
+	dc.OnClose(shutdown)
+	dc.OnOpen(func() {
+		go copyToChannel(dc, conn, lowBuffer, closed, shutdown)
+	})
+	return closed
+}
+
+// copyToChannel 将从连接读取的数据发送到数据通道，直到连接关闭
+func copyToChannel(dc *webrtc.DataChannel, conn net.Conn, lowBuffer, closed <-chan struct{}, shutdown func()) {
+	defer shutdown()
+
+	size := forwardChunkSize
+	if _, ok := conn.(net.PacketConn); ok {
+		size = forwardMaxDatagram
+	}
+	buf := make([]byte, size)
+	for {
+		for dc.BufferedAmount() > forwardMaxBufferedAmount {
+			select {
+			case <-lowBuffer:
+			case <-closed:
+				return
+			}
+		}
+
+		n, err := conn.Read(buf)
+		if n > 0 {
+			if err := dc.Send(buf[:n]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	// 连接已关闭，等待缓冲的数据发送完毕再关闭通道
+	for dc.BufferedAmount() > 0 {
+		select {
+		case <-closed:
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// udpSession 一个本地UDP来源地址对应的转发会话
+type udpSession struct {
+	dc         *webrtc.DataChannel // 数据通道打开前为nil
+	lastActive atomic.Int64        // 最后收发数据的时间(Unix纳秒)
+}
+
+// forwardUDP 按来源地址将本地UDP数据报经对端转发
+func (c *Client) forwardUDP(ctx context.Context, peerID, remoteAddr string, conn net.PacketConn) {
+	sessions := make(map[string]*udpSession)
+	var mu sync.Mutex
+
+	closeSession := func(key string, s *udpSession) {
+		mu.Lock()
+		if sessions[key] == s {
+			delete(sessions, key)
+		}
+		mu.Unlock()
+	}
+
+	// 定期关闭空闲的会话
+	go func() {
+		ticker := time.NewTicker(forwardUDPIdleTimeout / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				mu.Lock()
+				for key, s := range sessions {
+					if s.dc != nil {
+						s.dc.Close()
+					}
+					delete(sessions, key)
+				}
+				mu.Unlock()
+				return
+			case <-ticker.C:
+				idleSince := time.Now().Add(-forwardUDPIdleTimeout).UnixNano()
+				mu.Lock()
+				for key, s := range sessions {
+					if s.dc != nil && s.lastActive.Load() < idleSince {
+						s.dc.Close()
+						delete(sessions, key)
+					}
+				}
+				mu.Unlock()
+			}
+		}
+	}()
+
+	buf := make([]byte, forwardMaxDatagram)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Error("读取UDP数据失败", "error", err)
+			}
+			return
+		}
+		key := addr.String()
+
+		mu.Lock()
+		s, ok := sessions[key]
+		if !ok {
+			s = &udpSession{}
+			s.lastActive.Store(time.Now().UnixNano())
+			sessions[key] = s
+		}
+		dc := s.dc
+		mu.Unlock()
+
+		datagram := append([]byte(nil), buf[:n]...)
+		if !ok {
+			// 新的来源地址，打开会话后发送第一个数据报，期间收到的数据报丢弃
+			go func() {
+				dc, err := c.openForward(ctx, peerID, "udp", remoteAddr, unreliableChannelInit())
+				if err != nil {
+					log.Error("打开UDP转发失败", "peer_id", peerID, "target", remoteAddr, "error", err)
+					closeSession(key, s)
+					return
+				}
+				dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+					s.lastActive.Store(time.Now().UnixNano())
+					conn.WriteTo(msg.Data, addr)
+				})
+				dc.OnClose(func() { closeSession(key, s) })
+				dc.OnOpen(func() {
+					dc.Send(datagram)
+					mu.Lock()
+					s.dc = dc
+					mu.Unlock()
+				})
+			}()
+			continue
+		}
+
+		if dc != nil {
+			s.lastActive.Store(time.Now().UnixNano())
+			dc.Send(datagram)
+		}
+	}
+}
+
+// unreliableChannelInit 不重传、不保证顺序的数据通道参数
+func unreliableChannelInit() *webrtc.DataChannelInit {
+	ordered := false
+	maxRetransmits := uint16(0)
+	return &webrtc.DataChannelInit{
+		Ordered:        &ordered,
+		MaxRetransmits: &maxRetransmits,
+	}
+}
@@ -0,0 +1,239 @@
+package webrtc
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"client/config"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// newForwardClient 创建不启用音频、允许转发到allow的客户端
+func newForwardClient(t *testing.T, id string, allow ...string) *Client {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Client.ID = id
+	cfg.Forward.Allow = allow
+	c := NewClient(cfg, nil)
+	t.Cleanup(c.Close)
+	return c
+}
+
+// connectClients 不经过信令服务器，直接交换完整收集候选后的SDP建立连接
+func connectClients(t *testing.T, offerer, answerer *Client) {
+	t.Helper()
+	offerPC, err := offerer.GetPeerConnection(answerer.config.Client.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	answerPC, err := answerer.GetPeerConnection(offerer.config.Client.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offer, err := offerPC.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gathered := webrtc.GatheringCompletePromise(offerPC)
+	if err := offerPC.SetLocalDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	<-gathered
+	if err := answerPC.SetRemoteDescription(*offerPC.LocalDescription()); err != nil {
+		t.Fatal(err)
+	}
+
+	answer, err := answerPC.CreateAnswer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gathered = webrtc.GatheringCompletePromise(answerPC)
+	if err := answerPC.SetLocalDescription(answer); err != nil {
+		t.Fatal(err)
+	}
+	<-gathered
+	if err := offerPC.SetRemoteDescription(*answerPC.LocalDescription()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := offerer.messenger.waitReady(ctx, answerer.config.Client.ID, 15*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := answerer.messenger.waitReady(ctx, offerer.config.Client.ID, 15*time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tcpEcho 启动回显收到数据的TCP服务，返回其地址
+func tcpEcho(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// udpEcho 启动回显收到数据报的UDP服务，返回其地址
+func udpEcho(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, forwardMaxDatagram)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(buf[:n], addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+// TestForwardLoopback 两个客户端之间转发到允许的TCP和UDP目标，拒绝不在允许列表中的目标
+func TestForwardLoopback(t *testing.T) {
+	tcpTarget := tcpEcho(t)
+	udpTarget := udpEcho(t)
+	alice := newForwardClient(t, "alice")
+	bob := newForwardClient(t, "bob", "tcp/"+tcpTarget, "udp/"+udpTarget)
+	connectClients(t, alice, bob)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("tcp", func(t *testing.T) {
+		local, err := alice.Forward(ctx, "bob", "tcp", "127.0.0.1:0", tcpTarget)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, err := net.Dial("tcp", local.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+		payload := strings.Repeat("hello forward ", 4096) // 超过一次读取的大小
+		go conn.Write([]byte(payload))
+		got := make([]byte, len(payload))
+		if _, err := io.ReadFull(conn, got); err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != payload {
+			t.Error("回显的数据不一致")
+		}
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		denied := tcpEcho(t)
+		if _, err := alice.openForward(ctx, "bob", "tcp", denied, nil); err == nil || !strings.Contains(err.Error(), "不在对端允许转发的列表中") {
+			t.Fatalf("转发到不允许的目标应被拒绝: %v", err)
+		}
+		if _, err := alice.openForward(ctx, "bob", "udp", tcpTarget, nil); err == nil {
+			t.Fatal("网络类型不同的目标应被拒绝")
+		}
+
+		// 本地连接在对端拒绝后关闭
+		local, err := alice.Forward(ctx, "bob", "tcp", "127.0.0.1:0", denied)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, err := net.Dial("tcp", local.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+		if n, err := conn.Read(make([]byte, 1)); err == nil {
+			t.Fatalf("被拒绝的转发读取到%d字节", n)
+		}
+	})
+
+	t.Run("udp", func(t *testing.T) {
+		local, err := alice.Forward(ctx, "bob", "udp", "127.0.0.1:0", udpTarget)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, err := net.Dial("udp", local.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		// 会话打开前收到的数据报会被丢弃，重发直到收到回显
+		buf := make([]byte, 64)
+		deadline := time.Now().Add(10 * time.Second)
+		for time.Now().Before(deadline) {
+			if _, err := conn.Write([]byte("ping")); err != nil {
+				t.Fatal(err)
+			}
+			conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+			n, err := conn.Read(buf)
+			if err == nil {
+				if string(buf[:n]) != "ping" {
+					t.Fatalf("回显为%q", buf[:n])
+				}
+				return
+			}
+		}
+		t.Fatal("没有收到UDP回显")
+	})
+}
+
+// TestForwardChannelBindsPeer 转发通道只接受发起请求的对端，其他对端不能接管已连接的目标
+func TestForwardChannelBindsPeer(t *testing.T) {
+	c := newForwardClient(t, "bob")
+	f := &forwarder{client: c, pending: make(map[string]*pendingForward)}
+
+	target, peer := net.Pipe()
+	defer peer.Close()
+	f.pending["f1"] = &pendingForward{peerID: "alice", conn: target}
+
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	dc, err := pc.CreateDataChannel(forwardChannelPrefix+":f1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.handleChannel("mallory", dc)
+
+	if got, ok := f.pending["f1"]; !ok || got.peerID != "alice" {
+		t.Fatal("其他对端的通道不应移除等待中的转发")
+	}
+	// 目标连接没有被关闭
+	go target.Write([]byte("x"))
+	peer.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := peer.Read(make([]byte, 1)); err != nil {
+		t.Errorf("目标连接被关闭: %v", err)
+	}
+}
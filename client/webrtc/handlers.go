@@ -36,6 +36,13 @@ func (h *MessageHandler) HandleConnect(msg map[string]interface{}) {
 	// 确定本地客户端是发起方还是接收方
 	isInitiator := h.client.config.Client.ID == sourceID
 
+	// 记录对端所在的空间
+	peerID := sourceID
+	if isInitiator {
+		peerID = targetID
+	}
+	h.client.setPeerSpace(peerID, spaceID)
+
 	if isInitiator {
 		// 作为发起方，创建offer
 		log.Info("作为发起方创建连接", "target_id", targetID)
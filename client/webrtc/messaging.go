@@ -0,0 +1,354 @@
+package webrtc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v3"
+)
+
+const (
+	// 消息通道使用预先协商的ID，双方在创建PeerConnection时各自创建，不需要额外信令
+	reliableChannelLabel   = "messages"
+	unreliableChannelLabel = "messages-unreliable"
+	reliableChannelID      = 100
+	unreliableChannelID    = 101
+
+	maxMessageSize        = 16 * 1024 // 单条消息的最大字节数，保证各实现都能完整传输
+	defaultRequestTimeout = 10 * time.Second
+)
+
+// 内置消息类型
+const (
+	MessageTypeChat     = "chat"     // 文本聊天
+	MessageTypePing     = "ping"     // 连通性检测，对端自动回复
+	MessageTypeResponse = "response" // 请求的响应
+)
+
+// Message 对端之间传输的消息信封
+type Message struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Timestamp int64           `json:"timestamp"`          // 发送时间(Unix毫秒)
+	Request   bool            `json:"request,omitempty"`  // 发送方是否等待响应
+	ReplyTo   string          `json:"reply_to,omitempty"` // 响应对应的请求ID
+	Error     string          `json:"error,omitempty"`    // 处理请求失败的原因
+	Payload   json.RawMessage `json:"payload,omitempty"`
+}
+
+// Decode 将消息负载解析到v
+func (m *Message) Decode(v interface{}) error {
+	if len(m.Payload) == 0 {
+		return errors.New("消息负载为空")
+	}
+	return json.Unmarshal(m.Payload, v)
+}
+
+// ChatPayload 文本聊天消息的负载
+type ChatPayload struct {
+	Text string `json:"text"`
+}
+
+// PeerMessageHandler 处理某类对端消息
+//
+// 消息为请求时，返回值作为响应负载发送给对端，返回错误时对端收到错误信息。
+type PeerMessageHandler func(peerID string, msg *Message) (interface{}, error)
+
+// peerChannels 与一个对端之间的消息通道
+type peerChannels struct {
+	reliable   *webrtc.DataChannel
+	unreliable *webrtc.DataChannel
+}
+
+// messenger 基于DataChannel的对端消息层
+type messenger struct {
+	peers    map[string]*peerChannels
+	handlers map[string]PeerMessageHandler
+	pending  map[string]chan *Message // 等待响应的请求
+	mu       sync.Mutex
+}
+
+func newMessenger() *messenger {
+	m := &messenger{
+		peers:    make(map[string]*peerChannels),
+		handlers: make(map[string]PeerMessageHandler),
+		pending:  make(map[string]chan *Message),
+	}
+
+	m.handlers[MessageTypeChat] = func(peerID string, msg *Message) (interface{}, error) {
+		var chat ChatPayload
+		if err := msg.Decode(&chat); err != nil {
+			return nil, err
+		}
+		log.Info("收到聊天消息", "peer_id", peerID, "text", chat.Text)
+		return nil, nil
+	}
+	m.handlers[MessageTypePing] = func(peerID string, msg *Message) (interface{}, error) {
+		return nil, nil
+	}
+	return m
+}
+
+// attach 在PeerConnection上创建消息通道，必须在创建offer/answer之前调用
+func (m *messenger) attach(peerID string, pc *webrtc.PeerConnection) error {
+	negotiated := true
+	reliableID := uint16(reliableChannelID)
+	reliable, err := pc.CreateDataChannel(reliableChannelLabel, &webrtc.DataChannelInit{
+		Negotiated: &negotiated,
+		ID:         &reliableID,
+	})
+	if err != nil {
+		return fmt.Errorf("创建可靠消息通道失败: %w", err)
+	}
+
+	// 不可靠通道不重传、不保证顺序，适合频繁更新的状态类消息
+	ordered := false
+	maxRetransmits := uint16(0)
+	unreliableID := uint16(unreliableChannelID)
+	unreliable, err := pc.CreateDataChannel(unreliableChannelLabel, &webrtc.DataChannelInit{
+		Negotiated:     &negotiated,
+		ID:             &unreliableID,
+		Ordered:        &ordered,
+		MaxRetransmits: &maxRetransmits,
+	})
+	if err != nil {
+		return fmt.Errorf("创建不可靠消息通道失败: %w", err)
+	}
+
+	for _, dc := range []*webrtc.DataChannel{reliable, unreliable} {
+		dc.OnMessage(func(raw webrtc.DataChannelMessage) {
+			m.receive(peerID, dc, raw.Data)
+		})
+	}
+
+	m.mu.Lock()
+	m.peers[peerID] = &peerChannels{reliable: reliable, unreliable: unreliable}
+	m.mu.Unlock()
+	return nil
+}
+
+// detach 移除与对端的消息通道
+func (m *messenger) detach(peerID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.peers, peerID)
+}
+
+// waitReady 等待与对端的可靠消息通道打开，最多等待timeout
+func (m *messenger) waitReady(ctx context.Context, peerID string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		m.mu.Lock()
+		channels, ok := m.peers[peerID]
+		m.mu.Unlock()
+		if ok && channels.reliable.ReadyState() == webrtc.DataChannelStateOpen {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("等待与对端%s的连接超时", peerID)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// handle 注册消息处理器，同一类型只保留最后注册的处理器
+func (m *messenger) handle(msgType string, handler PeerMessageHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers[msgType] = handler
+}
+
+// send 发送消息
+func (m *messenger) send(peerID string, msg *Message, reliable bool) error {
+	m.mu.Lock()
+	channels, ok := m.peers[peerID]
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("未连接到对端: %s", peerID)
+	}
+
+	dc := channels.reliable
+	if !reliable {
+		dc = channels.unreliable
+	}
+	if dc.ReadyState() != webrtc.DataChannelStateOpen {
+		return fmt.Errorf("与对端%s的消息通道未打开", peerID)
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("序列化消息失败: %w", err)
+	}
+	if len(data) > maxMessageSize {
+		return fmt.Errorf("消息过大: %d字节，最大%d字节", len(data), maxMessageSize)
+	}
+	return dc.SendText(string(data))
+}
+
+// request 发送请求并等待响应
+func (m *messenger) request(ctx context.Context, peerID string, msg *Message) (*Message, error) {
+	msg.Request = true
+	reply := make(chan *Message, 1)
+
+	m.mu.Lock()
+	m.pending[msg.ID] = reply
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.pending, msg.ID)
+		m.mu.Unlock()
+	}()
+
+	if err := m.send(peerID, msg, true); err != nil {
+		return nil, err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultRequestTimeout)
+		defer cancel()
+	}
+
+	select {
+	case resp := <-reply:
+		if resp.Error != "" {
+			return resp, fmt.Errorf("对端处理请求失败: %s", resp.Error)
+		}
+		return resp, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("等待对端%s响应超时: %w", peerID, ctx.Err())
+	}
+}
+
+// receive 处理收到的原始消息
+func (m *messenger) receive(peerID string, dc *webrtc.DataChannel, data []byte) {
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Error("解析对端消息失败", "peer_id", peerID, "channel", dc.Label(), "error", err)
+		return
+	}
+
+	// 响应交给等待中的请求
+	if msg.ReplyTo != "" {
+		m.mu.Lock()
+		reply, ok := m.pending[msg.ReplyTo]
+		m.mu.Unlock()
+		if ok {
+			select {
+			case reply <- &msg:
+			default:
+			}
+		} else {
+			log.Warn("收到未知请求的响应", "peer_id", peerID, "reply_to", msg.ReplyTo)
+		}
+		return
+	}
+
+	// 请求的处理器可能再向对端发送请求，在单独的协程中处理以免阻塞通道上的后续消息
+	if msg.Request {
+		go m.dispatch(peerID, &msg)
+		return
+	}
+	m.dispatch(peerID, &msg)
+}
+
+// dispatch 调用消息处理器，消息为请求时发送响应
+func (m *messenger) dispatch(peerID string, msg *Message) {
+	m.mu.Lock()
+	handler, ok := m.handlers[msg.Type]
+	m.mu.Unlock()
+
+	var result interface{}
+	var err error
+	if ok {
+		result, err = handler(peerID, msg)
+	} else {
+		err = fmt.Errorf("不支持的消息类型: %s", msg.Type)
+		log.Warn("收到不支持的消息类型", "peer_id", peerID, "type", msg.Type)
+	}
+
+	if !msg.Request {
+		if err != nil && ok {
+			log.Error("处理对端消息失败", "peer_id", peerID, "type", msg.Type, "error", err)
+		}
+		return
+	}
+
+	resp, buildErr := newMessage(MessageTypeResponse, result)
+	if buildErr != nil {
+		resp, _ = newMessage(MessageTypeResponse, nil)
+		err = buildErr
+	}
+	resp.ReplyTo = msg.ID
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	if err := m.send(peerID, resp, true); err != nil {
+		log.Error("发送响应失败", "peer_id", peerID, "error", err)
+	}
+}
+
+// newMessage 创建带ID和时间戳的消息
+func newMessage(msgType string, payload interface{}) (*Message, error) {
+	msg := &Message{
+		ID:        uuid.NewString(),
+		Type:      msgType,
+		Timestamp: time.Now().UnixMilli(),
+	}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("序列化消息负载失败: %w", err)
+		}
+		msg.Payload = data
+	}
+	return msg, nil
+}
+
+// SendMessage 向对端发送消息，reliable为false时使用不重传、不保证顺序的通道
+func (c *Client) SendMessage(peerID, msgType string, payload interface{}, reliable bool) error {
+	msg, err := newMessage(msgType, payload)
+	if err != nil {
+		return err
+	}
+	return c.messenger.send(peerID, msg, reliable)
+}
+
+// Request 向对端发送请求并等待响应，ctx没有截止时间时默认等待10秒
+func (c *Client) Request(ctx context.Context, peerID, msgType string, payload interface{}) (*Message, error) {
+	msg, err := newMessage(msgType, payload)
+	if err != nil {
+		return nil, err
+	}
+	return c.messenger.request(ctx, peerID, msg)
+}
+
+// HandleMessage 注册对端消息处理器
+func (c *Client) HandleMessage(msgType string, handler PeerMessageHandler) {
+	c.messenger.handle(msgType, handler)
+}
+
+// SendChat 向对端发送文本聊天消息
+func (c *Client) SendChat(peerID, text string) error {
+	return c.SendMessage(peerID, MessageTypeChat, ChatPayload{Text: text}, true)
+}
+
+// Ping 检测与对端的消息通道，返回往返时间
+func (c *Client) Ping(ctx context.Context, peerID string) (time.Duration, error) {
+	start := time.Now()
+	if _, err := c.Request(ctx, peerID, MessageTypePing, nil); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
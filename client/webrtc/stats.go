@@ -0,0 +1,137 @@
+package webrtc
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	"client/audio"
+
+	"github.com/charmbracelet/log"
+	"github.com/pion/webrtc/v3"
+)
+
+// iceTransportStatsID pion统计报告中ICE传输层统计的ID
+const iceTransportStatsID = "iceTransport"
+
+// PeerStats 与一个对端的连接统计
+type PeerStats struct {
+	PeerID          string  `json:"peer_id"`
+	State           string  `json:"state"`                      // PeerConnection连接状态
+	RTTMs           float64 `json:"rtt_ms"`                     // 选中候选对的当前往返时间(毫秒)
+	JitterMs        float64 `json:"jitter_ms"`                  // 对端RTCP报告的到达抖动(毫秒)，音频未启用时为0
+	LossPercent     float64 `json:"loss_percent"`               // 对端RTCP报告的丢包率(%)，音频未启用时为0
+	BytesSent       uint64  `json:"bytes_sent"`                 // ICE传输层发送的字节数
+	BytesReceived   uint64  `json:"bytes_received"`             // ICE传输层接收的字节数
+	LocalCandidate  string  `json:"local_candidate,omitempty"`  // 本地候选类型: host、srflx、prflx、relay
+	RemoteCandidate string  `json:"remote_candidate,omitempty"` // 对端候选类型
+	Protocol        string  `json:"protocol,omitempty"`         // 候选对使用的传输协议
+}
+
+// ClientStats 定期采集的客户端统计
+type ClientStats struct {
+	Timestamp time.Time    `json:"timestamp"`
+	ClientID  string       `json:"client_id"`
+	Peers     []PeerStats  `json:"peers"`
+	Audio     *audio.Stats `json:"audio,omitempty"` // 音频未启用时为空
+}
+
+// CollectStats 采集所有对端连接和音频流水线的统计
+func (c *Client) CollectStats() ClientStats {
+	stats := ClientStats{
+		Timestamp: time.Now(),
+		ClientID:  c.config.Client.ID,
+		Peers:     []PeerStats{},
+	}
+
+	var audioStats audio.Stats
+	if c.audioManager != nil {
+		audioStats = c.audioManager.GetStats()
+		stats.Audio = &audioStats
+	}
+
+	for peerID, pc := range c.GetPeerConnections() {
+		peer := PeerStats{
+			PeerID: peerID,
+			State:  pc.ConnectionState().String(),
+		}
+
+		// 候选对统计不包含字节数，字节数取自ICE传输层统计
+		if transport, ok := pc.GetStats()[iceTransportStatsID].(webrtc.TransportStats); ok {
+			peer.BytesSent = transport.BytesSent
+			peer.BytesReceived = transport.BytesReceived
+		}
+
+		ice := pc.SCTP().Transport().ICETransport()
+		if pair, ok := ice.GetSelectedCandidatePairStats(); ok {
+			peer.RTTMs = pair.CurrentRoundTripTime * 1000
+		}
+		if pair, err := ice.GetSelectedCandidatePair(); err == nil && pair != nil {
+			peer.LocalCandidate = pair.Local.Typ.String()
+			peer.RemoteCandidate = pair.Remote.Typ.String()
+			peer.Protocol = pair.Local.Protocol.String()
+		}
+
+		// RTP统计来自音频管理器处理的RTCP反馈
+		if network, ok := audioStats.Peers[peerID]; ok {
+			peer.JitterMs = network.JitterMs
+			peer.LossPercent = network.LossPercent
+		}
+		stats.Peers = append(stats.Peers, peer)
+	}
+
+	sort.Slice(stats.Peers, func(i, j int) bool {
+		return stats.Peers[i].PeerID < stats.Peers[j].PeerID
+	})
+	return stats
+}
+
+// RunStats 按配置的间隔采集统计，发送给服务器并写入本地文件，直到ctx结束
+func (c *Client) RunStats(ctx context.Context) {
+	interval := c.config.Stats.Interval
+	if interval <= 0 {
+		log.Info("统计采集已禁用")
+		return
+	}
+
+	// 本地文件每行一条JSON记录，便于事后分析通话质量
+	var encoder *json.Encoder
+	if c.config.Stats.File != "" {
+		file, err := os.OpenFile(c.config.Stats.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.Error("打开统计文件失败", "error", err)
+		} else {
+			defer file.Close()
+			encoder = json.NewEncoder(file)
+		}
+	}
+
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats := c.CollectStats()
+
+			if encoder != nil {
+				if err := encoder.Encode(stats); err != nil {
+					log.Error("写入统计文件失败", "error", err)
+				}
+			}
+
+			// 没有对端连接时不上报，减少服务器负担
+			if len(stats.Peers) == 0 {
+				continue
+			}
+			c.SendJSON(map[string]interface{}{
+				"type": "stats",
+				"data": stats,
+			})
+		}
+	}
+}
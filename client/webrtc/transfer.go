@@ -0,0 +1,665 @@
+package webrtc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v3"
+)
+
+const (
+	// 文件传输使用的消息类型
+	MessageTypeFileOffer = "file_offer" // 发送方请求传输文件
+	MessageTypeFileDone  = "file_done"  // 发送方已发送全部数据，等待接收方校验
+
+	fileChannelPrefix = "file" // 文件数据通道标签前缀，完整标签为file:<传输ID>
+
+	fileChunkSize          = 16 * 1024   // 每个数据块的大小
+	fileMaxBufferedAmount  = 1024 * 1024 // 发送缓冲超过该值时暂停发送
+	fileLowBufferedAmount  = 256 * 1024  // 发送缓冲低于该值时继续发送
+	fileMaxAttempts        = 5           // 连接中断后最多重试的次数
+	fileReconnectWait      = time.Minute // 每次重试等待对端重新连接的时长
+	fileVerifyTimeout      = time.Minute // 等待接收方写入和校验的时长
+	partialFileSuffix      = ".part"
+	defaultMaxFileSizeMB   = 1024
+	fileChannelOpenTimeout = 30 * time.Second
+	fileAcceptTimeout      = 2 * time.Minute // 未开启自动接收时等待用户确认的时长
+	fileIdleTimeout        = time.Minute     // 接收方在该时长内没有收到数据时放弃传输
+)
+
+// FileOffer 文件传输请求
+type FileOffer struct {
+	TransferID string `json:"transfer_id"`
+	Name       string `json:"name"`
+	Size       int64  `json:"size"`
+	SHA256     string `json:"sha256"`
+}
+
+// FileAnswer 接收方对文件传输请求的答复
+type FileAnswer struct {
+	Offset int64 `json:"offset"` // 已接收的字节数，发送方从这里继续发送
+}
+
+// FileDone 发送完成通知
+type FileDone struct {
+	TransferID string `json:"transfer_id"`
+}
+
+// FileResult 接收方校验后的结果
+type FileResult struct {
+	Path string `json:"path"` // 接收方保存的文件路径
+}
+
+// FileOfferEvent 等待用户确认的文件传输请求
+type FileOfferEvent struct {
+	PeerID string `json:"peer_id"`
+	FileOffer
+}
+
+// FileProgress 文件发送进度
+type FileProgress struct {
+	Sent  int64 `json:"sent"`
+	Total int64 `json:"total"`
+}
+
+// incomingTransfer 正在接收的文件
+type incomingTransfer struct {
+	offer    FileOffer
+	peerID   string // 发起传输的对端，只接受该对端的数据通道
+	partPath string
+	offset   int64       // 开始接收时的偏移
+	timer    *time.Timer // 空闲超时后移除传输
+
+	mu       sync.Mutex // 数据通道回调和其他协程同时访问
+	file     *os.File
+	received int64
+	finished bool
+	done     chan struct{} // 接收完成或失败后关闭
+	path     string        // 校验通过后的保存路径
+	err      error
+}
+
+// fileReceiver 按配置策略接收对端发送的文件
+type fileReceiver struct {
+	dir        string
+	autoAccept bool
+	maxSize    int64
+	transfers  map[string]*incomingTransfer
+	mu         sync.Mutex
+
+	// 未开启自动接收时由回调通知用户，等待AnswerFileOffer答复
+	offerHandlers []func(FileOfferEvent)
+	decisions     map[string]chan bool
+}
+
+// registerFileTransfer 注册文件传输相关的消息和数据通道处理器
+func (c *Client) registerFileTransfer() {
+	maxSizeMB := c.config.Transfer.MaxSizeMB
+	if maxSizeMB <= 0 {
+		maxSizeMB = defaultMaxFileSizeMB
+	}
+	r := &fileReceiver{
+		dir:        c.config.Transfer.ReceiveDir,
+		autoAccept: c.config.Transfer.AutoAccept,
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		transfers:  make(map[string]*incomingTransfer),
+		decisions:  make(map[string]chan bool),
+	}
+	c.files = r
+
+	c.HandleMessage(MessageTypeFileOffer, r.handleOffer)
+	c.HandleMessage(MessageTypeFileDone, r.handleDone)
+	c.handleChannel(fileChannelPrefix, r.handleChannel)
+}
+
+// handleOffer 按接收策略决定是否接收文件，返回可续传的偏移
+func (r *fileReceiver) handleOffer(peerID string, msg *Message) (interface{}, error) {
+	var offer FileOffer
+	if err := msg.Decode(&offer); err != nil {
+		return nil, err
+	}
+
+	if r.dir == "" {
+		log.Warn("拒绝文件传输：未设置接收目录", "peer_id", peerID, "name", offer.Name)
+		return nil, errors.New("对端未开启文件接收")
+	}
+	name := filepath.Base(offer.Name)
+	if name == "." || name == ".." || name == string(filepath.Separator) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("无效的文件名: %s", offer.Name)
+	}
+	if offer.Size < 0 || offer.Size > r.maxSize {
+		return nil, fmt.Errorf("文件大小%d字节超过对端限制%d字节", offer.Size, r.maxSize)
+	}
+	if _, err := hex.DecodeString(offer.SHA256); err != nil || len(offer.SHA256) != sha256.Size*2 {
+		return nil, errors.New("无效的SHA-256")
+	}
+	offer.Name = name
+
+	if !r.autoAccept {
+		if err := r.waitAccept(peerID, offer); err != nil {
+			log.Warn("拒绝文件传输", "peer_id", peerID, "name", name, "error", err)
+			return nil, err
+		}
+	}
+
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建接收目录失败: %w", err)
+	}
+
+	// 未完成的数据按内容哈希保存，重新连接后发送相同文件即可续传
+	partPath := filepath.Join(r.dir, "."+offer.SHA256+partialFileSuffix)
+	var offset int64
+	if info, err := os.Stat(partPath); err == nil && info.Size() <= offer.Size {
+		offset = info.Size()
+	}
+
+	r.mu.Lock()
+	for id, t := range r.transfers {
+		// 同一文件的上一次传输已中断
+		if t.offer.SHA256 == offer.SHA256 {
+			r.removeLocked(id, t, errors.New("传输被新的请求取代"))
+		}
+	}
+	t := &incomingTransfer{
+		offer:    offer,
+		peerID:   peerID,
+		partPath: partPath,
+		offset:   offset,
+		received: offset,
+		done:     make(chan struct{}),
+	}
+	// 数据通道一直没有建立或发送方消失时，空闲超时后释放
+	t.timer = time.AfterFunc(fileIdleTimeout, func() {
+		r.expire(offer.TransferID, t)
+	})
+	r.transfers[offer.TransferID] = t
+	r.mu.Unlock()
+
+	log.Info("接收文件", "peer_id", peerID, "name", name, "size", offer.Size, "offset", offset)
+	return FileAnswer{Offset: offset}, nil
+}
+
+// waitAccept 通知回调并等待用户确认，没有注册回调时直接拒绝
+func (r *fileReceiver) waitAccept(peerID string, offer FileOffer) error {
+	r.mu.Lock()
+	handlers := append([]func(FileOfferEvent){}, r.offerHandlers...)
+	if len(handlers) == 0 {
+		r.mu.Unlock()
+		return errors.New("对端未开启文件接收")
+	}
+	if _, exists := r.decisions[offer.TransferID]; exists {
+		r.mu.Unlock()
+		return errors.New("重复的文件传输请求")
+	}
+	decision := make(chan bool, 1)
+	r.decisions[offer.TransferID] = decision
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.decisions, offer.TransferID)
+		r.mu.Unlock()
+	}()
+
+	log.Info("等待确认文件传输", "peer_id", peerID, "name", offer.Name, "size", offer.Size, "transfer_id", offer.TransferID)
+	event := FileOfferEvent{PeerID: peerID, FileOffer: offer}
+	for _, handler := range handlers {
+		handler(event)
+	}
+
+	select {
+	case accepted := <-decision:
+		if !accepted {
+			return errors.New("对端拒绝接收文件")
+		}
+		return nil
+	case <-time.After(fileAcceptTimeout):
+		return errors.New("等待对端确认超时")
+	}
+}
+
+// answer 答复等待确认的文件传输请求
+func (r *fileReceiver) answer(transferID string, accept bool) error {
+	r.mu.Lock()
+	decision, ok := r.decisions[transferID]
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("没有等待确认的文件传输: %s", transferID)
+	}
+	select {
+	case decision <- accept:
+		return nil
+	default:
+		return errors.New("该文件传输已经答复")
+	}
+}
+
+// removeLocked 结束并移除传输，调用方需持有r.mu
+func (r *fileReceiver) removeLocked(id string, t *incomingTransfer, err error) {
+	t.timer.Stop()
+	t.finish(err)
+	delete(r.transfers, id)
+}
+
+// expire 传输空闲超时，关闭临时文件并移除，保留已接收的数据以便续传
+func (r *fileReceiver) expire(id string, t *incomingTransfer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.transfers[id] != t {
+		return
+	}
+	log.Warn("文件传输超时", "peer_id", t.peerID, "name", t.offer.Name)
+	r.removeLocked(id, t, errors.New("文件传输超时"))
+}
+
+// dropPeer 对端断开连接时移除它的所有传输
+func (r *fileReceiver) dropPeer(peerID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, t := range r.transfers {
+		if t.peerID == peerID {
+			r.removeLocked(id, t, errors.New("对端已断开连接"))
+		}
+	}
+}
+
+// handleChannel 将文件数据通道上收到的数据写入临时文件
+func (r *fileReceiver) handleChannel(peerID string, dc *webrtc.DataChannel) {
+	transferID := strings.TrimPrefix(dc.Label(), fileChannelPrefix+":")
+
+	r.mu.Lock()
+	t, ok := r.transfers[transferID]
+	r.mu.Unlock()
+	if !ok {
+		log.Warn("收到未知的文件传输通道", "peer_id", peerID, "label", dc.Label())
+		dc.Close()
+		return
+	}
+	if t.peerID != peerID {
+		log.Warn("拒绝其他对端的文件传输通道", "peer_id", peerID, "offer_peer_id", t.peerID, "label", dc.Label())
+		dc.Close()
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.finished {
+		dc.Close()
+		return
+	}
+	t.timer.Reset(fileIdleTimeout)
+
+	file, err := os.OpenFile(t.partPath, os.O_WRONLY|os.O_CREATE, 0600)
+	if err == nil {
+		// 丢弃超出续传偏移的不完整数据
+		if err = file.Truncate(t.offset); err == nil {
+			_, err = file.Seek(t.offset, io.SeekStart)
+		}
+	}
+	if err != nil {
+		log.Error("打开临时文件失败", "error", err)
+		if file != nil {
+			file.Close()
+		}
+		t.finishLocked(err)
+		dc.Close()
+		return
+	}
+	t.file = file
+
+	if t.received == t.offer.Size {
+		t.completeLocked(r.dir)
+	}
+
+	dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.finished {
+			return
+		}
+		t.timer.Reset(fileIdleTimeout)
+
+		if t.received+int64(len(msg.Data)) > t.offer.Size {
+			t.finishLocked(errors.New("收到的数据超过文件大小"))
+			dc.Close()
+			return
+		}
+		if _, err := t.file.Write(msg.Data); err != nil {
+			t.finishLocked(fmt.Errorf("写入文件失败: %w", err))
+			dc.Close()
+			return
+		}
+		t.received += int64(len(msg.Data))
+		if t.received == t.offer.Size {
+			t.completeLocked(r.dir)
+		}
+	})
+	dc.OnClose(func() {
+		// 未接收完整时保留临时文件以便续传
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.finishLocked(fmt.Errorf("传输中断，已接收%d/%d字节", t.received, t.offer.Size))
+	})
+}
+
+// handleDone 等待数据写入和校验完成后答复发送方
+func (r *fileReceiver) handleDone(peerID string, msg *Message) (interface{}, error) {
+	var done FileDone
+	if err := msg.Decode(&done); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	t, ok := r.transfers[done.TransferID]
+	r.mu.Unlock()
+	if !ok || t.peerID != peerID {
+		return nil, errors.New("未知的文件传输")
+	}
+
+	// 由本请求负责移除传输，不再需要空闲超时
+	t.timer.Stop()
+	select {
+	case <-t.done:
+	case <-time.After(fileVerifyTimeout):
+		t.finish(errors.New("等待文件数据超时"))
+	}
+
+	r.mu.Lock()
+	if r.transfers[done.TransferID] == t {
+		delete(r.transfers, done.TransferID)
+	}
+	r.mu.Unlock()
+
+	if t.err != nil {
+		return nil, t.err
+	}
+	log.Info("文件接收完成", "peer_id", peerID, "path", t.path)
+	return FileResult{Path: t.path}, nil
+}
+
+// completeLocked 校验接收完整的文件并移动到接收目录，调用方需持有t.mu
+func (t *incomingTransfer) completeLocked(dir string) {
+	err := t.file.Close()
+	t.file = nil
+	if err != nil {
+		t.finishLocked(fmt.Errorf("关闭文件失败: %w", err))
+		return
+	}
+
+	sum, err := fileSHA256(t.partPath)
+	if err != nil {
+		t.finishLocked(err)
+		return
+	}
+	if sum != t.offer.SHA256 {
+		// 数据已损坏，删除后下次从头传输
+		os.Remove(t.partPath)
+		t.finishLocked(errors.New("SHA-256校验失败"))
+		return
+	}
+
+	path := uniquePath(filepath.Join(dir, t.offer.Name))
+	if err := os.Rename(t.partPath, path); err != nil {
+		t.finishLocked(fmt.Errorf("保存文件失败: %w", err))
+		return
+	}
+	t.path = path
+	t.finishLocked(nil)
+}
+
+// finish 结束传输
+func (t *incomingTransfer) finish(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.finishLocked(err)
+}
+
+// finishLocked 结束传输，只有第一次调用生效，调用方需持有t.mu
+func (t *incomingTransfer) finishLocked(err error) {
+	if t.finished {
+		return
+	}
+	t.finished = true
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+	t.err = err
+	close(t.done)
+}
+
+// OnFileOffer 注册文件传输请求回调
+//
+// 未开启自动接收时，收到请求会调用回调并等待AnswerFileOffer答复，超时视为拒绝；
+// 没有注册回调时直接拒绝。回调不应阻塞。
+func (c *Client) OnFileOffer(handler func(FileOfferEvent)) {
+	c.files.mu.Lock()
+	defer c.files.mu.Unlock()
+	c.files.offerHandlers = append(c.files.offerHandlers, handler)
+}
+
+// AnswerFileOffer 接受或拒绝等待确认的文件传输请求
+func (c *Client) AnswerFileOffer(transferID string, accept bool) error {
+	return c.files.answer(transferID, accept)
+}
+
+// SendFile 向对端发送文件，连接中断后等待重新连接并从中断处继续
+func (c *Client) SendFile(ctx context.Context, peerID, path string, progress func(FileProgress)) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("读取文件失败: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("不是普通文件: %s", path)
+	}
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return "", err
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= fileMaxAttempts; attempt++ {
+		if err := c.messenger.waitReady(ctx, peerID, fileReconnectWait); err != nil {
+			if lastErr != nil {
+				return "", fmt.Errorf("%w(上次错误: %v)", err, lastErr)
+			}
+			return "", err
+		}
+
+		offer := FileOffer{
+			TransferID: uuid.NewString(),
+			Name:       filepath.Base(path),
+			Size:       info.Size(),
+			SHA256:     sum,
+		}
+		// 对端未开启自动接收时需要等待用户确认
+		offerCtx, cancel := context.WithTimeout(ctx, fileAcceptTimeout+10*time.Second)
+		resp, err := c.Request(offerCtx, peerID, MessageTypeFileOffer, offer)
+		cancel()
+		if err != nil {
+			if resp != nil {
+				// 对端拒绝，不再重试
+				return "", err
+			}
+			lastErr = err
+			continue
+		}
+		var answer FileAnswer
+		if err := resp.Decode(&answer); err != nil {
+			return "", fmt.Errorf("解析对端答复失败: %w", err)
+		}
+
+		dc, err := c.sendFileData(ctx, peerID, path, offer, answer.Offset, progress)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			log.Warn("文件传输中断，等待重新连接", "peer_id", peerID, "attempt", attempt, "error", err)
+			lastErr = err
+			continue
+		}
+
+		// 数据通道在对端确认前保持打开，避免关闭时丢弃尚未送达的数据
+		verifyCtx, cancel := context.WithTimeout(ctx, fileVerifyTimeout+10*time.Second)
+		resp, err = c.Request(verifyCtx, peerID, MessageTypeFileDone, FileDone{TransferID: offer.TransferID})
+		cancel()
+		dc.Close()
+		if err != nil {
+			if resp != nil {
+				return "", err
+			}
+			lastErr = err
+			continue
+		}
+		var result FileResult
+		if err := resp.Decode(&result); err != nil {
+			return "", fmt.Errorf("解析对端答复失败: %w", err)
+		}
+		return result.Path, nil
+	}
+	return "", fmt.Errorf("文件传输失败，已重试%d次: %w", fileMaxAttempts, lastErr)
+}
+
+// sendFileData 在专用数据通道上从offset开始发送文件内容
+//
+// 成功时返回仍处于打开状态的数据通道，由调用方在对端确认后关闭。
+func (c *Client) sendFileData(ctx context.Context, peerID, path string, offer FileOffer, offset int64, progress func(FileProgress)) (*webrtc.DataChannel, error) {
+	c.mu.RLock()
+	pc, ok := c.peerConnections[peerID]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("未连接到对端: %s", peerID)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("定位文件失败: %w", err)
+	}
+
+	dc, err := pc.CreateDataChannel(fileChannelPrefix+":"+offer.TransferID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建文件数据通道失败: %w", err)
+	}
+	if err := streamFile(ctx, dc, file, offset, offer.Size, progress); err != nil {
+		dc.Close()
+		return nil, err
+	}
+	return dc, nil
+}
+
+// streamFile 按发送缓冲区的水位分块发送文件
+func streamFile(ctx context.Context, dc *webrtc.DataChannel, file *os.File, offset, size int64, progress func(FileProgress)) error {
+	opened := make(chan struct{})
+	closed := make(chan struct{})
+	lowBuffer := make(chan struct{}, 1)
+	dc.OnOpen(func() { close(opened) })
+	dc.OnClose(func() { close(closed) })
+	dc.SetBufferedAmountLowThreshold(fileLowBufferedAmount)
+	dc.OnBufferedAmountLow(func() {
+		select {
+		case lowBuffer <- struct{}{}:
+		default:
+		}
+	})
+
+	select {
+	case <-opened:
+	case <-closed:
+		return errors.New("文件数据通道被关闭")
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(fileChannelOpenTimeout):
+		return errors.New("打开文件数据通道超时")
+	}
+
+	buf := make([]byte, fileChunkSize)
+	sent := offset
+	for sent < size {
+		// 发送缓冲过多时等待对端消化，避免占满内存
+		for dc.BufferedAmount() > fileMaxBufferedAmount {
+			select {
+			case <-lowBuffer:
+			case <-closed:
+				return errors.New("文件数据通道被关闭")
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		n, err := file.Read(buf)
+		if n > 0 {
+			if err := dc.Send(buf[:n]); err != nil {
+				return fmt.Errorf("发送文件数据失败: %w", err)
+			}
+			sent += int64(n)
+			if progress != nil {
+				progress(FileProgress{Sent: sent, Total: size})
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("读取文件失败: %w", err)
+		}
+	}
+	if sent != size {
+		return errors.New("文件在发送过程中被修改")
+	}
+
+	// 等待缓冲的数据发送完毕再关闭通道
+	for dc.BufferedAmount() > 0 {
+		select {
+		case <-closed:
+			return errors.New("文件数据通道被关闭")
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	return nil
+}
+
+// fileSHA256 计算文件的SHA-256
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("计算SHA-256失败: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// uniquePath 文件已存在时在文件名后添加序号
+func uniquePath(path string) string {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return path
+	}
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
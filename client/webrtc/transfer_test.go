@@ -0,0 +1,149 @@
+package webrtc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+// newTestReceiver 创建接收目录位于临时目录的文件接收器
+func newTestReceiver(t *testing.T, autoAccept bool) *fileReceiver {
+	t.Helper()
+	return &fileReceiver{
+		dir:        t.TempDir(),
+		autoAccept: autoAccept,
+		maxSize:    1024 * 1024,
+		transfers:  make(map[string]*incomingTransfer),
+		decisions:  make(map[string]chan bool),
+	}
+}
+
+// offerMessage 构造文件传输请求消息
+func offerMessage(t *testing.T, transferID, content string) *Message {
+	t.Helper()
+	sum := sha256.Sum256([]byte(content))
+	msg, err := newMessage(MessageTypeFileOffer, FileOffer{
+		TransferID: transferID,
+		Name:       transferID + ".txt",
+		Size:       int64(len(content)),
+		SHA256:     hex.EncodeToString(sum[:]),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func doneMessage(t *testing.T, transferID string) *Message {
+	t.Helper()
+	msg, err := newMessage(MessageTypeFileDone, FileDone{TransferID: transferID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+// TestFileReceiverBindsPeer 传输只属于发起请求的对端
+func TestFileReceiverBindsPeer(t *testing.T) {
+	r := newTestReceiver(t, true)
+	if _, err := r.handleOffer("alice", offerMessage(t, "t1", "hello")); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.transfers["t1"].peerID; got != "alice" {
+		t.Fatalf("记录的对端为%q", got)
+	}
+
+	// 其他对端不能完成该传输
+	if _, err := r.handleDone("mallory", doneMessage(t, "t1")); err == nil {
+		t.Error("其他对端的完成通知应被拒绝")
+	}
+	if _, ok := r.transfers["t1"]; !ok {
+		t.Error("其他对端的请求不应移除传输")
+	}
+}
+
+// TestFileReceiverDropPeer 对端断开时只移除它的传输
+func TestFileReceiverDropPeer(t *testing.T) {
+	r := newTestReceiver(t, true)
+	if _, err := r.handleOffer("alice", offerMessage(t, "t1", "hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.handleOffer("bob", offerMessage(t, "t2", "world")); err != nil {
+		t.Fatal(err)
+	}
+	alice := r.transfers["t1"]
+
+	r.dropPeer("alice")
+
+	if _, ok := r.transfers["t1"]; ok {
+		t.Error("断开的对端的传输没有被移除")
+	}
+	if _, ok := r.transfers["t2"]; !ok {
+		t.Error("其他对端的传输被移除")
+	}
+	select {
+	case <-alice.done:
+		if alice.err == nil {
+			t.Error("被移除的传输应带有错误")
+		}
+	default:
+		t.Error("被移除的传输没有结束")
+	}
+}
+
+// TestFileReceiverExpire 数据通道一直没有建立时超时移除
+func TestFileReceiverExpire(t *testing.T) {
+	r := newTestReceiver(t, true)
+	if _, err := r.handleOffer("alice", offerMessage(t, "t1", "hello")); err != nil {
+		t.Fatal(err)
+	}
+	transfer := r.transfers["t1"]
+
+	r.expire("t1", transfer)
+
+	if _, ok := r.transfers["t1"]; ok {
+		t.Error("超时的传输没有被移除")
+	}
+	if _, err := r.handleDone("alice", doneMessage(t, "t1")); err == nil {
+		t.Error("超时后的完成通知应返回错误")
+	}
+}
+
+// TestFileReceiverAcceptHook 未开启自动接收时由回调确认
+func TestFileReceiverAcceptHook(t *testing.T) {
+	r := newTestReceiver(t, false)
+
+	// 没有回调时拒绝
+	if _, err := r.handleOffer("alice", offerMessage(t, "t1", "hello")); err == nil {
+		t.Fatal("没有回调时应拒绝文件传输")
+	}
+
+	var offers []FileOfferEvent
+	accept := map[string]bool{"t2": true, "t3": false}
+	r.offerHandlers = append(r.offerHandlers, func(event FileOfferEvent) {
+		offers = append(offers, event)
+		if err := r.answer(event.TransferID, accept[event.TransferID]); err != nil {
+			t.Error(err)
+		}
+	})
+
+	if _, err := r.handleOffer("alice", offerMessage(t, "t2", "hello")); err != nil {
+		t.Fatalf("确认接收后仍被拒绝: %v", err)
+	}
+	if _, ok := r.transfers["t2"]; !ok {
+		t.Error("确认接收的传输没有记录")
+	}
+	if _, err := r.handleOffer("alice", offerMessage(t, "t3", "world")); err == nil {
+		t.Error("拒绝接收后应返回错误")
+	}
+	if _, ok := r.transfers["t3"]; ok {
+		t.Error("拒绝接收的传输不应记录")
+	}
+
+	if len(offers) != 2 || offers[0].PeerID != "alice" || offers[0].Name != "t2.txt" {
+		t.Errorf("回调收到的请求不正确: %+v", offers)
+	}
+	if err := r.answer("t2", true); err == nil {
+		t.Error("已经处理的请求不能再次答复")
+	}
+}
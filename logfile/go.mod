@@ -0,0 +1,3 @@
+module logfile
+
+go 1.23.3
@@ -0,0 +1,45 @@
+package logfile
+
+import (
+	"io"
+	"regexp"
+)
+
+// sensitiveField 匹配文本和JSON格式日志中的敏感字段及其值
+//
+// 文本格式为key=value或key="value"，JSON格式为"key":"value"。字段名必须以敏感词
+// 结尾，前面可带以_或.分隔的前缀(如session_token、user.password)，因此
+// token_id、token_prefix这类只是引用凭据的字段不会被隐去。
+var sensitiveField = regexp.MustCompile(`(?i)(^|[\s{,])("?(?:[\w.]*[_.])?(?:token|password|passwd|secret|private_key|api_key|apikey|authorization|cookie|challenge)"?)(=|:\s*)("(?:[^"\\]|\\.)*"|[^\s,}]+)`)
+
+// redactWriter 写出前隐去日志中的令牌、密钥和密码
+//
+// 日志库每条记录只调用一次Write，因此可以按整条记录替换。
+type redactWriter struct {
+	w io.Writer
+}
+
+// NewRedactWriter 返回写出前隐去敏感字段值的Writer
+func NewRedactWriter(w io.Writer) io.Writer {
+	return &redactWriter{w: w}
+}
+
+func (r *redactWriter) Write(p []byte) (int, error) {
+	if _, err := r.w.Write(Redact(p)); err != nil {
+		return 0, err
+	}
+	// 返回原始长度，调用方据此判断是否完整写入
+	return len(p), nil
+}
+
+// Redact 将一条日志中敏感字段的值替换为***
+func Redact(p []byte) []byte {
+	return sensitiveField.ReplaceAllFunc(p, func(field []byte) []byte {
+		m := sensitiveField.FindSubmatch(field)
+		value := "***"
+		if len(m[4]) > 0 && m[4][0] == '"' {
+			value = `"***"`
+		}
+		return append(append(append(append([]byte{}, m[1]...), m[2]...), m[3]...), value...)
+	})
+}
@@ -0,0 +1,47 @@
+package logfile
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"文本令牌", `INFO 登录成功 token=abc123`, `INFO 登录成功 token=***`},
+		{"文本带引号", `INFO 登录 password="p w\"d" user=bob`, `INFO 登录 password="***" user=bob`},
+		{"文本前缀", `INFO 刷新 session_token=s1 access_token=a1`, `INFO 刷新 session_token=*** access_token=***`},
+		{"文本点分前缀", `DEBU 请求 user.password=x`, `DEBU 请求 user.password=***`},
+		{"文本大小写", `INFO 请求 Authorization=Bearer`, `INFO 请求 Authorization=***`},
+		{"文本令牌ID保留", `INFO 吊销令牌 token_id=42 token_prefix=gp2p_ab`, `INFO 吊销令牌 token_id=42 token_prefix=gp2p_ab`},
+		{"文本相似词保留", `INFO 统计 tokens=3 passwordless=true`, `INFO 统计 tokens=3 passwordless=true`},
+		{"JSON令牌", `{"level":"info","msg":"登录","token":"abc"}`, `{"level":"info","msg":"登录","token":"***"}`},
+		{"JSON数值", `{"msg":"挑战","challenge":12345,"user":"bob"}`, `{"msg":"挑战","challenge":***,"user":"bob"}`},
+		{"JSON前缀", `{"msg":"x","client_secret":"s","private_key":"k"}`, `{"msg":"x","client_secret":"***","private_key":"***"}`},
+		{"JSON令牌ID保留", `{"msg":"吊销","token_id":"42","token_prefix":"gp2p_ab"}`, `{"msg":"吊销","token_id":"42","token_prefix":"gp2p_ab"}`},
+		{"JSON带空格", `{"msg":"x", "api_key": "k"}`, `{"msg":"x", "api_key": "***"}`},
+		{"行首", `password=x`, `password=***`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(Redact([]byte(tt.in))); got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactWriterLength(t *testing.T) {
+	var buf bytes.Buffer
+	line := []byte("INFO x token=abcdefgh\n")
+	n, err := NewRedactWriter(&buf).Write(line)
+	if err != nil || n != len(line) {
+		t.Fatalf("Write = %d, %v, want %d, nil", n, err, len(line))
+	}
+	if buf.String() != "INFO x token=***\n" {
+		t.Errorf("written %q", buf.String())
+	}
+}
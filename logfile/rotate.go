@@ -0,0 +1,188 @@
+// Package logfile 提供客户端和服务端共用的日志输出：按大小和日期轮转的日志文件，
+// 以及写出前隐去敏感字段的包装
+package logfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat 轮转后日志文件名中的时间格式
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// Options 日志文件配置
+type Options struct {
+	Dir        string // 日志目录
+	Name       string // 日志文件名(不含扩展名)
+	MaxSizeMB  int    // 单个日志文件的大小上限(MB)，超过后轮转，0表示不按大小轮转
+	MaxAgeDays int    // 轮转后的日志保留天数，0表示不按时间清理
+	MaxBackups int    // 轮转后的日志最多保留的个数，0表示不按个数清理
+}
+
+// File 按大小和日期轮转的日志文件
+//
+// 当前日志写入<Name>.log，文件超过大小上限或跨天时重命名为<Name>-<时间>.log，
+// 并按保留天数和个数清理旧文件。按大小轮转时备份名取轮转时刻，跨天轮转时取
+// 文件打开时刻，使备份名落在其内容所属的日期。
+type File struct {
+	dir        string
+	name       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	now        func() time.Time
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// Open 打开日志文件，已存在时追加写入
+func Open(opts Options) (*File, error) {
+	return open(opts, time.Now)
+}
+
+func open(opts Options, now func() time.Time) (*File, error) {
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建日志目录失败: %w", err)
+	}
+
+	w := &File{
+		dir:        opts.Dir,
+		name:       opts.Name,
+		maxSize:    int64(opts.MaxSizeMB) * 1024 * 1024,
+		maxAge:     time.Duration(opts.MaxAgeDays) * 24 * time.Hour,
+		maxBackups: opts.MaxBackups,
+		now:        now,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	go w.cleanup()
+	return w, nil
+}
+
+// Write 写入一条日志，需要时先轮转
+func (w *File) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.size > 0 {
+		now := w.now()
+		var stamp time.Time
+		switch {
+		case !sameDay(w.openedAt, now):
+			stamp = w.openedAt
+		case w.maxSize > 0 && w.size+int64(len(p)) > w.maxSize:
+			stamp = now
+		}
+		if !stamp.IsZero() {
+			if err := w.rotate(stamp); err != nil {
+				// 轮转失败时继续写入当前文件，避免丢失日志
+				fmt.Fprintf(os.Stderr, "轮转日志文件失败: %v\n", err)
+			}
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close 关闭当前日志文件
+func (w *File) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+// sameDay 判断两个时刻是否在同一天
+func sameDay(a, b time.Time) bool {
+	y1, m1, d1 := a.Date()
+	y2, m2, d2 := b.Date()
+	return y1 == y2 && m1 == m2 && d1 == d2
+}
+
+// path 返回当前日志文件路径
+func (w *File) path() string {
+	return filepath.Join(w.dir, w.name+".log")
+}
+
+// open 打开当前日志文件
+func (w *File) open() error {
+	file, err := os.OpenFile(w.path(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("创建日志文件失败: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("读取日志文件信息失败: %w", err)
+	}
+
+	w.file = file
+	w.size = info.Size()
+	w.openedAt = w.now()
+	// 沿用上次运行留下的文件时，按其修改时间判断是否跨天
+	if w.size > 0 {
+		w.openedAt = info.ModTime()
+	}
+	return nil
+}
+
+// rotate 将当前日志文件重命名为以stamp命名的备份并打开新文件
+func (w *File) rotate(stamp time.Time) error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	backup := filepath.Join(w.dir, fmt.Sprintf("%s-%s.log", w.name, stamp.Format(backupTimeFormat)))
+	if err := os.Rename(w.path(), backup); err != nil {
+		// 重命名失败时重新打开原文件
+		if openErr := w.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	go w.cleanup()
+	return nil
+}
+
+// cleanup 删除超过保留天数或个数的备份日志
+func (w *File) cleanup() {
+	if w.maxAge <= 0 && w.maxBackups <= 0 {
+		return
+	}
+
+	backups, err := filepath.Glob(filepath.Join(w.dir, w.name+"-*.log"))
+	if err != nil {
+		return
+	}
+	// 文件名中的时间可以直接按字典序排序，最新的在前
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+
+	cutoff := w.now().Add(-w.maxAge)
+	for i, backup := range backups {
+		expired := w.maxBackups > 0 && i >= w.maxBackups
+		if !expired && w.maxAge > 0 {
+			stamp := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(backup), w.name+"-"), ".log")
+			if t, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local); err == nil && t.Before(cutoff) {
+				expired = true
+			}
+		}
+		if expired {
+			// 日志输出本身依赖该文件，错误直接写到标准错误
+			if err := os.Remove(backup); err != nil {
+				fmt.Fprintf(os.Stderr, "删除过期日志失败: %s: %v\n", backup, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package logfile
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// backups 返回目录中的备份日志文件名，按时间排序
+func backups(t *testing.T, dir string) []string {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, "app-*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(paths))
+	for i, p := range paths {
+		names[i] = filepath.Base(p)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestFileRotateBySize(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local)
+	w, err := open(Options{Dir: dir, Name: "app", MaxSizeMB: 1}, func() time.Time { return now })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	line := make([]byte, 600*1024)
+	if _, err := w.Write(line); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Hour)
+	if _, err := w.Write(line); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"app-2026-03-01T11-00-00.000.log"}
+	if got := backups(t, dir); len(got) != 1 || got[0] != want[0] {
+		t.Fatalf("backups = %v, want %v", got, want)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "app.log")); err != nil || info.Size() != int64(len(line)) {
+		t.Fatalf("current log size = %v, %v", info, err)
+	}
+}
+
+func TestFileRotateByDay(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 3, 1, 8, 30, 0, 0, time.Local)
+	w, err := open(Options{Dir: dir, Name: "app"}, func() time.Time { return now })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if _, err := w.Write([]byte("day1\n")); err != nil {
+		t.Fatal(err)
+	}
+	// 跨天后的第一条日志触发轮转，备份名应落在前一天
+	now = time.Date(2026, 3, 2, 0, 0, 1, 0, time.Local)
+	if _, err := w.Write([]byte("day2\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "app-2026-03-01T08-30-00.000.log"
+	if got := backups(t, dir); len(got) != 1 || got[0] != want {
+		t.Fatalf("backups = %v, want [%s]", got, want)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, want))
+	if err != nil || string(data) != "day1\n" {
+		t.Fatalf("backup content = %q, %v", data, err)
+	}
+}
+
+func TestFileCleanup(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+	for _, name := range []string{
+		"app-2026-03-01T00-00-00.000.log", // 超过保留天数
+		"app-2026-03-07T00-00-00.000.log", // 超过保留个数
+		"app-2026-03-08T00-00-00.000.log",
+		"app-2026-03-09T00-00-00.000.log",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := &File{dir: dir, name: "app", maxAge: 5 * 24 * time.Hour, maxBackups: 2, now: func() time.Time { return now }}
+	w.cleanup()
+
+	want := []string{"app-2026-03-08T00-00-00.000.log", "app-2026-03-09T00-00-00.000.log"}
+	got := backups(t, dir)
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("backups = %v, want %v", got, want)
+	}
+}
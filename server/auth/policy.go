@@ -0,0 +1,170 @@
+// Package auth 提供密码策略、登录失败锁定和请求限流
+//
+// 各项参数通过环境变量配置，由LoadFromEnv在启动时读取，未设置时使用默认值。
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// PasswordPolicy 密码策略
+type PasswordPolicy struct {
+	MinLength  int // 最小长度(字符数)
+	MinClasses int // 至少包含的字符种类数，种类为小写字母、大写字母、数字和其他字符
+}
+
+// LockoutPolicy 登录失败锁定策略
+//
+// 连续失败次数达到阈值后锁定，锁定时长从BaseDelay开始每次失败翻倍，不超过MaxDelay。
+type LockoutPolicy struct {
+	MaxFailures   int           // 同一账号允许的连续失败次数
+	IPMaxFailures int           // 同一来源IP允许的连续失败次数
+	BaseDelay     time.Duration // 首次锁定时长
+	MaxDelay      time.Duration // 最长锁定时长
+}
+
+// SessionPolicy 会话有效期策略
+//
+// 会话每次使用后顺延IdleTimeout，但自创建起不超过MaxLifetime，刷新令牌也不会延长MaxLifetime。
+type SessionPolicy struct {
+	IdleTimeout time.Duration // 超过该时间未使用的会话过期
+	MaxLifetime time.Duration // 会话自创建起的最长有效期
+}
+
+// bcryptMaxLength bcrypt只使用密码的前72字节
+const bcryptMaxLength = 72
+
+var (
+	// Passwords 当前的密码策略
+	Passwords = PasswordPolicy{
+		MinLength:  8,
+		MinClasses: 2,
+	}
+
+	// Lockout 当前的登录失败锁定策略
+	Lockout = LockoutPolicy{
+		MaxFailures:   5,
+		IPMaxFailures: 20,
+		BaseDelay:     30 * time.Second,
+		MaxDelay:      time.Hour,
+	}
+
+	// Sessions 当前的会话有效期策略
+	Sessions = SessionPolicy{
+		IdleTimeout: 24 * time.Hour,
+		MaxLifetime: 30 * 24 * time.Hour,
+	}
+
+	// RateLimitPerMinute 登录、WebAPIKey和客户端连接接口每个来源IP每分钟允许的请求数
+	RateLimitPerMinute = 30
+
+	// RateLimitBurst 限流允许的突发请求数
+	RateLimitBurst = 10
+)
+
+// LoadFromEnv 从环境变量读取密码策略、锁定策略和限流参数
+//
+// 支持PASSWORD_MIN_LENGTH、PASSWORD_MIN_CLASSES、LOGIN_MAX_FAILURES、LOGIN_IP_MAX_FAILURES、
+// LOGIN_LOCKOUT_BASE_SECONDS、LOGIN_LOCKOUT_MAX_SECONDS、SESSION_IDLE_HOURS、SESSION_MAX_DAYS、
+// RATE_LIMIT_PER_MINUTE和RATE_LIMIT_BURST。
+func LoadFromEnv() {
+	Passwords.MinLength = envInt("PASSWORD_MIN_LENGTH", Passwords.MinLength)
+	Passwords.MinClasses = envInt("PASSWORD_MIN_CLASSES", Passwords.MinClasses)
+	Lockout.MaxFailures = envInt("LOGIN_MAX_FAILURES", Lockout.MaxFailures)
+	Lockout.IPMaxFailures = envInt("LOGIN_IP_MAX_FAILURES", Lockout.IPMaxFailures)
+	Lockout.BaseDelay = time.Duration(envInt("LOGIN_LOCKOUT_BASE_SECONDS", int(Lockout.BaseDelay/time.Second))) * time.Second
+	Lockout.MaxDelay = time.Duration(envInt("LOGIN_LOCKOUT_MAX_SECONDS", int(Lockout.MaxDelay/time.Second))) * time.Second
+	Sessions.IdleTimeout = time.Duration(envInt("SESSION_IDLE_HOURS", int(Sessions.IdleTimeout/time.Hour))) * time.Hour
+	Sessions.MaxLifetime = time.Duration(envInt("SESSION_MAX_DAYS", int(Sessions.MaxLifetime/(24*time.Hour)))) * 24 * time.Hour
+	RateLimitPerMinute = envInt("RATE_LIMIT_PER_MINUTE", RateLimitPerMinute)
+	RateLimitBurst = envInt("RATE_LIMIT_BURST", RateLimitBurst)
+}
+
+// envInt 读取非负整数环境变量，未设置或无效时返回默认值
+func envInt(name string, def int) int {
+	if v := os.Getenv(name); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return def
+}
+
+// Validate 检查密码是否符合策略，username非空时不允许密码与用户名相同
+func (p PasswordPolicy) Validate(password, username string) error {
+	if password == "" {
+		return errors.New("密码不能为空")
+	}
+	if n := len([]rune(password)); n < p.MinLength {
+		return fmt.Errorf("密码长度不能少于%d个字符", p.MinLength)
+	}
+	if len(password) > bcryptMaxLength {
+		return fmt.Errorf("密码长度不能超过%d字节", bcryptMaxLength)
+	}
+	if username != "" && strings.EqualFold(password, username) {
+		return errors.New("密码不能与用户名相同")
+	}
+
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	classes := 0
+	for _, ok := range []bool{lower, upper, digit, other} {
+		if ok {
+			classes++
+		}
+	}
+	if classes < p.MinClasses {
+		return fmt.Errorf("密码至少需要包含小写字母、大写字母、数字和其他字符中的%d种", p.MinClasses)
+	}
+	return nil
+}
+
+// LockDuration 返回连续失败failures次后的锁定时长，未达到阈值maxFailures时返回0
+func (p LockoutPolicy) LockDuration(failures, maxFailures int) time.Duration {
+	if maxFailures <= 0 || failures < maxFailures {
+		return 0
+	}
+	// 限制指数，避免溢出
+	exp := failures - maxFailures
+	if exp > 30 {
+		exp = 30
+	}
+	// 先按浮点数比较上限，BaseDelay较大时乘积可能超出Duration的范围
+	d := float64(p.BaseDelay) * math.Pow(2, float64(exp))
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		return p.MaxDelay
+	}
+	if d >= math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(d)
+}
+
+// ExpiresAt 返回在now使用创建于createdAt的会话后的过期时间
+func (p SessionPolicy) ExpiresAt(createdAt, now time.Time) time.Time {
+	expiresAt := now.Add(p.IdleTimeout)
+	if p.MaxLifetime > 0 {
+		if limit := createdAt.Add(p.MaxLifetime); expiresAt.After(limit) {
+			expiresAt = limit
+		}
+	}
+	return expiresAt
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, MinClasses: 3}
+	tests := []struct {
+		password string
+		username string
+		want     string // 错误说明包含的内容，为空表示通过
+	}{
+		{"Abcdefg1", "", ""},
+		{"abcdefg1!", "", ""},
+		{"密码Abc12345", "", ""},
+		{"", "", "不能为空"},
+		{"Ab1!", "", "不能少于8个字符"},
+		{"密码密码密码1A", "", ""},      // 按字符计算长度
+		{"密码Ab1", "", "不能少于8个字符"}, // 字节数够但字符数不够
+		{strings.Repeat("Aa1", 25), "", "不能超过72字节"},
+		{"abcdefgh", "", "3种"},
+		{"abcdefg1", "", "3种"},
+		{"Bob12345x", "bob12345X", "不能与用户名相同"}, // 不区分大小写
+		{"Bob12345x", "", ""},
+	}
+	for _, tt := range tests {
+		err := policy.Validate(tt.password, tt.username)
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("Validate(%q) = %v, want nil", tt.password, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Validate(%q) = %v, want ...%s...", tt.password, err, tt.want)
+		}
+	}
+}
+
+func TestLockDuration(t *testing.T) {
+	policy := LockoutPolicy{BaseDelay: 30 * time.Second, MaxDelay: 10 * time.Minute}
+	tests := []struct {
+		failures, maxFailures int
+		want                  time.Duration
+	}{
+		{0, 5, 0},
+		{4, 5, 0},
+		{5, 5, 30 * time.Second}, // 达到阈值时锁定BaseDelay
+		{6, 5, time.Minute},      // 之后每次失败翻倍
+		{7, 5, 2 * time.Minute},
+		{9, 5, 8 * time.Minute},
+		{10, 5, 10 * time.Minute}, // 不超过MaxDelay
+		{1000, 5, 10 * time.Minute},
+		{100, 0, 0}, // 阈值为0时不锁定
+	}
+	for _, tt := range tests {
+		if got := policy.LockDuration(tt.failures, tt.maxFailures); got != tt.want {
+			t.Errorf("LockDuration(%d, %d) = %v, want %v", tt.failures, tt.maxFailures, got, tt.want)
+		}
+	}
+
+	// 没有上限时指数被限制，不会溢出为负数
+	unbounded := LockoutPolicy{BaseDelay: time.Second}
+	if got := unbounded.LockDuration(1000, 1); got <= 0 {
+		t.Errorf("无上限时LockDuration = %v", got)
+	}
+}
+
+// TestLockoutThresholds 账号和来源IP按各自的阈值锁定
+func TestLockoutThresholds(t *testing.T) {
+	policy := LockoutPolicy{MaxFailures: 5, IPMaxFailures: 20, BaseDelay: 30 * time.Second, MaxDelay: time.Hour}
+
+	for failures := 1; failures <= 25; failures++ {
+		account := policy.LockDuration(failures, policy.MaxFailures)
+		ip := policy.LockDuration(failures, policy.IPMaxFailures)
+		if (account > 0) != (failures >= 5) {
+			t.Errorf("%d次失败时账号锁定%v", failures, account)
+		}
+		if (ip > 0) != (failures >= 20) {
+			t.Errorf("%d次失败时来源IP锁定%v", failures, ip)
+		}
+	}
+	if got := policy.LockDuration(21, policy.IPMaxFailures); got != time.Minute {
+		t.Errorf("来源IP第21次失败锁定%v, want 1m", got)
+	}
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"server/metrics"
+)
+
+// idleTimeout 超过该时间没有请求的来源不再保留限流状态
+const idleTimeout = 10 * time.Minute
+
+// bucket 一个来源的令牌桶
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter 按来源IP限流的令牌桶
+type RateLimiter struct {
+	name  string
+	rate  float64 // 每秒补充的令牌数
+	burst float64
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	cleaned time.Time
+}
+
+// NewRateLimiter 创建限流器，name用于指标标签，perMinute为0时不限流
+func NewRateLimiter(name string, perMinute, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		name:    name,
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+		cleaned: time.Now(),
+	}
+}
+
+// Allow 消耗key的一个令牌，令牌不足时返回false和需要等待的时间
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	if l.rate <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.cleanup(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// cleanup 定期删除长时间没有请求的来源，调用方需持有锁
+func (l *RateLimiter) cleanup(now time.Time) {
+	if now.Sub(l.cleaned) < idleTimeout {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > idleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+	l.cleaned = now
+}
+
+// Middleware 按来源IP限流，超出限制时返回429
+func (l *RateLimiter) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := l.Allow(ClientIP(r)); !ok {
+			metrics.RateLimited.WithLabelValues(l.name).Inc()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "请求过于频繁，请稍后再试", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// ClientIP 返回请求的来源IP
+//
+// 只使用连接的对端地址，不信任X-Forwarded-For等可被客户端伪造的请求头。
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
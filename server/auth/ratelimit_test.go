@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestLimiter 创建使用手动时钟的限流器，返回推进时钟的函数
+func newTestLimiter(perMinute, burst int) (*RateLimiter, func(time.Duration)) {
+	l := NewRateLimiter("test", perMinute, burst)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+	return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestRateLimiterBurstAndRefill(t *testing.T) {
+	l, advance := newTestLimiter(60, 3) // 每秒补充1个令牌
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("第%d个请求在突发范围内应允许", i+1)
+		}
+	}
+	ok, wait := l.Allow("a")
+	if ok || wait != time.Second {
+		t.Fatalf("超出突发后 = %v, %v, want false, 1s", ok, wait)
+	}
+
+	// 半个令牌时仍拒绝，等待时间随补充减少
+	advance(500 * time.Millisecond)
+	if ok, wait := l.Allow("a"); ok || wait != 500*time.Millisecond {
+		t.Fatalf("补充半个令牌后 = %v, %v, want false, 500ms", ok, wait)
+	}
+	advance(500 * time.Millisecond)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Fatal("补充一个令牌后应允许")
+	}
+	if ok, _ := l.Allow("a"); ok {
+		t.Fatal("令牌用完后应拒绝")
+	}
+
+	// 其他来源不受影响
+	if ok, _ := l.Allow("b"); !ok {
+		t.Fatal("其他来源应允许")
+	}
+
+	// 长时间空闲后最多恢复到突发上限
+	advance(time.Minute)
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("空闲后第%d个请求应允许", i+1)
+		}
+	}
+	if ok, _ := l.Allow("a"); ok {
+		t.Fatal("令牌不应超过突发上限")
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	l, _ := newTestLimiter(0, 1)
+	for i := 0; i < 100; i++ {
+		if ok, wait := l.Allow("a"); !ok || wait != 0 {
+			t.Fatalf("perMinute为0时不限流: %v, %v", ok, wait)
+		}
+	}
+}
+
+func TestRateLimiterCleanup(t *testing.T) {
+	l, advance := newTestLimiter(60, 1)
+	l.Allow("a")
+	advance(idleTimeout / 2)
+	l.Allow("b")
+
+	advance(idleTimeout/2 + time.Second)
+	l.Allow("c")
+	if _, ok := l.buckets["a"]; ok {
+		t.Error("空闲超时的来源没有被清理")
+	}
+	if _, ok := l.buckets["b"]; !ok {
+		t.Error("未超时的来源被清理")
+	}
+}
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	l, advance := newTestLimiter(30, 1) // 每2秒补充1个令牌
+	handler := l.Middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/login", nil)
+		r.RemoteAddr = remoteAddr
+		// 不信任客户端提供的转发头
+		r.Header.Set("X-Forwarded-For", "203.0.113.9")
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	if w := request("192.0.2.1:1000"); w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d", w.Code)
+	}
+	// 同一IP的其他端口共享限额
+	w := request("192.0.2.1:2000")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" {
+		t.Fatalf("status = %d, Retry-After = %q, want 429, 2", w.Code, w.Header().Get("Retry-After"))
+	}
+	// Retry-After向上取整
+	advance(1500 * time.Millisecond)
+	if w := request("192.0.2.1:1000"); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("status = %d, Retry-After = %q, want 429, 1", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := request("192.0.2.2:1000"); w.Code != http.StatusNoContent {
+		t.Fatalf("其他IP status = %d", w.Code)
+	}
+	advance(500 * time.Millisecond)
+	if w := request("192.0.2.1:1000"); w.Code != http.StatusNoContent {
+		t.Fatalf("等待后status = %d", w.Code)
+	}
+}
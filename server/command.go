@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"server/db"
+	"server/handlers"
+	"server/openapi"
+)
+
+const commandUsage = `用法:
+  server                    启动服务器
+  server db fsck [-repair]  检查数据库中的孤立数据，-repair时删除
+  server openapi [-check]   输出OpenAPI接口文档，-check时检查所有路由是否都在文档中，响应中是否有敏感字段`
+
+// runCommand 执行命令行子命令，返回进程退出码
+func runCommand(args []string) int {
+	switch {
+	case len(args) >= 2 && args[0] == "db" && args[1] == "fsck":
+		return runFsck(args)
+	case args[0] == "openapi":
+		return runOpenAPI(args)
+	}
+	fmt.Fprintln(os.Stderr, commandUsage)
+	return 2
+}
+
+// runOpenAPI 输出接口文档，-check时只检查注册的路由是否都在文档中、响应中是否有敏感字段，有问题时返回1
+func runOpenAPI(args []string) int {
+	fs := flag.NewFlagSet("openapi", flag.ContinueOnError)
+	check := fs.Bool("check", false, "检查所有路由是否都在文档中，响应中是否有敏感字段")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	if *check {
+		// 文档页面只在设置API_DOCS时注册，检查时也需要注册
+		missing := openapi.Undocumented(handlers.Operations(), registerRoutes(http.NewServeMux(), true))
+		for _, r := range missing {
+			fmt.Printf("%s %s 未在接口文档中描述\n", r.Method, r.Pattern)
+		}
+		exposures := openapi.Exposures(handlers.Operations())
+		for _, e := range exposures {
+			fmt.Printf("%s %s 的响应包含敏感字段 %s\n", e.Method, e.Path, e.Field)
+		}
+		if len(missing) > 0 || len(exposures) > 0 {
+			return 1
+		}
+		fmt.Println("所有路由都已在接口文档中描述，响应中没有敏感字段")
+		return 0
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(handlers.OpenAPI()); err != nil {
+		fmt.Fprintln(os.Stderr, "生成接口文档失败:", err)
+		return 1
+	}
+	return 0
+}
+
+// runFsck 检查数据库中的孤立数据，-repair时删除
+func runFsck(args []string) int {
+
+	fs := flag.NewFlagSet("db fsck", flag.ContinueOnError)
+	repair := fs.Bool("repair", false, "删除检查到的孤立数据")
+	if err := fs.Parse(args[2:]); err != nil {
+		return 2
+	}
+
+	if err := db.Init(); err != nil {
+		fmt.Fprintln(os.Stderr, "数据库初始化失败:", err)
+		return 1
+	}
+
+	check := db.FindOrphans
+	if *repair {
+		check = db.RepairOrphans
+	}
+	checks, err := check()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "检查孤立数据失败:", err)
+		return 1
+	}
+
+	total := 0
+	for _, c := range checks {
+		fmt.Printf("%s.%s %s: %d\n", c.Table, c.Column, c.Description, len(c.IDs))
+		for _, id := range c.IDs {
+			fmt.Printf("  %s\n", id)
+		}
+		total += len(c.IDs)
+	}
+
+	switch {
+	case total == 0:
+		fmt.Println("未发现孤立数据")
+	case *repair:
+		fmt.Printf("已删除%d条孤立数据\n", total)
+	default:
+		fmt.Printf("发现%d条孤立数据，使用-repair删除\n", total)
+		return 1
+	}
+	return 0
+}
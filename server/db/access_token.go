@@ -0,0 +1,153 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"server/metrics"
+	"server/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AccessTokenPrefix 个人访问令牌的固定前缀，用于与会话令牌区分
+const AccessTokenPrefix = "p2p_"
+
+// accessTokenDisplayLength 保存用于识别令牌的前缀长度
+const accessTokenDisplayLength = len(AccessTokenPrefix) + 6
+
+// accessTokenColumns 查询访问令牌时的列，与scanAccessToken的顺序一致
+const accessTokenColumns = "id, user_id, name, token_prefix, scopes, expires_at, last_used_at, last_used_ip, created_at"
+
+// scanAccessToken 读取一行访问令牌数据，权限范围以空格分隔保存
+func scanAccessToken(row interface{ Scan(...interface{}) error }) (*models.AccessToken, error) {
+	token := &models.AccessToken{}
+	var (
+		scopes     string
+		expiresAt  sql.NullTime
+		lastUsedAt sql.NullTime
+	)
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.Prefix, &scopes, &expiresAt, &lastUsedAt, &token.LastUsedIP, &token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	token.Scopes = strings.Fields(scopes)
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	return token, nil
+}
+
+// CreateAccessToken 为用户创建个人访问令牌，expiresAt为nil时永不过期
+func CreateAccessToken(userID, name string, scopes []string, expiresAt *time.Time) (*models.AccessToken, error) {
+	defer metrics.ObserveQuery("CreateAccessToken")()
+
+	secret, err := newSessionToken()
+	if err != nil {
+		return nil, err
+	}
+	plain := AccessTokenPrefix + secret
+
+	token := &models.AccessToken{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    plain[:accessTokenDisplayLength],
+		Token:     plain,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO access_tokens (id, user_id, name, token_hash, token_prefix, scopes, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		token.ID,
+		token.UserID,
+		token.Name,
+		hashToken(plain),
+		token.Prefix,
+		strings.Join(scopes, " "),
+		token.ExpiresAt,
+		token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// GetAccessTokenByToken 通过明文令牌获取访问令牌，不存在时返回nil
+func GetAccessTokenByToken(plain string) (*models.AccessToken, error) {
+	defer metrics.ObserveQuery("GetAccessTokenByToken")()
+
+	token, err := scanAccessToken(db.QueryRow(
+		"SELECT "+accessTokenColumns+" FROM access_tokens WHERE token_hash = ?",
+		hashToken(plain),
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// GetAccessTokensByUserID 获取用户的所有访问令牌，最近创建的在前
+func GetAccessTokensByUserID(userID string) ([]*models.AccessToken, error) {
+	defer metrics.ObserveQuery("GetAccessTokensByUserID")()
+
+	rows, err := db.Query(
+		"SELECT "+accessTokenColumns+" FROM access_tokens WHERE user_id = ? ORDER BY created_at DESC",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*models.AccessToken{}
+	for rows.Next() {
+		token, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// TouchAccessToken 记录访问令牌的最近使用时间和来源IP
+func TouchAccessToken(id, ip string, usedAt time.Time) error {
+	defer metrics.ObserveQuery("TouchAccessToken")()
+
+	_, err := db.Exec("UPDATE access_tokens SET last_used_at = ?, last_used_ip = ? WHERE id = ?", usedAt, ip, id)
+	return err
+}
+
+// DeleteAccessToken 撤销用户的指定访问令牌
+func DeleteAccessToken(id, userID string) error {
+	defer metrics.ObserveQuery("DeleteAccessToken")()
+
+	result, err := db.Exec("DELETE FROM access_tokens WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+
+	// 检查是否找到并删除了令牌
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("访问令牌不存在")
+	}
+
+	return nil
+}
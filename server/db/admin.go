@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"server/metrics"
 	"server/models"
 	"time"
 
@@ -12,6 +13,8 @@ import (
 
 // SaveAdmin 保存管理员信息到数据库
 func SaveAdmin(admin *models.Admin) error {
+	defer metrics.ObserveQuery("SaveAdmin")()
+
 	// 检查用户名是否已存在
 	existingAdmin, err := GetAdminByUsername(admin.Username)
 	if err != nil {
@@ -41,7 +44,9 @@ func SaveAdmin(admin *models.Admin) error {
 
 // GetAdminList 获取管理员列表
 func GetAdminList() ([]*models.Admin, error) {
-	rows, err := db.Query("SELECT id, username, created_at FROM admins")
+	defer metrics.ObserveQuery("GetAdminList")()
+
+	rows, err := db.Query("SELECT id, username, must_change_password, created_at FROM admins")
 	if err != nil {
 		log.Error("查询管理员列表失败", "error", err)
 		return nil, err
@@ -51,7 +56,7 @@ func GetAdminList() ([]*models.Admin, error) {
 	var admins []*models.Admin
 	for rows.Next() {
 		admin := &models.Admin{}
-		err := rows.Scan(&admin.ID, &admin.Username, &admin.CreatedAt)
+		err := rows.Scan(&admin.ID, &admin.Username, &admin.MustChangePassword, &admin.CreatedAt)
 		if err != nil {
 			log.Error("扫描管理员数据失败", "error", err)
 			return nil, err
@@ -64,11 +69,13 @@ func GetAdminList() ([]*models.Admin, error) {
 
 // GetAdminByID 通过ID获取管理员信息
 func GetAdminByID(id string) (*models.Admin, error) {
+	defer metrics.ObserveQuery("GetAdminByID")()
+
 	admin := &models.Admin{}
 	err := db.QueryRow(
-		"SELECT id, username, created_at FROM admins WHERE id = ?",
+		"SELECT id, username, must_change_password, created_at FROM admins WHERE id = ?",
 		id,
-	).Scan(&admin.ID, &admin.Username, &admin.CreatedAt)
+	).Scan(&admin.ID, &admin.Username, &admin.MustChangePassword, &admin.CreatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -83,6 +90,8 @@ func GetAdminByID(id string) (*models.Admin, error) {
 
 // UpdateAdmin 更新管理员信息
 func UpdateAdmin(admin *models.Admin) error {
+	defer metrics.ObserveQuery("UpdateAdmin")()
+
 	// 检查管理员是否存在
 	existingAdmin, err := GetAdminByID(admin.ID)
 	if err != nil {
@@ -109,9 +118,9 @@ func UpdateAdmin(admin *models.Admin) error {
 		if err != nil {
 			return err
 		}
-		// 更新用户名和密码
+		// 更新用户名和密码，修改密码后不再要求强制修改
 		_, err = db.Exec(
-			"UPDATE admins SET username = ?, password = ? WHERE id = ?",
+			"UPDATE admins SET username = ?, password = ?, must_change_password = FALSE WHERE id = ?",
 			admin.Username,
 			string(hashedPassword),
 			admin.ID,
@@ -130,6 +139,8 @@ func UpdateAdmin(admin *models.Admin) error {
 
 // DeleteAdmin 删除管理员
 func DeleteAdmin(id string) error {
+	defer metrics.ObserveQuery("DeleteAdmin")()
+
 	// 检查是否是最后一个管理员
 	var count int
 	err := db.QueryRow("SELECT COUNT(*) FROM admins").Scan(&count)
@@ -140,20 +151,27 @@ func DeleteAdmin(id string) error {
 		return fmt.Errorf("不能删除最后一个管理员")
 	}
 
-	// 删除管理员
-	result, err := db.Exec("DELETE FROM admins WHERE id = ?", id)
-	if err != nil {
-		return err
-	}
+	// 删除管理员及其会话
+	return withTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec("DELETE FROM admins WHERE id = ?", id)
+		if err != nil {
+			return err
+		}
 
-	// 检查是否找到并删除了管理员
-	affected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if affected == 0 {
-		return fmt.Errorf("管理员不存在")
-	}
+		// 检查是否找到并删除了管理员
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return fmt.Errorf("管理员不存在")
+		}
 
-	return nil
+		// 同时删除该管理员代为登录用户的会话
+		_, err = tx.Exec(
+			"DELETE FROM sessions WHERE (user_id = ? AND principal_kind = ?) OR impersonator_id = ?",
+			id, string(models.PrincipalAdmin), id,
+		)
+		return err
+	})
 }
\ No newline at end of file
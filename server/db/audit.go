@@ -0,0 +1,97 @@
+package db
+
+import (
+	"server/metrics"
+	"server/models"
+	"strings"
+	"time"
+)
+
+// AddAuditLog 写入一条审计记录
+//
+// audit_log表只允许插入，删除用户或空间时也不会删除相关的审计记录。
+func AddAuditLog(entry *models.AuditEntry) error {
+	defer metrics.ObserveQuery("AddAuditLog")()
+
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	result, err := db.Exec(`
+		INSERT INTO audit_log (request_id, actor_kind, actor_id, action, target, ip, status, outcome, detail, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.RequestID, entry.ActorKind, entry.ActorID, entry.Action, entry.Target, entry.IP,
+		entry.Status, entry.Outcome, entry.Detail, entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+	entry.ID, err = result.LastInsertId()
+	return err
+}
+
+// QueryAuditLog 按条件分页查询审计记录，按时间倒序返回，同时返回符合条件的总数
+func QueryAuditLog(filter models.AuditFilter) ([]*models.AuditEntry, int, error) {
+	defer metrics.ObserveQuery("QueryAuditLog")()
+
+	var (
+		conds []string
+		args  []interface{}
+	)
+	for _, c := range []struct {
+		column string
+		value  string
+	}{
+		{"actor_kind", filter.ActorKind},
+		{"actor_id", filter.ActorID},
+		{"target", filter.Target},
+		{"ip", filter.IP},
+		{"outcome", filter.Outcome},
+	} {
+		if c.value != "" {
+			conds = append(conds, c.column+" = ?")
+			args = append(args, c.value)
+		}
+	}
+	if filter.Action != "" {
+		conds = append(conds, "instr(action, ?) > 0")
+		args = append(args, filter.Action)
+	}
+	if !filter.Since.IsZero() {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		conds = append(conds, "created_at < ?")
+		args = append(args, filter.Until)
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM audit_log"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Query(`
+		SELECT id, request_id, actor_kind, actor_id, action, target, ip, status, outcome, detail, created_at
+		FROM audit_log`+where+`
+		ORDER BY id DESC LIMIT ? OFFSET ?
+	`, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []*models.AuditEntry{}
+	for rows.Next() {
+		entry := &models.AuditEntry{}
+		if err := rows.Scan(&entry.ID, &entry.RequestID, &entry.ActorKind, &entry.ActorID, &entry.Action,
+			&entry.Target, &entry.IP, &entry.Status, &entry.Outcome, &entry.Detail, &entry.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, total, rows.Err()
+}
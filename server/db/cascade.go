@@ -0,0 +1,106 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"server/models"
+)
+
+// 级联删除
+//
+// 表定义中的外键没有ON DELETE CASCADE，且sessions.user_id同时保存用户和管理员的ID，
+// 启用PRAGMA foreign_keys会导致管理员无法登录，因此由应用在同一事务内删除关联数据：
+//   - 删除空间时删除空间内的客户端、TURN服务器和WebAPIKey
+//   - 删除用户时删除用户拥有的空间(及其关联数据)、客户端、TURN服务器、WebAPIKey、访问令牌和会话
+//   - 删除管理员时删除其会话
+// 遗留的孤立数据可以通过server db fsck检查和修复。
+
+// withTx 在事务中执行fn，fn返回错误时回滚
+func withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// queryIDs 查询一列ID
+func queryIDs(tx *sql.Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// deleteSpaceData 删除空间内的客户端、TURN服务器和WebAPIKey，返回被删除的客户端ID
+func deleteSpaceData(tx *sql.Tx, spaceID string) ([]string, error) {
+	clientIDs, err := queryIDs(tx, "SELECT id FROM clients WHERE space_id = ?", spaceID)
+	if err != nil {
+		return nil, fmt.Errorf("查询空间内客户端失败: %w", err)
+	}
+
+	for _, table := range []string{"clients", "turn_servers", "web_api_keys"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE space_id = ?", spaceID); err != nil {
+			return nil, fmt.Errorf("删除空间关联的%s失败: %w", table, err)
+		}
+	}
+	return clientIDs, nil
+}
+
+// deleteUserData 删除用户拥有的空间及其关联数据、客户端、TURN服务器、WebAPIKey、访问令牌和会话，返回被删除的客户端ID
+func deleteUserData(tx *sql.Tx, userID string) ([]string, error) {
+	spaceIDs, err := queryIDs(tx, "SELECT id FROM spaces WHERE owner_id = ?", userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询用户空间失败: %w", err)
+	}
+
+	var clientIDs []string
+	for _, spaceID := range spaceIDs {
+		ids, err := deleteSpaceData(tx, spaceID)
+		if err != nil {
+			return nil, err
+		}
+		clientIDs = append(clientIDs, ids...)
+	}
+	if _, err := tx.Exec("DELETE FROM spaces WHERE owner_id = ?", userID); err != nil {
+		return nil, fmt.Errorf("删除用户空间失败: %w", err)
+	}
+
+	// 用户在其他空间中的客户端
+	ids, err := queryIDs(tx, "SELECT id FROM clients WHERE owner_id = ?", userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询用户客户端失败: %w", err)
+	}
+	clientIDs = append(clientIDs, ids...)
+
+	for _, stmt := range []string{
+		"DELETE FROM clients WHERE owner_id = ?",
+		"DELETE FROM turn_servers WHERE owner_id = ?",
+		"DELETE FROM web_api_keys WHERE user_id = ?",
+		"DELETE FROM access_tokens WHERE user_id = ?",
+	} {
+		if _, err := tx.Exec(stmt, userID); err != nil {
+			return nil, fmt.Errorf("删除用户关联数据失败: %w", err)
+		}
+	}
+	// sessions.user_id同时保存管理员ID，只删除用户的会话
+	if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = ? AND principal_kind = ?", userID, string(models.PrincipalUser)); err != nil {
+		return nil, fmt.Errorf("删除用户会话失败: %w", err)
+	}
+	return clientIDs, nil
+}
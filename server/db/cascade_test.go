@@ -0,0 +1,215 @@
+package db
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"server/models"
+
+	"github.com/google/uuid"
+)
+
+// seedUser 创建用户，返回其ID
+func seedUser(t *testing.T, username string) string {
+	t.Helper()
+	user := &models.User{ID: uuid.New().String(), Username: username, Password: "password", Email: username + "@example.com"}
+	if err := SaveUser(user); err != nil {
+		t.Fatal(err)
+	}
+	return user.ID
+}
+
+// seedSpace 创建空间，不检查所有者是否存在
+func seedSpace(t *testing.T, ownerID string) string {
+	t.Helper()
+	space := &models.Space{ID: uuid.New().String(), OwnerID: ownerID, Name: "space"}
+	if err := SaveSpace(space); err != nil {
+		t.Fatal(err)
+	}
+	return space.ID
+}
+
+// seedClient 在空间内创建客户端，返回其ID
+func seedClient(t *testing.T, ownerID, spaceID string) string {
+	t.Helper()
+	client := &models.Client{ID: uuid.New().String(), OwnerID: ownerID, SpaceID: spaceID, Name: "client"}
+	if err := SaveClient(client); err != nil {
+		t.Fatal(err)
+	}
+	return client.ID
+}
+
+// seedTurn 在空间内创建TURN服务器，返回其ID
+func seedTurn(t *testing.T, ownerID, spaceID string) string {
+	t.Helper()
+	turn := &models.TurnServer{ID: uuid.New().String(), OwnerID: ownerID, SpaceID: spaceID, URL: "turn:turn.example.com"}
+	if err := SaveTurn(turn); err != nil {
+		t.Fatal(err)
+	}
+	return turn.ID
+}
+
+// seedWebAPIKey 在空间内创建WebAPIKey，返回其ID
+func seedWebAPIKey(t *testing.T, userID, spaceID string) string {
+	t.Helper()
+	key := &models.WebAPIKey{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Key:       uuid.New().String(),
+		SpaceID:   spaceID,
+		Name:      "web",
+		ExpiresAt: time.Now().Add(time.Hour),
+		CreatedAt: time.Now(),
+	}
+	if err := SaveWebAPIKey(key); err != nil {
+		t.Fatal(err)
+	}
+	return key.ID
+}
+
+// seedSpaceData 在空间内创建客户端、TURN服务器和WebAPIKey，返回客户端ID
+func seedSpaceData(t *testing.T, ownerID, spaceID string) string {
+	t.Helper()
+	seedTurn(t, ownerID, spaceID)
+	seedWebAPIKey(t, ownerID, spaceID)
+	return seedClient(t, ownerID, spaceID)
+}
+
+// seedSession 创建会话，返回其ID
+func seedSession(t *testing.T, kind models.PrincipalKind, id string) string {
+	t.Helper()
+	session, err := CreateSession(string(kind), id, "127.0.0.1", "test", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session.ID
+}
+
+// countRows 统计表中满足条件的行数
+func countRows(t *testing.T, table, where string, args ...interface{}) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE "+where, args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// sortedIDs 排序后用逗号连接，便于比较
+func sortedIDs(ids []string) string {
+	ids = append([]string(nil), ids...)
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+func TestDeleteSpaceCascade(t *testing.T) {
+	openTestDB(t)
+	alice := seedUser(t, "alice")
+	bob := seedUser(t, "bob")
+	target := seedSpace(t, alice)
+	other := seedSpace(t, alice)
+
+	want := []string{
+		seedSpaceData(t, alice, target),
+		seedClient(t, bob, target), // 其他用户在该空间的客户端
+	}
+	seedSpaceData(t, alice, other)
+	seedSession(t, models.PrincipalUser, alice)
+	if _, err := CreateAccessToken(alice, "cli", []string{models.ScopeSpacesRead}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := DeleteSpace(target, bob); err == nil {
+		t.Fatal("删除其他用户的空间应失败")
+	}
+	clientIDs, err := DeleteSpace(target, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sortedIDs(clientIDs) != sortedIDs(want) {
+		t.Errorf("返回的客户端 = %v, want %v", clientIDs, want)
+	}
+
+	for _, table := range []string{"clients", "turn_servers", "web_api_keys"} {
+		if n := countRows(t, table, "space_id = ?", target); n != 0 {
+			t.Errorf("%s 中剩余%d条空间数据", table, n)
+		}
+		if n := countRows(t, table, "space_id = ?", other); n != 1 {
+			t.Errorf("%s 中其他空间的数据 = %d, want 1", table, n)
+		}
+	}
+	if n := countRows(t, "spaces", "id = ?", target); n != 0 {
+		t.Error("空间没有被删除")
+	}
+	// 空间删除不影响用户的令牌和会话
+	if countRows(t, "access_tokens", "user_id = ?", alice) != 1 || countRows(t, "sessions", "user_id = ?", alice) != 1 {
+		t.Error("删除空间时删除了用户的令牌或会话")
+	}
+}
+
+func TestDeleteUserCascade(t *testing.T) {
+	openTestDB(t)
+	alice := seedUser(t, "alice")
+	bob := seedUser(t, "bob")
+	home := seedSpace(t, alice)
+	work := seedSpace(t, alice)
+	shared := seedSpace(t, bob)
+
+	want := []string{
+		seedSpaceData(t, alice, home),
+		seedSpaceData(t, alice, work),
+		seedClient(t, bob, home),        // 其他用户在被删除空间中的客户端
+		seedSpaceData(t, alice, shared), // 用户在其他空间中的数据
+	}
+	bobClient := seedClient(t, bob, shared)
+	seedSession(t, models.PrincipalUser, alice)
+	seedSession(t, models.PrincipalUser, bob)
+	// ID相同的管理员会话不属于该用户
+	adminSession := seedSession(t, models.PrincipalAdmin, alice)
+	for _, user := range []string{alice, bob} {
+		if _, err := CreateAccessToken(user, "cli", []string{models.ScopeSpacesRead}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clientIDs, err := DeleteUser(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sortedIDs(clientIDs) != sortedIDs(want) {
+		t.Errorf("返回的客户端 = %v, want %v", clientIDs, want)
+	}
+
+	checks := []struct {
+		table, where string
+		alice, bob   int
+	}{
+		{"users", "id = ?", 0, 1},
+		{"spaces", "owner_id = ?", 0, 1},
+		{"clients", "owner_id = ?", 0, 1},
+		{"turn_servers", "owner_id = ?", 0, 0},
+		{"web_api_keys", "user_id = ?", 0, 0},
+		{"access_tokens", "user_id = ?", 0, 1},
+		{"sessions", "principal_kind = 'user' AND user_id = ?", 0, 1},
+	}
+	for _, c := range checks {
+		if n := countRows(t, c.table, c.where, alice); n != c.alice {
+			t.Errorf("%s 中用户的数据 = %d, want %d", c.table, n, c.alice)
+		}
+		if n := countRows(t, c.table, c.where, bob); n != c.bob {
+			t.Errorf("%s 中其他用户的数据 = %d, want %d", c.table, n, c.bob)
+		}
+	}
+	if countRows(t, "clients", "id = ?", bobClient) != 1 {
+		t.Error("其他用户在共享空间中的客户端被删除")
+	}
+	if countRows(t, "sessions", "id = ?", adminSession) != 1 {
+		t.Error("ID相同的管理员会话被删除")
+	}
+
+	if _, err := DeleteUser(alice); err == nil {
+		t.Error("删除不存在的用户应失败")
+	}
+}
@@ -3,11 +3,14 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"server/metrics"
 	"server/models"
 )
 
 // SaveClient 保存客户端信息到数据库
 func SaveClient(client *models.Client) error {
+	defer metrics.ObserveQuery("SaveClient")()
+
 	_, err := db.Exec(`
 		INSERT INTO clients (id, owner_id, space_id, public_key, name, description)
 		VALUES (?, ?, ?, ?, ?, ?)
@@ -17,6 +20,8 @@ func SaveClient(client *models.Client) error {
 
 // GetClientByID 根据ID获取客户端信息
 func GetClientByID(id string) (*models.Client, error) {
+	defer metrics.ObserveQuery("GetClientByID")()
+
 	var client models.Client
 	err := db.QueryRow(`
 		SELECT id, owner_id, space_id, public_key, name, description
@@ -31,6 +36,8 @@ func GetClientByID(id string) (*models.Client, error) {
 
 // GetClientsByOwnerID 获取用户的所有客户端
 func GetClientsByOwnerID(ownerID string) ([]*models.Client, error) {
+	defer metrics.ObserveQuery("GetClientsByOwnerID")()
+
 	rows, err := db.Query(`
 		SELECT id, owner_id, space_id, public_key, name, description
 		FROM clients
@@ -54,6 +61,8 @@ func GetClientsByOwnerID(ownerID string) ([]*models.Client, error) {
 
 // UpdateClient 更新客户端信息
 func UpdateClient(client *models.Client) error {
+	defer metrics.ObserveQuery("UpdateClient")()
+
 	// 检查客户端是否存在
 	existingClient, err := GetClientByID(client.ID)
 	if err != nil {
@@ -81,6 +90,8 @@ func UpdateClient(client *models.Client) error {
 
 // DeleteClient 删除客户端
 func DeleteClient(id string, ownerID string) error {
+	defer metrics.ObserveQuery("DeleteClient")()
+
 	// 检查客户端是否存在
 	client, err := GetClientByID(id)
 	if err != nil {
@@ -105,6 +116,8 @@ func DeleteClient(id string, ownerID string) error {
 
 // GetClientsBySpaceID 获取同一空间内的所有客户端
 func GetClientsBySpaceID(spaceID string) ([]*models.Client, error) {
+	defer metrics.ObserveQuery("GetClientsBySpaceID")()
+
 	rows, err := db.Query(`
 		SELECT id, owner_id, space_id, public_key, name, description
 		FROM clients
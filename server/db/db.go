@@ -1,8 +1,12 @@
 package db
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
 	"fmt"
+	"os"
+	"server/metrics"
 	"server/models"
 	"time"
 
@@ -14,10 +18,23 @@ import (
 
 var db *sql.DB
 
+// legacyAdminPassword 旧版本初始管理员使用的默认密码
+const legacyAdminPassword = "admin123"
+
 // Init 初始化SQLite数据库连接并执行自动迁移
 func Init() error {
+	return Open("./data.db")
+}
+
+// Close 关闭数据库连接
+func Close() error {
+	return db.Close()
+}
+
+// Open 打开path处的SQLite数据库并执行自动迁移
+func Open(path string) error {
 	var err error
-	db, err = sql.Open("sqlite3", "./data.db")
+	db, err = sql.Open("sqlite3", path)
 	if err != nil {
 		return err
 	}
@@ -131,13 +148,29 @@ func Init() error {
 		return err
 	}
 
+	// 旧版本的sessions表保存明文令牌，直接删除重建，已登录的用户需要重新登录
+	hasHash, err := columnExists("sessions", "token_hash")
+	if err != nil {
+		log.Error("检查sessions表失败", "error", err)
+		return err
+	}
+	if !hasHash {
+		if _, err = db.Exec("DROP TABLE IF EXISTS sessions"); err != nil {
+			log.Error("删除旧版sessions表失败", "error", err)
+			return err
+		}
+	}
+
 	// 创建sessions表
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS sessions (
 			id TEXT PRIMARY KEY,
 			user_id TEXT NOT NULL,
-			token TEXT NOT NULL UNIQUE,
+			token_hash TEXT NOT NULL UNIQUE,
+			ip TEXT NOT NULL DEFAULT '',
+			user_agent TEXT NOT NULL DEFAULT '',
 			expires_at DATETIME NOT NULL,
+			last_used_at DATETIME NOT NULL,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY(user_id) REFERENCES users(id)
 		)
@@ -147,6 +180,91 @@ func Init() error {
 		return err
 	}
 
+	// 创建login_attempts表
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS login_attempts (
+			key TEXT PRIMARY KEY,
+			failures INTEGER NOT NULL DEFAULT 0,
+			locked_until DATETIME NOT NULL,
+			last_failure DATETIME NOT NULL
+		)
+	`)
+	if err != nil {
+		log.Error("创建login_attempts表失败", "error", err)
+		return err
+	}
+
+	// 创建access_tokens表
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS access_tokens (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			token_prefix TEXT NOT NULL,
+			scopes TEXT NOT NULL,
+			expires_at DATETIME,
+			last_used_at DATETIME,
+			last_used_ip TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY(user_id) REFERENCES users(id)
+		)
+	`)
+	if err != nil {
+		log.Error("创建access_tokens表失败", "error", err)
+		return err
+	}
+
+	// 创建audit_log表，触发器禁止修改和删除已写入的记录
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			request_id TEXT NOT NULL DEFAULT '',
+			actor_kind TEXT NOT NULL DEFAULT '',
+			actor_id TEXT NOT NULL DEFAULT '',
+			action TEXT NOT NULL,
+			target TEXT NOT NULL DEFAULT '',
+			ip TEXT NOT NULL DEFAULT '',
+			status INTEGER NOT NULL DEFAULT 0,
+			outcome TEXT NOT NULL,
+			detail TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor_id, created_at);
+		CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+		CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+		BEGIN
+			SELECT RAISE(ABORT, 'audit_log is append-only');
+		END;
+		CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+		BEGIN
+			SELECT RAISE(ABORT, 'audit_log is append-only');
+		END;
+	`)
+	if err != nil {
+		log.Error("创建audit_log表失败", "error", err)
+		return err
+	}
+
+	// 为旧版本创建的表补充新增的列
+	if err = addColumnIfMissing("admins", "must_change_password", "BOOLEAN NOT NULL DEFAULT FALSE"); err != nil {
+		log.Error("迁移admins表失败", "error", err)
+		return err
+	}
+	if err = addColumnIfMissing("users", "disabled", "BOOLEAN NOT NULL DEFAULT FALSE"); err != nil {
+		log.Error("迁移users表失败", "error", err)
+		return err
+	}
+	// 没有记录主体类型的旧会话无法确定属于用户还是管理员，认证时会被拒绝
+	if err = addColumnIfMissing("sessions", "principal_kind", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		log.Error("迁移sessions表失败", "error", err)
+		return err
+	}
+	if err = addColumnIfMissing("sessions", "impersonator_id", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		log.Error("迁移sessions表失败", "error", err)
+		return err
+	}
+
 	// 检查是否需要创建初始管理员账户
 	var count int
 	err = db.QueryRow("SELECT COUNT(*) FROM admins").Scan(&count)
@@ -157,32 +275,117 @@ func Init() error {
 
 	// 如果没有管理员账户，创建初始管理员
 	if count == 0 {
-		adminID := uuid.New().String()
-		// 对初始密码进行加密
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
-		if err != nil {
-			log.Error("加密管理员密码失败", "error", err)
+		if err = createInitialAdmin(); err != nil {
 			return err
 		}
+	} else if err = flagDefaultAdminPassword(); err != nil {
+		return err
+	}
+
+	log.Info("数据库初始化完成")
+	return nil
+}
+
+// columnExists 检查表中是否存在该列，表不存在时返回false
+func columnExists(table, column string) (bool, error) {
+	rows, err := db.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
 
-		_, err = db.Exec(
-			"INSERT INTO admins (id, username, password, created_at) VALUES (?, ?, ?, ?)",
-			adminID,
-			"admin",
-			string(hashedPassword),
-			time.Now(),
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
 		)
-		if err != nil {
-			log.Error("创建初始管理员账户失败", "error", err)
-			return err
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
 		}
-		log.Info("初始管理员账户创建成功", "username", "admin", "password", "admin123")
 	}
+	return false, rows.Err()
+}
 
-	log.Info("数据库初始化完成")
+// addColumnIfMissing 表中不存在该列时添加
+func addColumnIfMissing(table, column, definition string) error {
+	exists, err := columnExists(table, column)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	return err
+}
+
+// createInitialAdmin 创建使用随机密码的初始管理员，首次登录后必须修改密码
+//
+// 密码只在控制台输出一次，不写入日志文件。
+func createInitialAdmin() error {
+	password, err := randomPassword()
+	if err != nil {
+		log.Error("生成初始管理员密码失败", "error", err)
+		return err
+	}
+
+	// 对初始密码进行加密
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Error("加密管理员密码失败", "error", err)
+		return err
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO admins (id, username, password, must_change_password, created_at) VALUES (?, ?, ?, ?, ?)",
+		uuid.New().String(),
+		"admin",
+		string(hashedPassword),
+		true,
+		time.Now(),
+	)
+	if err != nil {
+		log.Error("创建初始管理员账户失败", "error", err)
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "\n初始管理员账户: admin\n初始密码: %s\n首次登录后必须修改密码，该密码不会再次显示。\n\n", password)
+	log.Warn("初始管理员账户创建成功，首次登录后必须修改密码", "username", "admin")
+	return nil
+}
+
+// flagDefaultAdminPassword 旧版本创建的初始管理员仍使用默认密码时，要求登录后修改
+func flagDefaultAdminPassword() error {
+	admin, err := GetAdminByUsername("admin")
+	if err != nil || admin == nil || admin.MustChangePassword {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(legacyAdminPassword)) != nil {
+		return nil
+	}
+
+	if _, err := db.Exec("UPDATE admins SET must_change_password = TRUE WHERE id = ?", admin.ID); err != nil {
+		log.Error("标记默认管理员密码失败", "error", err)
+		return err
+	}
+	log.Warn("初始管理员仍在使用默认密码，登录后必须修改", "username", admin.Username)
 	return nil
 }
 
+// randomPassword 生成随机的初始密码
+func randomPassword() (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
 // GetDB 返回数据库连接实例
 func GetDB() *sql.DB {
 	return db
@@ -190,11 +393,13 @@ func GetDB() *sql.DB {
 
 // GetUserByUsername 通过用户名查询用户信息
 func GetUserByUsername(username string) (*models.User, error) {
+	defer metrics.ObserveQuery("GetUserByUsername")()
+
 	user := &models.User{}
 	err := db.QueryRow(
-		"SELECT id, username, password, email, created_at, updated_at FROM users WHERE username = ?",
+		"SELECT id, username, password, email, disabled, created_at, updated_at FROM users WHERE username = ?",
 		username,
-	).Scan(&user.ID, &user.Username, &user.Password, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.Username, &user.Password, &user.Email, &user.Disabled, &user.CreatedAt, &user.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -209,11 +414,13 @@ func GetUserByUsername(username string) (*models.User, error) {
 
 // GetUserByEmail 通过邮箱查询用户信息
 func GetUserByEmail(email string) (*models.User, error) {
+	defer metrics.ObserveQuery("GetUserByEmail")()
+
 	user := &models.User{}
 	err := db.QueryRow(
-		"SELECT id, username, password, email, created_at, updated_at FROM users WHERE email = ?",
+		"SELECT id, username, password, email, disabled, created_at, updated_at FROM users WHERE email = ?",
 		email,
-	).Scan(&user.ID, &user.Username, &user.Password, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.Username, &user.Password, &user.Email, &user.Disabled, &user.CreatedAt, &user.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -228,6 +435,8 @@ func GetUserByEmail(email string) (*models.User, error) {
 
 // SaveUser 保存用户信息到数据库
 func SaveUser(user *models.User) error {
+	defer metrics.ObserveQuery("SaveUser")()
+
 	// 检查用户名是否已存在
 	existingUser, err := GetUserByUsername(user.Username)
 	if err != nil {
@@ -268,11 +477,13 @@ func SaveUser(user *models.User) error {
 
 // GetAdminByUsername 通过用户名查询管理员信息
 func GetAdminByUsername(username string) (*models.Admin, error) {
+	defer metrics.ObserveQuery("GetAdminByUsername")()
+
 	admin := &models.Admin{}
 	err := db.QueryRow(
-		"SELECT id, username, password, created_at FROM admins WHERE username = ?",
+		"SELECT id, username, password, must_change_password, created_at FROM admins WHERE username = ?",
 		username,
-	).Scan(&admin.ID, &admin.Username, &admin.Password, &admin.CreatedAt)
+	).Scan(&admin.ID, &admin.Username, &admin.Password, &admin.MustChangePassword, &admin.CreatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
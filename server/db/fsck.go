@@ -0,0 +1,96 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"server/metrics"
+)
+
+// OrphanCheck 一类孤立数据的检查结果
+type OrphanCheck struct {
+	Table       string   `json:"table"`       // 包含孤立数据的表
+	Column      string   `json:"column"`      // 引用不存在数据的列
+	Description string   `json:"description"` // 检查说明
+	IDs         []string `json:"ids"`         // 孤立数据的ID
+}
+
+// orphanRule 孤立数据检查规则
+type orphanRule struct {
+	table       string
+	column      string
+	description string
+	where       string // 判定为孤立数据的条件
+}
+
+// orphanRules 孤立数据检查规则，空间排在前面，修复时先删除孤立空间，其关联数据随之一并删除
+var orphanRules = []orphanRule{
+	{"spaces", "owner_id", "所有者不存在的空间", "owner_id NOT IN (SELECT id FROM users)"},
+	{"clients", "space_id", "空间不存在的客户端", "space_id NOT IN (SELECT id FROM spaces)"},
+	{"clients", "owner_id", "所有者不存在的客户端", "owner_id NOT IN (SELECT id FROM users)"},
+	{"turn_servers", "space_id", "空间不存在的TURN服务器", "space_id NOT IN (SELECT id FROM spaces)"},
+	{"turn_servers", "owner_id", "所有者不存在的TURN服务器", "owner_id NOT IN (SELECT id FROM users)"},
+	{"web_api_keys", "space_id", "空间不存在的WebAPIKey", "space_id NOT IN (SELECT id FROM spaces)"},
+	{"web_api_keys", "user_id", "所有者不存在的WebAPIKey", "user_id NOT IN (SELECT id FROM users)"},
+	{"access_tokens", "user_id", "所有者不存在的访问令牌", "user_id NOT IN (SELECT id FROM users)"},
+	// 会话按主体类型属于用户或管理员，没有类型的旧会话无法再使用
+	{"sessions", "user_id", "主体不存在的会话", "(principal_kind = 'user' AND user_id NOT IN (SELECT id FROM users)) OR (principal_kind = 'admin' AND user_id NOT IN (SELECT id FROM admins)) OR principal_kind NOT IN ('user', 'admin')"},
+	{"sessions", "impersonator_id", "代为登录的管理员不存在的会话", "impersonator_id != '' AND impersonator_id NOT IN (SELECT id FROM admins)"},
+}
+
+// FindOrphans 检查引用了不存在的用户、管理员或空间的数据
+func FindOrphans() ([]OrphanCheck, error) {
+	defer metrics.ObserveQuery("FindOrphans")()
+
+	var checks []OrphanCheck
+	err := withTx(func(tx *sql.Tx) error {
+		var err error
+		checks, err = checkOrphans(tx, false)
+		return err
+	})
+	return checks, err
+}
+
+// RepairOrphans 删除孤立数据，返回删除前的检查结果
+func RepairOrphans() ([]OrphanCheck, error) {
+	defer metrics.ObserveQuery("RepairOrphans")()
+
+	var checks []OrphanCheck
+	err := withTx(func(tx *sql.Tx) error {
+		var err error
+		checks, err = checkOrphans(tx, true)
+		return err
+	})
+	return checks, err
+}
+
+// checkOrphans 按规则依次检查孤立数据，repair为true时删除
+func checkOrphans(tx *sql.Tx, repair bool) ([]OrphanCheck, error) {
+	checks := make([]OrphanCheck, 0, len(orphanRules))
+	for _, rule := range orphanRules {
+		ids, err := queryIDs(tx, "SELECT id FROM "+rule.table+" WHERE "+rule.where)
+		if err != nil {
+			return nil, fmt.Errorf("检查%s失败: %w", rule.description, err)
+		}
+		checks = append(checks, OrphanCheck{
+			Table:       rule.table,
+			Column:      rule.column,
+			Description: rule.description,
+			IDs:         ids,
+		})
+
+		if !repair || len(ids) == 0 {
+			continue
+		}
+		if rule.table == "spaces" {
+			for _, id := range ids {
+				if _, err := deleteSpaceData(tx, id); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if _, err := tx.Exec("DELETE FROM " + rule.table + " WHERE " + rule.where); err != nil {
+			return nil, fmt.Errorf("删除%s失败: %w", rule.description, err)
+		}
+	}
+	return checks, nil
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"server/models"
+
+	"github.com/google/uuid"
+)
+
+// orphanIDs 按"表.列"汇总检查结果中的孤立数据
+func orphanIDs(checks []OrphanCheck) map[string]string {
+	found := make(map[string]string)
+	for _, check := range checks {
+		if len(check.IDs) > 0 {
+			found[check.Table+"."+check.Column] = sortedIDs(check.IDs)
+		}
+	}
+	return found
+}
+
+func TestFindAndRepairOrphans(t *testing.T) {
+	openTestDB(t)
+	alice := seedUser(t, "alice")
+	home := seedSpace(t, alice)
+	ghost := uuid.New().String()
+
+	// 正常数据
+	seedSpaceData(t, alice, home)
+	seedSession(t, models.PrincipalUser, alice)
+	admin := &models.Admin{ID: uuid.New().String(), Username: "root", Password: "password"}
+	if err := SaveAdmin(admin); err != nil {
+		t.Fatal(err)
+	}
+	seedSession(t, models.PrincipalAdmin, admin.ID)
+	if _, err := CreateImpersonationSession(admin.ID, alice, "127.0.0.1", "test", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 所有者不存在的空间，空间内的数据属于存在的用户，只能随空间一并删除
+	orphanSpace := seedSpace(t, ghost)
+	orphanSpaceClient := seedSpaceData(t, alice, orphanSpace)
+
+	token, err := CreateAccessToken(ghost, "cli", []string{models.ScopeSpacesRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	impersonation, err := CreateImpersonationSession(ghost, alice, "127.0.0.1", "test", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacySession := seedSession(t, models.PrincipalUser, alice)
+	if _, err := db.Exec("UPDATE sessions SET principal_kind = '' WHERE id = ?", legacySession); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"spaces.owner_id":          orphanSpace,
+		"clients.space_id":         seedClient(t, alice, uuid.New().String()),
+		"clients.owner_id":         seedClient(t, ghost, home),
+		"turn_servers.space_id":    seedTurn(t, alice, uuid.New().String()),
+		"turn_servers.owner_id":    seedTurn(t, ghost, home),
+		"web_api_keys.space_id":    seedWebAPIKey(t, alice, uuid.New().String()),
+		"web_api_keys.user_id":     seedWebAPIKey(t, ghost, home),
+		"access_tokens.user_id":    token.ID,
+		"sessions.impersonator_id": impersonation.ID,
+	}
+	want["sessions.user_id"] = sortedIDs([]string{
+		seedSession(t, models.PrincipalUser, ghost),
+		seedSession(t, models.PrincipalAdmin, ghost),
+		legacySession,
+	})
+
+	// 检查不修改数据，重复检查结果相同
+	for i := 0; i < 2; i++ {
+		checks, err := FindOrphans()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(checks) != len(orphanRules) {
+			t.Fatalf("检查结果%d条, want %d", len(checks), len(orphanRules))
+		}
+		assertOrphans(t, "FindOrphans", orphanIDs(checks), want)
+	}
+
+	checks, err := RepairOrphans()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertOrphans(t, "RepairOrphans", orphanIDs(checks), want)
+
+	// 孤立空间内的数据随空间删除
+	for _, table := range []string{"clients", "turn_servers", "web_api_keys"} {
+		if n := countRows(t, table, "space_id = ?", orphanSpace); n != 0 {
+			t.Errorf("孤立空间在%s中剩余%d条数据", table, n)
+		}
+	}
+	if countRows(t, "clients", "id = ?", orphanSpaceClient) != 0 {
+		t.Error("孤立空间内的客户端没有被删除")
+	}
+
+	checks, err = FindOrphans()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertOrphans(t, "修复后", orphanIDs(checks), map[string]string{})
+
+	// 正常数据保留
+	tests := []struct {
+		table, where string
+		args         []interface{}
+		want         int
+	}{
+		{"spaces", "id = ?", []interface{}{home}, 1},
+		{"clients", "space_id = ?", []interface{}{home}, 1},
+		{"turn_servers", "space_id = ?", []interface{}{home}, 1},
+		{"web_api_keys", "space_id = ?", []interface{}{home}, 1},
+		{"sessions", "principal_kind = 'user' AND user_id = ?", []interface{}{alice}, 2},
+		{"sessions", "principal_kind = 'admin' AND user_id = ?", []interface{}{admin.ID}, 1},
+	}
+	for _, tt := range tests {
+		if n := countRows(t, tt.table, tt.where, tt.args...); n != tt.want {
+			t.Errorf("%s WHERE %s = %d, want %d", tt.table, tt.where, n, tt.want)
+		}
+	}
+}
+
+// assertOrphans 比较各类孤立数据的ID
+func assertOrphans(t *testing.T, name string, got, want map[string]string) {
+	t.Helper()
+	for key, ids := range want {
+		if got[key] != ids {
+			t.Errorf("%s %s = %q, want %q", name, key, got[key], ids)
+		}
+	}
+	for key, ids := range got {
+		if _, ok := want[key]; !ok {
+			t.Errorf("%s 多报告了 %s = %q", name, key, ids)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"database/sql"
+	"server/metrics"
+	"server/models"
+	"strings"
+)
+
+// queryPage 按rowid顺序分页查询，scan读取一行数据并返回其rowid
+//
+// 多查询一行用于判断是否还有下一页，有下一页时返回本页最后一条记录的rowid作为游标，否则返回0。
+//
+// 这些表以TEXT列为主键，rowid不是表的一部分，SQLite执行VACUUM时可能重新编号。
+// 服务不会执行VACUUM；手动执行后，之前返回的游标可能跳过或重复记录，需要从第一页重新查询。
+func queryPage(table, columns string, conds []string, args []interface{}, page models.Page, scan func(rows *sql.Rows) (int64, error)) (int64, error) {
+	conds = append(conds, "rowid > ?")
+	args = append(args, page.After, page.Limit+1)
+
+	rows, err := db.Query(
+		"SELECT rowid, "+columns+" FROM "+table+" WHERE "+strings.Join(conds, " AND ")+" ORDER BY rowid LIMIT ?",
+		args...,
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var last int64
+	for n := 0; rows.Next(); n++ {
+		if n == page.Limit {
+			return last, nil
+		}
+		if last, err = scan(rows); err != nil {
+			return 0, err
+		}
+	}
+	return 0, rows.Err()
+}
+
+// ListSpaces 分页查询空间，返回下一页的游标
+func ListSpaces(filter models.SpaceFilter, page models.Page) ([]*models.Space, int64, error) {
+	defer metrics.ObserveQuery("ListSpaces")()
+
+	var (
+		conds []string
+		args  []interface{}
+	)
+	if filter.OwnerID != "" {
+		conds = append(conds, "owner_id = ?")
+		args = append(args, filter.OwnerID)
+	}
+	if filter.Name != "" {
+		conds = append(conds, "instr(lower(name), lower(?)) > 0")
+		args = append(args, filter.Name)
+	}
+
+	spaces := []*models.Space{}
+	next, err := queryPage("spaces", "id, owner_id, name, COALESCE(description, ''), created_at, updated_at", conds, args, page, func(rows *sql.Rows) (int64, error) {
+		var rowid int64
+		space := &models.Space{}
+		err := rows.Scan(&rowid, &space.ID, &space.OwnerID, &space.Name, &space.Description, &space.CreatedAt, &space.UpdatedAt)
+		spaces = append(spaces, space)
+		return rowid, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return spaces, next, nil
+}
+
+// ListClients 分页查询客户端，返回下一页的游标
+func ListClients(filter models.ClientFilter, page models.Page) ([]*models.Client, int64, error) {
+	defer metrics.ObserveQuery("ListClients")()
+
+	var (
+		conds []string
+		args  []interface{}
+	)
+	if filter.OwnerID != "" {
+		conds = append(conds, "owner_id = ?")
+		args = append(args, filter.OwnerID)
+	}
+	if filter.SpaceID != "" {
+		conds = append(conds, "space_id = ?")
+		args = append(args, filter.SpaceID)
+	}
+	if filter.Name != "" {
+		conds = append(conds, "instr(lower(name), lower(?)) > 0")
+		args = append(args, filter.Name)
+	}
+
+	clients := []*models.Client{}
+	next, err := queryPage("clients", "id, owner_id, space_id, public_key, name, COALESCE(description, '')", conds, args, page, func(rows *sql.Rows) (int64, error) {
+		var rowid int64
+		client := &models.Client{}
+		err := rows.Scan(&rowid, &client.ID, &client.OwnerID, &client.SpaceID, &client.PublicKey, &client.Name, &client.Description)
+		clients = append(clients, client)
+		return rowid, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return clients, next, nil
+}
+
+// ListTurns 分页查询TURN服务器配置，返回下一页的游标
+func ListTurns(filter models.TurnFilter, page models.Page) ([]*models.TurnServer, int64, error) {
+	defer metrics.ObserveQuery("ListTurns")()
+
+	var (
+		conds []string
+		args  []interface{}
+	)
+	if filter.OwnerID != "" {
+		conds = append(conds, "owner_id = ?")
+		args = append(args, filter.OwnerID)
+	}
+	if filter.SpaceID != "" {
+		conds = append(conds, "space_id = ?")
+		args = append(args, filter.SpaceID)
+	}
+
+	turns := []*models.TurnServer{}
+	next, err := queryPage("turn_servers", "id, owner_id, space_id, url, username, password, created_at, updated_at", conds, args, page, func(rows *sql.Rows) (int64, error) {
+		var rowid int64
+		turn := &models.TurnServer{}
+		err := rows.Scan(&rowid, &turn.ID, &turn.OwnerID, &turn.SpaceID, &turn.URL, &turn.Username, &turn.Password, &turn.CreatedAt, &turn.UpdatedAt)
+		turns = append(turns, turn)
+		return rowid, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return turns, next, nil
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	"server/models"
+
+	"github.com/google/uuid"
+)
+
+// seedSpaces 为owner按顺序创建n个空间，返回其ID
+func seedSpaces(t *testing.T, owner string, n int) []string {
+	t.Helper()
+	ids := make([]string, n)
+	for i := range ids {
+		space := &models.Space{ID: uuid.New().String(), OwnerID: owner, Name: fmt.Sprintf("space-%d", i)}
+		if err := SaveSpace(space); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = space.ID
+	}
+	return ids
+}
+
+// listAll 按limit逐页查询，返回所有ID和每页的条数
+func listAll(t *testing.T, filter models.SpaceFilter, limit int) ([]string, []int) {
+	t.Helper()
+	var ids []string
+	var sizes []int
+	page := models.Page{Limit: limit}
+	for {
+		spaces, next, err := ListSpaces(filter, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, len(spaces))
+		for _, space := range spaces {
+			ids = append(ids, space.ID)
+		}
+		if next == 0 {
+			return ids, sizes
+		}
+		if next <= page.After {
+			t.Fatalf("游标没有前进: %d -> %d", page.After, next)
+		}
+		page.After = next
+		if len(sizes) > 10 {
+			t.Fatal("分页没有结束")
+		}
+	}
+}
+
+func TestListSpacesPaging(t *testing.T) {
+	openTestDB(t)
+	want := seedSpaces(t, "alice", 5)
+	seedSpaces(t, "bob", 3)
+
+	tests := []struct {
+		limit int
+		sizes []int
+	}{
+		{2, []int{2, 2, 1}},
+		{5, []int{5}}, // 恰好一页时多查询的一行不存在，不返回游标
+		{4, []int{4, 1}},
+		{10, []int{5}},
+		{1, []int{1, 1, 1, 1, 1}},
+	}
+	for _, tt := range tests {
+		ids, sizes := listAll(t, models.SpaceFilter{OwnerID: "alice"}, tt.limit)
+		if fmt.Sprint(sizes) != fmt.Sprint(tt.sizes) {
+			t.Errorf("limit=%d 每页条数 = %v, want %v", tt.limit, sizes, tt.sizes)
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(want) {
+			t.Errorf("limit=%d 返回 %v, want %v", tt.limit, ids, want)
+		}
+	}
+}
+
+func TestListSpacesNextCursor(t *testing.T) {
+	openTestDB(t)
+	seedSpaces(t, "alice", 3)
+
+	spaces, next, err := ListSpaces(models.SpaceFilter{OwnerID: "alice"}, models.Page{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rowid int64
+	if err := db.QueryRow("SELECT rowid FROM spaces WHERE id = ?", spaces[1].ID).Scan(&rowid); err != nil {
+		t.Fatal(err)
+	}
+	if next != rowid {
+		t.Errorf("next = %d, want 本页最后一条的rowid %d", next, rowid)
+	}
+
+	// 游标之后没有数据时返回空列表而不是nil
+	spaces, next, err = ListSpaces(models.SpaceFilter{OwnerID: "alice"}, models.Page{Limit: 2, After: rowid + 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spaces == nil || len(spaces) != 0 || next != 0 {
+		t.Errorf("spaces = %v, next = %d", spaces, next)
+	}
+}
+
+func TestListSpacesNameFilter(t *testing.T) {
+	openTestDB(t)
+	seedSpaces(t, "alice", 12)
+
+	// space-1、space-10、space-11
+	ids, sizes := listAll(t, models.SpaceFilter{OwnerID: "alice", Name: "SPACE-1"}, 2)
+	if len(ids) != 3 || fmt.Sprint(sizes) != "[2 1]" {
+		t.Errorf("ids = %v, sizes = %v", ids, sizes)
+	}
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"database/sql"
+	"server/metrics"
+	"server/models"
+	"time"
+)
+
+// failureWindow 超过该时间没有再失败时，连续失败次数重新计算
+const failureWindow = 24 * time.Hour
+
+// GetLoginAttempt 获取登录失败记录，不存在时返回nil
+func GetLoginAttempt(key string) (*models.LoginAttempt, error) {
+	defer metrics.ObserveQuery("GetLoginAttempt")()
+
+	attempt := &models.LoginAttempt{}
+	err := db.QueryRow(
+		"SELECT key, failures, locked_until, last_failure FROM login_attempts WHERE key = ?",
+		key,
+	).Scan(&attempt.Key, &attempt.Failures, &attempt.LockedUntil, &attempt.LastFailure)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return attempt, nil
+}
+
+// RecordLoginFailure 记录一次登录失败，lockFor根据连续失败次数返回锁定时长
+func RecordLoginFailure(key string, lockFor func(failures int) time.Duration) (*models.LoginAttempt, error) {
+	defer metrics.ObserveQuery("RecordLoginFailure")()
+
+	var attempt *models.LoginAttempt
+	err := withTx(func(tx *sql.Tx) error {
+		now := time.Now()
+		attempt = &models.LoginAttempt{Key: key}
+		err := tx.QueryRow(
+			"SELECT failures, last_failure FROM login_attempts WHERE key = ?",
+			key,
+		).Scan(&attempt.Failures, &attempt.LastFailure)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if now.Sub(attempt.LastFailure) > failureWindow {
+			attempt.Failures = 0
+		}
+
+		attempt.Failures++
+		attempt.LastFailure = now
+		if d := lockFor(attempt.Failures); d > 0 {
+			attempt.LockedUntil = now.Add(d)
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO login_attempts (key, failures, locked_until, last_failure) VALUES (?, ?, ?, ?)
+			ON CONFLICT(key) DO UPDATE SET failures = excluded.failures, locked_until = excluded.locked_until, last_failure = excluded.last_failure
+		`, attempt.Key, attempt.Failures, attempt.LockedUntil, attempt.LastFailure)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attempt, nil
+}
+
+// ClearLoginAttempts 登录成功后清除失败记录
+func ClearLoginAttempts(key string) error {
+	defer metrics.ObserveQuery("ClearLoginAttempts")()
+
+	_, err := db.Exec("DELETE FROM login_attempts WHERE key = ?", key)
+	return err
+}
+
+// CleanLoginAttempts 清理已经解除锁定且超过统计窗口的失败记录
+func CleanLoginAttempts() error {
+	defer metrics.ObserveQuery("CleanLoginAttempts")()
+
+	now := time.Now()
+	_, err := db.Exec(
+		"DELETE FROM login_attempts WHERE locked_until < ? AND last_failure < ?",
+		now, now.Add(-failureWindow),
+	)
+	return err
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+// lockAfter 达到max次失败后每次锁定一分钟
+func lockAfter(max int) func(int) time.Duration {
+	return func(failures int) time.Duration {
+		if failures < max {
+			return 0
+		}
+		return time.Minute
+	}
+}
+
+func TestRecordLoginFailure(t *testing.T) {
+	openTestDB(t)
+
+	if attempt, err := GetLoginAttempt("user:alice"); err != nil || attempt != nil {
+		t.Fatalf("没有记录时 = %+v, %v, want nil", attempt, err)
+	}
+
+	// 账号和来源IP各自计数，按各自的阈值锁定
+	for i := 1; i <= 3; i++ {
+		account, err := RecordLoginFailure("user:alice", lockAfter(3))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ip, err := RecordLoginFailure("ip:192.0.2.1", lockAfter(5))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if account.Failures != i || ip.Failures != i {
+			t.Fatalf("第%d次失败计数 = %d, %d", i, account.Failures, ip.Failures)
+		}
+		if account.Locked() != (i >= 3) || ip.Locked() {
+			t.Fatalf("第%d次失败锁定状态 = %v, %v", i, account.Locked(), ip.Locked())
+		}
+	}
+
+	stored, err := GetLoginAttempt("user:alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Failures != 3 || !stored.Locked() {
+		t.Errorf("保存的记录 = %+v", stored)
+	}
+
+	// 登录成功后清除
+	if err := ClearLoginAttempts("user:alice"); err != nil {
+		t.Fatal(err)
+	}
+	if attempt, _ := GetLoginAttempt("user:alice"); attempt != nil {
+		t.Errorf("清除后仍有记录 %+v", attempt)
+	}
+	if attempt, _ := GetLoginAttempt("ip:192.0.2.1"); attempt == nil || attempt.Failures != 3 {
+		t.Errorf("清除账号记录影响了来源IP: %+v", attempt)
+	}
+}
+
+// TestLoginFailureWindow 超过统计窗口后连续失败次数重新计算
+func TestLoginFailureWindow(t *testing.T) {
+	openTestDB(t)
+
+	for i := 0; i < 4; i++ {
+		if _, err := RecordLoginFailure("user:bob", lockAfter(10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-failureWindow - time.Minute)
+	if _, err := db.Exec("UPDATE login_attempts SET last_failure = ? WHERE key = ?", old, "user:bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	attempt, err := RecordLoginFailure("user:bob", lockAfter(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt.Failures != 1 {
+		t.Errorf("超过统计窗口后计数 = %d, want 1", attempt.Failures)
+	}
+
+	// 过期且未锁定的记录会被清理
+	if _, err := db.Exec("UPDATE login_attempts SET last_failure = ? WHERE key = ?", old, "user:bob"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RecordLoginFailure("user:carol", lockAfter(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := CleanLoginAttempts(); err != nil {
+		t.Fatal(err)
+	}
+	if attempt, _ := GetLoginAttempt("user:bob"); attempt != nil {
+		t.Errorf("过期记录没有被清理: %+v", attempt)
+	}
+	if attempt, _ := GetLoginAttempt("user:carol"); attempt == nil {
+		t.Error("锁定中的记录被清理")
+	}
+}
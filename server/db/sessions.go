@@ -1,38 +1,107 @@
 package db
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"server/metrics"
+	"server/models"
 	"time"
-	
+
 	"github.com/charmbracelet/log"
 	"github.com/google/uuid"
 )
 
 // Session 表示一个用户会话
+//
+// 数据库中只保存令牌的SHA-256哈希，明文令牌只在创建和刷新时返回给客户端。
 type Session struct {
-	ID        string
-	UserID    string
-	Token     string
-	ExpiresAt time.Time
-	CreatedAt time.Time
+	ID             string    `json:"id"`
+	UserID         string    `json:"user_id"`                   // 用户或管理员ID，由PrincipalKind区分
+	PrincipalKind  string    `json:"principal_kind"`            // 会话主体类型: user、admin
+	ImpersonatorID string    `json:"impersonator_id,omitempty"` // 代为登录的管理员ID，用户本人登录时为空
+	Token          string    `json:"-"`                         // 明文令牌，仅在创建和刷新时有值
+	IP             string    `json:"ip"`                        // 最近一次使用时的来源IP
+	UserAgent      string    `json:"user_agent"`                // 创建会话时的User-Agent
+	ExpiresAt      time.Time `json:"expires_at"`
+	LastUsedAt     time.Time `json:"last_used_at"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// sessionColumns 查询会话时的列，与scanSession的顺序一致
+const sessionColumns = "id, user_id, principal_kind, impersonator_id, ip, user_agent, expires_at, last_used_at, created_at"
+
+// scanSession 读取一行会话数据
+func scanSession(row interface{ Scan(...interface{}) error }) (*Session, error) {
+	session := &Session{}
+	err := row.Scan(&session.ID, &session.UserID, &session.PrincipalKind, &session.ImpersonatorID, &session.IP, &session.UserAgent, &session.ExpiresAt, &session.LastUsedAt, &session.CreatedAt)
+	return session, err
+}
+
+// newSessionToken 生成随机会话令牌
+func newSessionToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成会话令牌失败: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken 返回令牌的SHA-256哈希
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
-// CreateSession 创建新的会话记录
-func CreateSession(userID string) (*Session, error) {
+// CreateSession 创建新的会话记录，kind为会话主体类型
+func CreateSession(kind, userID, ip, userAgent string, expiresAt time.Time) (*Session, error) {
+	defer metrics.ObserveQuery("CreateSession")()
+
+	return createSession(kind, userID, "", ip, userAgent, expiresAt)
+}
+
+// CreateImpersonationSession 为管理员创建代为登录用户的会话
+func CreateImpersonationSession(adminID, userID, ip, userAgent string, expiresAt time.Time) (*Session, error) {
+	defer metrics.ObserveQuery("CreateImpersonationSession")()
+
+	return createSession(string(models.PrincipalUser), userID, adminID, ip, userAgent, expiresAt)
+}
+
+// createSession 插入会话记录，impersonatorID为空表示主体本人登录
+func createSession(kind, userID, impersonatorID, ip, userAgent string, expiresAt time.Time) (*Session, error) {
+	token, err := newSessionToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
 	session := &Session{
-		ID:        uuid.New().String(),
-		UserID:    userID,
-		Token:     uuid.New().String(),
-		ExpiresAt: time.Now().Add(24 * time.Hour), // 会话有效期24小时
-		CreatedAt: time.Now(),
+		ID:             uuid.New().String(),
+		UserID:         userID,
+		PrincipalKind:  kind,
+		ImpersonatorID: impersonatorID,
+		Token:          token,
+		IP:             ip,
+		UserAgent:      userAgent,
+		ExpiresAt:      expiresAt,
+		LastUsedAt:     now,
+		CreatedAt:      now,
 	}
 
-	_, err := db.Exec(
-		"INSERT INTO sessions (id, user_id, token, expires_at, created_at) VALUES (?, ?, ?, ?, ?)",
+	_, err = db.Exec(
+		"INSERT INTO sessions (id, user_id, principal_kind, impersonator_id, token_hash, ip, user_agent, expires_at, last_used_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
 		session.ID,
 		session.UserID,
-		session.Token,
+		session.PrincipalKind,
+		session.ImpersonatorID,
+		hashToken(token),
+		session.IP,
+		session.UserAgent,
 		session.ExpiresAt,
+		session.LastUsedAt,
 		session.CreatedAt,
 	)
 
@@ -45,11 +114,12 @@ func CreateSession(userID string) (*Session, error) {
 
 // GetSessionByToken 通过令牌获取会话信息
 func GetSessionByToken(token string) (*Session, error) {
-	session := &Session{}
-	err := db.QueryRow(
-		"SELECT id, user_id, token, expires_at, created_at FROM sessions WHERE token = ?",
-		token,
-	).Scan(&session.ID, &session.UserID, &session.Token, &session.ExpiresAt, &session.CreatedAt)
+	defer metrics.ObserveQuery("GetSessionByToken")()
+
+	session, err := scanSession(db.QueryRow(
+		"SELECT "+sessionColumns+" FROM sessions WHERE token_hash = ?",
+		hashToken(token),
+	))
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -61,14 +131,126 @@ func GetSessionByToken(token string) (*Session, error) {
 	return session, nil
 }
 
+// GetSessionsByUserID 获取用户或管理员所有未过期的会话，最近使用的在前
+//
+// 用户和管理员的ID保存在同一列，需要同时按主体类型过滤。
+func GetSessionsByUserID(kind, userID string) ([]*Session, error) {
+	defer metrics.ObserveQuery("GetSessionsByUserID")()
+
+	rows, err := db.Query(
+		"SELECT "+sessionColumns+" FROM sessions WHERE user_id = ? AND principal_kind = ? AND expires_at > ? ORDER BY last_used_at DESC",
+		userID,
+		kind,
+		time.Now(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// TouchSession 记录会话的使用时间和来源IP，并延长过期时间
+func TouchSession(id, ip string, expiresAt time.Time) error {
+	defer metrics.ObserveQuery("TouchSession")()
+
+	_, err := db.Exec(
+		"UPDATE sessions SET ip = ?, last_used_at = ?, expires_at = ? WHERE id = ?",
+		ip,
+		time.Now(),
+		expiresAt,
+		id,
+	)
+	return err
+}
+
+// RefreshSession 为会话更换新令牌并延长过期时间，旧令牌随即失效
+func RefreshSession(session *Session, expiresAt time.Time) error {
+	defer metrics.ObserveQuery("RefreshSession")()
+
+	token, err := newSessionToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err = db.Exec(
+		"UPDATE sessions SET token_hash = ?, last_used_at = ?, expires_at = ? WHERE id = ?",
+		hashToken(token),
+		now,
+		expiresAt,
+		session.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	session.Token = token
+	session.LastUsedAt = now
+	session.ExpiresAt = expiresAt
+	return nil
+}
+
 // DeleteSession 删除会话记录
 func DeleteSession(token string) error {
-	_, err := db.Exec("DELETE FROM sessions WHERE token = ?", token)
+	defer metrics.ObserveQuery("DeleteSession")()
+
+	_, err := db.Exec("DELETE FROM sessions WHERE token_hash = ?", hashToken(token))
 	return err
 }
 
+// DeleteSessionByID 删除用户或管理员自己的一个会话
+func DeleteSessionByID(id, kind, userID string) error {
+	defer metrics.ObserveQuery("DeleteSessionByID")()
+
+	result, err := db.Exec("DELETE FROM sessions WHERE id = ? AND user_id = ? AND principal_kind = ?", id, userID, kind)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("会话不存在")
+	}
+	return nil
+}
+
+// DeleteSessionsByUserID 删除用户或管理员的所有会话，返回删除的数量
+func DeleteSessionsByUserID(kind, userID string) (int64, error) {
+	defer metrics.ObserveQuery("DeleteSessionsByUserID")()
+
+	result, err := db.Exec("DELETE FROM sessions WHERE user_id = ? AND principal_kind = ?", userID, kind)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// CountActiveSessions 统计未过期的会话数
+func CountActiveSessions() (int, error) {
+	defer metrics.ObserveQuery("CountActiveSessions")()
+
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM sessions WHERE expires_at > ?", time.Now()).Scan(&count)
+	return count, err
+}
+
 // CleanExpiredSessions 清理过期的会话记录
 func CleanExpiredSessions() error {
+	defer metrics.ObserveQuery("CleanExpiredSessions")()
+
 	// 获取当前时间
 	now := time.Now()
 
@@ -103,9 +285,12 @@ func StartSessionCleaner() {
 			if err := CleanExpiredSessions(); err != nil {
 				log.Error("执行定时清理任务失败", "error", err)
 			}
+			if err := CleanLoginAttempts(); err != nil {
+				log.Error("清理登录失败记录失败", "error", err)
+			}
 
 			// 等待下一次执行
 			<-ticker.C
 		}
 	}()
-}
\ No newline at end of file
+}
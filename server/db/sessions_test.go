@@ -0,0 +1,122 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"server/models"
+)
+
+// openTestDB 在临时目录中创建数据库
+func openTestDB(t *testing.T) {
+	t.Helper()
+	if err := Open(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+}
+
+// TestSessionsFilteredByPrincipalKind 用户和管理员的ID相同时，会话操作不能越过主体类型
+func TestSessionsFilteredByPrincipalKind(t *testing.T) {
+	openTestDB(t)
+
+	const id = "shared-id"
+	user := string(models.PrincipalUser)
+	admin := string(models.PrincipalAdmin)
+	expiresAt := time.Now().Add(time.Hour)
+
+	userSession, err := CreateSession(user, id, "127.0.0.1", "test", expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminSession, err := CreateSession(admin, id, "127.0.0.1", "test", expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := GetSessionsByUserID(user, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != userSession.ID {
+		t.Fatalf("用户会话列表包含了其他主体的会话: %+v", sessions)
+	}
+
+	if err := DeleteSessionByID(adminSession.ID, user, id); err == nil {
+		t.Error("用户不能删除管理员的会话")
+	}
+
+	count, err := DeleteSessionsByUserID(user, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("删除了%d个会话，期望1", count)
+	}
+	if session, err := GetSessionByToken(adminSession.Token); err != nil || session == nil {
+		t.Fatalf("管理员会话被删除: %v", err)
+	}
+
+	if err := DeleteSessionByID(adminSession.ID, admin, id); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestDeleteUserKeepsAdminSessions 删除用户不能删除ID相同的管理员的会话
+func TestDeleteUserKeepsAdminSessions(t *testing.T) {
+	openTestDB(t)
+
+	user := &models.User{ID: "shared-id", Username: "alice", Password: "password", Email: "alice@example.com"}
+	if err := SaveUser(user); err != nil {
+		t.Fatal(err)
+	}
+	expiresAt := time.Now().Add(time.Hour)
+	userSession, err := CreateSession(string(models.PrincipalUser), user.ID, "127.0.0.1", "test", expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminSession, err := CreateSession(string(models.PrincipalAdmin), user.ID, "127.0.0.1", "test", expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := DeleteUser(user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if session, err := GetSessionByToken(adminSession.Token); err != nil || session == nil {
+		t.Fatalf("删除用户时管理员会话被删除: %v", err)
+	}
+	if session, err := GetSessionByToken(userSession.Token); err != nil || session != nil {
+		t.Errorf("用户会话没有被删除: %v", err)
+	}
+}
+
+// TestImpersonationSession 代为登录的会话属于用户，记录管理员ID，删除管理员时一并删除
+func TestImpersonationSession(t *testing.T) {
+	openTestDB(t)
+
+	admin := &models.Admin{ID: "admin-id", Username: "root", Password: "password"}
+	if err := SaveAdmin(admin); err != nil {
+		t.Fatal(err)
+	}
+	session, err := CreateImpersonationSession(admin.ID, "user-id", "127.0.0.1", "test", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := GetSessionsByUserID(string(models.PrincipalUser), "user-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].ImpersonatorID != admin.ID {
+		t.Fatalf("用户会话列表中没有代为登录的会话: %+v", sessions)
+	}
+
+	if err := DeleteAdmin(admin.ID); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := GetSessionByToken(session.Token); err != nil || s != nil {
+		t.Errorf("删除管理员后代为登录的会话仍然存在: %v", err)
+	}
+}
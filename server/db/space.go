@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql"
 	"errors"
+	"server/metrics"
 	"server/models"
 	"time"
 
@@ -11,6 +12,8 @@ import (
 
 // SaveSpace 保存空间信息到数据库
 func SaveSpace(space *models.Space) error {
+	defer metrics.ObserveQuery("SaveSpace")()
+
 	// 设置创建和更新时间
 	now := time.Now()
 	space.CreatedAt = now
@@ -36,6 +39,8 @@ func SaveSpace(space *models.Space) error {
 
 // GetSpaceByID 根据ID获取空间信息
 func GetSpaceByID(id string) (*models.Space, error) {
+	defer metrics.ObserveQuery("GetSpaceByID")()
+
 	space := &models.Space{}
 	err := db.QueryRow(
 		"SELECT id, owner_id, name, description, created_at, updated_at FROM spaces WHERE id = ?",
@@ -55,6 +60,8 @@ func GetSpaceByID(id string) (*models.Space, error) {
 
 // GetSpacesByOwnerID 获取用户的所有空间
 func GetSpacesByOwnerID(ownerID string) ([]*models.Space, error) {
+	defer metrics.ObserveQuery("GetSpacesByOwnerID")()
+
 	rows, err := db.Query(
 		"SELECT id, owner_id, name, description, created_at, updated_at FROM spaces WHERE owner_id = ?",
 		ownerID,
@@ -81,6 +88,8 @@ func GetSpacesByOwnerID(ownerID string) ([]*models.Space, error) {
 
 // UpdateSpace 更新空间信息
 func UpdateSpace(space *models.Space) error {
+	defer metrics.ObserveQuery("UpdateSpace")()
+
 	// 检查空间是否存在
 	existingSpace, err := GetSpaceByID(space.ID)
 	if err != nil {
@@ -115,37 +124,52 @@ func UpdateSpace(space *models.Space) error {
 	return nil
 }
 
-// DeleteSpace 删除空间
-func DeleteSpace(id string, ownerID string) error {
+// DeleteSpace 删除空间及空间内的客户端、TURN服务器和WebAPIKey，返回被删除的客户端ID
+func DeleteSpace(id string, ownerID string) ([]string, error) {
+	defer metrics.ObserveQuery("DeleteSpace")()
+
 	// 检查空间是否存在
 	space, err := GetSpaceByID(id)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if space == nil {
-		return errors.New("空间不存在")
+		return nil, errors.New("空间不存在")
 	}
 
 	// 确保只能删除自己的空间
 	if space.OwnerID != ownerID {
-		return errors.New("无权删除此空间")
+		return nil, errors.New("无权删除此空间")
 	}
 
-	// 从数据库中删除
-	result, err := db.Exec("DELETE FROM spaces WHERE id = ? AND owner_id = ?", id, ownerID)
-	if err != nil {
-		log.Error("删除空间失败", "error", err)
-		return err
-	}
+	// 在同一事务中删除空间和关联数据
+	var clientIDs []string
+	err = withTx(func(tx *sql.Tx) error {
+		ids, err := deleteSpaceData(tx, id)
+		if err != nil {
+			return err
+		}
+		clientIDs = ids
+
+		result, err := tx.Exec("DELETE FROM spaces WHERE id = ? AND owner_id = ?", id, ownerID)
+		if err != nil {
+			return err
+		}
 
-	// 检查是否找到并删除了空间
-	affected, err := result.RowsAffected()
+		// 检查是否找到并删除了空间
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return errors.New("空间不存在或无权删除")
+		}
+		return nil
+	})
 	if err != nil {
-		return err
-	}
-	if affected == 0 {
-		return errors.New("空间不存在或无权删除")
+		log.Error("删除空间失败", "error", err)
+		return nil, err
 	}
 
-	return nil
+	return clientIDs, nil
 }
\ No newline at end of file
@@ -3,12 +3,15 @@ package db
 import (
 	"errors"
 	"database/sql"
+	"server/metrics"
 	"server/models"
 	"time"
 )
 
 // SaveTurn 保存TURN服务器配置
 func SaveTurn(turn *models.TurnServer) error {
+	defer metrics.ObserveQuery("SaveTurn")()
+
 	if turn == nil {
 		return errors.New("turn server config is nil")
 	}
@@ -28,6 +31,8 @@ func SaveTurn(turn *models.TurnServer) error {
 
 // GetTurnsByOwnerID 获取指定用户的所有TURN服务器配置
 func GetTurnsByOwnerID(ownerID string) ([]models.TurnServer, error) {
+	defer metrics.ObserveQuery("GetTurnsByOwnerID")()
+
 	rows, err := db.Query(`
 		SELECT id, owner_id, space_id, url, username, password, created_at, updated_at
 		FROM turn_servers
@@ -51,6 +56,8 @@ func GetTurnsByOwnerID(ownerID string) ([]models.TurnServer, error) {
 
 // UpdateTurn 更新TURN服务器配置
 func UpdateTurn(turn *models.TurnServer) error {
+	defer metrics.ObserveQuery("UpdateTurn")()
+
 	if turn == nil {
 		return errors.New("turn server config is nil")
 	}
@@ -83,6 +90,8 @@ func UpdateTurn(turn *models.TurnServer) error {
 
 // DeleteTurn 删除TURN服务器配置
 func DeleteTurn(turnID string, ownerID string) error {
+	defer metrics.ObserveQuery("DeleteTurn")()
+
 	// 检查TURN服务器是否存在
 	turn, err := GetTurnByID(turnID)
 	if err != nil {
@@ -107,6 +116,8 @@ func DeleteTurn(turnID string, ownerID string) error {
 
 // GetTurnByID 根据ID获取TURN服务器配置
 func GetTurnByID(id string) (*models.TurnServer, error) {
+	defer metrics.ObserveQuery("GetTurnByID")()
+
 	var turn models.TurnServer
 	err := db.QueryRow(`
 		SELECT id, owner_id, space_id, url, username, password, created_at, updated_at
@@ -126,6 +137,8 @@ func GetTurnByID(id string) (*models.TurnServer, error) {
 
 // GetTurnsBySpaceID 获取指定空间的所有TURN服务器配置
 func GetTurnsBySpaceID(spaceID string) ([]models.TurnServer, error) {
+	defer metrics.ObserveQuery("GetTurnsBySpaceID")()
+
 	rows, err := db.Query(`
 		SELECT id, owner_id, space_id, url, username, password, created_at, updated_at
 		FROM turn_servers
@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"server/metrics"
 	"server/models"
 	"time"
 
@@ -12,7 +13,9 @@ import (
 
 // GetUserList 获取用户列表
 func GetUserList() ([]*models.User, error) {
-	rows, err := db.Query("SELECT id, username, email, created_at, updated_at FROM users")
+	defer metrics.ObserveQuery("GetUserList")()
+
+	rows, err := db.Query("SELECT id, username, email, disabled, created_at, updated_at FROM users")
 	if err != nil {
 		log.Error("查询用户列表失败", "error", err)
 		return nil, err
@@ -22,7 +25,7 @@ func GetUserList() ([]*models.User, error) {
 	var users []*models.User
 	for rows.Next() {
 		user := &models.User{}
-		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Disabled, &user.CreatedAt, &user.UpdatedAt)
 		if err != nil {
 			log.Error("扫描用户数据失败", "error", err)
 			return nil, err
@@ -35,11 +38,13 @@ func GetUserList() ([]*models.User, error) {
 
 // GetUserByID 通过ID获取用户信息
 func GetUserByID(id string) (*models.User, error) {
+	defer metrics.ObserveQuery("GetUserByID")()
+
 	user := &models.User{}
 	err := db.QueryRow(
-		"SELECT id, username, email, created_at, updated_at FROM users WHERE id = ?",
+		"SELECT id, username, email, disabled, created_at, updated_at FROM users WHERE id = ?",
 		id,
-	).Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.Username, &user.Email, &user.Disabled, &user.CreatedAt, &user.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -54,6 +59,8 @@ func GetUserByID(id string) (*models.User, error) {
 
 // UpdateUser 更新用户信息
 func UpdateUser(user *models.User) error {
+	defer metrics.ObserveQuery("UpdateUser")()
+
 	// 检查用户是否存在
 	existingUser, err := GetUserByID(user.ID)
 	if err != nil {
@@ -114,22 +121,100 @@ func UpdateUser(user *models.User) error {
 	return err
 }
 
-// DeleteUser 删除用户
-func DeleteUser(id string) error {
-	// 删除用户
-	result, err := db.Exec("DELETE FROM users WHERE id = ?", id)
+// DeleteUser 删除用户及其拥有的空间、客户端、TURN服务器、WebAPIKey、访问令牌和会话，返回被删除的客户端ID
+func DeleteUser(id string) ([]string, error) {
+	defer metrics.ObserveQuery("DeleteUser")()
+
+	var clientIDs []string
+	err := withTx(func(tx *sql.Tx) error {
+		// 删除用户
+		result, err := tx.Exec("DELETE FROM users WHERE id = ?", id)
+		if err != nil {
+			return err
+		}
+
+		// 检查是否找到并删除了用户
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return fmt.Errorf("用户不存在")
+		}
+
+		clientIDs, err = deleteUserData(tx, id)
+		return err
+	})
 	if err != nil {
+		return nil, err
+	}
+
+	return clientIDs, nil
+}
+
+// SetUserDisabled 禁用或启用用户，禁用时同时注销用户的所有会话，返回用户拥有的客户端ID
+func SetUserDisabled(id string, disabled bool) ([]string, error) {
+	defer metrics.ObserveQuery("SetUserDisabled")()
+
+	var clientIDs []string
+	err := withTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec("UPDATE users SET disabled = ?, updated_at = ? WHERE id = ?", disabled, time.Now(), id)
+		if err != nil {
+			return err
+		}
+
+		// 检查是否找到了用户
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return fmt.Errorf("用户不存在")
+		}
+		if !disabled {
+			return nil
+		}
+
+		if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = ? AND principal_kind = ?", id, string(models.PrincipalUser)); err != nil {
+			return fmt.Errorf("注销用户会话失败: %w", err)
+		}
+		clientIDs, err = queryIDs(tx, "SELECT id FROM clients WHERE owner_id = ?", id)
 		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// 检查是否找到并删除了用户
-	affected, err := result.RowsAffected()
+	return clientIDs, nil
+}
+
+// ResetUserPassword 重置用户密码并注销用户的所有会话
+func ResetUserPassword(id, password string) error {
+	defer metrics.ObserveQuery("ResetUserPassword")()
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
-	if affected == 0 {
-		return fmt.Errorf("用户不存在")
-	}
 
-	return nil
+	return withTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec("UPDATE users SET password = ?, updated_at = ? WHERE id = ?", string(hashedPassword), time.Now(), id)
+		if err != nil {
+			return err
+		}
+
+		// 检查是否找到了用户
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return fmt.Errorf("用户不存在")
+		}
+
+		if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = ? AND principal_kind = ?", id, string(models.PrincipalUser)); err != nil {
+			return fmt.Errorf("注销用户会话失败: %w", err)
+		}
+		return nil
+	})
 }
\ No newline at end of file
@@ -2,11 +2,14 @@ package db
 
 import (
 	"database/sql"
+	"server/metrics"
 	"server/models"
 )
 
 // SaveWebAPIKey 保存WebAPIKey到数据库
 func SaveWebAPIKey(key *models.WebAPIKey) error {
+	defer metrics.ObserveQuery("SaveWebAPIKey")()
+
 	_, err := db.Exec(`
 		INSERT INTO web_api_keys (id, user_id, key, space_id, name, description, used, expires_at, created_at)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
@@ -16,6 +19,8 @@ func SaveWebAPIKey(key *models.WebAPIKey) error {
 
 // GetWebAPIKeyByKey 根据key获取WebAPIKey
 func GetWebAPIKeyByKey(key string) (*models.WebAPIKey, error) {
+	defer metrics.ObserveQuery("GetWebAPIKeyByKey")()
+
 	var apiKey models.WebAPIKey
 	err := db.QueryRow(`
 		SELECT id, user_id, key, space_id, name, description, used, expires_at, created_at
@@ -33,6 +38,8 @@ func GetWebAPIKeyByKey(key string) (*models.WebAPIKey, error) {
 
 // MarkWebAPIKeyAsUsed 将WebAPIKey标记为已使用
 func MarkWebAPIKeyAsUsed(id string) error {
+	defer metrics.ObserveQuery("MarkWebAPIKeyAsUsed")()
+
 	_, err := db.Exec(`
 		UPDATE web_api_keys
 		SET used = true
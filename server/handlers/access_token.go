@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"server/auth"
+	"server/db"
+	"server/logger"
+	"server/models"
+	"server/validate"
+	"time"
+)
+
+// maxAccessTokens 每个用户最多可以创建的访问令牌数
+const maxAccessTokens = 50
+
+func init() {
+	// 访问令牌的权限范围
+	validate.Register("scope", models.ValidScope, "不是有效的权限范围")
+}
+
+// AccessTokenRequest 创建访问令牌请求结构
+type AccessTokenRequest struct {
+	Name      string     `json:"name" validate:"required,max=64"`
+	Scopes    []string   `json:"scopes" validate:"required,scope"`
+	ExpiresAt *time.Time `json:"expires_at"` // RFC3339格式，为空表示永不过期
+}
+
+// CreatedAccessToken 创建访问令牌的响应结构，包含只返回一次的明文令牌
+type CreatedAccessToken struct {
+	*models.AccessToken
+	Token string `json:"token"`
+}
+
+// touchAccessToken 记录访问令牌的使用时间和来源IP，与会话相同按时间间隔节流
+func touchAccessToken(r *http.Request, token *models.AccessToken) {
+	now := time.Now()
+	if token.LastUsedAt != nil && now.Sub(*token.LastUsedAt) < sessionTouchInterval {
+		return
+	}
+	ip := auth.ClientIP(r)
+	if err := db.TouchAccessToken(token.ID, ip, now); err != nil {
+		logger.FromContext(r.Context()).Error("更新访问令牌使用时间失败", "error", err)
+		return
+	}
+	token.LastUsedAt = &now
+	token.LastUsedIP = ip
+}
+
+// HandleAccessTokenCreate 创建个人访问令牌，明文令牌只在响应中返回一次
+func HandleAccessTokenCreate(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request AccessTokenRequest
+	if !decodeBody(w, r, &request) {
+		return
+	}
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		validationFailed(w, r, validate.Errors{{Field: "expires_at", Message: "必须晚于当前时间"}})
+		return
+	}
+
+	// 去掉重复的权限范围
+	scopes := make([]string, 0, len(request.Scopes))
+	seen := make(map[string]bool)
+	for _, scope := range request.Scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	user := currentUser(r)
+	tokens, err := db.GetAccessTokensByUserID(user.ID)
+	if err != nil {
+		log.Error("获取访问令牌列表失败", "error", err)
+		http.Error(w, "Failed to create access token", http.StatusInternalServerError)
+		return
+	}
+	if len(tokens) >= maxAccessTokens {
+		http.Error(w, "访问令牌数量已达上限，请先撤销不再使用的令牌", http.StatusBadRequest)
+		return
+	}
+
+	token, err := db.CreateAccessToken(user.ID, request.Name, scopes, request.ExpiresAt)
+	if err != nil {
+		log.Error("创建访问令牌失败", "error", err)
+		http.Error(w, "Failed to create access token", http.StatusInternalServerError)
+		return
+	}
+	setAuditTarget(r, token.ID)
+
+	log.Info("访问令牌创建成功", "token_id", token.ID, "scopes", scopes)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   CreatedAccessToken{AccessToken: token, Token: token.Token},
+	})
+}
+
+// HandleAccessTokenList 列出当前用户的访问令牌，不包含明文令牌
+func HandleAccessTokenList(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tokens, err := db.GetAccessTokensByUserID(currentUser(r).ID)
+	if err != nil {
+		log.Error("获取访问令牌列表失败", "error", err)
+		http.Error(w, "Failed to get access token list", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   tokens,
+	})
+}
+
+// HandleAccessTokenDelete 撤销当前用户的指定访问令牌
+func HandleAccessTokenDelete(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tokenID := r.PathValue("id")
+	if err := db.DeleteAccessToken(tokenID, currentUser(r).ID); err != nil {
+		log.Error("撤销访问令牌失败", "token_id", tokenID, "error", err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	log.Info("访问令牌已撤销", "token_id", tokenID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "success",
+	})
+}
@@ -4,28 +4,61 @@ import (
 	"encoding/json"
 	"net/http"
 	"server/db"
+	"server/logger"
 	"server/models"
+	"time"
 
-	"github.com/charmbracelet/log"
 	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // RegisterRequest 注册请求结构
 type RegisterRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `json:"username" validate:"required,min=3,max=32"`
+	Password string `json:"password" validate:"required"`
+}
+
+// AdminUpdateRequest 管理员修改自己信息的请求结构
+type AdminUpdateRequest struct {
+	ID       string `json:"id" validate:"required"`
+	Username string `json:"username" validate:"required,min=3,max=32"`
+	Password string `json:"password"` // 为空表示不修改密码
+}
+
+// AdminInfo 返回给客户端的管理员信息，不包含密码哈希
+type AdminInfo struct {
+	ID                 string    `json:"id"`
+	Username           string    `json:"username"`
+	MustChangePassword bool      `json:"must_change_password"` // 是否需要先修改密码才能使用其他接口
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// newAdminInfo 将管理员转换为返回给客户端的结构
+func newAdminInfo(admin *models.Admin) *AdminInfo {
+	return &AdminInfo{
+		ID:                 admin.ID,
+		Username:           admin.Username,
+		MustChangePassword: admin.MustChangePassword,
+		CreatedAt:          admin.CreatedAt,
+	}
 }
 
 // HandleAdminRegister 处理管理员注册
 func HandleAdminRegister(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var req RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	// 检查密码是否符合密码策略
+	if !checkPassword(w, r, req.Password, req.Username) {
 		return
 	}
 
@@ -52,6 +85,8 @@ func HandleAdminRegister(w http.ResponseWriter, r *http.Request) {
 
 // HandleAdminList 处理管理员列表查询
 func HandleAdminList(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -65,32 +100,40 @@ func HandleAdminList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	infos := make([]*AdminInfo, 0, len(admins))
+	for _, admin := range admins {
+		infos = append(infos, newAdminInfo(admin))
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status": "success",
-		"data":   admins,
+		"data":   infos,
 	})
 }
 
 // HandleAdminUpdate 处理管理员信息更新
 func HandleAdminUpdate(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodPut {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 验证管理员身份
-	currentAdmin := r.Context().Value(AdminKey).(*models.Admin)
+	currentAdmin := currentAdmin(r)
 	if currentAdmin == nil {
 		http.Error(w, "需要管理员权限", http.StatusForbidden)
 		return
 	}
 
-	var updateAdmin models.Admin
-	if err := json.NewDecoder(r.Body).Decode(&updateAdmin); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var req AdminUpdateRequest
+	if !decodeBody(w, r, &req) {
 		return
 	}
+	setAuditTarget(r, req.ID)
+	updateAdmin := models.Admin{ID: req.ID, Username: req.Username, Password: req.Password}
 
 	// 确保管理员只能更新自己的信息
 	if currentAdmin.ID != updateAdmin.ID {
@@ -98,6 +141,29 @@ func HandleAdminUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 需要修改初始密码时必须提供新密码
+	if currentAdmin.MustChangePassword && updateAdmin.Password == "" {
+		http.Error(w, "请先修改初始密码", http.StatusBadRequest)
+		return
+	}
+
+	// 修改密码时检查新密码是否符合密码策略，且不能与当前密码相同
+	if updateAdmin.Password != "" {
+		if !checkPassword(w, r, updateAdmin.Password, updateAdmin.Username) {
+			return
+		}
+		existing, err := db.GetAdminByUsername(currentAdmin.Username)
+		if err != nil || existing == nil {
+			log.Error("查询管理员失败", "error", err)
+			http.Error(w, "Failed to update admin", http.StatusInternalServerError)
+			return
+		}
+		if bcrypt.CompareHashAndPassword([]byte(existing.Password), []byte(updateAdmin.Password)) == nil {
+			http.Error(w, "新密码不能与当前密码相同", http.StatusBadRequest)
+			return
+		}
+	}
+
 	// 更新管理员信息
 	if err := db.UpdateAdmin(&updateAdmin); err != nil {
 		log.Error("更新管理员信息失败", "error", err)
@@ -114,13 +180,15 @@ func HandleAdminUpdate(w http.ResponseWriter, r *http.Request) {
 
 // HandleAdminDelete 处理管理员删除
 func HandleAdminDelete(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 验证管理员身份
-	currentAdmin := r.Context().Value(AdminKey).(*models.Admin)
+	currentAdmin := currentAdmin(r)
 	if currentAdmin == nil {
 		http.Error(w, "需要管理员权限", http.StatusForbidden)
 		return
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"server/auth"
+	"server/db"
+	"server/logger"
+	"server/models"
+	"time"
+)
+
+// impersonationTTL 管理员代为登录会话的有效期，使用期间不会顺延
+const impersonationTTL = 15 * time.Minute
+
+// PasswordResetRequest 重置用户密码请求结构
+type PasswordResetRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+// ImpersonationResponse 管理员代为登录用户的响应结构
+type ImpersonationResponse struct {
+	Status    string    `json:"status"`
+	Token     string    `json:"token"` // 代为登录会话的令牌，通过Authorization: Bearer使用
+	ExpiresAt time.Time `json:"expires_at"`
+	User      *UserInfo `json:"user"`
+}
+
+// UserDisabledResponse 禁用或启用用户的响应结构
+type UserDisabledResponse struct {
+	Status   string `json:"status"`
+	Disabled bool   `json:"disabled"`
+}
+
+// HandleAdminUserDisable 禁用用户，注销其所有会话并断开其客户端的连接
+func HandleAdminUserDisable(w http.ResponseWriter, r *http.Request) {
+	setUserDisabled(w, r, true)
+}
+
+// HandleAdminUserEnable 启用被禁用的用户
+func HandleAdminUserEnable(w http.ResponseWriter, r *http.Request) {
+	setUserDisabled(w, r, false)
+}
+
+// setUserDisabled 禁用或启用路径中指定的用户
+func setUserDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	log := logger.FromContext(r.Context())
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.PathValue("id")
+	clientIDs, err := db.SetUserDisabled(userID, disabled)
+	if err != nil {
+		log.Error("修改用户状态失败", "target_user_id", userID, "error", err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if disabled {
+		DisconnectClients(clientIDs, "用户已禁用")
+		log.Info("用户已禁用", "target_user_id", userID, "clients", len(clientIDs))
+	} else {
+		log.Info("用户已启用", "target_user_id", userID)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(UserDisabledResponse{
+		Status:   "success",
+		Disabled: disabled,
+	})
+}
+
+// HandleAdminUserPassword 重置用户密码，用户的所有会话同时失效
+func HandleAdminUserPassword(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request PasswordResetRequest
+	if !decodeBody(w, r, &request) {
+		return
+	}
+
+	user := lookupUser(w, r)
+	if user == nil {
+		return
+	}
+
+	// 检查密码是否符合密码策略
+	if !checkPassword(w, r, request.Password, user.Username) {
+		return
+	}
+
+	if err := db.ResetUserPassword(user.ID, request.Password); err != nil {
+		log.Error("重置用户密码失败", "target_user_id", user.ID, "error", err)
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("用户密码已重置", "target_user_id", user.ID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "success",
+	})
+}
+
+// HandleAdminUserImpersonate 管理员代为登录用户，创建有效期固定的用户会话
+//
+// 代为登录的会话不设置Cookie，以免覆盖管理员自己的会话。使用该会话的所有请求都以管理员为操作者写入审计记录。
+func HandleAdminUserImpersonate(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := lookupUser(w, r)
+	if user == nil {
+		return
+	}
+	if user.Disabled {
+		http.Error(w, "用户已被禁用", http.StatusForbidden)
+		return
+	}
+
+	admin := currentAdmin(r)
+	session, err := db.CreateImpersonationSession(admin.ID, user.ID, auth.ClientIP(r), sessionUserAgent(r), time.Now().Add(impersonationTTL))
+	if err != nil {
+		log.Error("创建代为登录会话失败", "target_user_id", user.ID, "error", err)
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	log.Warn("管理员代为登录用户", "target_user_id", user.ID, "session_id", session.ID, "expires_at", session.ExpiresAt)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ImpersonationResponse{
+		Status:    "success",
+		Token:     session.Token,
+		ExpiresAt: session.ExpiresAt,
+		User:      newUserInfo(user),
+	})
+}
+
+// HandleAdminUserSpaces 查看指定用户的空间列表
+func HandleAdminUserSpaces(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := lookupUser(w, r)
+	if user == nil {
+		return
+	}
+
+	spaces, err := db.GetSpacesByOwnerID(user.ID)
+	if err != nil {
+		log.Error("获取空间列表失败", "target_user_id", user.ID, "error", err)
+		http.Error(w, "Failed to get space list", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   spaces,
+	})
+}
+
+// HandleAdminUserClients 查看指定用户的客户端列表
+func HandleAdminUserClients(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := lookupUser(w, r)
+	if user == nil {
+		return
+	}
+
+	clients, err := db.GetClientsByOwnerID(user.ID)
+	if err != nil {
+		log.Error("获取客户端列表失败", "target_user_id", user.ID, "error", err)
+		http.Error(w, "Failed to get client list", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   clients,
+	})
+}
+
+// lookupUser 查询路径中指定的用户，不存在或查询失败时写入错误响应并返回nil
+func lookupUser(w http.ResponseWriter, r *http.Request) *models.User {
+	userID := r.PathValue("id")
+	user, err := db.GetUserByID(userID)
+	if err != nil {
+		logger.FromContext(r.Context()).Error("查询用户失败", "target_user_id", userID, "error", err)
+		http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		return nil
+	}
+	if user == nil {
+		http.Error(w, "用户不存在", http.StatusNotFound)
+		return nil
+	}
+	return user
+}
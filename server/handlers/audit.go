@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"server/auth"
+	"server/db"
+	"server/logger"
+	"server/models"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultAuditLimit 审计记录查询默认每页条数
+	defaultAuditLimit = 50
+
+	// maxAuditLimit 审计记录查询每页最多条数
+	maxAuditLimit = 500
+
+	// auditActorClient 客户端WebSocket认证事件的操作者类型
+	auditActorClient = "client"
+)
+
+// AuditLogResponse 审计记录查询的响应结构
+type AuditLogResponse struct {
+	Status string               `json:"status"`
+	Data   []*models.AuditEntry `json:"data"`
+	Total  int                  `json:"total"` // 符合条件的记录总数
+	Limit  int                  `json:"limit"`
+	Offset int                  `json:"offset"`
+}
+
+// auditedReads 同样需要审计的GET接口，这些接口会创建数据或使用一次性凭据
+var auditedReads = map[string]bool{
+	"/api/web_api_keys": true,
+}
+
+type auditKey struct{}
+
+// auditInfo 请求处理过程中补充到审计记录的信息
+type auditInfo struct {
+	actorKind string
+	actorID   string
+	target    string
+	detail    string
+
+	// impersonated 管理员代为登录用户发起的请求，读请求同样记录
+	impersonated bool
+}
+
+// auditRecorder 记录响应状态码
+type auditRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *auditRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Audit 为写操作和认证接口记录审计日志，route为注册时的路径
+//
+// 操作者由认证中间件或登录接口通过setAuditActor补充，状态码小于400时记为成功。
+func Audit(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info := &auditInfo{target: r.PathValue("id")}
+		if info.target == "" {
+			info.target = r.URL.Query().Get("id")
+		}
+		rec := &auditRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r.WithContext(context.WithValue(r.Context(), auditKey{}, info)))
+
+		// 是否代为登录在认证后才能确定，读请求只有代为登录时才记录
+		if !isMutating(r.Method) && !auditedReads[route] && !info.impersonated {
+			return
+		}
+
+		outcome := models.AuditSuccess
+		if rec.status >= http.StatusBadRequest {
+			outcome = models.AuditFailure
+		}
+		recordAudit(r, &models.AuditEntry{
+			RequestID: logger.RequestID(r.Context()),
+			ActorKind: info.actorKind,
+			ActorID:   info.actorID,
+			Action:    r.Method + " " + route,
+			Target:    info.target,
+			IP:        auth.ClientIP(r),
+			Status:    rec.status,
+			Outcome:   outcome,
+			Detail:    info.detail,
+		})
+	}
+}
+
+// isMutating 判断请求方法是否会修改数据
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// recordAudit 写入审计记录，失败时只记录日志，不影响已完成的请求
+func recordAudit(r *http.Request, entry *models.AuditEntry) {
+	if err := db.AddAuditLog(entry); err != nil {
+		logger.FromContext(r.Context()).Error("写入审计记录失败", "action", entry.Action, "error", err)
+	}
+}
+
+// auditFromContext 返回请求的审计信息，请求没有经过Audit时返回nil
+func auditFromContext(r *http.Request) *auditInfo {
+	info, _ := r.Context().Value(auditKey{}).(*auditInfo)
+	return info
+}
+
+// setAuditActor 设置审计记录的操作者
+func setAuditActor(r *http.Request, kind models.PrincipalKind, id string) {
+	if info := auditFromContext(r); info != nil {
+		info.actorKind = string(kind)
+		info.actorID = id
+	}
+}
+
+// setAuditImpersonation 记录管理员代为登录用户发起的请求，操作者为管理员，detail为被代为登录的用户
+func setAuditImpersonation(r *http.Request, adminID, userID string) {
+	if info := auditFromContext(r); info != nil {
+		info.actorKind = string(models.PrincipalAdmin)
+		info.actorID = adminID
+		info.detail = "impersonate:" + userID
+		info.impersonated = true
+	}
+}
+
+// setAuditTarget 设置审计记录的操作对象
+func setAuditTarget(r *http.Request, target string) {
+	if info := auditFromContext(r); info != nil {
+		info.target = target
+	}
+}
+
+// setAuditDetail 设置审计记录的补充说明，如登录失败的原因
+func setAuditDetail(r *http.Request, detail string) {
+	if info := auditFromContext(r); info != nil {
+		info.detail = detail
+	}
+}
+
+// HandleAuditLog 按条件分页查询审计记录
+//
+// 支持actor_kind、actor_id、action、target、ip、outcome、since、until(RFC3339)、limit和offset参数。
+func HandleAuditLog(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.AuditFilter{
+		ActorKind: query.Get("actor_kind"),
+		ActorID:   query.Get("actor_id"),
+		Action:    query.Get("action"),
+		Target:    query.Get("target"),
+		IP:        query.Get("ip"),
+		Outcome:   query.Get("outcome"),
+		Limit:     defaultAuditLimit,
+	}
+
+	var err error
+	for name, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := query.Get(name); v != "" {
+			if *t, err = time.Parse(time.RFC3339, v); err != nil {
+				http.Error(w, "无效的"+name+"参数，需要RFC3339格式的时间", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	for name, n := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if v := query.Get(name); v != "" {
+			if *n, err = strconv.Atoi(v); err != nil || *n < 0 {
+				http.Error(w, "无效的"+name+"参数", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultAuditLimit
+	} else if filter.Limit > maxAuditLimit {
+		filter.Limit = maxAuditLimit
+	}
+
+	entries, total, err := db.QueryAuditLog(filter)
+	if err != nil {
+		log.Error("查询审计记录失败", "error", err)
+		http.Error(w, "Failed to query audit log", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AuditLogResponse{
+		Status: "success",
+		Data:   entries,
+		Total:  total,
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	})
+}
+
+// recordClientAuth 记录客户端WebSocket身份验证的审计记录
+func recordClientAuth(r *http.Request, clientID, outcome, detail string) {
+	recordAudit(r, &models.AuditEntry{
+		ActorKind: auditActorClient,
+		ActorID:   clientID,
+		Action:    "WS /ws/client",
+		Target:    clientID,
+		IP:        auth.ClientIP(r),
+		Outcome:   outcome,
+		Detail:    detail,
+	})
+}
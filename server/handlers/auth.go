@@ -2,38 +2,53 @@ package handlers
 
 import (
 	"encoding/json"
+	"math"
 	"net/http"
+	"server/auth"
 	"server/db"
+	"server/logger"
+	"server/metrics"
 	"server/models"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/charmbracelet/log"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // LoginRequest 登录请求结构
 type LoginRequest struct {
-	Account  string `json:"account"` // 可以是用户名或邮箱
-	Password string `json:"password"`
+	Account  string `json:"account" validate:"required,max=254"` // 可以是用户名或邮箱
+	Password string `json:"password" validate:"required"`
 }
 
 // LoginResponse 登录响应结构
 type LoginResponse struct {
-	Status string      `json:"status"`
-	User   *models.User  `json:"user,omitempty"`
-	Admin  *models.Admin `json:"admin,omitempty"`
+	Status string     `json:"status"`
+	User   *UserInfo  `json:"user,omitempty"`
+	Admin  *AdminInfo `json:"admin,omitempty"`
 }
 
 // HandleUserLogin 处理用户登录
 func HandleUserLogin(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var req LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	// 审计记录的操作对象为登录账号
+	setAuditTarget(r, req.Account)
+
+	// 账号或来源IP连续失败过多时暂时拒绝登录
+	accountKey, ipKey := loginKeys("user", req.Account, r)
+	if checkLoginLocked(w, r, accountKey, ipKey) {
 		return
 	}
 
@@ -48,6 +63,8 @@ func HandleUserLogin(w http.ResponseWriter, r *http.Request) {
 		user, err = db.GetUserByUsername(req.Account)
 		if err != nil || user == nil {
 			log.Error("用户登录失败：账号不存在", "account", req.Account, "error", err)
+			setAuditDetail(r, "账号不存在")
+			recordLoginFailure(r, accountKey, ipKey)
 			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 			return
 		}
@@ -56,12 +73,25 @@ func HandleUserLogin(w http.ResponseWriter, r *http.Request) {
 	// 验证密码
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
 		log.Error("用户登录失败：密码错误", "account", req.Account)
+		setAuditActor(r, models.PrincipalUser, user.ID)
+		setAuditDetail(r, "密码错误")
+		recordLoginFailure(r, accountKey, ipKey)
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
+	clearLoginFailures(r, accountKey)
+	setAuditActor(r, models.PrincipalUser, user.ID)
+
+	// 被禁用的用户不能登录
+	if user.Disabled {
+		log.Warn("用户登录失败：账号已被禁用", "user_id", user.ID)
+		setAuditDetail(r, "账号已被禁用")
+		http.Error(w, "账号已被禁用", http.StatusForbidden)
+		return
+	}
 
 	// 创建新的会话
-	session, err := db.CreateSession(user.ID)
+	session, err := newSession(r, models.PrincipalUser, user.ID)
 	if err != nil {
 		log.Error("创建用户会话失败", "user_id", user.ID, "error", err)
 		http.Error(w, "Failed to create session", http.StatusInternalServerError)
@@ -70,34 +100,36 @@ func HandleUserLogin(w http.ResponseWriter, r *http.Request) {
 	log.Info("用户登录成功", "username", user.Username, "user_id", user.ID)
 
 	// 设置Cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    session.Token,
-		Path:     "/",
-		Expires:  session.ExpiresAt,
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteStrictMode,
-	})
+	setSessionCookie(w, session)
 
 	// 返回用户信息
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(LoginResponse{
 		Status: "success",
-		User:   user,
+		User:   newUserInfo(user),
 	})
 }
 
 // HandleAdminLogin 处理管理员登录
 func HandleAdminLogin(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var req LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	// 审计记录的操作对象为登录账号
+	setAuditTarget(r, req.Account)
+
+	// 账号或来源IP连续失败过多时暂时拒绝登录
+	accountKey, ipKey := loginKeys("admin", req.Account, r)
+	if checkLoginLocked(w, r, accountKey, ipKey) {
 		return
 	}
 
@@ -106,46 +138,50 @@ func HandleAdminLogin(w http.ResponseWriter, r *http.Request) {
 	admin, err := db.GetAdminByUsername(req.Account)
 	if err != nil || admin == nil {
 		log.Error("管理员登录失败：用户名不存在", "username", req.Account, "error", err)
+		setAuditDetail(r, "账号不存在")
+		recordLoginFailure(r, accountKey, ipKey)
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
 	// 验证密码
 	if err := bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(req.Password)); err != nil {
 		log.Error("管理员登录失败：密码错误", "username", req.Account)
+		setAuditActor(r, models.PrincipalAdmin, admin.ID)
+		setAuditDetail(r, "密码错误")
+		recordLoginFailure(r, accountKey, ipKey)
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
+	clearLoginFailures(r, accountKey)
+	setAuditActor(r, models.PrincipalAdmin, admin.ID)
 
 	// 创建新的会话
-	session, err := db.CreateSession(admin.ID)
+	session, err := newSession(r, models.PrincipalAdmin, admin.ID)
 	if err != nil {
 		log.Error("创建管理员会话失败", "admin_id", admin.ID, "error", err)
 		http.Error(w, "Failed to create session", http.StatusInternalServerError)
 		return
 	}
 	log.Info("管理员登录成功", "username", admin.Username, "admin_id", admin.ID)
+	if admin.MustChangePassword {
+		log.Warn("管理员需要修改初始密码", "admin_id", admin.ID)
+	}
 
 	// 设置Cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    session.Token,
-		Path:     "/",
-		Expires:  session.ExpiresAt,
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteStrictMode,
-	})
+	setSessionCookie(w, session)
 
 	// 返回管理员信息
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(LoginResponse{
 		Status: "success",
-		Admin:  admin,
+		Admin:  newAdminInfo(admin),
 	})
 }
 
 // HandleLogout 处理用户登出
 func HandleLogout(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -156,20 +192,14 @@ func HandleLogout(w http.ResponseWriter, r *http.Request) {
 	if err == nil {
 		// 删除会话记录
 		if err := db.DeleteSession(cookie.Value); err != nil {
-			log.Error("删除用户会话失败", "session_token", cookie.Value, "error", err)
+			log.Error("删除用户会话失败", "error", err)
 		} else {
-			log.Info("用户登出成功", "session_token", cookie.Value)
+			log.Info("用户登出成功")
 		}
 	}
 
 	// 删除Cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    "",
-		Path:     "/",
-		Expires:  time.Now(),
-		HttpOnly: true,
-	})
+	clearSessionCookie(w)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
@@ -179,6 +209,8 @@ func HandleLogout(w http.ResponseWriter, r *http.Request) {
 
 // HandleAdminLogout 处理管理员登出
 func HandleAdminLogout(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -189,23 +221,79 @@ func HandleAdminLogout(w http.ResponseWriter, r *http.Request) {
 	if err == nil {
 		// 删除会话记录
 		if err := db.DeleteSession(cookie.Value); err != nil {
-			log.Error("删除管理员会话失败", "session_token", cookie.Value, "error", err)
+			log.Error("删除管理员会话失败", "error", err)
 		} else {
-			log.Info("管理员登出成功", "session_token", cookie.Value)
+			log.Info("管理员登出成功")
 		}
 	}
 
 	// 删除Cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    "",
-		Path:     "/",
-		Expires:  time.Now(),
-		HttpOnly: true,
-	})
+	clearSessionCookie(w)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
 		"status": "success",
 	})
-}
\ No newline at end of file
+}
+
+// loginKeys 返回登录失败计数使用的账号键和来源IP键，kind为user或admin
+func loginKeys(kind, account string, r *http.Request) (accountKey, ipKey string) {
+	return kind + ":" + strings.ToLower(account), "ip:" + auth.ClientIP(r)
+}
+
+// checkLoginLocked 检查账号和来源IP是否处于锁定期，锁定时返回429并返回true
+func checkLoginLocked(w http.ResponseWriter, r *http.Request, keys ...string) bool {
+	log := logger.FromContext(r.Context())
+
+	for _, key := range keys {
+		attempt, err := db.GetLoginAttempt(key)
+		if err != nil {
+			log.Error("查询登录失败记录失败", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return true
+		}
+		if attempt.Locked() {
+			retryAfter := int(math.Ceil(time.Until(attempt.LockedUntil).Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			setAuditDetail(r, "登录已锁定")
+			http.Error(w, "登录失败次数过多，请稍后再试", http.StatusTooManyRequests)
+			return true
+		}
+	}
+	return false
+}
+
+// recordLoginFailure 记录账号和来源IP的登录失败，达到阈值时锁定
+//
+// 账号不存在时同样计数，避免通过是否锁定判断账号是否存在。
+func recordLoginFailure(r *http.Request, accountKey, ipKey string) {
+	log := logger.FromContext(r.Context())
+
+	for _, target := range []struct {
+		key         string
+		scope       string
+		maxFailures int
+	}{
+		{accountKey, "account", auth.Lockout.MaxFailures},
+		{ipKey, "ip", auth.Lockout.IPMaxFailures},
+	} {
+		attempt, err := db.RecordLoginFailure(target.key, func(failures int) time.Duration {
+			return auth.Lockout.LockDuration(failures, target.maxFailures)
+		})
+		if err != nil {
+			log.Error("记录登录失败次数失败", "error", err)
+			continue
+		}
+		if attempt.Locked() {
+			metrics.LoginLockouts.WithLabelValues(target.scope).Inc()
+			log.Warn("连续登录失败，暂时锁定", "scope", target.scope, "failures", attempt.Failures, "locked_until", attempt.LockedUntil)
+		}
+	}
+}
+
+// clearLoginFailures 登录成功后清除账号的失败记录，来源IP的记录保留
+func clearLoginFailures(r *http.Request, accountKey string) {
+	if err := db.ClearLoginAttempts(accountKey); err != nil {
+		logger.FromContext(r.Context()).Error("清除登录失败记录失败", "error", err)
+	}
+}
@@ -4,35 +4,68 @@ import (
 	"encoding/json"
 	"net/http"
 	"server/db"
+	"server/logger"
 	"server/models"
+	"server/validate"
 
-	"github.com/charmbracelet/log"
 	"github.com/google/uuid"
 )
 
+// ClientRequest 创建客户端的请求结构
+type ClientRequest struct {
+	Name        string `json:"name" validate:"required,max=64"`
+	Description string `json:"description" validate:"max=256"`
+	SpaceID     string `json:"space_id" validate:"required"`
+}
+
+// ClientUpdateRequest 修改客户端的请求结构
+type ClientUpdateRequest struct {
+	ID string `json:"id" validate:"required"`
+	ClientRequest
+}
+
 // HandleClientCreate 处理客户端创建
 func HandleClientCreate(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 获取当前用户
-	user := r.Context().Value(UserKey).(*models.User)
+	user := currentUser(r)
 	if user == nil {
 		http.Error(w, "未授权的访问", http.StatusUnauthorized)
 		return
 	}
 
-	var client models.Client
-	if err := json.NewDecoder(r.Body).Decode(&client); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var req ClientRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	// 只能在自己的空间中创建
+	var errs validate.Errors
+	if err := checkSpaceOwner(&errs, "space_id", req.SpaceID, user.ID); err != nil {
+		log.Error("获取空间信息失败", "error", err)
+		http.Error(w, "获取空间信息失败", http.StatusInternalServerError)
+		return
+	}
+	if len(errs) > 0 {
+		validationFailed(w, r, errs)
 		return
 	}
 
 	// 设置客户端ID和所有者ID
-	client.ID = uuid.New().String()
-	client.OwnerID = user.ID
+	client := models.Client{
+		ID:          uuid.New().String(),
+		OwnerID:     user.ID,
+		SpaceID:     req.SpaceID,
+		Name:        req.Name,
+		Description: req.Description,
+	}
+	setAuditTarget(r, client.ID)
 
 	// 保存客户端信息
 	if err := db.SaveClient(&client); err != nil {
@@ -51,13 +84,15 @@ func HandleClientCreate(w http.ResponseWriter, r *http.Request) {
 
 // HandleClientList 处理客户端列表查询
 func HandleClientList(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 获取当前用户
-	user := r.Context().Value(UserKey).(*models.User)
+	user := currentUser(r)
 	if user == nil {
 		http.Error(w, "未授权的访问", http.StatusUnauthorized)
 		return
@@ -80,29 +115,55 @@ func HandleClientList(w http.ResponseWriter, r *http.Request) {
 
 // HandleClientUpdate 处理客户端信息更新
 func HandleClientUpdate(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodPut {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 获取当前用户
-	user := r.Context().Value(UserKey).(*models.User)
+	user := currentUser(r)
 	if user == nil {
 		http.Error(w, "未授权的访问", http.StatusUnauthorized)
 		return
 	}
 
-	var updateClient models.Client
-	if err := json.NewDecoder(r.Body).Decode(&updateClient); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var req ClientUpdateRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	setAuditTarget(r, req.ID)
+
+	// 确保只能更新自己的客户端，公钥等其他字段保持不变
+	updateClient, err := db.GetClientByID(req.ID)
+	if err != nil {
+		log.Error("获取客户端信息失败", "error", err)
+		http.Error(w, "获取客户端信息失败", http.StatusInternalServerError)
+		return
+	}
+	if updateClient == nil || updateClient.OwnerID != user.ID {
+		http.Error(w, "客户端不存在", http.StatusNotFound)
 		return
 	}
 
-	// 确保只能更新自己的客户端
-	updateClient.OwnerID = user.ID
+	// 只能移动到自己的空间
+	var errs validate.Errors
+	if err := checkSpaceOwner(&errs, "space_id", req.SpaceID, user.ID); err != nil {
+		log.Error("获取空间信息失败", "error", err)
+		http.Error(w, "获取空间信息失败", http.StatusInternalServerError)
+		return
+	}
+	if len(errs) > 0 {
+		validationFailed(w, r, errs)
+		return
+	}
+	updateClient.SpaceID = req.SpaceID
+	updateClient.Name = req.Name
+	updateClient.Description = req.Description
 
 	// 更新客户端信息
-	if err := db.UpdateClient(&updateClient); err != nil {
+	if err := db.UpdateClient(updateClient); err != nil {
 		log.Error("更新客户端信息失败", "error", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -117,13 +178,15 @@ func HandleClientUpdate(w http.ResponseWriter, r *http.Request) {
 
 // HandleClientDelete 处理客户端删除
 func HandleClientDelete(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 获取当前用户
-	user := r.Context().Value(UserKey).(*models.User)
+	user := currentUser(r)
 	if user == nil {
 		http.Error(w, "未授权的访问", http.StatusUnauthorized)
 		return
@@ -142,6 +205,7 @@ func HandleClientDelete(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	DisconnectClients([]string{clientID}, "客户端已删除")
 
 	log.Info("客户端删除成功", "client_id", clientID)
 	w.Header().Set("Content-Type", "application/json")
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"encoding/json"
 	"server/models"
 	"time"
 
@@ -9,6 +10,8 @@ import (
 
 // handlePing 处理来自客户端的ping消息
 func handlePing(client *models.Client, msg *models.Message) {
+	log := log.With("conn_id", client.ConnID)
+
 	// 解析ping消息数据
 	if data, ok := msg.Data.(map[string]interface{}); ok {
 		// 获取时间戳
@@ -28,8 +31,10 @@ func handlePing(client *models.Client, msg *models.Message) {
 					status[id] = stateStr
 				}
 			}
-			// 更新客户端的WebRTC状态
+			// 更新客户端的WebRTC状态，整体替换以便广播时复制
+			clientsLock.Lock()
 			client.WebRTCStatus = status
+			clientsLock.Unlock()
 		}
 	}
 
@@ -42,3 +47,35 @@ func handlePing(client *models.Client, msg *models.Message) {
 		log.Error("发送pong消息失败", "error", err)
 	}
 }
+
+// 客户端统计的大小上限，超过时丢弃，避免占用内存和推送给监控连接的带宽
+const (
+	maxStatsSize  = 16 * 1024
+	maxStatsPeers = 64
+)
+
+// handleStats 保存客户端上报的连接和音频统计，并推送给监控连接
+func handleStats(client *models.Client, msg *models.Message) {
+	log := log.With("conn_id", client.ConnID)
+
+	data, err := json.Marshal(msg.Data)
+	if err != nil || len(data) > maxStatsSize {
+		log.Warn("无效的统计消息", "client_id", client.ID, "size", len(data))
+		return
+	}
+	stats := &models.ClientStats{}
+	if err := json.Unmarshal(data, stats); err != nil || len(stats.Peers) > maxStatsPeers {
+		log.Warn("无效的统计消息", "client_id", client.ID, "error", err)
+		return
+	}
+
+	clientsLock.Lock()
+	if c, ok := clients[client.ID]; ok {
+		c.Stats = stats
+		c.StatsTime = time.Now()
+	}
+	clientsLock.Unlock()
+
+	log.Debug("收到客户端统计", "client_id", client.ID)
+	broadcastClientsInfo()
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"server/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialMonitor 连接监控WebSocket并读取连接时推送的客户端信息
+func dialMonitor(t *testing.T) *websocket.Conn {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(HandleInfoWebSocket))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	readClientsInfo(t, conn)
+	return conn
+}
+
+// readClientsInfo 读取一条客户端信息推送
+func readClientsInfo(t *testing.T, conn *websocket.Conn) []models.Client {
+	t.Helper()
+	var msg struct {
+		Type string          `json:"type"`
+		Data []models.Client `json:"data"`
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != "clients_info" {
+		t.Fatalf("type = %q, want clients_info", msg.Type)
+	}
+	return msg.Data
+}
+
+// addTestClient 直接登记在线客户端，不经过数据库
+func addTestClient(t *testing.T, id string) *models.Client {
+	t.Helper()
+	client := &models.Client{ID: id, ConnID: "conn-" + id}
+	clientsLock.Lock()
+	clients[id] = client
+	clientsLock.Unlock()
+	t.Cleanup(func() {
+		clientsLock.Lock()
+		delete(clients, id)
+		clientsLock.Unlock()
+	})
+	return client
+}
+
+func statsMessage(peers int) *models.Message {
+	list := make([]interface{}, peers)
+	for i := range list {
+		list[i] = map[string]interface{}{"peer_id": "peer", "state": "connected", "rtt_ms": 12.5}
+	}
+	return &models.Message{Type: "stats", Data: map[string]interface{}{
+		"timestamp": "2026-10-18T12:00:00Z",
+		"peers":     list,
+		"audio":     map[string]interface{}{"pipeline": map[string]interface{}{"sent_packets": 10}},
+	}}
+}
+
+func TestHandleStatsBroadcast(t *testing.T) {
+	conn := dialMonitor(t)
+	client := addTestClient(t, "stats-client")
+
+	handleStats(client, statsMessage(1))
+
+	list := readClientsInfo(t, conn)
+	if len(list) != 1 || list[0].Stats == nil {
+		t.Fatalf("clients = %+v, want stats", list)
+	}
+	stats := list[0].Stats
+	if len(stats.Peers) != 1 || stats.Peers[0].RTTMs != 12.5 || !strings.Contains(string(stats.Audio), "sent_packets") {
+		t.Errorf("stats = %+v", stats)
+	}
+	if list[0].StatsTime.IsZero() {
+		t.Error("stats_time not set")
+	}
+}
+
+func TestHandleStatsRejectsOversized(t *testing.T) {
+	client := addTestClient(t, "oversized-client")
+
+	handleStats(client, statsMessage(maxStatsPeers+1))
+	handleStats(client, &models.Message{Type: "stats", Data: map[string]interface{}{
+		"audio": strings.Repeat("x", maxStatsSize),
+	}})
+	handleStats(client, &models.Message{Type: "stats", Data: "not an object"})
+
+	clientsLock.RLock()
+	defer clientsLock.RUnlock()
+	if client.Stats != nil {
+		t.Errorf("stats = %+v, want rejected", client.Stats)
+	}
+}
+
+// TestHandleStatsConcurrent 统计更新、ping和广播并发进行，配合-race检查数据竞争
+func TestHandleStatsConcurrent(t *testing.T) {
+	conn := dialMonitor(t)
+	client := addTestClient(t, "concurrent-client")
+
+	const updates = 20
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < updates; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			handleStats(client, statsMessage(2))
+		}()
+		go func() {
+			defer wg.Done()
+			UpdateClientPing(client.ID, 5)
+		}()
+	}
+	wg.Wait()
+	conn.Close()
+	<-done
+}
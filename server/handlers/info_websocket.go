@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"server/db"
+	"server/metrics"
 	"server/models"
 
 	"github.com/charmbracelet/log"
@@ -18,6 +19,9 @@ var (
 	clients     = make(map[string]*models.Client)
 	monitors    = make(map[string]*websocket.Conn)
 	clientsLock sync.RWMutex
+
+	// monitorWriteLock 串行化对监控连接的写入，WebSocket连接不支持并发写
+	monitorWriteLock sync.Mutex
 )
 
 // HandleInfoWebSocket 处理WebSocket信息监控连接
@@ -31,16 +35,19 @@ func HandleInfoWebSocket(w http.ResponseWriter, r *http.Request) {
 
 	// 为监控连接生成唯一ID
 	monitorID := uuid.New().String()
+	log := log.With("conn_id", monitorID)
 
 	// 注册监控连接
 	clientsLock.Lock()
 	monitors[monitorID] = conn
+	updateConnectionMetrics()
 	clientsLock.Unlock()
 
 	// 清理函数
 	defer func() {
 		clientsLock.Lock()
 		delete(monitors, monitorID)
+		updateConnectionMetrics()
 		clientsLock.Unlock()
 	}()
 
@@ -80,6 +87,7 @@ func RegisterClient(client *models.Client) {
 	// 注册客户端
 	clientsLock.Lock()
 	clients[client.ID] = client
+	updateConnectionMetrics()
 	clientsLock.Unlock()
 
 	// 广播客户端状态更新
@@ -90,12 +98,38 @@ func RegisterClient(client *models.Client) {
 func UnregisterClient(clientID string) {
 	clientsLock.Lock()
 	delete(clients, clientID)
+	updateConnectionMetrics()
 	clientsLock.Unlock()
 
 	// 广播客户端状态更新
 	broadcastClientsInfo()
 }
 
+// DisconnectClients 断开已被删除的客户端的连接，连接关闭后由读取循环注销客户端
+func DisconnectClients(clientIDs []string, reason string) {
+	clientsLock.RLock()
+	var conns []*websocket.Conn
+	for _, id := range clientIDs {
+		if client, ok := clients[id]; ok {
+			conns = append(conns, client.Conn)
+		}
+	}
+	clientsLock.RUnlock()
+
+	deadline := time.Now().Add(time.Second)
+	for _, conn := range conns {
+		// WriteControl可以与其他写操作并发调用
+		msg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason)
+		if err := conn.WriteControl(websocket.CloseMessage, msg, deadline); err != nil {
+			log.Warn("发送关闭消息失败", "error", err)
+		}
+		conn.Close()
+	}
+	if len(conns) > 0 {
+		log.Info("已断开被删除客户端的连接", "count", len(conns), "reason", reason)
+	}
+}
+
 // UpdateClientPing 更新客户端的ping信息
 func UpdateClientPing(clientID string, pingDelay int64) {
 	clientsLock.Lock()
@@ -109,37 +143,56 @@ func UpdateClientPing(clientID string, pingDelay int64) {
 	broadcastClientsInfo()
 }
 
-// sendClientsInfo 发送客户端信息到指定的监控连接
-func sendClientsInfo(conn *websocket.Conn) {
+// updateConnectionMetrics 根据当前连接更新连接数指标，调用方需持有clientsLock
+func updateConnectionMetrics() {
+	// 重新统计而不是增减，重复注销同一客户端时也能保持准确
+	metrics.ConnectedClients.Reset()
+	for _, client := range clients {
+		metrics.ConnectedClients.WithLabelValues(client.SpaceID).Inc()
+	}
+	metrics.MonitorConnections.Set(float64(len(monitors)))
+}
+
+// clientsSnapshot 在持有clientsLock时复制所有客户端信息
+//
+// 序列化在释放锁之后进行，复制后不再读取会被并发修改的客户端对象。
+// Stats和WebRTCStatus更新时整体替换，可以与副本共享。
+func clientsSnapshot() []models.Client {
 	clientsLock.RLock()
-	clientsList := make([]*models.Client, 0, len(clients))
+	defer clientsLock.RUnlock()
+
+	clientsList := make([]models.Client, 0, len(clients))
 	for _, client := range clients {
-		clientsList = append(clientsList, client)
+		clientsList = append(clientsList, *client)
 	}
-	clientsLock.RUnlock()
+	return clientsList
+}
+
+// sendClientsInfo 发送客户端信息到指定的监控连接
+func sendClientsInfo(conn *websocket.Conn) {
+	monitorWriteLock.Lock()
+	defer monitorWriteLock.Unlock()
 
 	// 发送客户端列表
-	if err := conn.WriteJSON(map[string]interface{}{
+	message := map[string]interface{}{
 		"type": "clients_info",
-		"data": clientsList,
-	}); err != nil {
+		"data": clientsSnapshot(),
+	}
+	if err := conn.WriteJSON(message); err != nil {
 		log.Error("发送客户端信息失败", "error", err)
 	}
 }
 
 // broadcastClientsInfo 广播客户端信息到所有监控连接
 func broadcastClientsInfo() {
-	clientsLock.RLock()
-	clientsList := make([]*models.Client, 0, len(clients))
-	for _, client := range clients {
-		clientsList = append(clientsList, client)
-	}
-	clientsLock.RUnlock()
+	// 持有写锁后再复制，保证监控连接按更新顺序收到客户端信息
+	monitorWriteLock.Lock()
+	defer monitorWriteLock.Unlock()
 
 	// 准备广播消息
 	message := map[string]interface{}{
 		"type": "clients_info",
-		"data": clientsList,
+		"data": clientsSnapshot(),
 	}
 
 	// 广播到所有监控连接
@@ -4,6 +4,8 @@ import (
 	"context"
 	"net/http"
 	"server/db"
+	"server/logger"
+	"server/metrics"
 	"server/models"
 	"strings"
 	"time"
@@ -13,13 +15,33 @@ import (
 type AuthContext string
 
 const (
-	SessionKey AuthContext = "session"
-	UserKey    AuthContext = "user"
-	AdminKey   AuthContext = "admin"
+	SessionKey   AuthContext = "session"
+	PrincipalKey AuthContext = "principal"
 )
 
-// AuthMiddleware 用于验证用户会话的中间件
+// passwordChangeRoutes 需要修改初始密码的管理员可以访问的接口
+var passwordChangeRoutes = map[string]bool{
+	"/api/admin/update": true,
+	"/api/admin/logout": true,
+	"/api/logout/all":   true,
+}
+
+// impersonationDeniedRoutes 管理员代为登录时不能访问的接口，这些接口会创建比代为登录会话更长期的凭据或修改账号
+var impersonationDeniedRoutes = map[string]bool{
+	"/api/sessions/refresh":     true,
+	"/api/tokens":               true,
+	"/api/users/update":         true,
+	"/api/users/delete":         true,
+	"/api/web_api_key/generate": true,
+}
+
+// AuthMiddleware 用于验证用户会话的中间件，不接受个人访问令牌
 func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return authenticate("", next)
+}
+
+// authenticate 验证会话或个人访问令牌，scope为空时只接受会话，否则同时接受拥有该权限范围的访问令牌
+func authenticate(scope string, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// 优先从Cookie中获取token
 		var token string
@@ -30,89 +52,236 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			// 从请求头中获取认证令牌
 			auth := r.Header.Get("Authorization")
 			if auth == "" {
-				http.Error(w, "未提供认证令牌", http.StatusUnauthorized)
+				metrics.HTTPAuth.WithLabelValues("missing_token").Inc()
+				apiError(w, r, http.StatusUnauthorized, "missing_token", "未提供认证令牌")
 				return
 			}
 
 			// 解析Bearer令牌
 			parts := strings.Split(auth, " ")
 			if len(parts) != 2 || parts[0] != "Bearer" {
-				http.Error(w, "无效的认证令牌格式", http.StatusUnauthorized)
+				metrics.HTTPAuth.WithLabelValues("invalid_token").Inc()
+				apiError(w, r, http.StatusUnauthorized, "invalid_token", "无效的认证令牌格式")
 				return
 			}
 			token = parts[1]
+
+			// 个人访问令牌以固定前缀开头
+			if strings.HasPrefix(token, db.AccessTokenPrefix) {
+				authenticateToken(w, r, token, scope, next)
+				return
+			}
 		}
 
 		// 从数据库验证会话令牌
 		session, err := db.GetSessionByToken(token)
 		if err != nil || session == nil {
-			http.Error(w, "无效的会话", http.StatusUnauthorized)
+			metrics.HTTPAuth.WithLabelValues("invalid_session").Inc()
+			apiError(w, r, http.StatusUnauthorized, "invalid_session", "无效的会话")
 			return
 		}
 
 		// 检查会话是否过期
 		if session.ExpiresAt.Before(time.Now()) {
-			http.Error(w, "会话已过期", http.StatusUnauthorized)
+			metrics.HTTPAuth.WithLabelValues("expired").Inc()
+			apiError(w, r, http.StatusUnauthorized, "session_expired", "会话已过期")
 			return
 		}
 
-		// 将会话信息添加到请求上下文
-		ctx := context.WithValue(r.Context(), SessionKey, session)
-		r = r.WithContext(ctx)
-
-		// 尝试获取用户信息
-		user, err := db.GetUserByID(session.UserID)
-		if err == nil && user != nil {
-			ctx = context.WithValue(r.Context(), UserKey, user)
-			r = r.WithContext(ctx)
-			next.ServeHTTP(w, r)
+		// 按会话记录的主体类型加载用户或管理员，不在两类账号之间回退查找
+		var principal *models.Principal
+		switch models.PrincipalKind(session.PrincipalKind) {
+		case models.PrincipalUser:
+			user, err := db.GetUserByID(session.UserID)
+			if err == nil && user != nil {
+				// 禁用用户时会注销其会话，这里再次检查以防并发登录
+				if user.Disabled {
+					metrics.HTTPAuth.WithLabelValues("disabled").Inc()
+					setAuditActor(r, models.PrincipalUser, user.ID)
+					setAuditDetail(r, "账号已被禁用")
+					apiError(w, r, http.StatusForbidden, "account_disabled", "账号已被禁用")
+					return
+				}
+				principal = models.NewUserPrincipal(user)
+			}
+			if principal != nil && session.ImpersonatorID != "" && !checkImpersonation(w, r, session) {
+				return
+			}
+		case models.PrincipalAdmin:
+			admin, err := db.GetAdminByID(session.UserID)
+			if err == nil && admin != nil {
+				// 需要修改初始密码的管理员只能修改密码或登出
+				if admin.MustChangePassword && !passwordChangeRoutes[r.URL.Path] {
+					metrics.HTTPAuth.WithLabelValues("password_change_required").Inc()
+					apiError(w, r, http.StatusForbidden, "password_change_required", "请先修改初始密码")
+					return
+				}
+				principal = models.NewAdminPrincipal(admin)
+			}
+		}
+		if principal == nil {
+			metrics.HTTPAuth.WithLabelValues("unknown_principal").Inc()
+			apiError(w, r, http.StatusUnauthorized, codeUnauthenticated, "无效的用户身份")
 			return
 		}
+		metrics.HTTPAuth.WithLabelValues("success").Inc()
+		// 代为登录的会话已由checkImpersonation记录操作者
+		if session.ImpersonatorID == "" {
+			setAuditActor(r, principal.Kind, principal.ID)
+		}
 
-		// 尝试获取管理员信息
-		admin, err := db.GetAdminByID(session.UserID)
-		if err == nil && admin != nil {
-			ctx = context.WithValue(r.Context(), AdminKey, admin)
-			r = r.WithContext(ctx)
-			next.ServeHTTP(w, r)
-			return
+		// 记录会话使用并顺延过期时间
+		touchSession(r, session)
+
+		// 将会话和主体添加到请求上下文，后续日志都带上主体类型和ID
+		ctx := context.WithValue(r.Context(), SessionKey, session)
+		ctx = context.WithValue(ctx, PrincipalKey, principal)
+		log := logger.FromContext(ctx).With("principal", principal.Kind, "user_id", principal.ID)
+		if session.ImpersonatorID != "" {
+			log = log.With("impersonator_id", session.ImpersonatorID)
 		}
+		ctx = logger.NewContext(ctx, log)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// checkImpersonation 检查管理员代为登录的会话，管理员不存在或接口不允许代为访问时写入错误响应并返回false
+func checkImpersonation(w http.ResponseWriter, r *http.Request, session *db.Session) bool {
+	setAuditImpersonation(r, session.ImpersonatorID, session.UserID)
+
+	admin, err := db.GetAdminByID(session.ImpersonatorID)
+	if err != nil || admin == nil {
+		metrics.HTTPAuth.WithLabelValues("unknown_principal").Inc()
+		apiError(w, r, http.StatusUnauthorized, codeUnauthenticated, "无效的用户身份")
+		return false
+	}
+	if impersonationDeniedRoutes[r.URL.Path] {
+		metrics.HTTPAuth.WithLabelValues("impersonation_denied").Inc()
+		apiError(w, r, http.StatusForbidden, "impersonation_denied", "代为登录时不能访问该接口")
+		return false
+	}
+	return true
+}
+
+// authenticateToken 验证个人访问令牌及其权限范围，通过后以令牌所属用户的身份调用next
+func authenticateToken(w http.ResponseWriter, r *http.Request, plain, scope string, next http.HandlerFunc) {
+	token, err := db.GetAccessTokenByToken(plain)
+	if err != nil || token == nil {
+		metrics.HTTPAuth.WithLabelValues("invalid_access_token").Inc()
+		apiError(w, r, http.StatusUnauthorized, "invalid_access_token", "无效的访问令牌")
+		return
+	}
+	setAuditActor(r, models.PrincipalUser, token.UserID)
+	setAuditDetail(r, "access_token:"+token.ID)
+
+	if token.Expired() {
+		metrics.HTTPAuth.WithLabelValues("expired").Inc()
+		apiError(w, r, http.StatusUnauthorized, "access_token_expired", "访问令牌已过期")
+		return
+	}
+
+	user, err := db.GetUserByID(token.UserID)
+	if err != nil || user == nil {
+		metrics.HTTPAuth.WithLabelValues("unknown_principal").Inc()
+		apiError(w, r, http.StatusUnauthorized, codeUnauthenticated, "无效的用户身份")
+		return
+	}
+	if user.Disabled {
+		metrics.HTTPAuth.WithLabelValues("disabled").Inc()
+		apiError(w, r, http.StatusForbidden, "account_disabled", "账号已被禁用")
+		return
+	}
 
-		http.Error(w, "无效的用户身份", http.StatusUnauthorized)
+	// 访问令牌只能访问声明了权限范围的接口，不能管理会话、令牌或账号
+	if scope == "" {
+		metrics.HTTPAuth.WithLabelValues("scope_denied").Inc()
+		apiError(w, r, http.StatusForbidden, "token_not_allowed", "访问令牌不能访问该接口")
+		return
+	}
+	if !token.HasScope(scope) {
+		metrics.HTTPAuth.WithLabelValues("scope_denied").Inc()
+		apiError(w, r, http.StatusForbidden, "insufficient_scope", "访问令牌缺少"+scope+"权限")
+		return
 	}
+	metrics.HTTPAuth.WithLabelValues("success").Inc()
+
+	// 记录令牌使用
+	touchAccessToken(r, token)
+
+	principal := models.NewTokenPrincipal(user, token)
+	ctx := context.WithValue(r.Context(), PrincipalKey, principal)
+	ctx = logger.NewContext(ctx, logger.FromContext(ctx).With("principal", principal.Kind, "user_id", principal.ID, "token_id", token.ID))
+	next.ServeHTTP(w, r.WithContext(ctx))
 }
 
-// RequireAuth 包装需要认证的处理函数
+// RequireAuth 包装需要认证的处理函数，用户和管理员均可访问
 func RequireAuth(handler http.HandlerFunc) http.HandlerFunc {
 	return AuthMiddleware(handler)
 }
 
-// RequireUser 验证用户身份并确保只能操作自己的数据
-func RequireUser(handler http.HandlerFunc) http.HandlerFunc {
-	return AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
-		// 获取当前用户
-		user, ok := r.Context().Value(UserKey).(*models.User)
-		if !ok || user == nil {
-			http.Error(w, "需要用户权限", http.StatusForbidden)
+// RequireRole 验证主体拥有指定角色，否则返回403
+func RequireRole(role string, handler http.HandlerFunc) http.HandlerFunc {
+	return AuthMiddleware(requireRole(role, handler))
+}
+
+// requireRole 检查已认证的主体拥有指定角色，否则返回403
+func requireRole(role string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !currentPrincipal(r).HasRole(role) {
+			apiError(w, r, http.StatusForbidden, codeForbidden, "需要"+roleNames[role]+"权限")
 			return
 		}
 
 		// 调用处理函数
 		handler.ServeHTTP(w, r)
-	})
+	}
+}
+
+// RequireScope 验证用户身份，同时接受拥有scope权限范围的个人访问令牌
+func RequireScope(scope string, handler http.HandlerFunc) http.HandlerFunc {
+	return authenticate(scope, requireRole(models.RoleUser, handler))
+}
+
+// RequireUser 验证用户身份并确保只能操作自己的数据
+func RequireUser(handler http.HandlerFunc) http.HandlerFunc {
+	return RequireRole(models.RoleUser, handler)
 }
 
 // RequireAdmin 验证管理员身份
 func RequireAdmin(handler http.HandlerFunc) http.HandlerFunc {
-	return AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
-		// 获取当前管理员
-		admin, ok := r.Context().Value(AdminKey).(*models.Admin)
-		if !ok || admin == nil {
-			http.Error(w, "需要管理员权限", http.StatusForbidden)
-			return
-		}
+	return RequireRole(models.RoleAdmin, handler)
+}
 
-		// 调用处理函数
-		handler.ServeHTTP(w, r)
-	})
-}
\ No newline at end of file
+// roleNames 角色在错误信息中的名称
+var roleNames = map[string]string{
+	models.RoleUser:  "用户",
+	models.RoleAdmin: "管理员",
+}
+
+// currentPrincipal 返回请求的认证主体，未认证时返回nil
+func currentPrincipal(r *http.Request) *models.Principal {
+	principal, _ := r.Context().Value(PrincipalKey).(*models.Principal)
+	return principal
+}
+
+// currentSession 返回请求使用的会话，未认证时返回nil
+func currentSession(r *http.Request) *db.Session {
+	session, _ := r.Context().Value(SessionKey).(*db.Session)
+	return session
+}
+
+// currentUser 返回发起请求的普通用户，主体不是普通用户时返回nil
+func currentUser(r *http.Request) *models.User {
+	if principal := currentPrincipal(r); principal != nil {
+		return principal.User
+	}
+	return nil
+}
+
+// currentAdmin 返回发起请求的管理员，主体不是管理员时返回nil
+func currentAdmin(r *http.Request) *models.Admin {
+	if principal := currentPrincipal(r); principal != nil {
+		return principal.Admin
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"server/logger"
+	"server/models"
+	"server/openapi"
+	"sync"
+)
+
+// apiDocsPage 接口文档页面，从CDN加载Swagger UI并展示/api/openapi.json
+//
+//go:embed apidocs.html
+var apiDocsPage []byte
+
+var (
+	openAPIOnce sync.Once
+	openAPIJSON []byte
+	openAPIErr  error
+)
+
+// v2列表接口的分页参数
+var pageParams = []openapi.Param{
+	{Name: "limit", Type: "integer", Description: "每页条数，1到100，默认20"},
+	{Name: "cursor", Description: "上一页返回的next_cursor"},
+}
+
+// withPage 在查询参数后加上分页参数
+func withPage(params ...openapi.Param) []openapi.Param {
+	return append(params, pageParams...)
+}
+
+// Operations 返回所有接口的描述，新增或修改路由时需要同步修改
+func Operations() []openapi.Operation {
+	// v1接口返回{"status": "success", "data": ...}
+	v1 := func(op openapi.Operation) openapi.Operation {
+		op.Envelope = openapi.EnvelopeStatus
+		return op
+	}
+	// v2接口返回{"data": ...}，失败时返回JSON错误信息
+	v2 := func(op openapi.Operation) openapi.Operation {
+		op.Auth = openapi.AuthScope
+		op.Error = ErrorResponse{}
+		if op.Envelope == openapi.EnvelopeNone {
+			op.Envelope = openapi.EnvelopeData
+		}
+		return op
+	}
+	id := func(description string) []openapi.Param {
+		return []openapi.Param{{Name: "id", Description: description, Required: true}}
+	}
+
+	return []openapi.Operation{
+		// 认证
+		{Method: http.MethodPost, Path: "/api/register", Tag: "认证", Summary: "用户注册，成功后自动登录", Request: UserRequest{}, Response: RegisterResponse{}, Reveals: []string{"token"}},
+		{Method: http.MethodPost, Path: "/api/login", Tag: "认证", Summary: "用户登录，账号可以是用户名或邮箱", Request: LoginRequest{}, Response: LoginResponse{}},
+		{Method: http.MethodPost, Path: "/api/admin/login", Tag: "认证", Summary: "管理员登录", Request: LoginRequest{}, Response: LoginResponse{}},
+		v1(openapi.Operation{Method: http.MethodPost, Path: "/api/logout", Tag: "认证", Summary: "用户注销当前会话", Auth: openapi.AuthUser}),
+		v1(openapi.Operation{Method: http.MethodPost, Path: "/api/admin/logout", Tag: "认证", Summary: "管理员注销当前会话", Auth: openapi.AuthAdmin}),
+
+		// 会话
+		v1(openapi.Operation{Method: http.MethodGet, Path: "/api/sessions", Tag: "会话", Summary: "列出当前用户或管理员的所有会话", Auth: openapi.AuthSession, Response: []SessionInfo{}}),
+		v1(openapi.Operation{Method: http.MethodDelete, Path: "/api/sessions/{id}", Tag: "会话", Summary: "注销指定会话", Auth: openapi.AuthSession}),
+		{Method: http.MethodPost, Path: "/api/sessions/refresh", Tag: "会话", Summary: "刷新当前会话并延长有效期", Auth: openapi.AuthSession, Response: SessionRefreshResponse{}, Reveals: []string{"token"}},
+		{Method: http.MethodPost, Path: "/api/logout/all", Tag: "会话", Summary: "注销所有设备上的会话", Auth: openapi.AuthSession, Response: LogoutAllResponse{}},
+
+		// 访问令牌
+		v1(openapi.Operation{Method: http.MethodPost, Path: "/api/tokens", Tag: "访问令牌", Summary: "创建个人访问令牌，明文令牌只返回一次", Auth: openapi.AuthUser, Request: AccessTokenRequest{}, Response: CreatedAccessToken{}, Reveals: []string{"token"}}),
+		v1(openapi.Operation{Method: http.MethodGet, Path: "/api/tokens/list", Tag: "访问令牌", Summary: "列出当前用户的访问令牌", Auth: openapi.AuthUser, Response: []models.AccessToken{}}),
+		v1(openapi.Operation{Method: http.MethodDelete, Path: "/api/tokens/{id}", Tag: "访问令牌", Summary: "撤销访问令牌", Auth: openapi.AuthUser}),
+
+		// 管理员
+		v1(openapi.Operation{Method: http.MethodPost, Path: "/api/admin/register", Tag: "管理员", Summary: "创建管理员", Auth: openapi.AuthAdmin, Request: RegisterRequest{}}),
+		v1(openapi.Operation{Method: http.MethodGet, Path: "/api/admin/list", Tag: "管理员", Summary: "列出所有管理员", Auth: openapi.AuthAdmin, Response: []AdminInfo{}}),
+		v1(openapi.Operation{Method: http.MethodPut, Path: "/api/admin/update", Tag: "管理员", Summary: "修改当前管理员的用户名或密码", Auth: openapi.AuthAdmin, Request: AdminUpdateRequest{}}),
+		v1(openapi.Operation{Method: http.MethodDelete, Path: "/api/admin/delete", Tag: "管理员", Summary: "删除管理员，不指定id时删除自己", Auth: openapi.AuthAdmin, Query: []openapi.Param{{Name: "id", Description: "管理员ID"}}}),
+
+		// 用户管理与审计
+		{Method: http.MethodPost, Path: "/api/admin/users/{id}/disable", Tag: "用户管理", Summary: "禁用用户，注销其会话并断开其客户端", Auth: openapi.AuthAdmin, Response: UserDisabledResponse{}},
+		{Method: http.MethodPost, Path: "/api/admin/users/{id}/enable", Tag: "用户管理", Summary: "启用用户", Auth: openapi.AuthAdmin, Response: UserDisabledResponse{}},
+		v1(openapi.Operation{Method: http.MethodPost, Path: "/api/admin/users/{id}/password", Tag: "用户管理", Summary: "重置用户密码", Auth: openapi.AuthAdmin, Request: PasswordResetRequest{}}),
+		{Method: http.MethodPost, Path: "/api/admin/users/{id}/impersonate", Tag: "用户管理", Summary: "代为登录用户，返回15分钟内有效的会话令牌", Auth: openapi.AuthAdmin, Response: ImpersonationResponse{}, Reveals: []string{"token"}},
+		v1(openapi.Operation{Method: http.MethodGet, Path: "/api/admin/users/{id}/spaces", Tag: "用户管理", Summary: "查看用户的空间", Auth: openapi.AuthAdmin, Response: []models.Space{}}),
+		v1(openapi.Operation{Method: http.MethodGet, Path: "/api/admin/users/{id}/clients", Tag: "用户管理", Summary: "查看用户的客户端", Auth: openapi.AuthAdmin, Response: []models.Client{}}),
+		{Method: http.MethodGet, Path: "/api/admin/audit", Tag: "用户管理", Summary: "按条件分页查询审计记录", Auth: openapi.AuthAdmin, Response: AuditLogResponse{}, Query: []openapi.Param{
+			{Name: "actor_kind", Description: "操作者类型：user、admin或client"},
+			{Name: "actor_id", Description: "操作者ID"},
+			{Name: "action", Description: "包含该字符串的操作"},
+			{Name: "target", Description: "操作对象"},
+			{Name: "ip", Description: "来源IP"},
+			{Name: "outcome", Description: "操作结果：success或failure"},
+			{Name: "since", Description: "起始时间，RFC3339格式"},
+			{Name: "until", Description: "结束时间，RFC3339格式"},
+			{Name: "limit", Type: "integer", Description: "每页条数，默认50，最多500"},
+			{Name: "offset", Type: "integer", Description: "跳过的条数"},
+		}},
+
+		// 用户
+		v1(openapi.Operation{Method: http.MethodPost, Path: "/api/users", Tag: "用户", Summary: "管理员创建用户", Auth: openapi.AuthAdmin, Request: UserRequest{}}),
+		v1(openapi.Operation{Method: http.MethodGet, Path: "/api/users/list", Tag: "用户", Summary: "管理员列出所有用户，用户只返回自己", Auth: openapi.AuthSession, Response: []UserInfo{}}),
+		v1(openapi.Operation{Method: http.MethodPut, Path: "/api/users/update", Tag: "用户", Summary: "修改用户信息", Auth: openapi.AuthSession, Request: UserUpdateRequest{}}),
+		v1(openapi.Operation{Method: http.MethodDelete, Path: "/api/users/delete", Tag: "用户", Summary: "删除用户，用户只能删除自己", Auth: openapi.AuthSession, Query: []openapi.Param{{Name: "id", Description: "管理员删除时指定的用户ID"}}}),
+
+		// 客户端
+		v1(openapi.Operation{Method: http.MethodGet, Path: "/api/clients/list", Tag: "客户端", Summary: "列出当前用户的客户端", Auth: openapi.AuthScope, Scope: models.ScopeClientsRead, Response: []models.Client{}}),
+		v1(openapi.Operation{Method: http.MethodPut, Path: "/api/clients/update", Tag: "客户端", Summary: "修改客户端信息", Auth: openapi.AuthScope, Scope: models.ScopeClientsWrite, Request: ClientUpdateRequest{}}),
+		v1(openapi.Operation{Method: http.MethodDelete, Path: "/api/clients/delete", Tag: "客户端", Summary: "删除客户端", Auth: openapi.AuthScope, Scope: models.ScopeClientsWrite, Query: id("客户端ID")}),
+
+		// 空间
+		v1(openapi.Operation{Method: http.MethodPost, Path: "/api/spaces", Tag: "空间", Summary: "创建空间", Auth: openapi.AuthScope, Scope: models.ScopeSpacesWrite, Request: SpaceRequest{}, Response: models.Space{}}),
+		v1(openapi.Operation{Method: http.MethodGet, Path: "/api/spaces/list", Tag: "空间", Summary: "列出当前用户的空间", Auth: openapi.AuthScope, Scope: models.ScopeSpacesRead, Response: []models.Space{}}),
+		v1(openapi.Operation{Method: http.MethodPut, Path: "/api/spaces/update", Tag: "空间", Summary: "修改空间信息", Auth: openapi.AuthScope, Scope: models.ScopeSpacesWrite, Request: SpaceUpdateRequest{}}),
+		v1(openapi.Operation{Method: http.MethodDelete, Path: "/api/spaces/delete", Tag: "空间", Summary: "删除空间及空间内的客户端、TURN服务器和WebAPIKey", Auth: openapi.AuthScope, Scope: models.ScopeSpacesWrite, Query: id("空间ID")}),
+
+		// TURN服务器
+		v1(openapi.Operation{Method: http.MethodPost, Path: "/api/turns", Tag: "TURN服务器", Summary: "创建TURN服务器配置", Auth: openapi.AuthScope, Scope: models.ScopeTurnsWrite, Request: TurnRequest{}, Response: TurnServerInfo{}}),
+		v1(openapi.Operation{Method: http.MethodGet, Path: "/api/turns/list", Tag: "TURN服务器", Summary: "列出当前用户的TURN服务器配置", Auth: openapi.AuthScope, Scope: models.ScopeTurnsRead, Response: []TurnServerInfo{}}),
+		v1(openapi.Operation{Method: http.MethodPut, Path: "/api/turns/update", Tag: "TURN服务器", Summary: "修改TURN服务器配置", Auth: openapi.AuthScope, Scope: models.ScopeTurnsWrite, Request: TurnUpdateRequest{}}),
+		v1(openapi.Operation{Method: http.MethodDelete, Path: "/api/turns/delete", Tag: "TURN服务器", Summary: "删除TURN服务器配置", Auth: openapi.AuthScope, Scope: models.ScopeTurnsWrite, Query: id("TURN服务器配置ID")}),
+
+		// v2空间
+		v2(openapi.Operation{Method: http.MethodGet, Path: "/api/v2/spaces", Tag: "v2", Summary: "分页列出空间", Scope: models.ScopeSpacesRead, Response: models.Space{}, Envelope: openapi.EnvelopeList, Query: withPage(openapi.Param{Name: "name", Description: "名称包含该字符串，不区分大小写"})}),
+		v2(openapi.Operation{Method: http.MethodPost, Path: "/api/v2/spaces", Tag: "v2", Summary: "创建空间", Scope: models.ScopeSpacesWrite, Request: SpaceRequest{}, Response: models.Space{}, Status: http.StatusCreated}),
+		v2(openapi.Operation{Method: http.MethodGet, Path: "/api/v2/spaces/{id}", Tag: "v2", Summary: "获取空间", Scope: models.ScopeSpacesRead, Response: models.Space{}}),
+		v2(openapi.Operation{Method: http.MethodPatch, Path: "/api/v2/spaces/{id}", Tag: "v2", Summary: "修改空间，省略的字段保持不变", Scope: models.ScopeSpacesWrite, Request: spaceInput{}, Response: models.Space{}}),
+		v2(openapi.Operation{Method: http.MethodDelete, Path: "/api/v2/spaces/{id}", Tag: "v2", Summary: "删除空间及空间内的客户端、TURN服务器和WebAPIKey", Scope: models.ScopeSpacesWrite, Status: http.StatusNoContent}),
+		v2(openapi.Operation{Method: http.MethodGet, Path: "/api/v2/spaces/{id}/clients", Tag: "v2", Summary: "分页列出空间内的客户端", Scope: models.ScopeClientsRead, Response: models.Client{}, Envelope: openapi.EnvelopeList, Query: withPage(openapi.Param{Name: "name", Description: "名称包含该字符串，不区分大小写"})}),
+		v2(openapi.Operation{Method: http.MethodGet, Path: "/api/v2/spaces/{id}/turns", Tag: "v2", Summary: "分页列出空间的TURN服务器", Scope: models.ScopeTurnsRead, Response: TurnServerInfo{}, Envelope: openapi.EnvelopeList, Query: withPage()}),
+
+		// v2客户端
+		v2(openapi.Operation{Method: http.MethodGet, Path: "/api/v2/clients", Tag: "v2", Summary: "分页列出客户端", Scope: models.ScopeClientsRead, Response: models.Client{}, Envelope: openapi.EnvelopeList, Query: withPage(
+			openapi.Param{Name: "space_id", Description: "只列出该空间内的客户端"},
+			openapi.Param{Name: "name", Description: "名称包含该字符串，不区分大小写"},
+		)}),
+		v2(openapi.Operation{Method: http.MethodGet, Path: "/api/v2/clients/{id}", Tag: "v2", Summary: "获取客户端", Scope: models.ScopeClientsRead, Response: models.Client{}}),
+		v2(openapi.Operation{Method: http.MethodPatch, Path: "/api/v2/clients/{id}", Tag: "v2", Summary: "修改客户端的名称、描述或所在空间", Scope: models.ScopeClientsWrite, Request: clientInput{}, Response: models.Client{}}),
+		v2(openapi.Operation{Method: http.MethodDelete, Path: "/api/v2/clients/{id}", Tag: "v2", Summary: "删除客户端并断开其连接", Scope: models.ScopeClientsWrite, Status: http.StatusNoContent}),
+
+		// v2 TURN服务器
+		v2(openapi.Operation{Method: http.MethodGet, Path: "/api/v2/turns", Tag: "v2", Summary: "分页列出TURN服务器", Scope: models.ScopeTurnsRead, Response: TurnServerInfo{}, Envelope: openapi.EnvelopeList, Query: withPage(openapi.Param{Name: "space_id", Description: "只列出该空间的TURN服务器"})}),
+		v2(openapi.Operation{Method: http.MethodPost, Path: "/api/v2/turns", Tag: "v2", Summary: "在自己的空间中创建TURN服务器", Scope: models.ScopeTurnsWrite, Request: TurnRequest{}, Response: TurnServerInfo{}, Status: http.StatusCreated}),
+		v2(openapi.Operation{Method: http.MethodGet, Path: "/api/v2/turns/{id}", Tag: "v2", Summary: "获取TURN服务器", Scope: models.ScopeTurnsRead, Response: TurnServerInfo{}}),
+		v2(openapi.Operation{Method: http.MethodPatch, Path: "/api/v2/turns/{id}", Tag: "v2", Summary: "修改TURN服务器的地址或凭据", Scope: models.ScopeTurnsWrite, Request: turnInput{}, Response: TurnServerInfo{}}),
+		v2(openapi.Operation{Method: http.MethodDelete, Path: "/api/v2/turns/{id}", Tag: "v2", Summary: "删除TURN服务器", Scope: models.ScopeTurnsWrite, Status: http.StatusNoContent}),
+
+		// 客户端接入
+		{Method: http.MethodPost, Path: "/api/test/connect", Tag: "客户端接入", Summary: "让两个在线客户端建立测试连接", Request: TestConnectRequest{}, Response: TestConnectResponse{}},
+		v1(openapi.Operation{Method: http.MethodPost, Path: "/api/web_api_key/generate", Tag: "客户端接入", Summary: "生成接入新客户端的一次性WebAPIKey", Auth: openapi.AuthScope, Scope: models.ScopeConnect, Request: WebAPIKeyRequest{}, Response: WebAPIKeyInfo{}, Reveals: []string{"key"}}),
+		v1(openapi.Operation{Method: http.MethodGet, Path: "/api/web_api_keys", Tag: "客户端接入", Summary: "使用WebAPIKey创建客户端并获取连接配置", Response: ClientConfig{}, Query: []openapi.Param{
+			{Name: "key", Description: "WebAPIKey", Required: true},
+			{Name: "publickey", Description: "客户端PEM格式的RSA公钥", Required: true},
+		}}),
+		{Method: http.MethodGet, Path: "/ws/client", Tag: "客户端接入", Summary: "客户端信令WebSocket", Status: http.StatusSwitchingProtocols},
+		{Method: http.MethodGet, Path: "/ws/info", Tag: "客户端接入", Summary: "在线客户端信息WebSocket", Status: http.StatusSwitchingProtocols},
+
+		// 服务器
+		{Method: http.MethodGet, Path: "/api/openapi.json", Tag: "服务器", Summary: "本文档", Response: map[string]interface{}{}},
+		{Method: http.MethodGet, Path: "/api/docs", Tag: "服务器", Summary: "接口文档页面，设置API_DOCS后启用", Response: "", ContentType: "text/html"},
+		{Method: http.MethodGet, Path: "/metrics", Tag: "服务器", Summary: "Prometheus指标，设置METRICS_TOKEN后启用，需携带Authorization: Bearer <METRICS_TOKEN>", Response: "", ContentType: "text/plain"},
+	}
+}
+
+// OpenAPI 生成接口文档
+func OpenAPI() *openapi.Document {
+	return openapi.Build(openapi.Info{
+		Title:       "P2P Server API",
+		Description: "v1接口失败时返回纯文本错误信息，/api/v2接口返回JSON错误信息",
+		Version:     "2.0.0",
+	}, Operations())
+}
+
+// HandleOpenAPI 返回OpenAPI格式的接口文档
+func HandleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 文档只在第一次请求时生成
+	openAPIOnce.Do(func() {
+		openAPIJSON, openAPIErr = json.Marshal(OpenAPI())
+	})
+	if openAPIErr != nil {
+		log.Error("生成接口文档失败", "error", openAPIErr)
+		http.Error(w, "Failed to generate API document", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIJSON)
+}
+
+// HandleAPIDocs 返回接口文档页面
+func HandleAPIDocs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(apiDocsPage)
+}
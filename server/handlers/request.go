@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"server/auth"
+	"server/db"
+	"server/logger"
+	"server/validate"
+	"strconv"
+)
+
+// maxBodyBytes JSON请求体的最大字节数
+const maxBodyBytes = 1 << 20
+
+// decodeBody 解析JSON请求体并按validate标签校验参数
+//
+// 不允许未知字段，请求体超过maxBodyBytes时返回413，无法解析时返回400，
+// 校验失败时返回每个字段的错误，均返回false。
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			apiError(w, r, http.StatusRequestEntityTooLarge, codeBodyTooLarge, "请求体不能超过"+strconv.Itoa(maxBodyBytes)+"字节")
+			return false
+		}
+		apiError(w, r, http.StatusBadRequest, codeInvalidBody, "无效的请求体: "+err.Error())
+		return false
+	}
+
+	if errs := validate.Struct(v); len(errs) > 0 {
+		validationFailed(w, r, errs)
+		return false
+	}
+	return true
+}
+
+// validationFailed 返回400和每个字段的错误，v2接口返回JSON，其他接口返回纯文本
+func validationFailed(w http.ResponseWriter, r *http.Request, errs validate.Errors) {
+	if !isV2(r) {
+		http.Error(w, "参数无效: "+errs.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: APIError{
+		Code:      codeValidation,
+		Message:   "参数无效",
+		RequestID: logger.RequestID(r.Context()),
+		Fields:    errs,
+	}})
+}
+
+// checkSpaceOwner 检查引用的空间是否存在且属于userID，不满足时在errs中添加field的错误
+func checkSpaceOwner(errs *validate.Errors, field, spaceID, userID string) error {
+	space, err := db.GetSpaceByID(spaceID)
+	if err != nil {
+		return err
+	}
+	if space == nil || space.OwnerID != userID {
+		errs.Add(field, "空间不存在")
+	}
+	return nil
+}
+
+// checkPassword 检查密码是否符合密码策略，不符合时返回password字段的错误并返回false
+func checkPassword(w http.ResponseWriter, r *http.Request, password, username string) bool {
+	if err := auth.Passwords.Validate(password, username); err != nil {
+		validationFailed(w, r, validate.Errors{{Field: "password", Message: err.Error()}})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// decodeTarget decodeBody测试使用的请求体
+type decodeTarget struct {
+	Name string `json:"name" validate:"required,max=8"`
+}
+
+func TestDecodeBody(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		code   string // v2接口返回的错误码
+		field  string // v2接口返回的字段错误
+	}{
+		{"有效", "/api/v2/spaces", `{"name":"home"}`, http.StatusOK, "", ""},
+		{"未知字段", "/api/v2/spaces", `{"name":"home","owner_id":"x"}`, http.StatusBadRequest, codeInvalidBody, ""},
+		{"无法解析", "/api/v2/spaces", `{"name":`, http.StatusBadRequest, codeInvalidBody, ""},
+		{"类型错误", "/api/v2/spaces", `{"name":1}`, http.StatusBadRequest, codeInvalidBody, ""},
+		{"超过大小上限", "/api/v2/spaces", `{"name":"` + strings.Repeat("a", maxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge, codeBodyTooLarge, ""},
+		{"校验失败", "/api/v2/spaces", `{"name":"too long name"}`, http.StatusBadRequest, codeValidation, "name"},
+		{"v1未知字段", "/api/spaces", `{"name":"home","x":1}`, http.StatusBadRequest, "", ""},
+		{"v1超过大小上限", "/api/spaces", `{"name":"` + strings.Repeat("a", maxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			var v decodeTarget
+			ok := decodeBody(w, r, &v)
+
+			if tt.status == http.StatusOK {
+				if !ok || v.Name != "home" {
+					t.Fatalf("decodeBody = %v, %+v: %s", ok, v, w.Body)
+				}
+				return
+			}
+			if ok {
+				t.Fatal("decodeBody应返回false")
+			}
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.code == "" {
+				// v1接口返回纯文本
+				if strings.HasPrefix(w.Body.String(), "{") {
+					t.Errorf("v1接口不应返回JSON: %s", w.Body)
+				}
+				return
+			}
+
+			var resp ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("响应不是JSON错误: %v: %s", err, w.Body)
+			}
+			if resp.Error.Code != tt.code {
+				t.Errorf("code = %q, want %q", resp.Error.Code, tt.code)
+			}
+			if tt.field != "" && (len(resp.Error.Fields) != 1 || resp.Error.Fields[0].Field != tt.field) {
+				t.Errorf("fields = %+v, want %s", resp.Error.Fields, tt.field)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"server/auth"
+	"server/db"
+	"server/logger"
+	"server/models"
+	"time"
+)
+
+const (
+	// sessionTouchInterval 会话使用时间的最小更新间隔，避免每个请求都写数据库
+	sessionTouchInterval = time.Minute
+
+	// maxUserAgentLength 保存的User-Agent最大长度
+	maxUserAgentLength = 256
+)
+
+// SessionInfo 会话列表中的一项
+type SessionInfo struct {
+	*db.Session
+	Current bool `json:"current"` // 是否为发起请求的会话
+}
+
+// SessionRefreshResponse 刷新会话的响应结构
+type SessionRefreshResponse struct {
+	Status    string    `json:"status"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// LogoutAllResponse 注销所有会话的响应结构
+type LogoutAllResponse struct {
+	Status string `json:"status"`
+	Count  int64  `json:"count"` // 注销的会话数
+}
+
+// newSession 为用户或管理员创建会话，记录主体类型、来源IP和User-Agent
+func newSession(r *http.Request, kind models.PrincipalKind, userID string) (*db.Session, error) {
+	now := time.Now()
+	return db.CreateSession(string(kind), userID, auth.ClientIP(r), sessionUserAgent(r), auth.Sessions.ExpiresAt(now, now))
+}
+
+// sessionUserAgent 返回保存到会话的User-Agent，超长时截断
+func sessionUserAgent(r *http.Request) string {
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	return userAgent
+}
+
+// touchSession 记录会话的使用并顺延过期时间，代为登录的会话不顺延
+func touchSession(r *http.Request, session *db.Session) {
+	now := time.Now()
+	if now.Sub(session.LastUsedAt) < sessionTouchInterval {
+		return
+	}
+	expiresAt := auth.Sessions.ExpiresAt(session.CreatedAt, now)
+	if session.ImpersonatorID != "" {
+		expiresAt = session.ExpiresAt
+	}
+	if err := db.TouchSession(session.ID, auth.ClientIP(r), expiresAt); err != nil {
+		logger.FromContext(r.Context()).Error("更新会话使用时间失败", "error", err)
+		return
+	}
+	session.LastUsedAt = now
+	session.ExpiresAt = expiresAt
+}
+
+// setSessionCookie 设置会话Cookie
+func setSessionCookie(w http.ResponseWriter, session *db.Session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_token",
+		Value:    session.Token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// clearSessionCookie 删除会话Cookie
+func clearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_token",
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Now(),
+		HttpOnly: true,
+	})
+}
+
+// HandleSessionList 列出当前用户或管理员的所有会话
+func HandleSessionList(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	current := currentSession(r)
+	sessions, err := db.GetSessionsByUserID(current.PrincipalKind, current.UserID)
+	if err != nil {
+		log.Error("获取会话列表失败", "error", err)
+		http.Error(w, "Failed to get session list", http.StatusInternalServerError)
+		return
+	}
+
+	infos := make([]SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		infos = append(infos, SessionInfo{
+			Session: session,
+			Current: session.ID == current.ID,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   infos,
+	})
+}
+
+// HandleSessionDelete 注销当前用户或管理员的指定会话
+func HandleSessionDelete(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessionID := r.PathValue("id")
+	if sessionID == "" {
+		http.Error(w, "Missing session ID", http.StatusBadRequest)
+		return
+	}
+
+	// 只能注销属于自己的会话
+	current := currentSession(r)
+	if err := db.DeleteSessionByID(sessionID, current.PrincipalKind, current.UserID); err != nil {
+		log.Error("注销会话失败", "session_id", sessionID, "error", err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if sessionID == current.ID {
+		clearSessionCookie(w)
+	}
+
+	log.Info("会话注销成功", "session_id", sessionID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "success",
+	})
+}
+
+// HandleSessionRefresh 为当前会话更换令牌并顺延过期时间
+func HandleSessionRefresh(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session := currentSession(r)
+	if err := db.RefreshSession(session, auth.Sessions.ExpiresAt(session.CreatedAt, time.Now())); err != nil {
+		log.Error("刷新会话失败", "error", err)
+		http.Error(w, "Failed to refresh session", http.StatusInternalServerError)
+		return
+	}
+	setSessionCookie(w, session)
+
+	log.Info("会话刷新成功", "session_id", session.ID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SessionRefreshResponse{
+		Status:    "success",
+		Token:     session.Token,
+		ExpiresAt: session.ExpiresAt,
+	})
+}
+
+// HandleLogoutAll 注销当前用户或管理员在所有设备上的会话
+func HandleLogoutAll(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session := currentSession(r)
+	count, err := db.DeleteSessionsByUserID(session.PrincipalKind, session.UserID)
+	if err != nil {
+		log.Error("注销所有会话失败", "error", err)
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+	clearSessionCookie(w)
+
+	log.Info("已注销所有会话", "count", count)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(LogoutAllResponse{
+		Status: "success",
+		Count:  count,
+	})
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"server/metrics"
 	"server/models"
 
 	"github.com/charmbracelet/log"
@@ -8,9 +9,12 @@ import (
 
 // handleOffer 处理WebRTC offer信令
 func handleOffer(client *models.Client, msg *models.Message) {
+	log := log.With("conn_id", client.ConnID)
+
 	// 验证消息格式
 	if msg.TargetID == "" || msg.SDP == "" {
 		log.Error("无效的offer消息格式", "client_id", client.ID)
+		metrics.ForwardFailures.WithLabelValues("offer", "invalid").Inc()
 		return
 	}
 
@@ -24,6 +28,7 @@ func handleOffer(client *models.Client, msg *models.Message) {
 
 	if !exists {
 		log.Error("目标客户端不存在", "target_id", msg.TargetID)
+		metrics.ForwardFailures.WithLabelValues("offer", "target_not_found").Inc()
 		return
 	}
 
@@ -39,14 +44,20 @@ func handleOffer(client *models.Client, msg *models.Message) {
 	// 转发offer到目标客户端
 	if err := targetClient.Conn.WriteJSON(forwardMsg); err != nil {
 		log.Error("转发offer失败", "error", err, "target_id", msg.TargetID)
+		metrics.ForwardFailures.WithLabelValues("offer", "write_error").Inc()
+		return
 	}
+	metrics.SignalingForwarded.WithLabelValues("offer").Inc()
 }
 
 // handleAnswer 处理WebRTC answer信令
 func handleAnswer(client *models.Client, msg *models.Message) {
+	log := log.With("conn_id", client.ConnID)
+
 	// 验证消息格式
 	if msg.TargetID == "" || msg.SDP == "" {
 		log.Error("无效的answer消息格式", "client_id", client.ID)
+		metrics.ForwardFailures.WithLabelValues("answer", "invalid").Inc()
 		return
 	}
 
@@ -60,6 +71,7 @@ func handleAnswer(client *models.Client, msg *models.Message) {
 
 	if !exists {
 		log.Error("目标客户端不存在", "target_id", msg.TargetID)
+		metrics.ForwardFailures.WithLabelValues("answer", "target_not_found").Inc()
 		return
 	}
 
@@ -75,14 +87,20 @@ func handleAnswer(client *models.Client, msg *models.Message) {
 	// 转发answer到目标客户端
 	if err := targetClient.Conn.WriteJSON(forwardMsg); err != nil {
 		log.Error("转发answer失败", "error", err, "target_id", msg.TargetID)
+		metrics.ForwardFailures.WithLabelValues("answer", "write_error").Inc()
+		return
 	}
+	metrics.SignalingForwarded.WithLabelValues("answer").Inc()
 }
 
 // HandleICECandidates 处理WebRTC ICE候选信息
 func HandleICECandidates(client *models.Client, msg *models.Message) {
+	log := log.With("conn_id", client.ConnID)
+
 	// 验证消息格式
 	if msg.TargetID == "" || len(msg.ICECandidates) == 0 {
 		log.Error("无效的ICE候选消息格式", "client_id", client.ID)
+		metrics.ForwardFailures.WithLabelValues("ice_candidates", "invalid").Inc()
 		return
 	}
 
@@ -96,6 +114,7 @@ func HandleICECandidates(client *models.Client, msg *models.Message) {
 
 	if !exists {
 		log.Error("目标客户端不存在", "target_id", msg.TargetID)
+		metrics.ForwardFailures.WithLabelValues("ice_candidates", "target_not_found").Inc()
 		return
 	}
 
@@ -111,5 +130,8 @@ func HandleICECandidates(client *models.Client, msg *models.Message) {
 	// 转发ICE候选到目标客户端
 	if err := targetClient.Conn.WriteJSON(forwardMsg); err != nil {
 		log.Error("转发ICE候选失败", "error", err, "target_id", msg.TargetID)
+		metrics.ForwardFailures.WithLabelValues("ice_candidates", "write_error").Inc()
+		return
 	}
+	metrics.SignalingForwarded.WithLabelValues("ice_candidates").Inc()
 }
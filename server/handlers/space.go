@@ -4,35 +4,53 @@ import (
 	"encoding/json"
 	"net/http"
 	"server/db"
+	"server/logger"
 	"server/models"
 
-	"github.com/charmbracelet/log"
 	"github.com/google/uuid"
 )
 
+// SpaceRequest 创建空间的请求结构
+type SpaceRequest struct {
+	Name        string `json:"name" validate:"required,max=64"`
+	Description string `json:"description" validate:"max=256"`
+}
+
+// SpaceUpdateRequest 修改空间的请求结构
+type SpaceUpdateRequest struct {
+	ID string `json:"id" validate:"required"`
+	SpaceRequest
+}
+
 // HandleSpaceCreate 处理空间创建
 func HandleSpaceCreate(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 获取当前用户
-	user := r.Context().Value(UserKey).(*models.User)
+	user := currentUser(r)
 	if user == nil {
 		http.Error(w, "未授权的访问", http.StatusUnauthorized)
 		return
 	}
 
-	var space models.Space
-	if err := json.NewDecoder(r.Body).Decode(&space); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var req SpaceRequest
+	if !decodeBody(w, r, &req) {
 		return
 	}
 
 	// 设置空间ID和所有者ID
-	space.ID = uuid.New().String()
-	space.OwnerID = user.ID
+	space := models.Space{
+		ID:          uuid.New().String(),
+		OwnerID:     user.ID,
+		Name:        req.Name,
+		Description: req.Description,
+	}
+	setAuditTarget(r, space.ID)
 
 	// 保存空间信息
 	if err := db.SaveSpace(&space); err != nil {
@@ -51,13 +69,15 @@ func HandleSpaceCreate(w http.ResponseWriter, r *http.Request) {
 
 // HandleSpaceList 处理空间列表查询
 func HandleSpaceList(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 获取当前用户
-	user := r.Context().Value(UserKey).(*models.User)
+	user := currentUser(r)
 	if user == nil {
 		http.Error(w, "未授权的访问", http.StatusUnauthorized)
 		return
@@ -80,26 +100,33 @@ func HandleSpaceList(w http.ResponseWriter, r *http.Request) {
 
 // HandleSpaceUpdate 处理空间信息更新
 func HandleSpaceUpdate(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodPut {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 获取当前用户
-	user := r.Context().Value(UserKey).(*models.User)
+	user := currentUser(r)
 	if user == nil {
 		http.Error(w, "未授权的访问", http.StatusUnauthorized)
 		return
 	}
 
-	var updateSpace models.Space
-	if err := json.NewDecoder(r.Body).Decode(&updateSpace); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var req SpaceUpdateRequest
+	if !decodeBody(w, r, &req) {
 		return
 	}
+	setAuditTarget(r, req.ID)
 
 	// 确保只能更新自己的空间
-	updateSpace.OwnerID = user.ID
+	updateSpace := models.Space{
+		ID:          req.ID,
+		OwnerID:     user.ID,
+		Name:        req.Name,
+		Description: req.Description,
+	}
 
 	// 更新空间信息
 	if err := db.UpdateSpace(&updateSpace); err != nil {
@@ -117,13 +144,15 @@ func HandleSpaceUpdate(w http.ResponseWriter, r *http.Request) {
 
 // HandleSpaceDelete 处理空间删除
 func HandleSpaceDelete(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 获取当前用户
-	user := r.Context().Value(UserKey).(*models.User)
+	user := currentUser(r)
 	if user == nil {
 		http.Error(w, "未授权的访问", http.StatusUnauthorized)
 		return
@@ -137,11 +166,13 @@ func HandleSpaceDelete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 删除空间
-	if err := db.DeleteSpace(spaceID, user.ID); err != nil {
+	clientIDs, err := db.DeleteSpace(spaceID, user.ID)
+	if err != nil {
 		log.Error("删除空间失败", "error", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	DisconnectClients(clientIDs, "空间已删除")
 
 	log.Info("空间删除成功", "space_id", spaceID)
 	w.Header().Set("Content-Type", "application/json")
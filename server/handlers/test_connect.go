@@ -4,20 +4,27 @@ import (
 	"encoding/json"
 	"net/http"
 	"server/db"
+	"server/logger"
 	"server/models"
-
-	"github.com/charmbracelet/log"
 )
 
 // TestConnectRequest 测试连接请求结构
 type TestConnectRequest struct {
-	SourceID string `json:"source_id"`
-	TargetID string `json:"target_id"`
-	SpaceID  string `json:"space_id"`
+	SourceID string `json:"source_id" validate:"required"`
+	TargetID string `json:"target_id" validate:"required"`
+	SpaceID  string `json:"space_id" validate:"required"`
+}
+
+// TestConnectResponse 测试连接响应结构
+type TestConnectResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
 }
 
 // HandleTestConnect 处理测试连接API请求
 func HandleTestConnect(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -25,16 +32,7 @@ func HandleTestConnect(w http.ResponseWriter, r *http.Request) {
 
 	// 解析请求体
 	var req TestConnectRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Error("解析请求体失败", "error", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	// 验证请求参数
-	if req.SourceID == "" || req.TargetID == "" || req.SpaceID == "" {
-		log.Error("请求参数不完整")
-		http.Error(w, "Missing required parameters", http.StatusBadRequest)
+	if !decodeBody(w, r, &req) {
 		return
 	}
 
@@ -109,8 +107,8 @@ func HandleTestConnect(w http.ResponseWriter, r *http.Request) {
 
 	// 返回成功响应
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status": "success",
-		"message": "Connection request sent to both clients",
+	json.NewEncoder(w).Encode(TestConnectResponse{
+		Status:  "success",
+		Message: "Connection request sent to both clients",
 	})
 }
\ No newline at end of file
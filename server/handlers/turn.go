@@ -4,35 +4,99 @@ import (
 	"encoding/json"
 	"net/http"
 	"server/db"
+	"server/logger"
 	"server/models"
+	"server/validate"
+	"time"
 
-	"github.com/charmbracelet/log"
 	"github.com/google/uuid"
 )
 
+// TurnRequest 创建TURN服务器配置的请求结构
+type TurnRequest struct {
+	SpaceID  string `json:"space_id" validate:"required"`
+	URL      string `json:"url" validate:"required,turnurl,max=256"`
+	Username string `json:"username" validate:"max=128"`
+	Password string `json:"password" validate:"max=128"`
+}
+
+// TurnUpdateRequest 修改TURN服务器配置的请求结构，不能修改所在空间
+type TurnUpdateRequest struct {
+	ID       string  `json:"id" validate:"required"`
+	URL      string  `json:"url" validate:"required,turnurl,max=256"`
+	Username string  `json:"username" validate:"max=128"`
+	Password *string `json:"password" validate:"max=128"` // 省略时保留原密码，空字符串表示清除密码
+}
+
+// TurnServerInfo 返回给用户的TURN服务器配置，密码只写不读
+type TurnServerInfo struct {
+	ID          string    `json:"id"`
+	OwnerID     string    `json:"owner_id"`
+	SpaceID     string    `json:"space_id"`
+	URL         string    `json:"url"`
+	Username    string    `json:"username"`
+	HasPassword bool      `json:"has_password"` // 是否设置了密码
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// newTurnServerInfo 将TURN服务器配置转换为返回给用户的结构
+func newTurnServerInfo(turn *models.TurnServer) *TurnServerInfo {
+	return &TurnServerInfo{
+		ID:          turn.ID,
+		OwnerID:     turn.OwnerID,
+		SpaceID:     turn.SpaceID,
+		URL:         turn.URL,
+		Username:    turn.Username,
+		HasPassword: turn.Password != "",
+		CreatedAt:   turn.CreatedAt,
+		UpdatedAt:   turn.UpdatedAt,
+	}
+}
+
 // HandleTurnCreate 处理TURN服务器配置创建
 func HandleTurnCreate(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 获取当前用户
-	user := r.Context().Value(UserKey).(*models.User)
+	user := currentUser(r)
 	if user == nil {
 		http.Error(w, "未授权的访问", http.StatusUnauthorized)
 		return
 	}
 
-	var turn models.TurnServer
-	if err := json.NewDecoder(r.Body).Decode(&turn); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var req TurnRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	// 只能在自己的空间中创建
+	var errs validate.Errors
+	if err := checkSpaceOwner(&errs, "space_id", req.SpaceID, user.ID); err != nil {
+		log.Error("获取空间信息失败", "error", err)
+		http.Error(w, "获取空间信息失败", http.StatusInternalServerError)
+		return
+	}
+	if len(errs) > 0 {
+		validationFailed(w, r, errs)
 		return
 	}
 
 	// 设置TURN服务器配置ID和所有者ID
-	turn.ID = uuid.New().String()
-	turn.OwnerID = user.ID
+	turn := models.TurnServer{
+		ID:       uuid.New().String(),
+		OwnerID:  user.ID,
+		SpaceID:  req.SpaceID,
+		URL:      req.URL,
+		Username: req.Username,
+		Password: req.Password,
+	}
+	setAuditTarget(r, turn.ID)
 
 	// 保存TURN服务器配置
 	if err := db.SaveTurn(&turn); err != nil {
@@ -45,19 +109,21 @@ func HandleTurnCreate(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status": "success",
-		"data":   turn,
+		"data":   newTurnServerInfo(&turn),
 	})
 }
 
 // HandleTurnList 处理TURN服务器配置列表查询
 func HandleTurnList(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 获取当前用户
-	user := r.Context().Value(UserKey).(*models.User)
+	user := currentUser(r)
 	if user == nil {
 		http.Error(w, "未授权的访问", http.StatusUnauthorized)
 		return
@@ -71,38 +137,59 @@ func HandleTurnList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	infos := make([]*TurnServerInfo, 0, len(turns))
+	for i := range turns {
+		infos = append(infos, newTurnServerInfo(&turns[i]))
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status": "success",
-		"data":   turns,
+		"data":   infos,
 	})
 }
 
 // HandleTurnUpdate 处理TURN服务器配置更新
 func HandleTurnUpdate(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodPut {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 获取当前用户
-	user := r.Context().Value(UserKey).(*models.User)
+	user := currentUser(r)
 	if user == nil {
 		http.Error(w, "未授权的访问", http.StatusUnauthorized)
 		return
 	}
 
-	var updateTurn models.TurnServer
-	if err := json.NewDecoder(r.Body).Decode(&updateTurn); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var req TurnUpdateRequest
+	if !decodeBody(w, r, &req) {
 		return
 	}
+	setAuditTarget(r, req.ID)
 
-	// 确保只能更新自己的TURN服务器配置
-	updateTurn.OwnerID = user.ID
+	// 确保只能更新自己的TURN服务器配置，密码不会返回给客户端，省略时保留原密码
+	updateTurn, err := db.GetTurnByID(req.ID)
+	if err != nil {
+		log.Error("获取TURN服务器配置失败", "error", err)
+		http.Error(w, "获取TURN服务器配置失败", http.StatusInternalServerError)
+		return
+	}
+	if updateTurn == nil || updateTurn.OwnerID != user.ID {
+		http.Error(w, "TURN服务器不存在", http.StatusNotFound)
+		return
+	}
+	updateTurn.URL = req.URL
+	updateTurn.Username = req.Username
+	if req.Password != nil {
+		updateTurn.Password = *req.Password
+	}
 
 	// 更新TURN服务器配置
-	if err := db.UpdateTurn(&updateTurn); err != nil {
+	if err := db.UpdateTurn(updateTurn); err != nil {
 		log.Error("更新TURN服务器配置失败", "error", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -117,13 +204,15 @@ func HandleTurnUpdate(w http.ResponseWriter, r *http.Request) {
 
 // HandleTurnDelete 处理TURN服务器配置删除
 func HandleTurnDelete(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 获取当前用户
-	user := r.Context().Value(UserKey).(*models.User)
+	user := currentUser(r)
 	if user == nil {
 		http.Error(w, "未授权的访问", http.StatusUnauthorized)
 		return
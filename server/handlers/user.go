@@ -4,26 +4,78 @@ import (
 	"encoding/json"
 	"net/http"
 	"server/db"
+	"server/logger"
 	"server/models"
-	"github.com/charmbracelet/log"
+	"time"
 	"github.com/google/uuid"
 )
 
+// RegisterResponse 用户注册响应结构
+type RegisterResponse struct {
+	Status string    `json:"status"`
+	User   *UserInfo `json:"user"`
+	Token  string    `json:"token"`
+}
+
+// UserInfo 返回给客户端的用户信息，不包含密码哈希
+type UserInfo struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	Disabled  bool      `json:"disabled"` // 被管理员禁用的用户不能登录，客户端不能连接
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// newUserInfo 将用户转换为返回给客户端的结构
+func newUserInfo(user *models.User) *UserInfo {
+	return &UserInfo{
+		ID:        user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		Disabled:  user.Disabled,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+}
+
+// UserRequest 用户注册和管理员创建用户的请求结构
+type UserRequest struct {
+	Username string `json:"username" validate:"required,min=3,max=32"`
+	Password string `json:"password" validate:"required"`
+	Email    string `json:"email" validate:"required,email,max=254"`
+}
+
+// UserUpdateRequest 修改用户信息的请求结构
+type UserUpdateRequest struct {
+	ID       string `json:"id" validate:"required"`
+	Username string `json:"username" validate:"required,min=3,max=32"`
+	Password string `json:"password"` // 为空表示不修改密码
+	Email    string `json:"email" validate:"required,email,max=254"`
+}
+
 // HandleUserCreate 处理用户创建
 func HandleUserCreate(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var user models.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var req UserRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	// 检查密码是否符合密码策略
+	if !checkPassword(w, r, req.Password, req.Username) {
 		return
 	}
 
 	// 设置用户ID
-	user.ID = uuid.New().String()
+	user := models.User{ID: uuid.New().String(), Username: req.Username, Password: req.Password, Email: req.Email}
+	setAuditTarget(r, user.ID)
 
 	// 保存用户信息
 	if err := db.SaveUser(&user); err != nil {
@@ -41,17 +93,19 @@ func HandleUserCreate(w http.ResponseWriter, r *http.Request) {
 
 // HandleUserList 处理用户列表查询
 func HandleUserList(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 获取当前用户或管理员身份
-	admin, ok := r.Context().Value(AdminKey).(*models.Admin)
-	if !ok || admin == nil {
+	admin := currentAdmin(r)
+	if admin == nil {
 		// 非管理员只能查看自己的信息
-		user, ok := r.Context().Value(UserKey).(*models.User)
-		if !ok || user == nil {
+		user := currentUser(r)
+		if user == nil {
 			http.Error(w, "未授权的访问", http.StatusUnauthorized)
 			return
 		}
@@ -59,7 +113,7 @@ func HandleUserList(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"status": "success",
-			"data":   []models.User{*user},
+			"data":   []*UserInfo{newUserInfo(user)},
 		})
 		return
 	}
@@ -72,31 +126,44 @@ func HandleUserList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	infos := make([]*UserInfo, 0, len(users))
+	for _, user := range users {
+		infos = append(infos, newUserInfo(user))
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status": "success",
-		"data":   users,
+		"data":   infos,
 	})
 }
 
 // HandleUserUpdate 处理用户信息更新
 func HandleUserUpdate(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodPut {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var updateUser models.User
-	if err := json.NewDecoder(r.Body).Decode(&updateUser); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var req UserUpdateRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	setAuditTarget(r, req.ID)
+	updateUser := models.User{ID: req.ID, Username: req.Username, Password: req.Password, Email: req.Email}
+
+	// 修改密码时检查新密码是否符合密码策略
+	if updateUser.Password != "" && !checkPassword(w, r, updateUser.Password, updateUser.Username) {
 		return
 	}
 
 	// 获取当前用户或管理员身份
-	admin := r.Context().Value(AdminKey).(*models.Admin)
+	admin := currentAdmin(r)
 	if admin == nil {
 		// 非管理员只能更新自己的信息
-		user := r.Context().Value(UserKey).(*models.User)
+		user := currentUser(r)
 		if user == nil || user.ID != updateUser.ID {
 			http.Error(w, "未授权的操作", http.StatusForbidden)
 			return
@@ -119,26 +186,30 @@ func HandleUserUpdate(w http.ResponseWriter, r *http.Request) {
 
 // HandleUserDelete 处理用户删除
 func HandleUserDelete(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 获取当前用户或管理员身份
-	admin := r.Context().Value(AdminKey).(*models.Admin)
+	admin := currentAdmin(r)
 	if admin == nil {
 		// 非管理员只能删除自己的账号
-		user := r.Context().Value(UserKey).(*models.User)
+		user := currentUser(r)
 		if user == nil {
 			http.Error(w, "未授权的操作", http.StatusForbidden)
 			return
 		}
 		// 删除自己的账号
-		if err := db.DeleteUser(user.ID); err != nil {
+		clientIDs, err := db.DeleteUser(user.ID)
+		if err != nil {
 			log.Error("删除用户失败", "error", err)
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		DisconnectClients(clientIDs, "用户已删除")
 		log.Info("用户删除成功", "user_id", user.ID)
 	} else {
 		// 管理员可以删除指定用户
@@ -147,11 +218,13 @@ func HandleUserDelete(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Missing user ID", http.StatusBadRequest)
 			return
 		}
-		if err := db.DeleteUser(userID); err != nil {
+		clientIDs, err := db.DeleteUser(userID)
+		if err != nil {
 			log.Error("删除用户失败", "error", err)
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		DisconnectClients(clientIDs, "用户已删除")
 		log.Info("管理员删除用户成功", "user_id", userID)
 	}
 
@@ -163,20 +236,26 @@ func HandleUserDelete(w http.ResponseWriter, r *http.Request) {
 
 // HandleRegister 处理用户注册请求
 func HandleUserRegister(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var user models.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		log.Error("用户注册失败：无效的请求体", "error", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var req UserRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	// 检查密码是否符合密码策略
+	if !checkPassword(w, r, req.Password, req.Username) {
 		return
 	}
 
 	// 生成用户ID
-	user.ID = uuid.New().String()
+	user := models.User{ID: uuid.New().String(), Username: req.Username, Password: req.Password, Email: req.Email}
+	setAuditTarget(r, user.ID)
 	log.Info("开始创建新用户", "user_id", user.ID, "username", user.Username)
 
 	// 保存用户信息到数据库
@@ -195,7 +274,7 @@ func HandleUserRegister(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 创建新的会话
-	session, err := db.CreateSession(user.ID)
+	session, err := newSession(r, models.PrincipalUser, user.ID)
 	if err != nil {
 		log.Error("创建会话失败", "error", err)
 		http.Error(w, "Failed to create session", http.StatusInternalServerError)
@@ -203,22 +282,14 @@ func HandleUserRegister(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 设置Cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    session.Token,
-		Path:     "/",
-		Expires:  session.ExpiresAt,
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteStrictMode,
-	})
+	setSessionCookie(w, session)
 
 	// 返回用户信息和会话令牌
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status": "success",
-		"user": user,
-		"token": session.Token,
+	json.NewEncoder(w).Encode(RegisterResponse{
+		Status: "success",
+		User:   newUserInfo(&user),
+		Token:  session.Token,
 	})
 	log.Info("用户注册成功", "username", user.Username, "email", user.Email)
 }
\ No newline at end of file
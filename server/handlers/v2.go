@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"server/logger"
+	"server/models"
+	"server/validate"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// v2接口
+//
+// /api/v2下的接口按资源组织路由，通过Methods按请求方法分发，成功时返回{"data": ...}，
+// 列表额外返回next_cursor，失败时返回{"error": {"code", "message", "request_id"}}。
+// 认证中间件在v2路径下同样返回该格式的错误。
+
+// v2Prefix v2接口的路径前缀
+const v2Prefix = "/api/v2/"
+
+const (
+	// defaultPageLimit 列表默认每页条数
+	defaultPageLimit = 20
+
+	// maxPageLimit 列表每页最多条数
+	maxPageLimit = 100
+)
+
+// v2接口错误码，认证中间件另有更具体的错误码
+const (
+	codeInvalidRequest   = "invalid_request"
+	codeInvalidBody      = "invalid_body"
+	codeBodyTooLarge     = "body_too_large"
+	codeValidation       = "validation_failed"
+	codeInvalidCursor    = "invalid_cursor"
+	codeUnauthenticated  = "unauthenticated"
+	codeForbidden        = "forbidden"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeInternal         = "internal_error"
+)
+
+// APIError v2接口的错误信息
+type APIError struct {
+	Code      string `json:"code"`                 // 机器可读的错误码
+	Message   string `json:"message"`              // 错误说明
+	RequestID string `json:"request_id,omitempty"` // 请求ID，与日志中的request_id对应
+
+	// Fields 参数校验失败时每个字段的错误
+	Fields validate.Errors `json:"fields,omitempty"`
+}
+
+// ErrorResponse v2接口的错误响应
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// isV2 判断请求是否访问v2接口
+func isV2(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, v2Prefix)
+}
+
+// apiError 返回错误响应，v2接口返回JSON错误信息，其他接口保持纯文本
+func apiError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	if !isV2(r) {
+		http.Error(w, message, status)
+		return
+	}
+	writeJSON(w, status, ErrorResponse{
+		Error: APIError{Code: code, Message: message, RequestID: logger.RequestID(r.Context())},
+	})
+}
+
+// writeJSON 以JSON格式写入响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeData 返回单个资源
+func writeData(w http.ResponseWriter, status int, data interface{}) {
+	writeJSON(w, status, map[string]interface{}{"data": data})
+}
+
+// writeList 返回一页列表，next为下一页的游标，没有更多数据时为0
+func writeList(w http.ResponseWriter, data interface{}, next int64) {
+	resp := map[string]interface{}{"data": data}
+	if next > 0 {
+		resp["next_cursor"] = encodeCursor(next)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Methods 按请求方法分发的处理函数，不支持的方法返回405和Allow响应头
+type Methods map[string]http.HandlerFunc
+
+// ServeHTTP 调用请求方法对应的处理函数
+func (m Methods) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if handler, ok := m[r.Method]; ok {
+		handler(w, r)
+		return
+	}
+
+	allowed := make([]string, 0, len(m))
+	for method := range m {
+		allowed = append(allowed, method)
+	}
+	sort.Strings(allowed)
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	apiError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "不支持的请求方法: "+r.Method)
+}
+
+// HandleV2NotFound 处理不存在的v2接口
+func HandleV2NotFound(w http.ResponseWriter, r *http.Request) {
+	apiError(w, r, http.StatusNotFound, codeNotFound, "接口不存在")
+}
+
+// encodeCursor 将记录的rowid编码为不透明的分页游标
+func encodeCursor(rowid int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(rowid, 10)))
+}
+
+// decodeCursor 解析分页游标
+func decodeCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	rowid, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || rowid <= 0 {
+		return 0, errors.New("无效的游标")
+	}
+	return rowid, nil
+}
+
+// parsePage 解析cursor和limit查询参数，参数无效时返回400并返回false
+func parsePage(w http.ResponseWriter, r *http.Request) (models.Page, bool) {
+	page := models.Page{Limit: defaultPageLimit}
+	query := r.URL.Query()
+
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageLimit {
+			apiError(w, r, http.StatusBadRequest, codeInvalidRequest, "limit必须是1到"+strconv.Itoa(maxPageLimit)+"之间的整数")
+			return page, false
+		}
+		page.Limit = n
+	}
+	if v := query.Get("cursor"); v != "" {
+		after, err := decodeCursor(v)
+		if err != nil {
+			apiError(w, r, http.StatusBadRequest, codeInvalidCursor, "无效的cursor参数")
+			return page, false
+		}
+		page.After = after
+	}
+	return page, true
+}
+
+// internalError 记录错误日志并返回500
+func internalError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	logger.FromContext(r.Context()).Error(message, "error", err)
+	apiError(w, r, http.StatusInternalServerError, codeInternal, message)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/http"
+	"server/db"
+	"server/logger"
+	"server/models"
+	"server/validate"
+)
+
+// clientInput 修改客户端的请求体，省略的字段保持不变
+type clientInput struct {
+	Name        *string `json:"name" validate:"required,max=64"`
+	Description *string `json:"description" validate:"max=256"`
+	SpaceID     *string `json:"space_id" validate:"required"`
+}
+
+// HandleV2ClientList 分页列出当前用户的客户端，支持按space_id和名称过滤
+func HandleV2ClientList(w http.ResponseWriter, r *http.Request) {
+	listClients(w, r, r.URL.Query().Get("space_id"))
+}
+
+// listClients 分页列出当前用户的客户端，spaceID非空时只列出该空间内的客户端
+func listClients(w http.ResponseWriter, r *http.Request, spaceID string) {
+	page, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	clients, next, err := db.ListClients(models.ClientFilter{
+		OwnerID: currentUser(r).ID,
+		SpaceID: spaceID,
+		Name:    r.URL.Query().Get("name"),
+	}, page)
+	if err != nil {
+		internalError(w, r, "获取客户端列表失败", err)
+		return
+	}
+	writeList(w, clients, next)
+}
+
+// HandleV2ClientGet 获取客户端
+func HandleV2ClientGet(w http.ResponseWriter, r *http.Request) {
+	client := ownedClient(w, r, r.PathValue("id"))
+	if client == nil {
+		return
+	}
+	writeData(w, http.StatusOK, client)
+}
+
+// HandleV2ClientUpdate 修改客户端的名称、描述或所在空间
+func HandleV2ClientUpdate(w http.ResponseWriter, r *http.Request) {
+	var input clientInput
+	if !decodeBody(w, r, &input) {
+		return
+	}
+	client := ownedClient(w, r, r.PathValue("id"))
+	if client == nil {
+		return
+	}
+
+	if input.Name != nil {
+		client.Name = *input.Name
+	}
+	if input.Description != nil {
+		client.Description = *input.Description
+	}
+	if input.SpaceID != nil && *input.SpaceID != client.SpaceID {
+		// 只能移动到自己的空间
+		var errs validate.Errors
+		if err := checkSpaceOwner(&errs, "space_id", *input.SpaceID, client.OwnerID); err != nil {
+			internalError(w, r, "获取空间信息失败", err)
+			return
+		}
+		if len(errs) > 0 {
+			validationFailed(w, r, errs)
+			return
+		}
+		client.SpaceID = *input.SpaceID
+	}
+
+	if err := db.UpdateClient(client); err != nil {
+		internalError(w, r, "更新客户端信息失败", err)
+		return
+	}
+
+	logger.FromContext(r.Context()).Info("客户端信息更新成功", "client_id", client.ID)
+	writeData(w, http.StatusOK, client)
+}
+
+// HandleV2ClientDelete 删除客户端并断开其连接
+func HandleV2ClientDelete(w http.ResponseWriter, r *http.Request) {
+	client := ownedClient(w, r, r.PathValue("id"))
+	if client == nil {
+		return
+	}
+
+	if err := db.DeleteClient(client.ID, client.OwnerID); err != nil {
+		internalError(w, r, "删除客户端失败", err)
+		return
+	}
+	DisconnectClients([]string{client.ID}, "客户端已删除")
+
+	logger.FromContext(r.Context()).Info("客户端删除成功", "client_id", client.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownedClient 获取当前用户的客户端，客户端不存在或属于其他用户时返回404并返回nil
+func ownedClient(w http.ResponseWriter, r *http.Request, id string) *models.Client {
+	client, err := db.GetClientByID(id)
+	if err != nil {
+		internalError(w, r, "获取客户端信息失败", err)
+		return nil
+	}
+	if client == nil || client.OwnerID != currentUser(r).ID {
+		apiError(w, r, http.StatusNotFound, codeNotFound, "客户端不存在")
+		return nil
+	}
+	return client
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"net/http"
+	"server/db"
+	"server/logger"
+	"server/models"
+
+	"github.com/google/uuid"
+)
+
+// spaceInput 修改空间的请求体，省略的字段保持不变
+type spaceInput struct {
+	Name        *string `json:"name" validate:"required,max=64"`
+	Description *string `json:"description" validate:"max=256"`
+}
+
+// HandleV2SpaceList 分页列出当前用户的空间，支持按名称过滤
+func HandleV2SpaceList(w http.ResponseWriter, r *http.Request) {
+	page, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	spaces, next, err := db.ListSpaces(models.SpaceFilter{
+		OwnerID: currentUser(r).ID,
+		Name:    r.URL.Query().Get("name"),
+	}, page)
+	if err != nil {
+		internalError(w, r, "获取空间列表失败", err)
+		return
+	}
+	writeList(w, spaces, next)
+}
+
+// HandleV2SpaceCreate 创建空间
+func HandleV2SpaceCreate(w http.ResponseWriter, r *http.Request) {
+	var input SpaceRequest
+	if !decodeBody(w, r, &input) {
+		return
+	}
+
+	space := &models.Space{
+		ID:          uuid.New().String(),
+		OwnerID:     currentUser(r).ID,
+		Name:        input.Name,
+		Description: input.Description,
+	}
+	setAuditTarget(r, space.ID)
+
+	if err := db.SaveSpace(space); err != nil {
+		internalError(w, r, "创建空间失败", err)
+		return
+	}
+
+	logger.FromContext(r.Context()).Info("空间创建成功", "space_id", space.ID)
+	w.Header().Set("Location", v2Prefix+"spaces/"+space.ID)
+	writeData(w, http.StatusCreated, space)
+}
+
+// HandleV2SpaceGet 获取空间
+func HandleV2SpaceGet(w http.ResponseWriter, r *http.Request) {
+	space := ownedSpace(w, r, r.PathValue("id"))
+	if space == nil {
+		return
+	}
+	writeData(w, http.StatusOK, space)
+}
+
+// HandleV2SpaceUpdate 修改空间的名称或描述
+func HandleV2SpaceUpdate(w http.ResponseWriter, r *http.Request) {
+	var input spaceInput
+	if !decodeBody(w, r, &input) {
+		return
+	}
+	space := ownedSpace(w, r, r.PathValue("id"))
+	if space == nil {
+		return
+	}
+
+	if input.Name != nil {
+		space.Name = *input.Name
+	}
+	if input.Description != nil {
+		space.Description = *input.Description
+	}
+
+	if err := db.UpdateSpace(space); err != nil {
+		internalError(w, r, "更新空间信息失败", err)
+		return
+	}
+
+	logger.FromContext(r.Context()).Info("空间信息更新成功", "space_id", space.ID)
+	writeData(w, http.StatusOK, space)
+}
+
+// HandleV2SpaceDelete 删除空间及空间内的客户端、TURN服务器和WebAPIKey
+func HandleV2SpaceDelete(w http.ResponseWriter, r *http.Request) {
+	space := ownedSpace(w, r, r.PathValue("id"))
+	if space == nil {
+		return
+	}
+
+	clientIDs, err := db.DeleteSpace(space.ID, space.OwnerID)
+	if err != nil {
+		internalError(w, r, "删除空间失败", err)
+		return
+	}
+	DisconnectClients(clientIDs, "空间已删除")
+
+	logger.FromContext(r.Context()).Info("空间删除成功", "space_id", space.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleV2SpaceClients 分页列出空间内的客户端，支持按名称过滤
+func HandleV2SpaceClients(w http.ResponseWriter, r *http.Request) {
+	space := ownedSpace(w, r, r.PathValue("id"))
+	if space == nil {
+		return
+	}
+	listClients(w, r, space.ID)
+}
+
+// HandleV2SpaceTurns 分页列出空间的TURN服务器
+func HandleV2SpaceTurns(w http.ResponseWriter, r *http.Request) {
+	space := ownedSpace(w, r, r.PathValue("id"))
+	if space == nil {
+		return
+	}
+	listTurns(w, r, space.ID)
+}
+
+// ownedSpace 获取当前用户的空间，空间不存在或属于其他用户时返回404并返回nil
+func ownedSpace(w http.ResponseWriter, r *http.Request, id string) *models.Space {
+	space, err := db.GetSpaceByID(id)
+	if err != nil {
+		internalError(w, r, "获取空间信息失败", err)
+		return nil
+	}
+	if space == nil || space.OwnerID != currentUser(r).ID {
+		apiError(w, r, http.StatusNotFound, codeNotFound, "空间不存在")
+		return nil
+	}
+	return space
+}
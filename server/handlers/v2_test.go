@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	for _, rowid := range []int64{1, 42, 1 << 40} {
+		got, err := decodeCursor(encodeCursor(rowid))
+		if err != nil || got != rowid {
+			t.Errorf("decodeCursor(encodeCursor(%d)) = %d, %v", rowid, got, err)
+		}
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	raw := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	for _, cursor := range []string{
+		"",
+		"!!!", // 不是base64
+		base64.StdEncoding.EncodeToString([]byte("12")), // 带填充的标准编码
+		raw("0"),
+		raw("-5"),
+		raw("abc"),
+		raw("1.5"),
+		raw("99999999999999999999"), // 超出int64
+	} {
+		if rowid, err := decodeCursor(cursor); err == nil {
+			t.Errorf("decodeCursor(%q) = %d, want error", cursor, rowid)
+		}
+	}
+}
+
+func TestParsePage(t *testing.T) {
+	tests := []struct {
+		query  string
+		status int
+		code   string
+		limit  int
+		after  int64
+	}{
+		{"", http.StatusOK, "", defaultPageLimit, 0},
+		{"limit=5&cursor=" + encodeCursor(7), http.StatusOK, "", 5, 7},
+		{"limit=100", http.StatusOK, "", maxPageLimit, 0},
+		{"limit=0", http.StatusBadRequest, codeInvalidRequest, 0, 0},
+		{"limit=101", http.StatusBadRequest, codeInvalidRequest, 0, 0},
+		{"limit=x", http.StatusBadRequest, codeInvalidRequest, 0, 0},
+		{"cursor=bad!", http.StatusBadRequest, codeInvalidCursor, 0, 0},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/api/v2/spaces?"+tt.query, nil)
+		w := httptest.NewRecorder()
+		page, ok := parsePage(w, r)
+		if tt.status == http.StatusOK {
+			if !ok || page.Limit != tt.limit || page.After != tt.after {
+				t.Errorf("parsePage(%q) = %+v, %v", tt.query, page, ok)
+			}
+			continue
+		}
+		var resp ErrorResponse
+		if ok || w.Code != tt.status || json.Unmarshal(w.Body.Bytes(), &resp) != nil || resp.Error.Code != tt.code {
+			t.Errorf("parsePage(%q) = %v, %d %s, want %d %s", tt.query, ok, w.Code, w.Body, tt.status, tt.code)
+		}
+	}
+}
+
+func TestMethods(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+	handler := Methods{http.MethodPost: ok, http.MethodGet: ok, http.MethodPatch: ok}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/api/v2/spaces/1", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("PATCH status = %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v2/spaces/1", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("DELETE status = %d", w.Code)
+	}
+	if got := w.Header().Get("Allow"); got != "GET, PATCH, POST" {
+		t.Errorf("Allow = %q", got)
+	}
+	var resp ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Error.Code != codeMethodNotAllowed || !strings.Contains(resp.Error.Message, "DELETE") {
+		t.Errorf("body = %s", w.Body)
+	}
+
+	// 其他接口保持纯文本错误
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/spaces", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") == "" || strings.HasPrefix(w.Body.String(), "{") {
+		t.Errorf("v1 405 = %d %q %s", w.Code, w.Header().Get("Allow"), w.Body)
+	}
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"net/http"
+	"server/db"
+	"server/logger"
+	"server/models"
+	"server/validate"
+
+	"github.com/google/uuid"
+)
+
+// turnInput 修改TURN服务器的请求体，省略的字段保持不变，不能修改所在空间
+type turnInput struct {
+	URL      *string `json:"url" validate:"required,turnurl,max=256"`
+	Username *string `json:"username" validate:"max=128"`
+	Password *string `json:"password" validate:"max=128"`
+}
+
+// HandleV2TurnList 分页列出当前用户的TURN服务器，支持按space_id过滤
+func HandleV2TurnList(w http.ResponseWriter, r *http.Request) {
+	listTurns(w, r, r.URL.Query().Get("space_id"))
+}
+
+// listTurns 分页列出当前用户的TURN服务器，spaceID非空时只列出该空间的TURN服务器
+func listTurns(w http.ResponseWriter, r *http.Request, spaceID string) {
+	page, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	turns, next, err := db.ListTurns(models.TurnFilter{
+		OwnerID: currentUser(r).ID,
+		SpaceID: spaceID,
+	}, page)
+	if err != nil {
+		internalError(w, r, "获取TURN服务器配置列表失败", err)
+		return
+	}
+	infos := make([]*TurnServerInfo, 0, len(turns))
+	for _, turn := range turns {
+		infos = append(infos, newTurnServerInfo(turn))
+	}
+	writeList(w, infos, next)
+}
+
+// HandleV2TurnCreate 在当前用户的空间中创建TURN服务器配置
+func HandleV2TurnCreate(w http.ResponseWriter, r *http.Request) {
+	var input TurnRequest
+	if !decodeBody(w, r, &input) {
+		return
+	}
+
+	// 只能在自己的空间中创建
+	user := currentUser(r)
+	var errs validate.Errors
+	if err := checkSpaceOwner(&errs, "space_id", input.SpaceID, user.ID); err != nil {
+		internalError(w, r, "获取空间信息失败", err)
+		return
+	}
+	if len(errs) > 0 {
+		validationFailed(w, r, errs)
+		return
+	}
+
+	turn := &models.TurnServer{
+		ID:       uuid.New().String(),
+		OwnerID:  user.ID,
+		SpaceID:  input.SpaceID,
+		URL:      input.URL,
+		Username: input.Username,
+		Password: input.Password,
+	}
+	setAuditTarget(r, turn.ID)
+
+	if err := db.SaveTurn(turn); err != nil {
+		internalError(w, r, "创建TURN服务器配置失败", err)
+		return
+	}
+
+	logger.FromContext(r.Context()).Info("TURN服务器配置创建成功", "turn_id", turn.ID)
+	w.Header().Set("Location", v2Prefix+"turns/"+turn.ID)
+	writeData(w, http.StatusCreated, newTurnServerInfo(turn))
+}
+
+// HandleV2TurnGet 获取TURN服务器配置
+func HandleV2TurnGet(w http.ResponseWriter, r *http.Request) {
+	turn := ownedTurn(w, r, r.PathValue("id"))
+	if turn == nil {
+		return
+	}
+	writeData(w, http.StatusOK, newTurnServerInfo(turn))
+}
+
+// HandleV2TurnUpdate 修改TURN服务器的地址或凭据
+func HandleV2TurnUpdate(w http.ResponseWriter, r *http.Request) {
+	var input turnInput
+	if !decodeBody(w, r, &input) {
+		return
+	}
+	turn := ownedTurn(w, r, r.PathValue("id"))
+	if turn == nil {
+		return
+	}
+
+	if input.URL != nil {
+		turn.URL = *input.URL
+	}
+	if input.Username != nil {
+		turn.Username = *input.Username
+	}
+	if input.Password != nil {
+		turn.Password = *input.Password
+	}
+
+	if err := db.UpdateTurn(turn); err != nil {
+		internalError(w, r, "更新TURN服务器配置失败", err)
+		return
+	}
+
+	logger.FromContext(r.Context()).Info("TURN服务器配置更新成功", "turn_id", turn.ID)
+	writeData(w, http.StatusOK, newTurnServerInfo(turn))
+}
+
+// HandleV2TurnDelete 删除TURN服务器配置
+func HandleV2TurnDelete(w http.ResponseWriter, r *http.Request) {
+	turn := ownedTurn(w, r, r.PathValue("id"))
+	if turn == nil {
+		return
+	}
+
+	if err := db.DeleteTurn(turn.ID, turn.OwnerID); err != nil {
+		internalError(w, r, "删除TURN服务器配置失败", err)
+		return
+	}
+
+	logger.FromContext(r.Context()).Info("TURN服务器配置删除成功", "turn_id", turn.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownedTurn 获取当前用户的TURN服务器配置，不存在或属于其他用户时返回404并返回nil
+func ownedTurn(w http.ResponseWriter, r *http.Request, id string) *models.TurnServer {
+	turn, err := db.GetTurnByID(id)
+	if err != nil {
+		internalError(w, r, "获取TURN服务器配置失败", err)
+		return nil
+	}
+	if turn == nil || turn.OwnerID != currentUser(r).ID {
+		apiError(w, r, http.StatusNotFound, codeNotFound, "TURN服务器不存在")
+		return nil
+	}
+	return turn
+}
@@ -4,65 +4,80 @@ import (
 	"encoding/json"
 	"net/http"
 	"server/db"
+	"server/logger"
 	"server/models"
+	"server/validate"
 	"time"
 	"encoding/pem"
 	"crypto/x509"
 	"fmt"
 
-	"github.com/charmbracelet/log"
 	"github.com/google/uuid"
 )
 
+// WebAPIKeyRequest 生成WebAPIKey请求结构
+type WebAPIKeyRequest struct {
+	Name        string `json:"name" validate:"required,max=64"`         // 客户端名称
+	Description string `json:"description" validate:"max=256"`         // 客户端描述
+	SpaceID     string `json:"space_id" validate:"required"`
+}
+
+// WebAPIKeyInfo 生成WebAPIKey的响应结构，密钥只在生成时返回一次
+type WebAPIKeyInfo struct {
+	ID          string    `json:"id"`
+	Key         string    `json:"key"` // 客户端初始化时使用的一次性密钥
+	SpaceID     string    `json:"space_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ClientConfig 使用WebAPIKey创建客户端后返回的连接配置
+type ClientConfig struct {
+	Server struct {
+		Host string `json:"host"`
+		Port int    `json:"port"`
+	} `json:"server"`
+	WebSocket struct {
+		Path           string `json:"path"`
+		PingInterval   int    `json:"ping_interval"`
+		ReconnectDelay int    `json:"reconnect_delay"`
+	} `json:"websocket"`
+	ClientID string `json:"client_id"`
+}
+
 // GenerateWebAPIKey 生成一个新的WebAPIKey
 func GenerateWebAPIKey(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 获取当前用户
-	user := r.Context().Value(UserKey).(*models.User)
+	user := currentUser(r)
 	if user == nil {
 		http.Error(w, "未授权的访问", http.StatusUnauthorized)
 		return
 	}
 
 	// 解析请求体
-	var request struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
-		SpaceID     string `json:"space_id"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	// 验证请求参数
-	if request.Name == "" {
-		http.Error(w, "客户端名称不能为空", http.StatusBadRequest)
-		return
-	}
-
-	if request.SpaceID == "" {
-		http.Error(w, "空间ID不能为空", http.StatusBadRequest)
+	var request WebAPIKeyRequest
+	if !decodeBody(w, r, &request) {
 		return
 	}
 
 	// 验证空间是否存在且属于当前用户
-	space, err := db.GetSpaceByID(request.SpaceID)
-	if err != nil {
+	var errs validate.Errors
+	if err := checkSpaceOwner(&errs, "space_id", request.SpaceID, user.ID); err != nil {
 		log.Error("获取空间信息失败", "error", err)
 		http.Error(w, "获取空间信息失败", http.StatusInternalServerError)
 		return
 	}
-	if space == nil {
-		http.Error(w, "指定的空间不存在", http.StatusBadRequest)
-		return
-	}
-	if space.OwnerID != user.ID {
-		http.Error(w, "无权访问该空间", http.StatusForbidden)
+	if len(errs) > 0 {
+		validationFailed(w, r, errs)
 		return
 	}
 
@@ -80,7 +95,15 @@ func GenerateWebAPIKey(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status": "success",
-		"data":   apiKey,
+		"data": WebAPIKeyInfo{
+			ID:          apiKey.ID,
+			Key:         apiKey.Key,
+			SpaceID:     apiKey.SpaceID,
+			Name:        apiKey.Name,
+			Description: apiKey.Description,
+			ExpiresAt:   apiKey.ExpiresAt,
+			CreatedAt:   apiKey.CreatedAt,
+		},
 	})
 }
 
@@ -105,6 +128,8 @@ func ValidatePublicKey(publicKey string) error {
 
 // HandleGetWebAPIKey 获取单个WebAPIKey详细信息
 func HandleGetWebAPIKey(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
 	// 解析查询参数
 	key := r.URL.Query().Get("key")
 	publickey := r.URL.Query().Get("publickey")
@@ -129,6 +154,20 @@ func HandleGetWebAPIKey(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "无效的WebAPIKey", http.StatusUnauthorized)
 		return
 	}
+	setAuditActor(r, models.PrincipalUser, apiKey.UserID)
+
+	// 被禁用的用户不能通过WebAPIKey创建客户端
+	owner, err := db.GetUserByID(apiKey.UserID)
+	if err != nil {
+		log.Error("查询WebAPIKey所有者失败", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if owner == nil || owner.Disabled {
+		setAuditDetail(r, "所有者不存在或已被禁用")
+		http.Error(w, "账号已被禁用", http.StatusForbidden)
+		return
+	}
 
 	// 创建新的客户端
 	client := &models.Client{
@@ -147,6 +186,8 @@ func HandleGetWebAPIKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setAuditTarget(r, client.ID)
+
 	// 标记WebAPIKey为已使用
 	if err := db.MarkWebAPIKeyAsUsed(key); err != nil {
 		log.Error("标记WebAPIKey已使用失败", "error", err)
@@ -156,21 +197,17 @@ func HandleGetWebAPIKey(w http.ResponseWriter, r *http.Request) {
 	// 获取服务器端口
 	port := 8080 // 默认端口
 
+	config := ClientConfig{ClientID: client.ID}
+	config.Server.Host = r.Host
+	config.Server.Port = port
+	config.WebSocket.Path = "/ws/client"
+	config.WebSocket.PingInterval = 3
+	config.WebSocket.ReconnectDelay = 5
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status": "success",
-		"data": map[string]interface{}{
-			"server": map[string]interface{}{
-				"host": r.Host,
-				"port": port,
-			},
-			"websocket": map[string]interface{}{
-				"path":            "/ws/client",
-				"ping_interval":   3,
-				"reconnect_delay": 5,
-			},
-			"client_id": client.ID,
-		},
+		"data":   config,
 	})
 	log.Info("WebAPIKey验证成功，客户端创建成功", "key_id", key, "user_id", apiKey.UserID, "client_id", client.ID)
 }
@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"server/crypto"
 	"server/db"
+	"server/logger"
+	"server/metrics"
 	"server/models"
 
 	"github.com/charmbracelet/log"
@@ -18,6 +20,10 @@ var upgrader = websocket.Upgrader{
 
 // HandleWebSocket 处理WebSocket连接
 func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	// 每个连接的日志都带上连接ID，便于关联同一客户端的信令过程
+	connID := logger.NewID()
+	log := log.With("conn_id", connID, "remote_addr", r.RemoteAddr)
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Error("WebSocket连接升级失败", "error", err)
@@ -25,6 +31,18 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
+	// 身份验证未通过的连接计入失败，已声明客户端ID的失败写入审计记录
+	var clientID, failure string
+	authenticated := false
+	defer func() {
+		if !authenticated {
+			metrics.AuthHandshakes.WithLabelValues("failure").Inc()
+			if clientID != "" {
+				recordClientAuth(r, clientID, models.AuditFailure, failure)
+			}
+		}
+	}()
+
 	// 等待客户端发送ID
 	var msg models.Message
 	if err := conn.ReadJSON(&msg); err != nil {
@@ -38,11 +56,12 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 解析客户端ID
-	clientID, ok := msg.Data.(string)
+	id, ok := msg.Data.(string)
 	if !ok {
 		log.Error("客户端ID格式错误")
 		return
 	}
+	clientID = id
 
 	// 查询客户端信息
 	dbClient, err := db.GetClientByID(clientID)
@@ -52,6 +71,19 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 	if dbClient == nil {
 		log.Error("客户端不存在")
+		failure = "客户端不存在"
+		return
+	}
+
+	// 被禁用用户的客户端不能连接
+	owner, err := db.GetUserByID(dbClient.OwnerID)
+	if err != nil {
+		log.Error("查询客户端所有者失败", "error", err)
+		return
+	}
+	if owner == nil || owner.Disabled {
+		log.Warn("客户端所有者不存在或已被禁用", "owner_id", dbClient.OwnerID)
+		failure = "所有者不存在或已被禁用"
 		return
 	}
 
@@ -93,6 +125,7 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	responseData, ok := msg.Data.(string)
 	if !ok || responseData != challenge {
 		log.Error("验证失败")
+		failure = "挑战应答错误"
 		return
 	}
 
@@ -101,8 +134,13 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		ID:        clientID,
 		PublicKey: dbClient.PublicKey,
 		Conn:      conn,
+		ConnID:    connID,
 	}
 
+	authenticated = true
+	metrics.AuthHandshakes.WithLabelValues("success").Inc()
+	recordClientAuth(r, clientID, models.AuditSuccess, "")
+
 	// 注册客户端
 	RegisterClient(client)
 	defer UnregisterClient(client.ID)
@@ -128,6 +166,10 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 // handleMessage 处理接收到的WebSocket消息
 func handleMessage(client *models.Client, msg *models.Message) {
+	log := log.With("conn_id", client.ConnID)
+
+	metrics.SignalingMessages.WithLabelValues(messageTypeLabel(msg.Type)).Inc()
+
 	// 根据消息类型处理不同的信令逻辑
 	switch msg.Type {
 	case "ping":
@@ -141,7 +183,20 @@ func handleMessage(client *models.Client, msg *models.Message) {
 	case "ice_candidates":
 		// 处理ICE候选列表
 		HandleICECandidates(client, msg)
+	case "stats":
+		// 保存客户端上报的统计
+		handleStats(client, msg)
 	default:
 		log.Warn("未知的消息类型", "type", msg.Type)
 	}
-}
\ No newline at end of file
+}
+
+// messageTypeLabel 返回用作指标标签的消息类型，未知类型统一为unknown，避免客户端制造任意多的标签
+func messageTypeLabel(msgType string) string {
+	switch msgType {
+	case "ping", "offer", "answer", "ice_candidates", "stats":
+		return msgType
+	default:
+		return "unknown"
+	}
+}
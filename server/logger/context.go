@@ -0,0 +1,59 @@
+package logger
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader 请求ID的HTTP头，请求中携带时沿用，否则生成新的ID
+const RequestIDHeader = "X-Request-ID"
+
+type contextKey struct{}
+
+type requestIDKey struct{}
+
+// validRequestID 限制沿用的请求ID格式，避免客户端在日志中注入任意内容
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// NewContext 返回携带logger的context
+func NewContext(ctx context.Context, logger *log.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext 返回context中的logger，没有时返回默认logger
+func FromContext(ctx context.Context) *log.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*log.Logger); ok {
+		return logger
+	}
+	return log.Default()
+}
+
+// RequestID 返回context中的请求ID，没有时返回空字符串
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewID 生成关联ID
+func NewID() string {
+	return uuid.New().String()
+}
+
+// WithRequestID 为每个HTTP请求分配请求ID，写入响应头并放入请求context的logger
+func WithRequestID(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = NewID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		logger := log.With("request_id", id)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		next(w, r.WithContext(NewContext(ctx, logger)))
+	}
+}
@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"logfile"
+
+	"github.com/charmbracelet/log"
+)
+
+// TestRedactFormatterOutput 按日志库实际的文本和JSON输出检查敏感字段隐去规则
+func TestRedactFormatterOutput(t *testing.T) {
+	formatters := map[string]log.Formatter{
+		"text": log.TextFormatter,
+		"json": log.JSONFormatter,
+	}
+	tests := []struct {
+		key    string
+		value  interface{}
+		redact bool
+	}{
+		{"token", "gp2p_abcdef", true},
+		{"session_token", "s-123", true},
+		{"access_token", "a b c", true},
+		{"password", "Passw0rd!", true},
+		{"authorization", "Bearer-xyz", true},
+		{"challenge", 123456, true},
+		{"private_key", "-----BEGIN", true},
+		{"token_id", "tok-42", false},
+		{"token_prefix", "gp2p_ab", false},
+		{"client_id", "c-1", false},
+		{"username", "alice", false},
+	}
+
+	for name, formatter := range formatters {
+		for _, tt := range tests {
+			var buf bytes.Buffer
+			l := log.New(logfile.NewRedactWriter(&buf))
+			l.SetFormatter(formatter)
+			l.Info("测试", tt.key, tt.value, "user", "bob")
+
+			out := buf.String()
+			if !strings.Contains(out, "bob") {
+				t.Errorf("%s %s: 其他字段被隐去: %q", name, tt.key, out)
+			}
+			hidden := !strings.Contains(out, fmt.Sprint(tt.value))
+			if hidden != tt.redact {
+				t.Errorf("%s %s: redact = %v, want %v: %q", name, tt.key, hidden, tt.redact, out)
+			}
+		}
+	}
+}
@@ -2,17 +2,26 @@ package main
 
 import (
 	"net/http"
+	"os"
 
+	"server/auth"
 	"server/db"
 	"server/handlers"
 	"server/logger"
+	"server/metrics"
+	"server/openapi"
 
 	"github.com/charmbracelet/log"
 )
 
 func main() {
-	// 初始化日志记录器
-	if err := logger.InitLogger("logs"); err != nil {
+	// 子命令，如server db fsck
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
+	// 初始化日志记录器，级别、格式和轮转策略通过LOG_*环境变量配置
+	if err := logger.InitLogger(logger.OptionsFromEnv("logs", "server")); err != nil {
 		log.Fatal("初始化日志记录器失败", "error", err)
 	}
 
@@ -21,53 +30,30 @@ func main() {
 		log.Fatal("数据库初始化失败", "error", err)
 	}
 
-	// 设置路由
-	http.HandleFunc("/ws/client", handlers.HandleWebSocket)
-	http.HandleFunc("/ws/info", handlers.HandleInfoWebSocket)
-	http.HandleFunc("/api/register", handlers.HandleUserRegister)
-	http.HandleFunc("/api/login", handlers.HandleUserLogin)
-	http.HandleFunc("/api/admin/login", handlers.HandleAdminLogin)
-	http.HandleFunc("/api/logout", handlers.RequireUser(handlers.HandleLogout))
-	http.HandleFunc("/api/admin/logout", handlers.RequireAdmin(handlers.HandleAdminLogout))
-
-	// 管理员管理API
-	http.HandleFunc("/api/admin/register", handlers.RequireAdmin(handlers.HandleAdminRegister))
-	http.HandleFunc("/api/admin/list", handlers.RequireAdmin(handlers.HandleAdminList))
-	http.HandleFunc("/api/admin/update", handlers.RequireAuth(handlers.HandleAdminUpdate))
-	http.HandleFunc("/api/admin/delete", handlers.RequireAuth(handlers.HandleAdminDelete))
-
-	// 用户管理API
-	http.HandleFunc("/api/users", handlers.RequireAdmin(handlers.HandleUserCreate))
-	http.HandleFunc("/api/users/list", handlers.RequireAuth(handlers.HandleUserList))
-	http.HandleFunc("/api/users/update", handlers.RequireAuth(handlers.HandleUserUpdate))
-	http.HandleFunc("/api/users/delete", handlers.RequireAuth(handlers.HandleUserDelete))
-
-	// 客户端管理API
-	http.HandleFunc("/api/clients/list", handlers.RequireUser(handlers.HandleClientList))
-	http.HandleFunc("/api/clients/update", handlers.RequireUser(handlers.HandleClientUpdate))
-	http.HandleFunc("/api/clients/delete", handlers.RequireUser(handlers.HandleClientDelete))
+	// 定时清理过期会话和登录失败记录
+	db.StartSessionCleaner()
 
-	// 空间管理API
-	http.HandleFunc("/api/spaces", handlers.RequireUser(handlers.HandleSpaceCreate))
-	http.HandleFunc("/api/spaces/list", handlers.RequireUser(handlers.HandleSpaceList))
-	http.HandleFunc("/api/spaces/update", handlers.RequireUser(handlers.HandleSpaceUpdate))
-	http.HandleFunc("/api/spaces/delete", handlers.RequireUser(handlers.HandleSpaceDelete))
+	// 密码策略、登录锁定和限流参数通过环境变量配置
+	auth.LoadFromEnv()
 
-	// TURN服务器管理API
-	http.HandleFunc("/api/turns", handlers.RequireUser(handlers.HandleTurnCreate))
-	http.HandleFunc("/api/turns/list", handlers.RequireUser(handlers.HandleTurnList))
-	http.HandleFunc("/api/turns/update", handlers.RequireUser(handlers.HandleTurnUpdate))
-	http.HandleFunc("/api/turns/delete", handlers.RequireUser(handlers.HandleTurnDelete))
+	// 会话数在采集指标时从数据库统计
+	metrics.RegisterSessionCount(db.CountActiveSessions)
 
-	// Postman配置文件API
-	http.HandleFunc("/api/postman", handlers.HandlePostmanConfig)
-
-	// 测试连接API
-	http.HandleFunc("/api/test/connect", handlers.HandleTestConnect)
+	// 注册路由，文档中缺少的路由记录错误日志，可以通过server openapi -check检查
+	routes := registerRoutes(http.DefaultServeMux, os.Getenv("API_DOCS") != "")
+	for _, r := range openapi.Undocumented(handlers.Operations(), routes) {
+		log.Error("路由未在接口文档中描述", "method", r.Method, "pattern", r.Pattern)
+	}
+	for _, e := range openapi.Exposures(handlers.Operations()) {
+		log.Error("接口响应包含敏感字段", "method", e.Method, "path", e.Path, "field", e.Field)
+	}
 
-	// WebAPIKey管理API
-	http.HandleFunc("/api/web_api_key/generate", handlers.RequireUser(handlers.GenerateWebAPIKey))
-	http.HandleFunc("/api/web_api_keys", handlers.HandleGetWebAPIKey)
+	// Prometheus指标，设置METRICS_TOKEN后启用，请求需携带Authorization: Bearer <令牌>
+	if token := os.Getenv("METRICS_TOKEN"); token != "" {
+		http.Handle("/metrics", metrics.Handler(token))
+	} else {
+		log.Info("未设置METRICS_TOKEN，指标接口已禁用")
+	}
 
 	// 启动服务器
 	log.Info("服务器启动", "port", 8080)
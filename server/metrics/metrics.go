@@ -0,0 +1,181 @@
+// Package metrics 提供服务器的Prometheus指标
+//
+// 指标注册在独立的Registry中，通过Handler以Prometheus文本格式导出，
+// 访问需要携带配置的令牌。
+package metrics
+
+import (
+	"crypto/subtle"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "p2p"
+
+// Registry 服务器指标的注册表
+var Registry = prometheus.NewRegistry()
+
+var (
+	// ConnectedClients 各空间当前连接的客户端数
+	ConnectedClients = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "connected_clients",
+		Help:      "当前通过WebSocket连接的客户端数",
+	}, []string{"space"})
+
+	// MonitorConnections 当前的监控连接数
+	MonitorConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "monitor_connections",
+		Help:      "当前的信息监控WebSocket连接数",
+	})
+
+	// SignalingMessages 收到的信令消息数
+	SignalingMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "signaling_messages_total",
+		Help:      "收到的客户端WebSocket消息数",
+	}, []string{"type"})
+
+	// SignalingForwarded 成功转发的信令消息数
+	SignalingForwarded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "signaling_forwarded_total",
+		Help:      "成功转发给目标客户端的信令消息数",
+	}, []string{"type"})
+
+	// ForwardFailures 转发失败的信令消息数
+	ForwardFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "signaling_forward_failures_total",
+		Help:      "转发失败的信令消息数",
+	}, []string{"type", "reason"})
+
+	// AuthHandshakes 客户端WebSocket身份验证结果
+	AuthHandshakes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_handshakes_total",
+		Help:      "客户端WebSocket挑战应答身份验证次数",
+	}, []string{"result"})
+
+	// HTTPAuth 需要认证的HTTP请求的认证结果
+	HTTPAuth = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_auth_total",
+		Help:      "需要认证的HTTP请求的会话验证次数",
+	}, []string{"result"})
+
+	// RateLimited 被限流拒绝的请求数
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "超出频率限制被拒绝的请求数",
+	}, []string{"limiter"})
+
+	// LoginLockouts 因连续登录失败被锁定的次数
+	LoginLockouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_lockouts_total",
+		Help:      "因连续登录失败触发锁定的次数",
+	}, []string{"scope"})
+
+	// HTTPRequestDuration 各路由HTTP请求的处理耗时
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP请求的处理耗时",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+
+	// DBQueryDuration 各数据库操作的耗时
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "数据库操作的耗时",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"query"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		ConnectedClients,
+		MonitorConnections,
+		SignalingMessages,
+		SignalingForwarded,
+		ForwardFailures,
+		AuthHandshakes,
+		HTTPAuth,
+		RateLimited,
+		LoginLockouts,
+		HTTPRequestDuration,
+		DBQueryDuration,
+	)
+}
+
+// RegisterSessionCount 注册当前有效会话数指标，count在每次采集时调用
+func RegisterSessionCount(count func() (int, error)) {
+	Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_sessions",
+		Help:      "当前未过期的登录会话数",
+	}, func() float64 {
+		n, err := count()
+		if err != nil {
+			log.Error("统计会话数失败", "error", err)
+			return math.NaN()
+		}
+		return float64(n)
+	}))
+}
+
+// ObserveQuery 记录数据库操作耗时，用法为defer metrics.ObserveQuery("GetUserByID")()
+func ObserveQuery(query string) func() {
+	start := time.Now()
+	return func() {
+		DBQueryDuration.WithLabelValues(query).Observe(time.Since(start).Seconds())
+	}
+}
+
+// statusRecorder 记录响应状态码
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// InstrumentHandler 记录路由的请求耗时，route为注册时的路径，避免按实际URL产生过多标签
+func InstrumentHandler(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		HTTPRequestDuration.WithLabelValues(route, r.Method, strconv.Itoa(rec.status)).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler 返回导出指标的HTTP处理器，请求需携带Authorization: Bearer <token>
+func Handler(token string) http.Handler {
+	metricsHandler := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			http.Error(w, "无效的指标访问令牌", http.StatusUnauthorized)
+			return
+		}
+		metricsHandler.ServeHTTP(w, r)
+	})
+}
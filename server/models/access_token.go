@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+// 访问令牌的权限范围
+const (
+	ScopeSpacesRead   = "spaces:read"   // 查看空间
+	ScopeSpacesWrite  = "spaces:write"  // 创建、修改和删除空间
+	ScopeClientsRead  = "clients:read"  // 查看客户端
+	ScopeClientsWrite = "clients:write" // 修改和删除客户端
+	ScopeTurnsRead    = "turns:read"    // 查看TURN服务器
+	ScopeTurnsWrite   = "turns:write"   // 创建、修改和删除TURN服务器
+	ScopeConnect      = "connect"       // 生成WebAPIKey接入新客户端
+)
+
+// Scopes 所有可用的权限范围
+var Scopes = []string{
+	ScopeSpacesRead,
+	ScopeSpacesWrite,
+	ScopeClientsRead,
+	ScopeClientsWrite,
+	ScopeTurnsRead,
+	ScopeTurnsWrite,
+	ScopeConnect,
+}
+
+// AccessToken 用户创建的个人访问令牌，供脚本通过Authorization: Bearer访问API
+//
+// 数据库中只保存令牌的SHA-256哈希，明文令牌只在创建时返回一次。
+type AccessToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // 令牌的前几位，用于识别令牌
+	Token      string     `json:"-"`      // 明文令牌，仅在创建时有值，通过CreatedAccessToken返回
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`             // 为空表示永不过期
+	LastUsedAt *time.Time `json:"last_used_at"`           // 为空表示从未使用
+	LastUsedIP string     `json:"last_used_ip,omitempty"` // 最近一次使用时的来源IP
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Expired 返回令牌是否已过期
+func (t *AccessToken) Expired() bool {
+	return t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt)
+}
+
+// HasScope 判断令牌是否拥有权限范围
+func (t *AccessToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidScope 判断是否为可用的权限范围
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
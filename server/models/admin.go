@@ -4,8 +4,9 @@ import "time"
 
 // Admin 表示系统管理员
 type Admin struct {
-	ID        string    `json:"id"`
-	Username  string    `json:"username"`
-	Password  string    `json:"password"`
-	CreatedAt time.Time `json:"created_at"`
+	ID                 string    `json:"id"`
+	Username           string    `json:"username"`
+	Password           string    `json:"-"`                    // bcrypt哈希，不返回给客户端
+	MustChangePassword bool      `json:"must_change_password"` // 是否需要先修改密码才能使用其他接口
+	CreatedAt          time.Time `json:"created_at"`
 }
\ No newline at end of file
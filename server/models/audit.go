@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// 审计记录的操作结果
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEntry 一条审计记录，记录写操作和认证事件，写入后不可修改
+type AuditEntry struct {
+	ID        int64     `json:"id"`
+	RequestID string    `json:"request_id"` // 请求ID，与日志中的request_id对应
+	ActorKind string    `json:"actor_kind"` // 操作者类型：user、admin或client，未认证时为空
+	ActorID   string    `json:"actor_id"`   // 操作者ID，未认证时为空
+	Action    string    `json:"action"`     // 操作，格式为"方法 路由"
+	Target    string    `json:"target"`     // 操作对象，如被操作的用户ID或登录账号
+	IP        string    `json:"ip"`         // 来源IP
+	Status    int       `json:"status"`     // HTTP状态码，WebSocket事件为0
+	Outcome   string    `json:"outcome"`    // 操作结果：success或failure
+	Detail    string    `json:"detail"`     // 补充说明，如失败原因
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditFilter 审计记录查询条件，空字段不作为条件
+type AuditFilter struct {
+	ActorKind string
+	ActorID   string
+	Action    string // 包含该字符串的操作
+	Target    string
+	IP        string
+	Outcome   string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+	Offset    int
+}
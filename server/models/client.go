@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -15,8 +16,34 @@ type Client struct {
 	Name          string            `json:"name"`
 	Description   string            `json:"description"`
 	Conn          *websocket.Conn   `json:"-"`
+	ConnID        string            `json:"conn_id"` // WebSocket连接ID，用于关联日志
 	ConnectedAt   time.Time         `json:"connected_at"`
 	LastPingTime  time.Time         `json:"last_ping_time"`
 	LastPingDelay int64             `json:"last_ping_delay"` // 毫秒
 	WebRTCStatus  map[string]string `json:"webrtc_status"`   // WebRTC连接状态
+	Stats         *ClientStats      `json:"stats,omitempty"` // 客户端最近一次上报的连接和音频统计
+	StatsTime     time.Time         `json:"stats_time"`      // 最近一次上报统计的时间
+}
+
+// ClientStats 客户端上报的连接和音频统计
+//
+// 保存后不再修改，更新时整体替换，复制Client时可以共享。
+type ClientStats struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Peers     []PeerStats     `json:"peers"`
+	Audio     json.RawMessage `json:"audio,omitempty"` // 音频流水线统计，服务端只转发不解析
+}
+
+// PeerStats 客户端与一个对端的连接统计
+type PeerStats struct {
+	PeerID          string  `json:"peer_id"`
+	State           string  `json:"state"`
+	RTTMs           float64 `json:"rtt_ms"`
+	JitterMs        float64 `json:"jitter_ms"`
+	LossPercent     float64 `json:"loss_percent"`
+	BytesSent       uint64  `json:"bytes_sent"`
+	BytesReceived   uint64  `json:"bytes_received"`
+	LocalCandidate  string  `json:"local_candidate,omitempty"`
+	RemoteCandidate string  `json:"remote_candidate,omitempty"`
+	Protocol        string  `json:"protocol,omitempty"`
 }
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// LoginAttempt 记录一个账号或来源IP的连续登录失败
+type LoginAttempt struct {
+	Key         string    `json:"key"`          // 账号为user:或admin:加小写用户名，来源IP为ip:加地址
+	Failures    int       `json:"failures"`     // 连续失败次数
+	LockedUntil time.Time `json:"locked_until"` // 锁定截止时间，零值表示未锁定
+	LastFailure time.Time `json:"last_failure"` // 最近一次失败时间
+}
+
+// Locked 返回当前是否处于锁定期
+func (a *LoginAttempt) Locked() bool {
+	return a != nil && time.Now().Before(a.LockedUntil)
+}
@@ -0,0 +1,28 @@
+package models
+
+// Page 列表查询的分页参数
+//
+// 列表按创建顺序返回，After为上一页最后一条记录的游标，0表示从第一条开始。
+type Page struct {
+	After int64
+	Limit int
+}
+
+// SpaceFilter 空间列表的过滤条件，空字段不作为条件
+type SpaceFilter struct {
+	OwnerID string
+	Name    string // 名称包含该字符串，不区分大小写
+}
+
+// ClientFilter 客户端列表的过滤条件，空字段不作为条件
+type ClientFilter struct {
+	OwnerID string
+	SpaceID string
+	Name    string // 名称包含该字符串，不区分大小写
+}
+
+// TurnFilter TURN服务器列表的过滤条件，空字段不作为条件
+type TurnFilter struct {
+	OwnerID string
+	SpaceID string
+}
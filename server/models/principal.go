@@ -0,0 +1,63 @@
+package models
+
+// PrincipalKind 会话主体的类型
+type PrincipalKind string
+
+const (
+	PrincipalUser  PrincipalKind = "user"  // 普通用户
+	PrincipalAdmin PrincipalKind = "admin" // 管理员
+)
+
+// 角色
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Principal 表示发起请求的已认证主体
+type Principal struct {
+	Kind  PrincipalKind `json:"kind"`
+	ID    string        `json:"id"`
+	Roles []string      `json:"roles"`
+	User  *User         `json:"-"` // Kind为user时有值
+	Admin *Admin        `json:"-"` // Kind为admin时有值
+	Token *AccessToken  `json:"-"` // 通过个人访问令牌认证时有值
+}
+
+// NewUserPrincipal 创建普通用户主体
+func NewUserPrincipal(user *User) *Principal {
+	return &Principal{Kind: PrincipalUser, ID: user.ID, Roles: []string{RoleUser}, User: user}
+}
+
+// NewTokenPrincipal 创建通过个人访问令牌认证的普通用户主体
+func NewTokenPrincipal(user *User, token *AccessToken) *Principal {
+	principal := NewUserPrincipal(user)
+	principal.Token = token
+	return principal
+}
+
+// NewAdminPrincipal 创建管理员主体
+func NewAdminPrincipal(admin *Admin) *Principal {
+	return &Principal{Kind: PrincipalAdmin, ID: admin.ID, Roles: []string{RoleAdmin}, Admin: admin}
+}
+
+// HasRole 判断主体是否拥有角色
+func (p *Principal) HasRole(role string) bool {
+	if p == nil {
+		return false
+	}
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope 判断主体是否拥有权限范围，通过会话认证的主体不受权限范围限制
+func (p *Principal) HasScope(scope string) bool {
+	if p == nil {
+		return false
+	}
+	return p.Token == nil || p.Token.HasScope(scope)
+}
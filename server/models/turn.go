@@ -9,7 +9,7 @@ type TurnServer struct {
 	SpaceID   string    `json:"space_id"`
 	URL       string    `json:"url"`
 	Username  string    `json:"username"`
-	Password  string    `json:"password"`
+	Password  string    `json:"-"` // 只写不读，不返回给客户端
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
\ No newline at end of file
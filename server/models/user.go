@@ -6,8 +6,9 @@ import "time"
 type User struct {
 	ID        string    `json:"id"`
 	Username  string    `json:"username"`
-	Password  string    `json:"password"`
+	Password  string    `json:"-"` // bcrypt哈希，不返回给客户端
 	Email     string    `json:"email"`
+	Disabled  bool      `json:"disabled"` // 被管理员禁用的用户不能登录，客户端不能连接
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
\ No newline at end of file
@@ -10,26 +10,13 @@ import (
 type WebAPIKey struct {
 	ID          string    `json:"id"`
 	UserID      string    `json:"user_id"`
-	Key         string    `json:"key"`
+	Key         string    `json:"-"` // 只在生成时通过WebAPIKeyInfo返回一次
 	SpaceID     string    `json:"space_id"`
 	Name        string    `json:"name"`        // 客户端名称
 	Description string    `json:"description"` // 客户端描述
 	Used        bool      `json:"used"`
 	ExpiresAt   time.Time `json:"expires_at"`
 	CreatedAt   time.Time `json:"created_at"`
-
-	// 服务器连接信息
-	Server struct {
-		Host string `json:"host"`
-		Port int    `json:"port"`
-	} `json:"server"`
-
-	// WebSocket配置
-	WebSocket struct {
-		Path           string `json:"path"`
-		PingInterval   int    `json:"ping_interval"`
-		ReconnectDelay int    `json:"reconnect_delay"`
-	} `json:"websocket"`
 }
 
 // NewWebAPIKey 创建新的WebAPIKey
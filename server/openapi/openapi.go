@@ -0,0 +1,380 @@
+package openapi
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version 生成的OpenAPI文档版本
+const Version = "3.0.3"
+
+// Auth 接口的认证方式
+type Auth int
+
+const (
+	AuthNone    Auth = iota // 不需要认证
+	AuthSession             // 需要用户或管理员会话
+	AuthUser                // 需要用户会话
+	AuthAdmin               // 需要管理员会话
+	AuthScope               // 需要用户会话或拥有Scope权限范围的访问令牌
+)
+
+// Envelope 响应体的外层结构
+type Envelope int
+
+const (
+	EnvelopeNone   Envelope = iota // Response即为响应体
+	EnvelopeStatus                 // {"status": "success", "data": Response}，Response为nil时没有data
+	EnvelopeData                   // {"data": Response}
+	EnvelopeList                   // {"data": [Response], "next_cursor": "..."}
+)
+
+// Operation 描述一个接口，请求和响应的结构由Go类型生成
+type Operation struct {
+	Method      string      // 请求方法
+	Path        string      // 路由模式，如/api/v2/spaces/{id}
+	Summary     string      // 接口说明
+	Tag         string      // 分组
+	Auth        Auth        // 认证方式
+	Scope       string      // Auth为AuthScope时需要的权限范围
+	Query       []Param     // 查询参数
+	Request     interface{} // 请求体类型的零值，为nil表示没有请求体
+	Response    interface{} // 响应体类型的零值，为nil表示只返回状态
+	Envelope    Envelope    // 响应体的外层结构
+	Status      int         // 成功时的状态码，默认200
+	Error       interface{} // 错误响应体类型的零值，为nil表示返回纯文本错误信息
+	ContentType string      // 响应的Content-Type，默认application/json
+	Reveals     []string    // 响应中有意返回的敏感字段，如创建时只返回一次的明文令牌
+}
+
+// Param 查询参数
+type Param struct {
+	Name        string
+	Description string
+	Type        string // 参数类型，默认string
+	Required    bool
+}
+
+// Document OpenAPI文档
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components components                       `json:"components"`
+}
+
+// Info 文档的基本信息
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*securityScheme `json:"securitySchemes"`
+}
+
+type securityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	OperationID string                `json:"operationId"`
+	Parameters  []*parameter          `json:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*response  `json:"responses"`
+	Security    []map[string][]string `json:"security"`
+	Scope       string                `json:"x-scope,omitempty"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*mediaType `json:"content"`
+}
+
+type response struct {
+	Description string                `json:"description"`
+	Content     map[string]*mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// 认证方式
+var (
+	cookieAuth = map[string][]string{"cookieAuth": {}}
+	bearerAuth = map[string][]string{"bearerAuth": {}}
+)
+
+// Build 根据接口描述生成OpenAPI文档
+func Build(info Info, ops []Operation) *Document {
+	g := newGenerator()
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]map[string]*operation),
+		Components: components{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]*securityScheme{
+				"cookieAuth": {Type: "apiKey", In: "cookie", Name: "session_token", Description: "登录后设置的会话Cookie"},
+				"bearerAuth": {Type: "http", Scheme: "bearer", Description: "会话令牌或p2p_开头的个人访问令牌"},
+			},
+		},
+	}
+
+	for _, op := range ops {
+		item := doc.Paths[op.Path]
+		if item == nil {
+			item = make(map[string]*operation)
+			doc.Paths[op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = g.operation(op)
+	}
+	return doc
+}
+
+// operation 生成单个接口的描述
+func (g *generator) operation(op Operation) *operation {
+	o := &operation{
+		Summary:     op.Summary,
+		OperationID: operationID(op.Method, op.Path),
+		Responses:   make(map[string]*response),
+		Security:    []map[string][]string{},
+	}
+	if op.Tag != "" {
+		o.Tags = []string{op.Tag}
+	}
+
+	switch op.Auth {
+	case AuthSession:
+		o.Description = "需要用户或管理员登录"
+		o.Security = []map[string][]string{cookieAuth, bearerAuth}
+	case AuthUser:
+		o.Description = "需要用户登录，不接受访问令牌"
+		o.Security = []map[string][]string{cookieAuth, bearerAuth}
+	case AuthAdmin:
+		o.Description = "需要管理员登录"
+		o.Security = []map[string][]string{cookieAuth, bearerAuth}
+	case AuthScope:
+		o.Description = "需要用户登录，或使用拥有" + op.Scope + "权限范围的访问令牌"
+		o.Security = []map[string][]string{cookieAuth, bearerAuth}
+		o.Scope = op.Scope
+	}
+
+	// 路径参数
+	for _, segment := range strings.Split(op.Path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			o.Parameters = append(o.Parameters, &parameter{
+				Name:     strings.Trim(segment, "{}"),
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+	}
+	for _, p := range op.Query {
+		typ := p.Type
+		if typ == "" {
+			typ = "string"
+		}
+		o.Parameters = append(o.Parameters, &parameter{
+			Name:        p.Name,
+			In:          "query",
+			Description: p.Description,
+			Required:    p.Required,
+			Schema:      &Schema{Type: typ},
+		})
+	}
+
+	if op.Request != nil {
+		o.RequestBody = &requestBody{
+			Required: true,
+			Content:  map[string]*mediaType{"application/json": {Schema: g.schemaOf(op.Request)}},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := &response{Description: http.StatusText(status)}
+	if body := g.responseSchema(op); body != nil {
+		contentType := op.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		success.Content = map[string]*mediaType{contentType: {Schema: body}}
+	}
+	o.Responses[strconv.Itoa(status)] = success
+
+	errorContent := map[string]*mediaType{"text/plain": {Schema: &Schema{Type: "string"}}}
+	if op.Error != nil {
+		errorContent = map[string]*mediaType{"application/json": {Schema: g.schemaOf(op.Error)}}
+	}
+	o.Responses["default"] = &response{Description: "错误", Content: errorContent}
+	return o
+}
+
+// responseSchema 生成成功响应的结构，没有响应体时返回nil
+func (g *generator) responseSchema(op Operation) *Schema {
+	if op.Status == http.StatusNoContent {
+		return nil
+	}
+
+	var data *Schema
+	if op.Response != nil {
+		data = g.schemaOf(op.Response)
+	}
+
+	switch op.Envelope {
+	case EnvelopeStatus:
+		s := &Schema{Type: "object", Properties: map[string]*Schema{
+			"status": {Type: "string", Enum: []string{"success"}},
+		}}
+		if data != nil {
+			s.Properties["data"] = data
+		}
+		return s
+	case EnvelopeData:
+		return &Schema{Type: "object", Properties: map[string]*Schema{"data": data}}
+	case EnvelopeList:
+		return &Schema{Type: "object", Properties: map[string]*Schema{
+			"data":        {Type: "array", Items: data},
+			"next_cursor": {Type: "string", Description: "下一页的游标，没有更多数据时省略"},
+		}}
+	}
+	return data
+}
+
+// operationID 根据请求方法和路径生成接口ID，如get_api_v2_spaces_id
+func operationID(method, path string) string {
+	replacer := strings.NewReplacer("/", "_", "{", "", "}", "")
+	return strings.ToLower(method) + strings.TrimRight(replacer.Replace(path), "_")
+}
+
+// Route 注册的路由，Method为空表示处理函数自行检查请求方法
+type Route struct {
+	Method  string
+	Pattern string
+}
+
+// Undocumented 返回文档中没有描述的路由
+//
+// Method为空的路由只要求文档中有该路径的接口。以/结尾的子树模式只用于兜底，不检查。
+func Undocumented(ops []Operation, routes []Route) []Route {
+	documented := make(map[string]bool)
+	for _, op := range ops {
+		documented[op.Path] = true
+		documented[op.Method+" "+op.Path] = true
+	}
+
+	var missing []Route
+	for _, route := range routes {
+		if strings.HasSuffix(route.Pattern, "/") {
+			continue
+		}
+		key := route.Pattern
+		if route.Method != "" {
+			key = route.Method + " " + route.Pattern
+		}
+		if !documented[key] {
+			missing = append(missing, route)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		if missing[i].Pattern != missing[j].Pattern {
+			return missing[i].Pattern < missing[j].Pattern
+		}
+		return missing[i].Method < missing[j].Method
+	})
+	return missing
+}
+
+// SecretFields 不应出现在成功响应中的敏感字段名
+var SecretFields = []string{"password", "password_hash", "secret", "token", "key"}
+
+// Exposure 成功响应中出现的敏感字段
+type Exposure struct {
+	Method string
+	Path   string
+	Field  string // 字段在响应体中的路径，如data.user.password
+}
+
+// Exposures 检查所有接口的成功响应，返回未在Operation.Reveals中声明的敏感字段
+func Exposures(ops []Operation) []Exposure {
+	secret := make(map[string]bool)
+	for _, name := range SecretFields {
+		secret[name] = true
+	}
+
+	g := newGenerator()
+	var exposures []Exposure
+	for _, op := range ops {
+		body := g.responseSchema(op)
+		if body == nil {
+			continue
+		}
+		revealed := make(map[string]bool)
+		for _, name := range op.Reveals {
+			revealed[name] = true
+		}
+		g.walk(body, "", make(map[string]bool), func(path, name string) {
+			if secret[name] && !revealed[name] {
+				exposures = append(exposures, Exposure{Method: op.Method, Path: op.Path, Field: path})
+			}
+		})
+	}
+	return exposures
+}
+
+// walk 遍历Schema中的所有属性，$ref引用的结构体展开遍历，seen避免递归类型重复展开
+func (g *generator) walk(s *Schema, prefix string, seen map[string]bool, visit func(path, name string)) {
+	if s == nil {
+		return
+	}
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		g.walk(g.schemas[name], prefix, seen, visit)
+		delete(seen, name)
+		return
+	}
+
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		visit(path, name)
+		g.walk(s.Properties[name], path, seen, visit)
+	}
+	g.walk(s.Items, prefix+"[]", seen, visit)
+	g.walk(s.AdditionalProperties, prefix, seen, visit)
+}
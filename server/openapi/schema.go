@@ -0,0 +1,203 @@
+package openapi
+
+import (
+	"reflect"
+	"server/validate"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema JSON Schema，只包含生成文档用到的字段
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// turnURLPattern 与validate.IsTurnURL对应的正则表达式，只检查基本格式
+const turnURLPattern = `^(stuns?:[^?/@ ]+|turns?:[^?/@ ]+(\?transport=(udp|tcp))?)$`
+
+// generator 根据Go类型生成Schema，具名结构体放入components并通过$ref引用
+type generator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func newGenerator() *generator {
+	return &generator{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// schemaOf 生成值的类型对应的Schema
+func (g *generator) schemaOf(v interface{}) *Schema {
+	return g.schema(reflect.TypeOf(v))
+}
+
+func (g *generator) schema(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Ptr:
+		s := g.schema(t.Elem())
+		if s.Ref != "" {
+			return s
+		}
+		s.Nullable = true
+		return s
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + g.define(t)}
+	}
+	// interface{}等任意值
+	return &Schema{}
+}
+
+// define 将具名结构体放入components，返回其名称，不同包的同名类型加上包名区分
+func (g *generator) define(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := exportedName(t.Name())
+	if _, taken := g.schemas[name]; taken {
+		pkg := t.PkgPath()
+		name = exportedName(pkg[strings.LastIndex(pkg, "/")+1:]) + name
+	}
+	// 先占位，避免递归类型无限展开
+	g.names[t] = name
+	g.schemas[name] = &Schema{}
+	*g.schemas[name] = *g.object(t)
+	return name
+}
+
+// object 生成结构体的Schema，字段名取自json标签，validate标签转换为对应的约束
+func (g *generator) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		// 没有json名称的嵌入结构体，字段展开到外层
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				inner := g.object(embedded)
+				for k, v := range inner.Properties {
+					s.Properties[k] = v
+				}
+				s.Required = append(s.Required, inner.Required...)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		s.Properties[name] = g.schema(field.Type)
+		if tag := field.Tag.Get("validate"); tag != "" {
+			applyRules(s, name, field.Type, validate.ParseTag(tag))
+		}
+	}
+	return s
+}
+
+// applyRules 将字段的校验规则转换为Schema约束，指针字段为可选字段，required只要求不为空
+func applyRules(parent *Schema, name string, t reflect.Type, rules []validate.Rule) {
+	s := parent.Properties[name]
+	if s.Ref != "" {
+		return
+	}
+	optional := t.Kind() == reflect.Ptr
+	if optional {
+		t = t.Elem()
+	}
+	slice := t.Kind() == reflect.Slice
+
+	for _, rule := range rules {
+		n, _ := strconv.Atoi(rule.Param)
+		switch rule.Name {
+		case "required":
+			if !optional {
+				parent.Required = append(parent.Required, name)
+			}
+			one := 1
+			if slice && s.MinItems == nil {
+				s.MinItems = &one
+			} else if t.Kind() == reflect.String && s.MinLength == nil {
+				s.MinLength = &one
+			}
+		case "min":
+			if slice {
+				s.MinItems = &n
+			} else {
+				s.MinLength = &n
+			}
+		case "max":
+			if slice {
+				s.MaxItems = &n
+			} else {
+				s.MaxLength = &n
+			}
+		case "email":
+			s.Format = "email"
+		case "turnurl":
+			s.Pattern = turnURLPattern
+		}
+	}
+}
+
+// exportedName 将类型名首字母大写，如spaceInput转为SpaceInput
+func exportedName(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"server/db"
+	"server/handlers"
+	"server/models"
+	"server/openapi"
+
+	"github.com/google/uuid"
+)
+
+// TestRoutesDocumented 所有注册的路由都需要在接口文档中描述
+func TestRoutesDocumented(t *testing.T) {
+	routes := registerRoutes(http.NewServeMux(), true)
+	for _, r := range openapi.Undocumented(handlers.Operations(), routes) {
+		t.Errorf("%s %s 未在接口文档中描述", r.Method, r.Pattern)
+	}
+}
+
+// exposureSkipped 不检查响应的接口及原因
+var exposureSkipped = map[string]string{
+	"/ws/client":        "WebSocket接口",
+	"/ws/info":          "WebSocket接口",
+	"/api/test/connect": "需要两个在线客户端",
+	"/api/openapi.json": "文档中的敏感字段只是字段名",
+	"/api/docs":         "返回HTML页面",
+	"/metrics":          "不由registerRoutes注册",
+}
+
+// exposureFixture 检查响应时使用的数据
+type exposureFixture struct {
+	t         *testing.T
+	user      *models.User
+	admin     *models.Admin
+	victim    *models.User // 管理员禁用、重置密码和代为登录的用户
+	space     *models.Space
+	turn      *models.TurnServer
+	client    *models.Client
+	publicKey string
+	seq       int
+}
+
+// newExposureFixture 创建用户、管理员以及用户的空间、TURN服务器和客户端
+func newExposureFixture(t *testing.T) *exposureFixture {
+	t.Helper()
+	f := &exposureFixture{t: t}
+	f.user, _ = seedUser(t, "alice")
+	f.victim, _ = seedUser(t, "mallory")
+	f.admin, _ = seedAdmin(t, "root")
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.publicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	f.space = f.newSpace()
+	f.turn = f.newTurn()
+	f.client = f.newClient()
+	return f
+}
+
+// newSpace 为用户创建空间
+func (f *exposureFixture) newSpace() *models.Space {
+	f.t.Helper()
+	space := &models.Space{ID: uuid.New().String(), OwnerID: f.user.ID, Name: "home"}
+	if err := db.SaveSpace(space); err != nil {
+		f.t.Fatal(err)
+	}
+	return space
+}
+
+// newTurn 在用户的空间中创建TURN服务器
+func (f *exposureFixture) newTurn() *models.TurnServer {
+	f.t.Helper()
+	turn := &models.TurnServer{ID: uuid.New().String(), OwnerID: f.user.ID, SpaceID: f.space.ID, URL: "turn:turn.example.com:3478", Username: "turn", Password: "turn-secret"}
+	if err := db.SaveTurn(turn); err != nil {
+		f.t.Fatal(err)
+	}
+	return turn
+}
+
+// newClient 在用户的空间中创建客户端
+func (f *exposureFixture) newClient() *models.Client {
+	f.t.Helper()
+	client := &models.Client{ID: uuid.New().String(), OwnerID: f.user.ID, SpaceID: f.space.ID, PublicKey: f.publicKey, Name: "laptop"}
+	if err := db.SaveClient(client); err != nil {
+		f.t.Fatal(err)
+	}
+	return client
+}
+
+// deleteTarget 为DELETE接口创建新的待删除对象并返回其ID，以免删除其他接口使用的数据
+func (f *exposureFixture) deleteTarget(op openapi.Operation) string {
+	f.t.Helper()
+	f.seq++
+	switch {
+	case strings.HasPrefix(op.Path, "/api/sessions/"):
+		session, err := db.CreateSession(string(models.PrincipalUser), f.user.ID, "127.0.0.1", "test", time.Now().Add(time.Hour))
+		if err != nil {
+			f.t.Fatal(err)
+		}
+		return session.ID
+	case strings.HasPrefix(op.Path, "/api/tokens/"):
+		token, err := db.CreateAccessToken(f.user.ID, "test", models.Scopes, nil)
+		if err != nil {
+			f.t.Fatal(err)
+		}
+		return token.ID
+	case strings.Contains(op.Path, "/spaces"):
+		return f.newSpace().ID
+	case strings.Contains(op.Path, "/clients"):
+		return f.newClient().ID
+	case strings.Contains(op.Path, "/turns"):
+		return f.newTurn().ID
+	}
+	f.t.Fatalf("DELETE %s 没有可删除的对象", op.Path)
+	return ""
+}
+
+// selfDeleting 删除调用者自己账号的接口，以新建的用户和管理员身份调用
+var selfDeleting = map[string]bool{
+	"/api/users/delete": true,
+	"/api/admin/delete": true,
+}
+
+// credential 为principal创建新的会话或访问令牌，每个请求使用新的凭据，以免注销或刷新影响后续请求
+func (f *exposureFixture) credential(op openapi.Operation, principal string) string {
+	f.t.Helper()
+	user, admin := f.user, f.admin
+	if selfDeleting[op.Path] {
+		f.seq++
+		user, _ = seedUser(f.t, fmt.Sprintf("user%d", f.seq))
+		admin, _ = seedAdmin(f.t, fmt.Sprintf("admin%d", f.seq))
+	}
+	switch principal {
+	case "user":
+		return seedSession(f.t, models.PrincipalUser, user.ID)
+	case "admin":
+		return seedSession(f.t, models.PrincipalAdmin, admin.ID)
+	}
+	token, err := db.CreateAccessToken(user.ID, "test", models.Scopes, nil)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return token.Token
+}
+
+// path 将路由中的{id}替换为对应资源的ID，带上需要的查询参数
+func (f *exposureFixture) path(op openapi.Operation) string {
+	if selfDeleting[op.Path] {
+		return op.Path
+	}
+	if op.Method == http.MethodDelete {
+		id := f.deleteTarget(op)
+		if strings.Contains(op.Path, "{id}") {
+			return strings.Replace(op.Path, "{id}", id, 1)
+		}
+		return op.Path + "?" + url.Values{"id": {id}}.Encode()
+	}
+
+	var id string
+	switch {
+	case strings.HasPrefix(op.Path, "/api/admin/users/"):
+		id = f.victim.ID
+	case strings.HasPrefix(op.Path, "/api/v2/spaces/"):
+		id = f.space.ID
+	case strings.HasPrefix(op.Path, "/api/v2/clients/"):
+		id = f.client.ID
+	case strings.HasPrefix(op.Path, "/api/v2/turns/"):
+		id = f.turn.ID
+	}
+	path := strings.Replace(op.Path, "{id}", id, 1)
+
+	// WebAPIKey只能使用一次，每次请求生成新的
+	if op.Path == "/api/web_api_keys" {
+		key := models.NewWebAPIKey(f.user.ID, "phone", "", f.space.ID)
+		if err := db.SaveWebAPIKey(key); err != nil {
+			f.t.Fatal(err)
+		}
+		path += "?" + url.Values{"key": {key.Key}, "publickey": {f.publicKey}}.Encode()
+	}
+	return path
+}
+
+// body 返回POST和PATCH接口的请求体，用户名等唯一字段每次不同
+func (f *exposureFixture) body(op openapi.Operation) interface{} {
+	f.seq++
+	name := fmt.Sprintf("name%d", f.seq)
+	if op.Method == http.MethodPatch {
+		switch op.Path {
+		case "/api/v2/spaces/{id}":
+			return map[string]interface{}{"name": name}
+		case "/api/v2/clients/{id}":
+			return map[string]interface{}{"name": name, "space_id": f.space.ID}
+		case "/api/v2/turns/{id}":
+			return map[string]interface{}{"url": "turn:turn.example.com:3478", "username": name, "password": "turn-secret"}
+		}
+		return nil
+	}
+	switch op.Path {
+	case "/api/register", "/api/users":
+		return handlers.UserRequest{Username: name, Password: testPassword, Email: name + "@example.com"}
+	case "/api/login":
+		return handlers.LoginRequest{Account: f.user.Username, Password: testPassword}
+	case "/api/admin/login":
+		return handlers.LoginRequest{Account: f.admin.Username, Password: testPassword}
+	case "/api/admin/register":
+		return handlers.RegisterRequest{Username: name, Password: testPassword}
+	case "/api/admin/users/{id}/password":
+		return handlers.PasswordResetRequest{Password: testPassword}
+	case "/api/tokens":
+		return handlers.AccessTokenRequest{Name: name, Scopes: []string{models.ScopeSpacesRead}}
+	case "/api/spaces", "/api/v2/spaces":
+		return handlers.SpaceRequest{Name: name}
+	case "/api/turns", "/api/v2/turns":
+		return handlers.TurnRequest{SpaceID: f.space.ID, URL: "turn:turn.example.com:3478", Username: name, Password: "turn-secret"}
+	case "/api/web_api_key/generate":
+		return handlers.WebAPIKeyRequest{Name: name, SpaceID: f.space.ID}
+	}
+	return nil
+}
+
+// TestResponsesHideSecrets 以用户、管理员和访问令牌的身份调用所有GET、POST、PATCH和DELETE接口，
+// 成功响应中不能出现接口没有声明返回的敏感字段，204响应不能有响应体
+func TestResponsesHideSecrets(t *testing.T) {
+	s := newTestServer(t)
+	f := newExposureFixture(t)
+
+	secret := make(map[string]bool)
+	for _, name := range openapi.SecretFields {
+		secret[name] = true
+	}
+
+	for _, op := range handlers.Operations() {
+		if op.Method == http.MethodPut {
+			continue
+		}
+		if _, ok := exposureSkipped[op.Path]; ok {
+			continue
+		}
+
+		revealed := make(map[string]bool)
+		for _, name := range op.Reveals {
+			revealed[name] = true
+		}
+
+		succeeded := false
+		for _, principal := range []string{"user", "admin", "token"} {
+			var body interface{}
+			if op.Method == http.MethodPost || op.Method == http.MethodPatch {
+				body = f.body(op)
+			}
+			status, data := s.do(op.Method, f.path(op), f.credential(op, principal), body)
+			if status >= http.StatusMultipleChoices {
+				continue
+			}
+			succeeded = true
+			if status == http.StatusNoContent {
+				if len(data) != 0 {
+					t.Errorf("%s %s 以%s身份调用返回204但响应体不为空: %q", op.Method, op.Path, principal, data)
+				}
+				continue
+			}
+			if len(data) == 0 {
+				continue
+			}
+
+			var value interface{}
+			if err := json.Unmarshal(data, &value); err != nil {
+				t.Errorf("%s %s 以%s身份调用的响应不是JSON: %v", op.Method, op.Path, principal, err)
+				continue
+			}
+			for _, field := range findSecrets(value, "", secret, revealed) {
+				t.Errorf("%s %s 以%s身份调用的响应包含敏感字段 %s", op.Method, op.Path, principal, field)
+			}
+		}
+		if !succeeded {
+			t.Errorf("%s %s 以任何身份调用都没有成功，无法检查响应", op.Method, op.Path)
+		}
+	}
+}
+
+// findSecrets 返回JSON值中不在revealed中的敏感字段路径，路径格式与openapi.Exposure.Field一致
+func findSecrets(value interface{}, prefix string, secret, revealed map[string]bool) []string {
+	var found []string
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for name, child := range v {
+			path := name
+			if prefix != "" {
+				path = prefix + "." + name
+			}
+			if secret[name] && !revealed[name] {
+				found = append(found, path)
+			}
+			found = append(found, findSecrets(child, path, secret, revealed)...)
+		}
+	case []interface{}:
+		for _, child := range v {
+			found = append(found, findSecrets(child, prefix+"[]", secret, revealed)...)
+		}
+	}
+	return found
+}
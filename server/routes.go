@@ -0,0 +1,149 @@
+package main
+
+import (
+	"net/http"
+
+	"server/auth"
+	"server/handlers"
+	"server/logger"
+	"server/metrics"
+	"server/models"
+	"server/openapi"
+)
+
+// registerRoutes 注册所有接口，apiDocs为true时同时注册文档页面，返回注册的路由用于检查接口文档
+func registerRoutes(mux *http.ServeMux, apiDocs bool) []openapi.Route {
+	loginLimiter := auth.NewRateLimiter("login", auth.RateLimitPerMinute, auth.RateLimitBurst)
+	apiKeyLimiter := auth.NewRateLimiter("web_api_key", auth.RateLimitPerMinute, auth.RateLimitBurst)
+	wsLimiter := auth.NewRateLimiter("ws_client", auth.RateLimitPerMinute, auth.RateLimitBurst)
+
+	var routes []openapi.Route
+
+	// handle 注册不经过通用中间件的接口
+	handle := func(pattern string, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, handler)
+		routes = append(routes, openapi.Route{Pattern: pattern})
+	}
+
+	// route 注册HTTP接口，为请求分配请求ID，记录请求耗时和写操作的审计日志
+	route := func(pattern string, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, logger.WithRequestID(metrics.InstrumentHandler(pattern, handlers.Audit(pattern, handler))))
+		routes = append(routes, openapi.Route{Pattern: pattern})
+	}
+
+	// routeMethods 注册按请求方法分发的接口，不支持的方法返回405
+	routeMethods := func(pattern string, methods handlers.Methods) {
+		mux.HandleFunc(pattern, logger.WithRequestID(metrics.InstrumentHandler(pattern, handlers.Audit(pattern, methods.ServeHTTP))))
+		for method := range methods {
+			routes = append(routes, openapi.Route{Method: method, Pattern: pattern})
+		}
+	}
+
+	// 设置路由
+	handle("/ws/client", wsLimiter.Middleware(handlers.HandleWebSocket))
+	handle("/ws/info", handlers.HandleInfoWebSocket)
+	route("/api/register", loginLimiter.Middleware(handlers.HandleUserRegister))
+	route("/api/login", loginLimiter.Middleware(handlers.HandleUserLogin))
+	route("/api/admin/login", loginLimiter.Middleware(handlers.HandleAdminLogin))
+	route("/api/logout", handlers.RequireUser(handlers.HandleLogout))
+	route("/api/admin/logout", handlers.RequireAdmin(handlers.HandleAdminLogout))
+
+	// 会话管理API
+	route("/api/sessions", handlers.RequireAuth(handlers.HandleSessionList))
+	route("/api/sessions/{id}", handlers.RequireAuth(handlers.HandleSessionDelete))
+	route("/api/sessions/refresh", handlers.RequireAuth(handlers.HandleSessionRefresh))
+	route("/api/logout/all", handlers.RequireAuth(handlers.HandleLogoutAll))
+
+	// 个人访问令牌API，只能通过会话访问
+	route("/api/tokens", handlers.RequireUser(handlers.HandleAccessTokenCreate))
+	route("/api/tokens/list", handlers.RequireUser(handlers.HandleAccessTokenList))
+	route("/api/tokens/{id}", handlers.RequireUser(handlers.HandleAccessTokenDelete))
+
+	// 管理员管理API
+	route("/api/admin/register", handlers.RequireAdmin(handlers.HandleAdminRegister))
+	route("/api/admin/list", handlers.RequireAdmin(handlers.HandleAdminList))
+	route("/api/admin/update", handlers.RequireAdmin(handlers.HandleAdminUpdate))
+	route("/api/admin/delete", handlers.RequireAdmin(handlers.HandleAdminDelete))
+
+	// 管理员管理用户和查询审计记录API
+	route("/api/admin/users/{id}/disable", handlers.RequireAdmin(handlers.HandleAdminUserDisable))
+	route("/api/admin/users/{id}/enable", handlers.RequireAdmin(handlers.HandleAdminUserEnable))
+	route("/api/admin/users/{id}/password", handlers.RequireAdmin(handlers.HandleAdminUserPassword))
+	route("/api/admin/users/{id}/impersonate", handlers.RequireAdmin(handlers.HandleAdminUserImpersonate))
+	route("/api/admin/users/{id}/spaces", handlers.RequireAdmin(handlers.HandleAdminUserSpaces))
+	route("/api/admin/users/{id}/clients", handlers.RequireAdmin(handlers.HandleAdminUserClients))
+	route("/api/admin/audit", handlers.RequireAdmin(handlers.HandleAuditLog))
+
+	// 用户管理API
+	route("/api/users", handlers.RequireAdmin(handlers.HandleUserCreate))
+	route("/api/users/list", handlers.RequireAuth(handlers.HandleUserList))
+	route("/api/users/update", handlers.RequireAuth(handlers.HandleUserUpdate))
+	route("/api/users/delete", handlers.RequireAuth(handlers.HandleUserDelete))
+
+	// 客户端管理API，以下接口同时接受拥有对应权限范围的访问令牌
+	route("/api/clients/list", handlers.RequireScope(models.ScopeClientsRead, handlers.HandleClientList))
+	route("/api/clients/update", handlers.RequireScope(models.ScopeClientsWrite, handlers.HandleClientUpdate))
+	route("/api/clients/delete", handlers.RequireScope(models.ScopeClientsWrite, handlers.HandleClientDelete))
+
+	// 空间管理API
+	route("/api/spaces", handlers.RequireScope(models.ScopeSpacesWrite, handlers.HandleSpaceCreate))
+	route("/api/spaces/list", handlers.RequireScope(models.ScopeSpacesRead, handlers.HandleSpaceList))
+	route("/api/spaces/update", handlers.RequireScope(models.ScopeSpacesWrite, handlers.HandleSpaceUpdate))
+	route("/api/spaces/delete", handlers.RequireScope(models.ScopeSpacesWrite, handlers.HandleSpaceDelete))
+
+	// TURN服务器管理API
+	route("/api/turns", handlers.RequireScope(models.ScopeTurnsWrite, handlers.HandleTurnCreate))
+	route("/api/turns/list", handlers.RequireScope(models.ScopeTurnsRead, handlers.HandleTurnList))
+	route("/api/turns/update", handlers.RequireScope(models.ScopeTurnsWrite, handlers.HandleTurnUpdate))
+	route("/api/turns/delete", handlers.RequireScope(models.ScopeTurnsWrite, handlers.HandleTurnDelete))
+
+	// v2 API，按资源组织路由，同时接受拥有对应权限范围的访问令牌
+	route("/api/v2/", handlers.HandleV2NotFound)
+	routeMethods("/api/v2/spaces", handlers.Methods{
+		http.MethodGet:  handlers.RequireScope(models.ScopeSpacesRead, handlers.HandleV2SpaceList),
+		http.MethodPost: handlers.RequireScope(models.ScopeSpacesWrite, handlers.HandleV2SpaceCreate),
+	})
+	routeMethods("/api/v2/spaces/{id}", handlers.Methods{
+		http.MethodGet:    handlers.RequireScope(models.ScopeSpacesRead, handlers.HandleV2SpaceGet),
+		http.MethodPatch:  handlers.RequireScope(models.ScopeSpacesWrite, handlers.HandleV2SpaceUpdate),
+		http.MethodDelete: handlers.RequireScope(models.ScopeSpacesWrite, handlers.HandleV2SpaceDelete),
+	})
+	routeMethods("/api/v2/spaces/{id}/clients", handlers.Methods{
+		http.MethodGet: handlers.RequireScope(models.ScopeClientsRead, handlers.HandleV2SpaceClients),
+	})
+	routeMethods("/api/v2/spaces/{id}/turns", handlers.Methods{
+		http.MethodGet: handlers.RequireScope(models.ScopeTurnsRead, handlers.HandleV2SpaceTurns),
+	})
+	routeMethods("/api/v2/clients", handlers.Methods{
+		http.MethodGet: handlers.RequireScope(models.ScopeClientsRead, handlers.HandleV2ClientList),
+	})
+	routeMethods("/api/v2/clients/{id}", handlers.Methods{
+		http.MethodGet:    handlers.RequireScope(models.ScopeClientsRead, handlers.HandleV2ClientGet),
+		http.MethodPatch:  handlers.RequireScope(models.ScopeClientsWrite, handlers.HandleV2ClientUpdate),
+		http.MethodDelete: handlers.RequireScope(models.ScopeClientsWrite, handlers.HandleV2ClientDelete),
+	})
+	routeMethods("/api/v2/turns", handlers.Methods{
+		http.MethodGet:  handlers.RequireScope(models.ScopeTurnsRead, handlers.HandleV2TurnList),
+		http.MethodPost: handlers.RequireScope(models.ScopeTurnsWrite, handlers.HandleV2TurnCreate),
+	})
+	routeMethods("/api/v2/turns/{id}", handlers.Methods{
+		http.MethodGet:    handlers.RequireScope(models.ScopeTurnsRead, handlers.HandleV2TurnGet),
+		http.MethodPatch:  handlers.RequireScope(models.ScopeTurnsWrite, handlers.HandleV2TurnUpdate),
+		http.MethodDelete: handlers.RequireScope(models.ScopeTurnsWrite, handlers.HandleV2TurnDelete),
+	})
+
+	// 接口文档，设置API_DOCS后同时提供文档页面
+	route("/api/openapi.json", handlers.HandleOpenAPI)
+	if apiDocs {
+		route("/api/docs", handlers.HandleAPIDocs)
+	}
+
+	// 测试连接API
+	route("/api/test/connect", handlers.HandleTestConnect)
+
+	// WebAPIKey管理API
+	route("/api/web_api_key/generate", handlers.RequireScope(models.ScopeConnect, handlers.GenerateWebAPIKey))
+	route("/api/web_api_keys", apiKeyLimiter.Middleware(handlers.HandleGetWebAPIKey))
+
+	return routes
+}
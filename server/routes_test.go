@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"server/db"
+	"server/handlers"
+	"server/models"
+
+	"github.com/google/uuid"
+)
+
+// testServer 注册了所有路由、使用临时数据库的测试服务器
+type testServer struct {
+	*httptest.Server
+	t *testing.T
+}
+
+// newTestServer 在临时目录中创建数据库并启动测试服务器
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	if err := db.Open(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	registerRoutes(mux, true)
+	server := httptest.NewServer(mux)
+	t.Cleanup(func() {
+		server.Close()
+		db.Close()
+	})
+	return &testServer{Server: server, t: t}
+}
+
+// do 以token的身份发送请求，body不为nil时编码为JSON请求体
+func (s *testServer) do(method, path, token string, body interface{}) (int, []byte) {
+	s.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, s.URL+path, reader)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return resp.StatusCode, data
+}
+
+// testPassword 测试用户和管理员的密码，符合默认密码策略
+const testPassword = "Tr0ub4dor&3x!"
+
+// seedUser 创建密码为testPassword的用户并返回其会话令牌
+func seedUser(t *testing.T, username string) (*models.User, string) {
+	t.Helper()
+	user := &models.User{ID: uuid.New().String(), Username: username, Password: testPassword, Email: username + "@example.com"}
+	if err := db.SaveUser(user); err != nil {
+		t.Fatal(err)
+	}
+	return user, seedSession(t, models.PrincipalUser, user.ID)
+}
+
+// seedAdmin 创建密码为testPassword的管理员并返回其会话令牌
+func seedAdmin(t *testing.T, username string) (*models.Admin, string) {
+	t.Helper()
+	admin := &models.Admin{ID: uuid.New().String(), Username: username, Password: testPassword}
+	if err := db.SaveAdmin(admin); err != nil {
+		t.Fatal(err)
+	}
+	return admin, seedSession(t, models.PrincipalAdmin, admin.ID)
+}
+
+func seedSession(t *testing.T, kind models.PrincipalKind, id string) string {
+	t.Helper()
+	session, err := db.CreateSession(string(kind), id, "127.0.0.1", "test", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session.Token
+}
+
+// TestAdminImpersonation 代为登录的会话有效期固定，不能访问受限接口，所有请求以管理员为操作者审计
+func TestAdminImpersonation(t *testing.T) {
+	s := newTestServer(t)
+	user, _ := seedUser(t, "alice")
+	admin, adminToken := seedAdmin(t, "root")
+
+	// 用户不能代为登录
+	_, userToken := seedUser(t, "bob")
+	if status, _ := s.do("POST", "/api/admin/users/"+user.ID+"/impersonate", userToken, nil); status != http.StatusForbidden {
+		t.Errorf("用户代为登录返回%d，期望403", status)
+	}
+
+	status, body := s.do("POST", "/api/admin/users/"+user.ID+"/impersonate", adminToken, nil)
+	if status != http.StatusOK {
+		t.Fatalf("代为登录失败: %d %s", status, body)
+	}
+	var resp handlers.ImpersonationResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Token == "" || resp.User == nil || resp.User.ID != user.ID {
+		t.Fatalf("代为登录的响应不正确: %s", body)
+	}
+	if ttl := time.Until(resp.ExpiresAt); ttl <= 0 || ttl > 15*time.Minute {
+		t.Errorf("代为登录会话的有效期为%v", ttl)
+	}
+
+	if status, body := s.do("GET", "/api/spaces/list", resp.Token, nil); status != http.StatusOK {
+		t.Errorf("代为登录后查看空间失败: %d %s", status, body)
+	}
+	for _, path := range []string{"/api/tokens", "/api/sessions/refresh"} {
+		if status, _ := s.do("POST", path, resp.Token, map[string]interface{}{}); status != http.StatusForbidden {
+			t.Errorf("代为登录时POST %s返回%d，期望403", path, status)
+		}
+	}
+
+	// 读请求同样以管理员为操作者记录
+	entries, _, err := db.QueryAuditLog(models.AuditFilter{ActorKind: string(models.PrincipalAdmin), ActorID: admin.ID, Action: "GET /api/spaces/list", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || !strings.Contains(entries[0].Detail, user.ID) {
+		t.Errorf("代为登录的读请求没有写入审计记录: %+v", entries)
+	}
+}
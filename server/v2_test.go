@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"server/db"
+	"server/handlers"
+	"server/models"
+
+	"github.com/google/uuid"
+)
+
+// seedSpace 为用户创建空间
+func seedSpace(t *testing.T, ownerID, name string) *models.Space {
+	t.Helper()
+	space := &models.Space{ID: uuid.New().String(), OwnerID: ownerID, Name: name}
+	if err := db.SaveSpace(space); err != nil {
+		t.Fatal(err)
+	}
+	return space
+}
+
+// decodeError 解析v2接口的错误响应
+func decodeError(t *testing.T, data []byte) handlers.APIError {
+	t.Helper()
+	var resp handlers.ErrorResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("响应不是JSON错误: %v: %s", err, data)
+	}
+	return resp.Error
+}
+
+// TestV2ForeignSpaceField 引用其他用户的空间时返回space_id字段的校验错误
+func TestV2ForeignSpaceField(t *testing.T) {
+	s := newTestServer(t)
+	alice, token := seedUser(t, "alice")
+	bob, _ := seedUser(t, "bob")
+	own := seedSpace(t, alice.ID, "home")
+	foreign := seedSpace(t, bob.ID, "other")
+
+	client := &models.Client{ID: uuid.New().String(), OwnerID: alice.ID, SpaceID: own.ID, Name: "laptop"}
+	if err := db.SaveClient(client); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method, path string
+		body         interface{}
+	}{
+		{http.MethodPost, "/api/v2/turns", map[string]string{"space_id": foreign.ID, "url": "turn:turn.example.com"}},
+		{http.MethodPost, "/api/v2/turns", map[string]string{"space_id": uuid.New().String(), "url": "turn:turn.example.com"}},
+		{http.MethodPatch, "/api/v2/clients/" + client.ID, map[string]string{"name": "laptop", "space_id": foreign.ID}},
+	}
+	for _, tt := range tests {
+		status, data := s.do(tt.method, tt.path, token, tt.body)
+		if status != http.StatusBadRequest {
+			t.Fatalf("%s %s status = %d: %s", tt.method, tt.path, status, data)
+		}
+		apiErr := decodeError(t, data)
+		if apiErr.Code != "validation_failed" || len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "space_id" {
+			t.Errorf("%s %s error = %+v, want space_id字段错误", tt.method, tt.path, apiErr)
+		}
+	}
+
+	// 客户端仍在原来的空间
+	stored, err := db.GetClientByID(client.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.SpaceID != own.ID {
+		t.Errorf("客户端被移到了其他用户的空间")
+	}
+
+	// 自己的空间可以使用
+	status, data := s.do(http.MethodPost, "/api/v2/turns", token, map[string]string{"space_id": own.ID, "url": "turn:turn.example.com"})
+	if status != http.StatusCreated {
+		t.Fatalf("status = %d: %s", status, data)
+	}
+}
+
+// TestV2SpacesPaging 按next_cursor逐页列出空间，直到没有下一页
+func TestV2SpacesPaging(t *testing.T) {
+	s := newTestServer(t)
+	alice, token := seedUser(t, "alice")
+	bob, _ := seedUser(t, "bob")
+	var want []string
+	for i := 0; i < 7; i++ {
+		want = append(want, seedSpace(t, alice.ID, fmt.Sprintf("space-%d", i)).ID)
+		seedSpace(t, bob.ID, fmt.Sprintf("other-%d", i))
+	}
+
+	var got []string
+	path := "/api/v2/spaces?limit=3"
+	for pages := 1; ; pages++ {
+		status, data := s.do(http.MethodGet, path, token, nil)
+		if status != http.StatusOK {
+			t.Fatalf("GET %s status = %d: %s", path, status, data)
+		}
+		var resp struct {
+			Data       []models.Space `json:"data"`
+			NextCursor string         `json:"next_cursor"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Data) > 3 {
+			t.Fatalf("第%d页返回%d条", pages, len(resp.Data))
+		}
+		for _, space := range resp.Data {
+			got = append(got, space.ID)
+		}
+		if resp.NextCursor == "" {
+			if pages != 3 {
+				t.Errorf("共%d页, want 3", pages)
+			}
+			break
+		}
+		if pages > 3 {
+			t.Fatal("分页没有结束")
+		}
+		path = "/api/v2/spaces?limit=3&cursor=" + url.QueryEscape(resp.NextCursor)
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("spaces = %v, want %v", got, want)
+	}
+
+	for _, query := range []string{"cursor=bad!", "limit=0", "limit=101"} {
+		status, data := s.do(http.MethodGet, "/api/v2/spaces?"+query, token, nil)
+		if status != http.StatusBadRequest || decodeError(t, data).Code == "" {
+			t.Errorf("%s: status = %d: %s", query, status, data)
+		}
+	}
+}
+
+// TestV2ErrorEnvelope 认证中间件和路由在v2路径下返回JSON错误，v1路径保持纯文本
+func TestV2ErrorEnvelope(t *testing.T) {
+	s := newTestServer(t)
+	user, session := seedUser(t, "alice")
+	readOnly, err := db.CreateAccessToken(user.ID, "read", []string{models.ScopeSpacesRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method, path, token string
+		status              int
+		code                string
+	}{
+		{http.MethodGet, "/api/v2/spaces", "", http.StatusUnauthorized, "missing_token"},
+		{http.MethodGet, "/api/v2/spaces", "not-a-session", http.StatusUnauthorized, "invalid_session"},
+		{http.MethodGet, "/api/v2/spaces", db.AccessTokenPrefix + "unknown", http.StatusUnauthorized, "invalid_access_token"},
+		{http.MethodPost, "/api/v2/spaces", readOnly.Token, http.StatusForbidden, "insufficient_scope"},
+		{http.MethodPut, "/api/v2/spaces", session, http.StatusMethodNotAllowed, "method_not_allowed"},
+		{http.MethodGet, "/api/v2/nothing", session, http.StatusNotFound, "not_found"},
+	}
+	for _, tt := range tests {
+		status, data := s.do(tt.method, tt.path, tt.token, nil)
+		if status != tt.status {
+			t.Errorf("%s %s status = %d, want %d: %s", tt.method, tt.path, status, tt.status, data)
+			continue
+		}
+		apiErr := decodeError(t, data)
+		if apiErr.Code != tt.code || apiErr.Message == "" || apiErr.RequestID == "" {
+			t.Errorf("%s %s error = %+v, want code %s", tt.method, tt.path, apiErr, tt.code)
+		}
+	}
+
+	// v1接口的认证错误仍是纯文本
+	status, data := s.do(http.MethodGet, "/api/spaces/list", "", nil)
+	if status != http.StatusUnauthorized || strings.HasPrefix(string(data), "{") {
+		t.Errorf("v1 status = %d: %s", status, data)
+	}
+}
@@ -0,0 +1,330 @@
+package validate
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 请求参数校验
+//
+// 请求结构体的字段通过validate标签声明规则，多个规则以逗号分隔，如`validate:"required,max=64"`。
+// 字段名取自json标签。指针字段为nil时表示未提供，跳过所有规则，用于只修改部分字段的请求；
+// 字符串的空值只检查required。切片的规则作用于每个元素，min和max作用于元素个数。
+//
+// 内置规则：
+//
+//	required  不能为空，字符串去掉首尾空白后不能为空
+//	min=N     字符串至少N个字符，切片至少N个元素
+//	max=N     字符串最多N个字符，切片最多N个元素
+//	email     有效的邮箱地址
+//	turnurl   有效的STUN/TURN地址，如stun:host:3478或turn:host?transport=tcp
+//
+// 其他规则通过Register注册。
+
+// FieldError 字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"`   // 字段名，与请求体中的JSON字段名一致
+	Message string `json:"message"` // 错误说明
+}
+
+// Errors 请求参数的所有校验错误
+type Errors []FieldError
+
+// Add 添加字段的校验错误，用于结构体标签之外的检查，如引用的空间是否存在
+func (e *Errors) Add(field, message string) {
+	*e = append(*e, FieldError{Field: field, Message: message})
+}
+
+// Error 将所有校验错误拼接为一行
+func (e Errors) Error() string {
+	parts := make([]string, 0, len(e))
+	for _, fe := range e {
+		parts = append(parts, fe.Field+": "+fe.Message)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Rule 一条校验规则
+type Rule struct {
+	Name  string
+	Param string // 规则参数，如max=64中的64
+}
+
+// ParseTag 解析validate标签
+func ParseTag(tag string) []Rule {
+	var rules []Rule
+	for _, part := range strings.Split(tag, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, param, _ := strings.Cut(part, "=")
+		rules = append(rules, Rule{Name: name, Param: param})
+	}
+	return rules
+}
+
+// stringRule 作用于单个字符串的规则，返回空字符串表示通过
+type stringRule func(value string) string
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]stringRule{
+		"email": func(value string) string {
+			if !IsEmail(value) {
+				return "不是有效的邮箱地址"
+			}
+			return ""
+		},
+		"turnurl": func(value string) string {
+			if !IsTurnURL(value) {
+				return "不是有效的STUN/TURN地址，需要以stun:、stuns:、turn:或turns:开头"
+			}
+			return ""
+		},
+	}
+)
+
+// Register 注册作用于字符串的规则，check返回false时使用message作为错误说明
+func Register(name string, check func(string) bool, message string) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules[name] = func(value string) string {
+		if !check(value) {
+			return message
+		}
+		return ""
+	}
+}
+
+// Struct 按validate标签校验结构体，v可以是结构体或结构体指针，没有错误时返回nil
+func Struct(v interface{}) Errors {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs Errors
+	checkStruct(value, &errs)
+	return errs
+}
+
+// checkStruct 校验结构体的每个字段，嵌入的结构体展开检查
+func checkStruct(value reflect.Value, errs *Errors) {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			checkStruct(value.Field(i), errs)
+			continue
+		}
+		tag := field.Tag.Get("validate")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			name = field.Name
+		}
+		if message := checkField(value.Field(i), ParseTag(tag)); message != "" {
+			errs.Add(name, message)
+		}
+	}
+}
+
+// checkField 按规则校验字段，返回第一个错误的说明
+func checkField(value reflect.Value, fieldRules []Rule) string {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		return checkString(value.String(), fieldRules)
+	case reflect.Slice, reflect.Array:
+		for _, rule := range fieldRules {
+			if message := checkLength(rule, value.Len(), "元素"); message != "" {
+				return message
+			}
+			if rule.Name == "required" && value.Len() == 0 {
+				return "不能为空"
+			}
+		}
+		for j := 0; j < value.Len(); j++ {
+			elem := value.Index(j)
+			if elem.Kind() != reflect.String {
+				continue
+			}
+			if message := checkString(elem.String(), elementRules(fieldRules)); message != "" {
+				return fmt.Sprintf("第%d个元素%s", j+1, message)
+			}
+		}
+	default:
+		if hasRule(fieldRules, "required") && value.IsZero() {
+			return "不能为空"
+		}
+	}
+	return ""
+}
+
+// checkString 按规则校验字符串，空字符串只检查required
+func checkString(value string, fieldRules []Rule) string {
+	if strings.TrimSpace(value) == "" {
+		if hasRule(fieldRules, "required") {
+			return "不能为空"
+		}
+		if value == "" {
+			return ""
+		}
+	}
+
+	for _, rule := range fieldRules {
+		switch rule.Name {
+		case "required":
+		case "min", "max":
+			if message := checkLength(rule, len([]rune(value)), "字符"); message != "" {
+				return message
+			}
+		default:
+			rulesMu.RLock()
+			check, ok := rules[rule.Name]
+			rulesMu.RUnlock()
+			if !ok {
+				panic("validate: 未知的校验规则 " + rule.Name)
+			}
+			if message := check(value); message != "" {
+				return message
+			}
+		}
+	}
+	return ""
+}
+
+// checkLength 检查min和max规则，unit为长度的单位
+func checkLength(rule Rule, length int, unit string) string {
+	if rule.Name != "min" && rule.Name != "max" {
+		return ""
+	}
+	n, err := strconv.Atoi(rule.Param)
+	if err != nil {
+		panic("validate: 无效的规则参数 " + rule.Name + "=" + rule.Param)
+	}
+	if rule.Name == "min" && length < n {
+		return fmt.Sprintf("不能少于%d个%s", n, unit)
+	}
+	if rule.Name == "max" && length > n {
+		return fmt.Sprintf("不能超过%d个%s", n, unit)
+	}
+	return ""
+}
+
+// elementRules 切片元素适用的规则，去掉作用于元素个数的min、max和required
+func elementRules(fieldRules []Rule) []Rule {
+	var result []Rule
+	for _, rule := range fieldRules {
+		switch rule.Name {
+		case "required", "min", "max":
+		default:
+			result = append(result, rule)
+		}
+	}
+	return result
+}
+
+// hasRule 判断规则列表中是否包含指定规则
+func hasRule(fieldRules []Rule, name string) bool {
+	for _, rule := range fieldRules {
+		if rule.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEmail 判断是否为不带显示名称的邮箱地址，如user@example.com
+func IsEmail(value string) bool {
+	addr, err := mail.ParseAddress(value)
+	if err != nil || addr.Address != value {
+		return false
+	}
+	_, domain, _ := strings.Cut(value, "@")
+	return domain != "" && !strings.HasPrefix(domain, ".") && !strings.HasSuffix(domain, ".")
+}
+
+// IsTurnURL 判断是否为RFC 7064和RFC 7065定义的STUN/TURN地址
+//
+// 格式为stun:host[:port]、stuns:host[:port]、turn:host[:port][?transport=udp|tcp]或turns:host[:port][?transport=udp|tcp]。
+func IsTurnURL(value string) bool {
+	scheme, rest, ok := strings.Cut(value, ":")
+	if !ok {
+		return false
+	}
+
+	switch strings.ToLower(scheme) {
+	case "stun", "stuns":
+		if strings.Contains(rest, "?") {
+			return false
+		}
+	case "turn", "turns":
+		if hostport, query, ok := strings.Cut(rest, "?"); ok {
+			if query != "transport=udp" && query != "transport=tcp" {
+				return false
+			}
+			rest = hostport
+		}
+	default:
+		return false
+	}
+
+	if strings.ContainsAny(rest, "/@ ") {
+		return false
+	}
+
+	// IPv6地址需要放在方括号内
+	host, port := rest, ""
+	if strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]")
+		if end < 0 || net.ParseIP(rest[1:end]) == nil {
+			return false
+		}
+		host = rest[1:end]
+		if after := rest[end+1:]; after != "" {
+			if !strings.HasPrefix(after, ":") {
+				return false
+			}
+			port = after[1:]
+		}
+	} else if i := strings.LastIndex(rest, ":"); i >= 0 {
+		host, port = rest[:i], rest[i+1:]
+		if strings.Contains(host, ":") {
+			return false
+		}
+	}
+	if host == "" {
+		return false
+	}
+	if port != "" || strings.HasSuffix(rest, ":") {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,159 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsTurnURL(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"stun:stun.example.com", true},
+		{"stun:stun.example.com:3478", true},
+		{"stuns:stun.example.com:5349", true},
+		{"turn:h", true},
+		{"TURN:h:3478", true},
+		{"turn:h?transport=udp", true},
+		{"turns:h?transport=tcp", true},
+		{"turn:h:443?transport=tcp", true},
+		{"turn:[::1]:3478", true},
+		{"turn:[::1]", true},
+		{"turn:192.0.2.1:3478", true},
+
+		{"turn:h:", false},        // 冒号后缺少端口
+		{"[::1]:3478", false},     // 缺少协议
+		{"turn:::1", false},       // IPv6地址没有方括号
+		{"turn:[::1", false},      // 缺少右括号
+		{"turn:[::1]x", false},    // 括号后不是端口
+		{"turn:[zz]:3478", false}, // 括号内不是IP地址
+		{"turn:h:0", false},       // 端口超出范围
+		{"turn:h:65536", false},   // 端口超出范围
+		{"turn:h:abc", false},     // 端口不是数字
+		{"stun:h?x", false},       // STUN地址不能带查询参数
+		{"stun:h?transport=udp", false},
+		{"turn:h?transport=sctp", false},
+		{"turn:h?x", false},
+		{"turn:user@h", false},
+		{"turn:h/path", false},
+		{"turn:", false},
+		{"http://h:3478", false},
+		{"h:3478", false},
+	}
+	for _, tt := range tests {
+		if got := IsTurnURL(tt.value); got != tt.want {
+			t.Errorf("IsTurnURL(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestIsEmail(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"user@example.com", true},
+		{"first.last+tag@sub.example.com", true},
+		{"user@localhost", true},
+
+		{"", false},
+		{"plain", false},
+		{"user@", false},
+		{"@example.com", false},
+		{"user@.example.com", false},
+		{"user@example.com.", false},
+		{"User <user@example.com>", false}, // 不接受显示名称
+		{" user@example.com", false},
+		{"a@b@example.com", false},
+	}
+	for _, tt := range tests {
+		if got := IsEmail(tt.value); got != tt.want {
+			t.Errorf("IsEmail(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+// sample 覆盖指针、切片和非字符串字段的规则
+type sample struct {
+	Name  *string  `json:"name" validate:"required,max=4"`
+	Email *string  `json:"email" validate:"email"`
+	URLs  []string `json:"urls" validate:"min=1,max=2,turnurl"`
+	Tags  []string `json:"tags,omitempty" validate:"required"`
+	Count int      `json:"count" validate:"required"`
+	Note  string   `validate:"min=2"`
+}
+
+func ptr(s string) *string { return &s }
+
+func TestStruct(t *testing.T) {
+	valid := func() sample {
+		return sample{
+			Name:  ptr("abc"),
+			URLs:  []string{"turn:h"},
+			Tags:  []string{"x"},
+			Count: 1,
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(s *sample)
+		field  string // 期望出错的字段，为空表示通过
+		want   string // 错误说明包含的内容
+	}{
+		{"全部有效", func(s *sample) {}, "", ""},
+		{"指针为nil时跳过所有规则", func(s *sample) { s.Name = nil; s.Email = nil }, "", ""},
+		{"指针指向空字符串时检查required", func(s *sample) { s.Name = ptr("  ") }, "name", "不能为空"},
+		{"指针指向的字符串超长", func(s *sample) { s.Name = ptr("abcde") }, "name", "不能超过4个字符"},
+		{"按字符计算长度", func(s *sample) { s.Name = ptr("空间名称") }, "", ""},
+		{"指针指向无效邮箱", func(s *sample) { s.Email = ptr("bob") }, "email", "邮箱"},
+		{"指针指向空字符串时不检查其他规则", func(s *sample) { s.Email = ptr("") }, "", ""},
+		{"切片元素个数不足", func(s *sample) { s.URLs = nil }, "urls", "不能少于1个元素"},
+		{"切片元素个数超出", func(s *sample) { s.URLs = []string{"turn:a", "turn:b", "turn:c"} }, "urls", "不能超过2个元素"},
+		{"切片元素逐个校验", func(s *sample) { s.URLs = []string{"turn:a", "http://b"} }, "urls", "第2个元素"},
+		{"切片required", func(s *sample) { s.Tags = []string{} }, "tags", "不能为空"},
+		{"非字符串required", func(s *sample) { s.Count = 0 }, "count", "不能为空"},
+		{"没有json标签时使用字段名", func(s *sample) { s.Note = "a" }, "Note", "不能少于2个字符"},
+		{"空字符串不检查min", func(s *sample) { s.Note = "" }, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := valid()
+			tt.modify(&s)
+			errs := Struct(&s)
+			if tt.field == "" {
+				if len(errs) != 0 {
+					t.Fatalf("Struct() = %v, want no errors", errs)
+				}
+				return
+			}
+			if len(errs) != 1 || errs[0].Field != tt.field || !strings.Contains(errs[0].Message, tt.want) {
+				t.Fatalf("Struct() = %v, want %s: ...%s...", errs, tt.field, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructNonStruct(t *testing.T) {
+	var nilSample *sample
+	for _, v := range []interface{}{nil, nilSample, "text", 3} {
+		if errs := Struct(v); errs != nil {
+			t.Errorf("Struct(%#v) = %v, want nil", v, errs)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("lower", func(s string) bool { return strings.ToLower(s) == s }, "只能包含小写字母")
+	v := struct {
+		Code string `json:"code" validate:"lower"`
+	}{Code: "ABC"}
+	errs := Struct(v)
+	if len(errs) != 1 || errs[0].Message != "只能包含小写字母" {
+		t.Fatalf("Struct() = %v", errs)
+	}
+	if errs.Error() != "code: 只能包含小写字母" {
+		t.Errorf("Error() = %q", errs.Error())
+	}
+}